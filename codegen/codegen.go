@@ -0,0 +1,18 @@
+// Package codegen generates the Go code of the norm files parsed by the parser
+// package, for tools embedding norm instead of running the norm command.
+package codegen
+
+import (
+	"io"
+
+	"github.com/agrewal/norm/internal/core"
+	"github.com/agrewal/norm/parser"
+)
+
+// Generate writes the Go code generated for f to w. The commands of the
+// !file groups of f are written to w along with the others, as with the
+// -stdout flag of the norm command. The package of the code must be known,
+// see parser.Package and parser.ResolvePackage.
+func Generate(f *parser.File, w io.Writer) error {
+	return core.Generate(f, w)
+}
@@ -0,0 +1,64 @@
+package codegen
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	normparser "github.com/agrewal/norm/parser"
+)
+
+const input = `-- !norm
+-- !file store.go
+-- !stamp none
+
+-- !read GetUsers
+-- !input domain string
+-- !output ID int
+SELECT id FROM user WHERE domain = $1
+
+-- !file users.go
+-- !exec DeleteUser
+-- !input id int
+DELETE FROM user WHERE id = $1
+`
+
+func TestGenerate(t *testing.T) {
+	f, err := normparser.Parse(strings.NewReader(input), normparser.Package("store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code bytes.Buffer
+	if err := Generate(f, &code); err != nil {
+		t.Fatal(err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "store.go", code.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("The generated code does not parse: %v", err)
+	}
+	if file.Name.Name != "store" {
+		t.Errorf("Expected package store, got %s", file.Name.Name)
+	}
+	// The commands of the !file groups are written along with the others.
+	for _, want := range []string{"func (n *Norm) GetUsers(", "func (n *Norm) DeleteUser("} {
+		if !strings.Contains(code.String(), want) {
+			t.Errorf("Expected %s in the generated code", want)
+		}
+	}
+}
+
+func TestGenerateWithoutPackage(t *testing.T) {
+	f, err := normparser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var code bytes.Buffer
+	if err := Generate(f, &code); err == nil || !strings.Contains(err.Error(), "!package") {
+		t.Errorf("Expected an error about the missing package, got %v", err)
+	}
+	if code.Len() != 0 {
+		t.Errorf("Expected nothing written, got %d bytes", code.Len())
+	}
+}
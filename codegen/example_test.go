@@ -0,0 +1,40 @@
+package codegen_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/agrewal/norm/codegen"
+	"github.com/agrewal/norm/parser"
+)
+
+func ExampleGenerate() {
+	src := `-- !norm
+-- !package store
+-- !file store.go
+
+-- !read GetUsers
+-- !input domain string
+-- !output ID int
+SELECT id FROM user WHERE domain = $1
+`
+	f, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var code bytes.Buffer
+	if err := codegen.Generate(f, &code); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, line := range strings.Split(code.String(), "\n") {
+		if strings.HasPrefix(line, "package ") || strings.HasPrefix(line, "func (n *Norm) GetUsers(") {
+			fmt.Println(line)
+		}
+	}
+	// Output:
+	// package store
+	// func (n *Norm) GetUsers(ctx context.Context, domain string, opts ...Option) ([]int, error) {
+}
@@ -0,0 +1,11 @@
+-- !norm
+-- Generates the queries of queries.norm.sql for sqlite. postgres/postgres.norm.sql
+-- and mysql/mysql.norm.sql generate them for the other databases.
+
+-- !file conformance.go
+
+-- !package conformance
+
+-- !stamp hash
+
+-- !dialect sqlite
@@ -0,0 +1,287 @@
+package conformance
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/agrewal/norm/example/conformance/mysql"
+	"github.com/agrewal/norm/example/conformance/postgres"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var ctx = context.Background()
+
+// legs are the databases the conformance tests run against, each through the
+// Norm of the package generated for its !dialect: this package for sqlite,
+// and packages postgres and mysql. sqlite runs on a temporary file, with this
+// package and with the ? placeholders of package mysql. The others run when
+// the environment variable env holds the DSN of a database and the
+// database/sql driver is linked into the test binary, which the conformance
+// build tag does, see drivers_test.go. Their table is created if it does not
+// exist and emptied before every test.
+var legs = []struct {
+	name   string
+	driver string
+	env    string
+	open   func(driver, dsn string) (interface{}, error)
+}{
+	{"sqlite", "sqlite3", "", openSQLite},
+	{"sqlite_mysql_dialect", "sqlite3", "", openMySQL},
+	{"postgres", "postgres", "NORM_TEST_POSTGRES_DSN", openPostgres},
+	{"mysql", "mysql", "NORM_TEST_MYSQL_DSN", openMySQL},
+}
+
+func openSQLite(driver, dsn string) (interface{}, error) {
+	return NewNorm(driver, dsn)
+}
+
+func openPostgres(driver, dsn string) (interface{}, error) {
+	return postgres.NewNorm(driver, dsn)
+}
+
+func openMySQL(driver, dsn string) (interface{}, error) {
+	return mysql.NewNorm(driver, dsn)
+}
+
+// conformanceTests are run against every leg, each on an empty table.
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, d *db)
+}{
+	{"ReadOne", testReadOne},
+	{"ReadOneNoRows", testReadOneNoRows},
+	{"Read", testRead},
+	{"ReadWithInput", testReadWithInput},
+	{"NamedInputs", testNamedInputs},
+	{"RowsAffected", testRowsAffected},
+	{"Tx", testTx},
+}
+
+func registered(driver string) bool {
+	for _, name := range sql.Drivers() {
+		if name == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// TestConformance runs the tests against every leg, and checks that the
+// results of the calls of every leg are the ones of the first, sqlite.
+func TestConformance(t *testing.T) {
+	var first string
+	var want []string
+	for _, l := range legs {
+		l := l
+		var log []string
+		ran := t.Run(l.name, func(t *testing.T) {
+			dsn := os.Getenv(l.env)
+			if l.env == "" {
+				f, err := ioutil.TempFile("", "norm_conformance_*.db")
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.Close()
+				defer os.Remove(f.Name())
+				dsn = fmt.Sprintf("file:%s", f.Name())
+			} else if dsn == "" {
+				t.Skipf("%s is not set", l.env)
+			}
+			if !registered(l.driver) {
+				t.Skipf("no %s driver is linked in, see drivers_test.go", l.driver)
+			}
+			n, err := l.open(l.driver, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := &db{t: t, n: reflect.ValueOf(n), log: &log}
+			defer d.mustCall("Close")
+			d.mustCall("CreateAccountTable")
+			for _, ct := range conformanceTests {
+				d.mustCall("DeleteAccounts")
+				t.Run(ct.name, func(t *testing.T) {
+					ct.test(t, &db{t: t, n: d.n, log: d.log})
+				})
+			}
+			d.mustCall("DeleteAccounts")
+		})
+		if !ran || len(log) == 0 {
+			continue
+		}
+		if want == nil {
+			first, want = l.name, log
+			continue
+		}
+		for ix := 0; ix < len(want) || ix < len(log); ix++ {
+			if ix >= len(want) || ix >= len(log) || log[ix] != want[ix] {
+				t.Errorf("%s made %d calls and %s %d, first differing at call %d:\n%s: %s\n%s: %s", l.name, len(log), first, len(want), ix+1, l.name, at(log, ix), first, at(want, ix))
+				break
+			}
+		}
+	}
+}
+
+func at(log []string, ix int) string {
+	if ix < len(log) {
+		return log[ix]
+	}
+	return "(none)"
+}
+
+// db calls the generated functions of a Norm, or of a NormTx, of any of the
+// generated packages by name. The packages declare the same functions and
+// structs, which only differ in their Go types, so the results are compared
+// as JSON.
+type db struct {
+	t *testing.T
+	n reflect.Value
+	// log holds every call of call and its results, shared by the tests of
+	// a leg.
+	log *[]string
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// invoke calls the function name with ctx, if it takes one, and args. It
+// returns the results but the error encoded as JSON, separated by spaces,
+// and the error.
+func (d *db) invoke(name string, args ...interface{}) (string, error) {
+	d.t.Helper()
+	m := d.n.MethodByName(name)
+	if !m.IsValid() {
+		d.t.Fatalf("No function %s", name)
+	}
+	var in []reflect.Value
+	if m.Type().NumIn() > 0 && m.Type().In(0) == contextType {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	for _, arg := range args {
+		in = append(in, reflect.ValueOf(arg))
+	}
+	out := m.Call(in)
+	err, _ := out[len(out)-1].Interface().(error)
+	var results []string
+	for _, v := range out[:len(out)-1] {
+		data, jerr := json.Marshal(v.Interface())
+		if jerr != nil {
+			d.t.Fatal(jerr)
+		}
+		results = append(results, string(data))
+	}
+	return strings.Join(results, " "), err
+}
+
+// call is invoke, recording the call and its results in the log of the leg.
+func (d *db) call(name string, args ...interface{}) (string, error) {
+	d.t.Helper()
+	got, err := d.invoke(name, args...)
+	entry := fmt.Sprintf("%s%v: %s", name, args, got)
+	if err != nil {
+		entry += " error " + err.Error()
+	}
+	*d.log = append(*d.log, entry)
+	return got, err
+}
+
+// mustCall calls the function name outside of the log, failing the test on
+// an error, for setting up the database.
+func (d *db) mustCall(name string, args ...interface{}) {
+	d.t.Helper()
+	if _, err := d.invoke(name, args...); err != nil {
+		d.t.Fatalf("%s: %v", name, err)
+	}
+}
+
+// expect calls the function name and checks that it returns the results
+// want, as JSON.
+func (d *db) expect(want string, name string, args ...interface{}) {
+	d.t.Helper()
+	got, err := d.call(name, args...)
+	if err != nil {
+		d.t.Fatalf("%s%v: %v", name, args, err)
+	}
+	if got != want {
+		d.t.Errorf("Expected %s%v to return %s, got %s", name, args, want, got)
+	}
+}
+
+// expectErr calls the function name and checks that it fails with want.
+func (d *db) expectErr(want error, name string, args ...interface{}) {
+	d.t.Helper()
+	if _, err := d.call(name, args...); err != want {
+		d.t.Errorf("Expected %s%v to fail with %v, got %v", name, args, want, err)
+	}
+}
+
+// begin starts a transaction, returning the db running the calls in it.
+func (d *db) begin() *db {
+	d.t.Helper()
+	out := d.n.MethodByName("Begin").Call([]reflect.Value{reflect.ValueOf(ctx)})
+	if err, _ := out[1].Interface().(error); err != nil {
+		d.t.Fatal(err)
+	}
+	return &db{t: d.t, n: out[0], log: d.log}
+}
+
+func (d *db) addAccounts(emails ...string) {
+	d.t.Helper()
+	for ix, email := range emails {
+		d.expect("", "AddAccount", int64(ix+1), email)
+	}
+}
+
+func testReadOne(t *testing.T, d *db) {
+	d.addAccounts("a@example.com")
+	d.expect(`{"Email":"a@example.com","Visits":0}`, "GetAccount", int64(1))
+}
+
+func testReadOneNoRows(t *testing.T, d *db) {
+	d.expectErr(sql.ErrNoRows, "GetAccount", int64(1))
+}
+
+func testRead(t *testing.T, d *db) {
+	d.addAccounts("b@example.com", "a@example.com")
+	expected := `[{"ID":1,"Email":"b@example.com"},{"ID":2,"Email":"a@example.com"}]`
+	d.expect(expected, "ListAccounts")
+	// Again, from the prepared statement.
+	d.expect(expected, "ListAccounts")
+}
+
+func testReadWithInput(t *testing.T, d *db) {
+	d.addAccounts("b@example.com", "a@example.com", "c@example.com")
+	for _, id := range []int64{1, 3, 3} {
+		d.expect("1", "AddVisit", id)
+	}
+	d.expect(`["b@example.com","c@example.com"]`, "ListAccountsByVisits", int64(1))
+	d.expect(`null`, "ListAccountsByVisits", int64(3))
+}
+
+func testNamedInputs(t *testing.T, d *db) {
+	d.addAccounts("a@example.com", "b@example.com", "c@example.com")
+	d.expect(`[1,2]`, "ListAccountsMatching", int64(1), "b@example.com")
+	d.expect(`[2]`, "ListAccountsMatching", int64(4), "B@example.com")
+}
+
+func testRowsAffected(t *testing.T, d *db) {
+	d.addAccounts("a@example.com")
+	d.expect("1", "AddVisit", int64(1))
+	d.expect("0", "DeleteAccount", int64(2))
+}
+
+func testTx(t *testing.T, d *db) {
+	tx := d.begin()
+	tx.addAccounts("a@example.com")
+	tx.expect("", "Rollback")
+	d.expectErr(sql.ErrNoRows, "GetAccount", int64(1))
+	tx = d.begin()
+	tx.addAccounts("a@example.com")
+	tx.expect("", "Commit")
+	d.expect(`{"Email":"a@example.com","Visits":0}`, "GetAccount", int64(1))
+}
@@ -0,0 +1,11 @@
+//go:build conformance
+// +build conformance
+
+package conformance
+
+// The drivers of the databases the conformance tests run against besides
+// sqlite, linked in by go test -tags conformance, see legs.
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
@@ -0,0 +1,6 @@
+// Package conformance runs the same queries, generated by norm, against every
+// database the tests are configured with, checking that the generated code
+// behaves the same on all of them. See conformance_test.go.
+package conformance
+
+//go:generate norm generate -strict conformance.norm.sql queries.norm.sql
@@ -0,0 +1,5 @@
+// Package mysql holds the queries of the conformance tests generated for the
+// mysql !dialect, see package conformance.
+package mysql
+
+//go:generate norm generate -strict mysql.norm.sql ../queries.norm.sql
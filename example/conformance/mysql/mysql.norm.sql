@@ -0,0 +1,11 @@
+-- !norm
+-- Generates the queries of ../queries.norm.sql for mysql, which only takes ?
+-- placeholders.
+
+-- !file conformance.go
+
+-- !package mysql
+
+-- !stamp hash
+
+-- !dialect mysql
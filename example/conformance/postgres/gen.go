@@ -0,0 +1,5 @@
+// Package postgres holds the queries of the conformance tests generated for
+// the postgres !dialect, see package conformance.
+package postgres
+
+//go:generate norm generate -strict postgres.norm.sql ../queries.norm.sql
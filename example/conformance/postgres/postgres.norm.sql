@@ -0,0 +1,11 @@
+-- !norm
+-- Generates the queries of ../queries.norm.sql for postgres, which only takes
+-- $n placeholders.
+
+-- !file conformance.go
+
+-- !package postgres
+
+-- !stamp hash
+
+-- !dialect postgres
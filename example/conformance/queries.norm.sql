@@ -0,0 +1,74 @@
+-- !norm
+-- The queries of the conformance tests, run against every database configured
+-- in conformance_test.go. They only use SQL that all of these databases
+-- accept, so that a failing test points at a difference in the generated code
+-- or in the drivers, rather than in the queries. The inputs are named, so that
+-- the !dialect of the files generated along with this one, conformance.norm.sql
+-- for sqlite, postgres/postgres.norm.sql and mysql/mysql.norm.sql, chooses the
+-- placeholders.
+
+-- !exec CreateAccountTable
+-- !doc Creates the table of the tests, unless it exists from a previous run.
+CREATE TABLE IF NOT EXISTS norm_conformance_account (
+  id INTEGER PRIMARY KEY,
+  email TEXT NOT NULL,
+  visits INTEGER NOT NULL
+)
+
+-- !exec DeleteAccounts
+-- !rows_affected
+-- !doc Empties the table before every test.
+DELETE FROM norm_conformance_account
+
+-- !exec AddAccount
+-- !input id int64
+-- !input email string
+INSERT INTO norm_conformance_account (id, email, visits)
+VALUES (:id, :email, 0)
+
+-- !read_one GetAccount
+-- !input id int64
+-- !output Email string
+-- !output Visits int64
+SELECT email, visits
+FROM norm_conformance_account
+WHERE id = :id
+
+-- !read ListAccounts
+-- !output ID int64
+-- !output Email string
+SELECT id, email
+FROM norm_conformance_account
+ORDER BY id
+
+-- !read ListAccountsByVisits
+-- !input minVisits int64
+-- !output Email string
+SELECT email
+FROM norm_conformance_account
+WHERE visits >= :minVisits
+ORDER BY email
+
+-- !exec AddVisit
+-- !input id int64
+-- !rows_affected
+UPDATE norm_conformance_account
+SET visits = visits + 1
+WHERE id = :id
+
+-- !exec DeleteAccount
+-- !input id int64
+-- !rows_affected
+DELETE FROM norm_conformance_account
+WHERE id = :id
+
+-- !read ListAccountsMatching
+-- !input id int64
+-- !input email string
+-- !output ID int64
+-- !doc Uses its inputs out of order and more than once, which sqlite and mysql
+-- !doc bind to ? placeholders one by one.
+SELECT id
+FROM norm_conformance_account
+WHERE email = :email OR id = :id OR email = lower(:email)
+ORDER BY id
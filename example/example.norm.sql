@@ -5,76 +5,545 @@
 -- Filename to write the generated output in
 
 -- !package example
--- package name of the generated code
+-- package name of the generated code. If omitted, it is taken from the other Go
+-- files next to the output file, or from the name of their directory.
+
+-- !stamp hash
+-- The generated files hold a hash of their content instead of the date they
+-- were generated on, so regenerating them without changes leaves them as is.
 
 -- You can import packages by putting in a command like so !import "time"
+-- The quotes are optional, and the package can be given an alias with a command
+-- like so !import pgtypes github.com/jackc/pgx/v5/pgtype. Repeated imports are
+-- only added once.
+
+-- If your models live in another package, you can name it with a command
+-- like so !model_pkg github.com/acme/app/models and !model User will refer
+-- to models.User. The package is imported automatically.
+
+-- !mocks
+-- Writes a MockQuerier implementing the generated Querier interface with
+-- function fields to norm_mock.go, for tests that do not use a database.
+
+-- !id_type UserID int64 user.id
+-- Declares a distinct ID type with Scan and Value methods. Inputs and outputs
+-- derived from the listed table.column names use it, so that other IDs cannot
+-- be passed by mistake. It can also be named in !input and !output lines.
+
+-- !check_columns
+-- Generated reads compare the columns returned by the database with the
+-- declared outputs before scanning, and return an error if they differ. This
+-- can be turned off at run time with the generated CheckColumns variable.
+
+-- !usage_counts
+-- Counts the calls of every query, returned by the generated QueryCounts. A
+-- JSON report of the counts can be checked by norm prune for unused queries.
+
+-- !cache_keys
+-- Generates a CacheKey function for every query reading rows, a stable key of
+-- the query and its inputs for caches in front of the database.
+-- A block can choose the fields of its key, one per line, with a command like
+-- so !cache_key email strings.ToLower(email), a Go expression of its inputs
+-- that equivalent calls agree on. The expression may use the packages imported
+-- with !import.
+-- !import "strings"
+
+-- !large_result_threshold 1000
+-- Slice returning functions report results larger than this many rows through
+-- the generated OnLargeResult hook, which suggests using the Scan variant.
+-- !pragma foreign_keys=ON busy_timeout=5000
+-- Sets sqlite pragmas on every new connection opened with the generated Open
+-- function. Use journal_mode=WAL and a busy_timeout for concurrent access.
+
+-- !on_connect CREATE TEMP TABLE IF NOT EXISTS session (k text, v text)
+-- Statements run on every new connection opened with the generated Open
+-- function, after which the generated OnConnect hook is called if set. This is
+-- the place for session state like SET application_name or temp tables.
 
+-- !application_name norm-example
+-- Connections opened with the generated Open function identify themselves to
+-- postgres and mysql servers with this name, which can be changed at run time
+-- through the generated ApplicationName variable.
+
+-- !attach audit file:audit?mode=memory&cache=shared
+-- Attaches a sqlite database under a schema name on every new connection. The
+-- connections must be opened with the generated Open function instead of
+-- sql.Open. Queries using a schema that is not attached are reported.
 
 -- Each block generates code depending on the "command". Supported commands are
--- "read", "read_one", "exec". The name following the command will be used in
--- the API names in autogenerated code. Having an intermediate `model` is optional.
+-- "read", "read_one", "exec", "exec_returning", "exec_many" and "view". The
+-- name following the command will be used in the API names in autogenerated
+-- code. Having an intermediate `model` is optional.
 
 -- !read GetUserListNoModel
 -- !output ID int
 -- !output Email string
+-- !warmup
 -- !doc Retrieves all emails from the users table. Since there is no
--- !doc intermediate model, an output struct is autocreated which will contain only
--- !doc the fields specified in the output. Please make sure that the field names
--- !doc are capitalized.
+-- !doc intermediate model, an output struct is autocreated which will contain
+-- !doc only the fields specified in the output. Please make sure that the field
+-- !doc names are capitalized.
 SELECT id, email
 FROM user
 ORDER BY email ASC
 
 -- !read GetUserEmailsNoModel
 -- !output Email string
+-- !max_concurrency 2 nowait
 -- !doc Retrieves all emails from the users table. In this example, there is
--- !doc only one output field. Therefore an intermediate struct is also not needed,
--- !doc we just return a slice of the output type (string in this case)
+-- !doc only one output field. Therefore an intermediate struct is also not
+-- !doc needed, we just return a slice of the output type (string in this case).
+-- !doc At most two calls can run at once because of !max_concurrency, further
+-- !doc calls fail with ErrConcurrencyLimit. Without nowait, they wait for a
+-- !doc free slot.
 SELECT email
 FROM user
 ORDER BY email ASC
 
+-- !read GetUserListLimited
+-- !output ID int
+-- !output Email string
+-- !max_concurrency 1 nowait
+-- !fallback GetUserListPaged
+-- !doc Retrieves users, one call at a time. When the query fails, here because
+-- !doc of the limit, the cheaper query named by !fallback is run instead, when
+-- !doc the generated ShouldFallback allows it. The fallback must read into the
+-- !doc same struct, and Degraded reports whether it ran.
+SELECT id, email
+FROM user
+ORDER BY id ASC
+
+-- !read GetUserListPaged
+-- !output ID int
+-- !output Email string
+-- !model GetUserListLimitedOutput
+-- !doc Retrieves the first 100 users, into the struct of GetUserListLimited
+-- !doc it is the fallback of.
+SELECT id, email
+FROM user
+ORDER BY id ASC
+LIMIT 100
+
+-- !read GetUserRows
+-- !doc Without !output lines, the outputs are derived from the SELECT list and
+-- !doc typed from the columns of the user table: id is an int64, and email,
+-- !doc which can be NULL, a sql.NullString.
+SELECT id, email
+FROM user
+ORDER BY id ASC
+
 -- !read GetUserListWithModel
 -- !output ID int
 -- !output Email string
 -- !model User
+-- !compare
 -- !doc Retrieves all emails from the users table. In this example, an
 -- !doc intermediate model is used. See `gen.go` for the model definition. This
--- !doc allows users to specify an arbitrary intermediate struct.
+-- !doc allows users to specify an arbitrary intermediate struct. Because of
+-- !doc !compare, CompareGetUserListWithModel reports the users that differ
+-- !doc between two databases.
 SELECT id, email
 FROM user
 ORDER BY email ASC
 
 -- !exec AddUser
 -- !input email string
+-- !sensitive email
 -- !doc Add a user to the DB
 INSERT into user(email)
 VALUES ($1)
 
+-- !exec_many AddUsers
+-- !input email string
+-- !sensitive email
+-- !doc Adds users in one transaction, running the statement once per row. The
+-- !doc rows are AddUsersRow structs with a field per input, or the struct named
+-- !doc with !model.
+INSERT INTO user (email)
+VALUES ($1)
+
+-- !copy CopyUsers
+-- !input email string
+-- !doc Bulk loads users into a postgres table with COPY FROM STDIN, through the
+-- !doc lib/pq driver. The body is the table name and the inputs are its
+-- !doc columns. CopyUsersFrom reads the rows from a function instead of a
+-- !doc slice.
+user
+
 -- !exec DeleteAllUsers
 -- !doc Deletes all users from the DB
 DELETE FROM user
 
+-- !exec AddUserResult
+-- !input email string
+-- !result
+-- !doc Adds a user, returning the sql.Result to read the new ID from. With
+-- !doc !rows_affected instead, only the number of affected rows is returned.
+INSERT into user(email)
+VALUES ($1)
+
+-- !exec_returning AddUserReturning
+-- !input email string
+-- !output ID int
+-- !output Email string
+-- !model User
+-- !doc Adds a user and reads back the new row. Statements with a RETURNING
+-- !doc clause are generated like read_one commands. This needs sqlite 3.35 or
+-- !doc newer, or postgres.
+INSERT INTO user(email)
+VALUES ($1)
+RETURNING id, email
+
+-- !exec DeleteUser
+-- !input email string
+-- !rows_affected
+-- !doc Deletes a user by email, returning the number of users deleted
+DELETE FROM user
+WHERE email = $1
+
 -- !read_one FindUser
 -- !input email string
 -- !output ID int
 -- !output Email string
+-- !compare
+-- !cache_key email strings.ToLower(email)
 -- !doc Finds user by email
 SELECT id, email
 FROM USER
 WHERE email = $1
 
+-- !read_one FindUserWithModel
+-- !input email string
+-- !output ID int
+-- !output Email string
+-- !model User
+-- !doc Finds user by email, reading into the User model. A
+-- !doc FindUserWithModelInto variant is also generated to read into an existing
+-- !doc User.
+SELECT id, email
+FROM USER
+WHERE email = $1
+
+-- !read_one FindUserSwappedColumns
+-- !input email string
+-- !output ID int
+-- !output Email string
+-- !doc Selects the columns in a different order than the outputs, which
+-- !doc !check_columns reports as an error instead of scanning the email into
+-- !doc ID.
+SELECT email, id
+FROM USER
+WHERE email = $1
+
+-- !read_one FindUserByEmail
+-- !input email string
+-- !output email string
+-- !deprecated use FindUserEmail instead.
+-- !doc Finds user by email. Deprecated commands get a Deprecated paragraph in
+-- !doc their doc comments, and report their first use through the generated
+-- !doc OnDeprecatedUse hook.
+SELECT email
+FROM USER
+WHERE email = $1
+
+-- !read FindUsersNamed
+-- !input email string
+-- !input domain string
+-- !output ID int
+-- !no_prepare
+-- !doc Inputs can be referred to by name, like :email, instead of by position.
+-- !doc The query is run without preparing it first, because of !no_prepare.
+SELECT id
+FROM user
+WHERE email = :email OR (:domain <> '' AND email LIKE '%@' || :domain)
+ORDER BY id ASC
+
 -- !read_one FindUserEmail
 -- !input email string
 -- !output email string
--- !doc Finds user by email.
+-- !limit_group lookups
+-- !doc Finds user by email. The query is in the lookups group of !limit_group,
+-- !doc whose rate is limited by Limiters["lookups"] when it is set.
 SELECT email
 FROM USER
 WHERE email = $1
 
+-- !read_one FindUserByID
+-- !output ID int
+-- !output Email string
+-- !doc Without !input lines, inputs are derived from the placeholders. The
+-- !doc input here is named and typed after the id column of the user table.
+SELECT id, email
+FROM user
+WHERE id = $1
+
+-- !read_one FindUserEmailOrEmpty
+-- !output email string null
+-- !readonly
+-- !doc The email column can be NULL, which the null marker on the output scans
+-- !doc as an empty string.
+SELECT email
+FROM user
+WHERE id = $1
+
+-- !read GetUserEmailsOrEmpty
+-- !output ID int64
+-- !output Email string null
+-- !readonly
+-- !doc Runs in a read only transaction because of !readonly, in which the
+-- !doc database rejects writes.
+SELECT id, email
+FROM user
+ORDER BY id ASC
+
+-- !read GetUserAccounts
+-- !output ID int64
+-- !output Email string
+-- !model UserAccount
+-- !mapper newUserAccount
+-- !doc Rows are scanned into GetUserAccountsRow and passed to newUserAccount,
+-- !doc named with !mapper, which builds the UserAccount model.
+SELECT id, email
+FROM user
+ORDER BY id ASC
+
+-- !read GetUserContacts
+-- !output ID int64
+-- !output Email string
+-- !model UserContact
+-- !model_gen
+-- !tags json,db
+-- !doc The UserContact model is generated from the outputs because of
+-- !doc !model_gen, instead of being declared next to the generated file. Its
+-- !doc fields get json and db tags in snake case because of !tags.
+SELECT id, email
+FROM user
+ORDER BY id ASC
+
+-- !read FindUserEmailsByIDs
+-- !input ids []UserID
+-- !input domain string
+-- !output Email string
+-- !bind domain
+-- !doc Slice inputs, other than []byte, are expanded into one placeholder per
+-- !doc element when the query runs, here in an IN list. An empty slice makes
+-- !doc the IN predicate false, so it matches no rows. The !bind directive
+-- !doc generates BindFindUserEmailsByIDs, returning this function with domain
+-- !doc fixed.
+SELECT email
+FROM user
+WHERE id IN ($1) AND email LIKE '%@' || $2
+ORDER BY id ASC
+
+-- !read GetTenantUserEmails
+-- !input_ctx domain string key:tenantDomain
+-- !output Email string
+-- !doc Reads the emails of the users of the tenant carried by the context, see
+-- !doc WithTenantDomain, instead of taking the domain as a parameter.
+SELECT email
+FROM user
+WHERE email LIKE '%@' || $1
+ORDER BY id ASC
+
+-- !read FindUserEmailsExcept
+-- !input ids []UserID
+-- !output Email string
+-- !doc An empty slice makes a NOT IN predicate true, so it matches every row.
+SELECT email
+FROM user
+WHERE user.id NOT IN ($1)
+ORDER BY id ASC
+
 -- !exec CreateUserTable
 -- !doc Creates the user table
 CREATE TABLE user (
 	id integer primary key autoincrement,
 	email text
 )
+
+-- !exec CreateAuditEventTable
+-- !doc Creates the event table in the attached audit database
+CREATE TABLE IF NOT EXISTS audit.event (
+	id integer primary key autoincrement,
+	msg text
+)
+
+-- !exec AddAuditEvent
+-- !input msg string
+-- !doc Adds an event to the attached audit database
+INSERT INTO audit.event(msg)
+VALUES ($1)
+
+-- !read GetAuditEvents
+-- !output Msg string
+-- !doc Reads the messages in the attached audit database
+SELECT msg
+FROM audit.event
+ORDER BY id ASC
+
+-- A later !file directive writes the blocks after it to another file of the
+-- package, here the membership queries to store_membership.go. Naming the main
+-- file again, as before the view below, goes back to it.
+-- !file store_membership.go
+
+-- !exec CreateMembershipTable
+-- !doc Creates the membership table, keyed by user and group
+CREATE TABLE membership (
+	user_id integer NOT NULL,
+	group_name text NOT NULL,
+	role text NOT NULL,
+	PRIMARY KEY (user_id, group_name)
+)
+
+-- !exec AddMembership
+-- !input userID int64
+-- !input group string
+-- !input role string
+-- !key MembershipKey userID group
+-- !doc Adds a user to a group. The inputs listed after !key are grouped into a
+-- !doc MembershipKey parameter.
+INSERT INTO membership (user_id, group_name, role)
+VALUES ($1, $2, $3)
+
+-- !read_one FindMembershipRole
+-- !input userID int64
+-- !input group string
+-- !output Role string
+-- !key MembershipKey
+-- !doc Queries on a composite key take it as one struct, declared with !key.
+-- !doc Without a list of inputs, all the inputs are grouped. Queries naming the
+-- !doc same key share the struct.
+SELECT role
+FROM membership
+WHERE user_id = $1 AND group_name = $2
+
+-- !exec DeleteMembership
+-- !input userID int64
+-- !input group string
+-- !key MembershipKey
+-- !rows_affected
+-- !audit_log userID group
+-- !doc Removes a user from a group. Because of !audit_log, a row naming the
+-- !doc actor set with WithAuditActor and the listed inputs is written to the
+-- !doc table created by CreateAuditLogTable, in the transaction of the query.
+DELETE FROM membership
+WHERE user_id = $1 AND group_name = $2
+
+-- !file store.go
+
+-- !view UserDomain user_domain
+-- !output ID int
+-- !output Domain string
+-- !doc Views declared in the file get a generated model struct, a function
+-- !doc creating the view and a List function reading all of its rows. Add
+-- !doc !materialized to the block to also generate a Refresh function.
+SELECT id, substr(email, instr(email, '@') + 1) AS domain
+FROM user
+
+-- !read GetUserDomainsByDomain
+-- !input domain string
+-- !output ID int
+-- !output Domain string
+-- !model UserDomain
+-- !doc Other commands can read into the model of a view. Their outputs are
+-- !doc checked against the columns of the view.
+SELECT id, domain
+FROM user_domain
+WHERE domain = $1
+ORDER BY id ASC
+
+-- !read_one FindUserAsOf
+-- !input email string
+-- !output ID int
+-- !output Email string
+-- !as_of mariadb
+-- !doc Finds user by email as of a point in time, on a system-versioned user
+-- !doc table. The zero time reads the current rows, which is all sqlite does.
+SELECT id, email
+FROM user
+WHERE email = $1
+
+-- !exec AddLogin
+-- !input userID int
+-- !input at time.Time
+-- !partition_by at monthly
+-- !doc Records a login of a user. The login table is partitioned by month on
+-- !doc postgres, and the partition of the login is created first if needed.
+INSERT INTO login (user_id, logged_in_at)
+VALUES ($1, $2)
+
+-- !exec CreateUserSecretTable
+-- !doc Creates the table of the encrypted personal data of the users
+CREATE TABLE user_secret (
+  user_id integer PRIMARY KEY,
+  ssn blob NOT NULL
+)
+
+-- !exec SetUserSSN
+-- !input userID int
+-- !input ssn string
+-- !encrypted ssn
+-- !doc Stores the social security number of a user, encrypted by Encryption.
+INSERT OR REPLACE INTO user_secret (user_id, ssn)
+VALUES ($1, $2)
+
+-- !read_one GetUserSSN
+-- !input userID int
+-- !output SSN string
+-- !encrypted SSN
+-- !doc Reads the social security number of a user, decrypted by Encryption.
+SELECT ssn
+FROM user_secret
+WHERE user_id = $1
+
+-- !read_one GetUserByID
+-- !input id int
+-- !output ID int
+-- !output Email string
+-- !etag
+-- !doc Gets a user by id, with the ETag of the row for UpdateUserEmailIfMatch.
+SELECT id, email
+FROM user
+WHERE id = $1
+
+-- !exec UpdateUserEmail
+-- !input email string
+-- !input id int
+-- !if_match GetUserByID
+-- !doc Changes the email of a user.
+UPDATE user
+SET email = $1
+WHERE id = $2
+
+-- !read CountUsersByDomain
+-- !output Domain string
+-- !output Users int
+-- !doc Counts the users of every email domain. The query keeps its blank lines,
+-- !doc since the block ends at !end rather than at the first blank line.
+WITH domains AS (
+  SELECT substr(email, instr(email, '@') + 1) AS domain
+  FROM user
+)
+
+SELECT domain, count(*) AS users
+FROM domains
+GROUP BY domain
+ORDER BY domain
+-- !end
+
+-- !script Maintain
+-- !doc Rebuilds the indexes and the statistics of the query planner.
+REINDEX;
+ANALYZE;
+VACUUM
+
+-- Blocks between !ifdef and !endif lines are only generated when the name is
+-- passed to norm with -define, see gen.go. This lets builds of different
+-- editions share a norm file.
+-- !ifdef stats
+-- !read_one CountUsers
+-- !output n int
+-- !doc Counts the users.
+SELECT count(*) AS n
+FROM user
+-- !endif
@@ -1,8 +1,30 @@
 package example
 
-//go:generate norm example.norm.sql
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate norm generate -strict -define stats -fuzz example.norm.sql
 
 type User struct {
 	ID    int
 	Email string
 }
+
+// UserAccount is built from the rows of GetUserAccounts by newUserAccount.
+type UserAccount struct {
+	ID     int64
+	Email  string
+	Domain string
+}
+
+// newUserAccount is the mapper of GetUserAccounts, computing the domain of the
+// email.
+func newUserAccount(row GetUserAccountsRow) (UserAccount, error) {
+	at := strings.LastIndex(row.Email, "@")
+	if at < 0 {
+		return UserAccount{}, fmt.Errorf("user %d has an invalid email %q", row.ID, row.Email)
+	}
+	return UserAccount{ID: row.ID, Email: row.Email, Domain: row.Email[at+1:]}, nil
+}
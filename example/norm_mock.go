@@ -0,0 +1,833 @@
+// Code generated by norm. DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MockQuerier implements Querier with a function field for every method, for
+// tests that do not use a database. Calling a method whose function is not set
+// panics.
+type MockQuerier struct {
+	WarmUpFunc                       func(ctx context.Context) error
+	CreateAuditLogTableFunc          func(ctx context.Context) error
+	CreateLoginPartitionFunc         func(ctx context.Context, t time.Time) error
+	GetUserListNoModelScanFunc       func(ctx context.Context, opts ...Option) (*GetUserListNoModelResult, error)
+	AppendGetUserListNoModelFunc     func(ctx context.Context, dst []GetUserListNoModelOutput, opts ...Option) ([]GetUserListNoModelOutput, error)
+	GetUserListNoModelFunc           func(ctx context.Context, opts ...Option) ([]GetUserListNoModelOutput, error)
+	GetUserEmailsNoModelScanFunc     func(ctx context.Context, opts ...Option) (*GetUserEmailsNoModelResult, error)
+	AppendGetUserEmailsNoModelFunc   func(ctx context.Context, dst []string, opts ...Option) ([]string, error)
+	GetUserEmailsNoModelFunc         func(ctx context.Context, opts ...Option) ([]string, error)
+	GetUserListLimitedScanFunc       func(ctx context.Context, opts ...Option) (*GetUserListLimitedResult, error)
+	AppendGetUserListLimitedFunc     func(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserListLimitedFunc           func(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserListPagedScanFunc         func(ctx context.Context, opts ...Option) (*GetUserListPagedResult, error)
+	AppendGetUserListPagedFunc       func(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserListPagedFunc             func(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserRowsScanFunc              func(ctx context.Context, opts ...Option) (*GetUserRowsResult, error)
+	AppendGetUserRowsFunc            func(ctx context.Context, dst []GetUserRowsOutput, opts ...Option) ([]GetUserRowsOutput, error)
+	GetUserRowsFunc                  func(ctx context.Context, opts ...Option) ([]GetUserRowsOutput, error)
+	GetUserListWithModelScanFunc     func(ctx context.Context, opts ...Option) (*GetUserListWithModelResult, error)
+	AppendGetUserListWithModelFunc   func(ctx context.Context, dst []User, opts ...Option) ([]User, error)
+	GetUserListWithModelFunc         func(ctx context.Context, opts ...Option) ([]User, error)
+	CompareGetUserListWithModelFunc  func(ctx context.Context, other *Norm) (Diff, error)
+	AddUserFunc                      func(ctx context.Context, email string, opts ...Option) error
+	AddUsersFunc                     func(ctx context.Context, rows []AddUsersRow, opts ...Option) error
+	CopyUsersFunc                    func(ctx context.Context, rows []CopyUsersRow, opts ...Option) error
+	CopyUsersFromFunc                func(ctx context.Context, next func() (CopyUsersRow, bool, error), opts ...Option) error
+	DeleteAllUsersFunc               func(ctx context.Context, opts ...Option) error
+	AddUserResultFunc                func(ctx context.Context, email string, opts ...Option) (sql.Result, error)
+	AddUserReturningIntoFunc         func(ctx context.Context, dst *User, email string, opts ...Option) error
+	AddUserReturningFunc             func(ctx context.Context, email string, opts ...Option) (*User, error)
+	DeleteUserFunc                   func(ctx context.Context, email string, opts ...Option) (int64, error)
+	FindUserIntoFunc                 func(ctx context.Context, dst *FindUserOutput, email string, opts ...Option) error
+	FindUserFunc                     func(ctx context.Context, email string, opts ...Option) (*FindUserOutput, error)
+	CompareFindUserFunc              func(ctx context.Context, other *Norm, email string) (Diff, error)
+	FindUserWithModelIntoFunc        func(ctx context.Context, dst *User, email string, opts ...Option) error
+	FindUserWithModelFunc            func(ctx context.Context, email string, opts ...Option) (*User, error)
+	FindUserSwappedColumnsIntoFunc   func(ctx context.Context, dst *FindUserSwappedColumnsOutput, email string, opts ...Option) error
+	FindUserSwappedColumnsFunc       func(ctx context.Context, email string, opts ...Option) (*FindUserSwappedColumnsOutput, error)
+	FindUserByEmailIntoFunc          func(ctx context.Context, dst *string, email string, opts ...Option) error
+	FindUserByEmailFunc              func(ctx context.Context, email string, opts ...Option) (*string, error)
+	FindUsersNamedScanFunc           func(ctx context.Context, email string, domain string, opts ...Option) (*FindUsersNamedResult, error)
+	AppendFindUsersNamedFunc         func(ctx context.Context, dst []int, email string, domain string, opts ...Option) ([]int, error)
+	FindUsersNamedFunc               func(ctx context.Context, email string, domain string, opts ...Option) ([]int, error)
+	FindUserEmailIntoFunc            func(ctx context.Context, dst *string, email string, opts ...Option) error
+	FindUserEmailFunc                func(ctx context.Context, email string, opts ...Option) (*string, error)
+	FindUserByIDIntoFunc             func(ctx context.Context, dst *FindUserByIDOutput, id UserID, opts ...Option) error
+	FindUserByIDFunc                 func(ctx context.Context, id UserID, opts ...Option) (*FindUserByIDOutput, error)
+	FindUserEmailOrEmptyIntoFunc     func(ctx context.Context, dst *string, id UserID, opts ...Option) error
+	FindUserEmailOrEmptyFunc         func(ctx context.Context, id UserID, opts ...Option) (*string, error)
+	GetUserEmailsOrEmptyScanFunc     func(ctx context.Context, opts ...Option) (*GetUserEmailsOrEmptyResult, error)
+	AppendGetUserEmailsOrEmptyFunc   func(ctx context.Context, dst []GetUserEmailsOrEmptyOutput, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error)
+	GetUserEmailsOrEmptyFunc         func(ctx context.Context, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error)
+	GetUserAccountsScanFunc          func(ctx context.Context, opts ...Option) (*GetUserAccountsResult, error)
+	AppendGetUserAccountsFunc        func(ctx context.Context, dst []UserAccount, opts ...Option) ([]UserAccount, error)
+	GetUserAccountsFunc              func(ctx context.Context, opts ...Option) ([]UserAccount, error)
+	GetUserContactsScanFunc          func(ctx context.Context, opts ...Option) (*GetUserContactsResult, error)
+	AppendGetUserContactsFunc        func(ctx context.Context, dst []UserContact, opts ...Option) ([]UserContact, error)
+	GetUserContactsFunc              func(ctx context.Context, opts ...Option) ([]UserContact, error)
+	FindUserEmailsByIDsScanFunc      func(ctx context.Context, ids []UserID, domain string, opts ...Option) (*FindUserEmailsByIDsResult, error)
+	AppendFindUserEmailsByIDsFunc    func(ctx context.Context, dst []string, ids []UserID, domain string, opts ...Option) ([]string, error)
+	FindUserEmailsByIDsFunc          func(ctx context.Context, ids []UserID, domain string, opts ...Option) ([]string, error)
+	GetTenantUserEmailsScanFunc      func(ctx context.Context, opts ...Option) (*GetTenantUserEmailsResult, error)
+	AppendGetTenantUserEmailsFunc    func(ctx context.Context, dst []string, opts ...Option) ([]string, error)
+	GetTenantUserEmailsFunc          func(ctx context.Context, opts ...Option) ([]string, error)
+	FindUserEmailsExceptScanFunc     func(ctx context.Context, ids []UserID, opts ...Option) (*FindUserEmailsExceptResult, error)
+	AppendFindUserEmailsExceptFunc   func(ctx context.Context, dst []string, ids []UserID, opts ...Option) ([]string, error)
+	FindUserEmailsExceptFunc         func(ctx context.Context, ids []UserID, opts ...Option) ([]string, error)
+	CreateUserTableFunc              func(ctx context.Context, opts ...Option) error
+	CreateAuditEventTableFunc        func(ctx context.Context, opts ...Option) error
+	AddAuditEventFunc                func(ctx context.Context, msg string, opts ...Option) error
+	GetAuditEventsScanFunc           func(ctx context.Context, opts ...Option) (*GetAuditEventsResult, error)
+	AppendGetAuditEventsFunc         func(ctx context.Context, dst []string, opts ...Option) ([]string, error)
+	GetAuditEventsFunc               func(ctx context.Context, opts ...Option) ([]string, error)
+	CreateMembershipTableFunc        func(ctx context.Context, opts ...Option) error
+	AddMembershipFunc                func(ctx context.Context, key MembershipKey, role string, opts ...Option) error
+	FindMembershipRoleIntoFunc       func(ctx context.Context, dst *string, key MembershipKey, opts ...Option) error
+	FindMembershipRoleFunc           func(ctx context.Context, key MembershipKey, opts ...Option) (*string, error)
+	DeleteMembershipFunc             func(ctx context.Context, key MembershipKey, opts ...Option) (int64, error)
+	CreateUserDomainViewFunc         func(ctx context.Context) error
+	ListUserDomainScanFunc           func(ctx context.Context, opts ...Option) (*ListUserDomainResult, error)
+	AppendListUserDomainFunc         func(ctx context.Context, dst []UserDomain, opts ...Option) ([]UserDomain, error)
+	ListUserDomainFunc               func(ctx context.Context, opts ...Option) ([]UserDomain, error)
+	GetUserDomainsByDomainScanFunc   func(ctx context.Context, domain string, opts ...Option) (*GetUserDomainsByDomainResult, error)
+	AppendGetUserDomainsByDomainFunc func(ctx context.Context, dst []UserDomain, domain string, opts ...Option) ([]UserDomain, error)
+	GetUserDomainsByDomainFunc       func(ctx context.Context, domain string, opts ...Option) ([]UserDomain, error)
+	FindUserAsOfIntoFunc             func(ctx context.Context, dst *FindUserAsOfOutput, email string, asOf time.Time, opts ...Option) error
+	FindUserAsOfFunc                 func(ctx context.Context, email string, asOf time.Time, opts ...Option) (*FindUserAsOfOutput, error)
+	AddLoginFunc                     func(ctx context.Context, userID int, at time.Time, opts ...Option) error
+	CreateUserSecretTableFunc        func(ctx context.Context, opts ...Option) error
+	SetUserSSNFunc                   func(ctx context.Context, userID int, ssn string, opts ...Option) error
+	GetUserSSNIntoFunc               func(ctx context.Context, dst *string, userID int, opts ...Option) error
+	GetUserSSNFunc                   func(ctx context.Context, userID int, opts ...Option) (*string, error)
+	GetUserByIDIntoFunc              func(ctx context.Context, dst *GetUserByIDOutput, id int, opts ...Option) error
+	GetUserByIDFunc                  func(ctx context.Context, id int, opts ...Option) (*GetUserByIDOutput, error)
+	UpdateUserEmailFunc              func(ctx context.Context, email string, id int, opts ...Option) error
+	UpdateUserEmailIfMatchFunc       func(ctx context.Context, email string, id int, etag string) error
+	CountUsersByDomainScanFunc       func(ctx context.Context, opts ...Option) (*CountUsersByDomainResult, error)
+	AppendCountUsersByDomainFunc     func(ctx context.Context, dst []CountUsersByDomainOutput, opts ...Option) ([]CountUsersByDomainOutput, error)
+	CountUsersByDomainFunc           func(ctx context.Context, opts ...Option) ([]CountUsersByDomainOutput, error)
+	MaintainFunc                     func(ctx context.Context, dryRun bool) error
+	CountUsersIntoFunc               func(ctx context.Context, dst *int, opts ...Option) error
+	CountUsersFunc                   func(ctx context.Context, opts ...Option) (*int, error)
+}
+
+var _ Querier = (*MockQuerier)(nil)
+
+func (m *MockQuerier) WarmUp(ctx context.Context) error {
+	if m.WarmUpFunc == nil {
+		panic("MockQuerier.WarmUpFunc is not set")
+	}
+	return m.WarmUpFunc(ctx)
+}
+
+func (m *MockQuerier) CreateAuditLogTable(ctx context.Context) error {
+	if m.CreateAuditLogTableFunc == nil {
+		panic("MockQuerier.CreateAuditLogTableFunc is not set")
+	}
+	return m.CreateAuditLogTableFunc(ctx)
+}
+
+func (m *MockQuerier) CreateLoginPartition(ctx context.Context, t time.Time) error {
+	if m.CreateLoginPartitionFunc == nil {
+		panic("MockQuerier.CreateLoginPartitionFunc is not set")
+	}
+	return m.CreateLoginPartitionFunc(ctx, t)
+}
+
+func (m *MockQuerier) GetUserListNoModelScan(ctx context.Context, opts ...Option) (*GetUserListNoModelResult, error) {
+	if m.GetUserListNoModelScanFunc == nil {
+		panic("MockQuerier.GetUserListNoModelScanFunc is not set")
+	}
+	return m.GetUserListNoModelScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserListNoModel(ctx context.Context, dst []GetUserListNoModelOutput, opts ...Option) ([]GetUserListNoModelOutput, error) {
+	if m.AppendGetUserListNoModelFunc == nil {
+		panic("MockQuerier.AppendGetUserListNoModelFunc is not set")
+	}
+	return m.AppendGetUserListNoModelFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserListNoModel(ctx context.Context, opts ...Option) ([]GetUserListNoModelOutput, error) {
+	if m.GetUserListNoModelFunc == nil {
+		panic("MockQuerier.GetUserListNoModelFunc is not set")
+	}
+	return m.GetUserListNoModelFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserEmailsNoModelScan(ctx context.Context, opts ...Option) (*GetUserEmailsNoModelResult, error) {
+	if m.GetUserEmailsNoModelScanFunc == nil {
+		panic("MockQuerier.GetUserEmailsNoModelScanFunc is not set")
+	}
+	return m.GetUserEmailsNoModelScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserEmailsNoModel(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	if m.AppendGetUserEmailsNoModelFunc == nil {
+		panic("MockQuerier.AppendGetUserEmailsNoModelFunc is not set")
+	}
+	return m.AppendGetUserEmailsNoModelFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserEmailsNoModel(ctx context.Context, opts ...Option) ([]string, error) {
+	if m.GetUserEmailsNoModelFunc == nil {
+		panic("MockQuerier.GetUserEmailsNoModelFunc is not set")
+	}
+	return m.GetUserEmailsNoModelFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserListLimitedScan(ctx context.Context, opts ...Option) (*GetUserListLimitedResult, error) {
+	if m.GetUserListLimitedScanFunc == nil {
+		panic("MockQuerier.GetUserListLimitedScanFunc is not set")
+	}
+	return m.GetUserListLimitedScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserListLimited(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	if m.AppendGetUserListLimitedFunc == nil {
+		panic("MockQuerier.AppendGetUserListLimitedFunc is not set")
+	}
+	return m.AppendGetUserListLimitedFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserListLimited(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	if m.GetUserListLimitedFunc == nil {
+		panic("MockQuerier.GetUserListLimitedFunc is not set")
+	}
+	return m.GetUserListLimitedFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserListPagedScan(ctx context.Context, opts ...Option) (*GetUserListPagedResult, error) {
+	if m.GetUserListPagedScanFunc == nil {
+		panic("MockQuerier.GetUserListPagedScanFunc is not set")
+	}
+	return m.GetUserListPagedScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserListPaged(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	if m.AppendGetUserListPagedFunc == nil {
+		panic("MockQuerier.AppendGetUserListPagedFunc is not set")
+	}
+	return m.AppendGetUserListPagedFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserListPaged(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	if m.GetUserListPagedFunc == nil {
+		panic("MockQuerier.GetUserListPagedFunc is not set")
+	}
+	return m.GetUserListPagedFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserRowsScan(ctx context.Context, opts ...Option) (*GetUserRowsResult, error) {
+	if m.GetUserRowsScanFunc == nil {
+		panic("MockQuerier.GetUserRowsScanFunc is not set")
+	}
+	return m.GetUserRowsScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserRows(ctx context.Context, dst []GetUserRowsOutput, opts ...Option) ([]GetUserRowsOutput, error) {
+	if m.AppendGetUserRowsFunc == nil {
+		panic("MockQuerier.AppendGetUserRowsFunc is not set")
+	}
+	return m.AppendGetUserRowsFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserRows(ctx context.Context, opts ...Option) ([]GetUserRowsOutput, error) {
+	if m.GetUserRowsFunc == nil {
+		panic("MockQuerier.GetUserRowsFunc is not set")
+	}
+	return m.GetUserRowsFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserListWithModelScan(ctx context.Context, opts ...Option) (*GetUserListWithModelResult, error) {
+	if m.GetUserListWithModelScanFunc == nil {
+		panic("MockQuerier.GetUserListWithModelScanFunc is not set")
+	}
+	return m.GetUserListWithModelScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserListWithModel(ctx context.Context, dst []User, opts ...Option) ([]User, error) {
+	if m.AppendGetUserListWithModelFunc == nil {
+		panic("MockQuerier.AppendGetUserListWithModelFunc is not set")
+	}
+	return m.AppendGetUserListWithModelFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserListWithModel(ctx context.Context, opts ...Option) ([]User, error) {
+	if m.GetUserListWithModelFunc == nil {
+		panic("MockQuerier.GetUserListWithModelFunc is not set")
+	}
+	return m.GetUserListWithModelFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) CompareGetUserListWithModel(ctx context.Context, other *Norm) (Diff, error) {
+	if m.CompareGetUserListWithModelFunc == nil {
+		panic("MockQuerier.CompareGetUserListWithModelFunc is not set")
+	}
+	return m.CompareGetUserListWithModelFunc(ctx, other)
+}
+
+func (m *MockQuerier) AddUser(ctx context.Context, email string, opts ...Option) error {
+	if m.AddUserFunc == nil {
+		panic("MockQuerier.AddUserFunc is not set")
+	}
+	return m.AddUserFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) AddUsers(ctx context.Context, rows []AddUsersRow, opts ...Option) error {
+	if m.AddUsersFunc == nil {
+		panic("MockQuerier.AddUsersFunc is not set")
+	}
+	return m.AddUsersFunc(ctx, rows, opts...)
+}
+
+func (m *MockQuerier) CopyUsers(ctx context.Context, rows []CopyUsersRow, opts ...Option) error {
+	if m.CopyUsersFunc == nil {
+		panic("MockQuerier.CopyUsersFunc is not set")
+	}
+	return m.CopyUsersFunc(ctx, rows, opts...)
+}
+
+func (m *MockQuerier) CopyUsersFrom(ctx context.Context, next func() (CopyUsersRow, bool, error), opts ...Option) error {
+	if m.CopyUsersFromFunc == nil {
+		panic("MockQuerier.CopyUsersFromFunc is not set")
+	}
+	return m.CopyUsersFromFunc(ctx, next, opts...)
+}
+
+func (m *MockQuerier) DeleteAllUsers(ctx context.Context, opts ...Option) error {
+	if m.DeleteAllUsersFunc == nil {
+		panic("MockQuerier.DeleteAllUsersFunc is not set")
+	}
+	return m.DeleteAllUsersFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AddUserResult(ctx context.Context, email string, opts ...Option) (sql.Result, error) {
+	if m.AddUserResultFunc == nil {
+		panic("MockQuerier.AddUserResultFunc is not set")
+	}
+	return m.AddUserResultFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) AddUserReturningInto(ctx context.Context, dst *User, email string, opts ...Option) error {
+	if m.AddUserReturningIntoFunc == nil {
+		panic("MockQuerier.AddUserReturningIntoFunc is not set")
+	}
+	return m.AddUserReturningIntoFunc(ctx, dst, email, opts...)
+}
+
+func (m *MockQuerier) AddUserReturning(ctx context.Context, email string, opts ...Option) (*User, error) {
+	if m.AddUserReturningFunc == nil {
+		panic("MockQuerier.AddUserReturningFunc is not set")
+	}
+	return m.AddUserReturningFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) DeleteUser(ctx context.Context, email string, opts ...Option) (int64, error) {
+	if m.DeleteUserFunc == nil {
+		panic("MockQuerier.DeleteUserFunc is not set")
+	}
+	return m.DeleteUserFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) FindUserInto(ctx context.Context, dst *FindUserOutput, email string, opts ...Option) error {
+	if m.FindUserIntoFunc == nil {
+		panic("MockQuerier.FindUserIntoFunc is not set")
+	}
+	return m.FindUserIntoFunc(ctx, dst, email, opts...)
+}
+
+func (m *MockQuerier) FindUser(ctx context.Context, email string, opts ...Option) (*FindUserOutput, error) {
+	if m.FindUserFunc == nil {
+		panic("MockQuerier.FindUserFunc is not set")
+	}
+	return m.FindUserFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) CompareFindUser(ctx context.Context, other *Norm, email string) (Diff, error) {
+	if m.CompareFindUserFunc == nil {
+		panic("MockQuerier.CompareFindUserFunc is not set")
+	}
+	return m.CompareFindUserFunc(ctx, other, email)
+}
+
+func (m *MockQuerier) FindUserWithModelInto(ctx context.Context, dst *User, email string, opts ...Option) error {
+	if m.FindUserWithModelIntoFunc == nil {
+		panic("MockQuerier.FindUserWithModelIntoFunc is not set")
+	}
+	return m.FindUserWithModelIntoFunc(ctx, dst, email, opts...)
+}
+
+func (m *MockQuerier) FindUserWithModel(ctx context.Context, email string, opts ...Option) (*User, error) {
+	if m.FindUserWithModelFunc == nil {
+		panic("MockQuerier.FindUserWithModelFunc is not set")
+	}
+	return m.FindUserWithModelFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) FindUserSwappedColumnsInto(ctx context.Context, dst *FindUserSwappedColumnsOutput, email string, opts ...Option) error {
+	if m.FindUserSwappedColumnsIntoFunc == nil {
+		panic("MockQuerier.FindUserSwappedColumnsIntoFunc is not set")
+	}
+	return m.FindUserSwappedColumnsIntoFunc(ctx, dst, email, opts...)
+}
+
+func (m *MockQuerier) FindUserSwappedColumns(ctx context.Context, email string, opts ...Option) (*FindUserSwappedColumnsOutput, error) {
+	if m.FindUserSwappedColumnsFunc == nil {
+		panic("MockQuerier.FindUserSwappedColumnsFunc is not set")
+	}
+	return m.FindUserSwappedColumnsFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) FindUserByEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error {
+	if m.FindUserByEmailIntoFunc == nil {
+		panic("MockQuerier.FindUserByEmailIntoFunc is not set")
+	}
+	return m.FindUserByEmailIntoFunc(ctx, dst, email, opts...)
+}
+
+func (m *MockQuerier) FindUserByEmail(ctx context.Context, email string, opts ...Option) (*string, error) {
+	if m.FindUserByEmailFunc == nil {
+		panic("MockQuerier.FindUserByEmailFunc is not set")
+	}
+	return m.FindUserByEmailFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) FindUsersNamedScan(ctx context.Context, email string, domain string, opts ...Option) (*FindUsersNamedResult, error) {
+	if m.FindUsersNamedScanFunc == nil {
+		panic("MockQuerier.FindUsersNamedScanFunc is not set")
+	}
+	return m.FindUsersNamedScanFunc(ctx, email, domain, opts...)
+}
+
+func (m *MockQuerier) AppendFindUsersNamed(ctx context.Context, dst []int, email string, domain string, opts ...Option) ([]int, error) {
+	if m.AppendFindUsersNamedFunc == nil {
+		panic("MockQuerier.AppendFindUsersNamedFunc is not set")
+	}
+	return m.AppendFindUsersNamedFunc(ctx, dst, email, domain, opts...)
+}
+
+func (m *MockQuerier) FindUsersNamed(ctx context.Context, email string, domain string, opts ...Option) ([]int, error) {
+	if m.FindUsersNamedFunc == nil {
+		panic("MockQuerier.FindUsersNamedFunc is not set")
+	}
+	return m.FindUsersNamedFunc(ctx, email, domain, opts...)
+}
+
+func (m *MockQuerier) FindUserEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error {
+	if m.FindUserEmailIntoFunc == nil {
+		panic("MockQuerier.FindUserEmailIntoFunc is not set")
+	}
+	return m.FindUserEmailIntoFunc(ctx, dst, email, opts...)
+}
+
+func (m *MockQuerier) FindUserEmail(ctx context.Context, email string, opts ...Option) (*string, error) {
+	if m.FindUserEmailFunc == nil {
+		panic("MockQuerier.FindUserEmailFunc is not set")
+	}
+	return m.FindUserEmailFunc(ctx, email, opts...)
+}
+
+func (m *MockQuerier) FindUserByIDInto(ctx context.Context, dst *FindUserByIDOutput, id UserID, opts ...Option) error {
+	if m.FindUserByIDIntoFunc == nil {
+		panic("MockQuerier.FindUserByIDIntoFunc is not set")
+	}
+	return m.FindUserByIDIntoFunc(ctx, dst, id, opts...)
+}
+
+func (m *MockQuerier) FindUserByID(ctx context.Context, id UserID, opts ...Option) (*FindUserByIDOutput, error) {
+	if m.FindUserByIDFunc == nil {
+		panic("MockQuerier.FindUserByIDFunc is not set")
+	}
+	return m.FindUserByIDFunc(ctx, id, opts...)
+}
+
+func (m *MockQuerier) FindUserEmailOrEmptyInto(ctx context.Context, dst *string, id UserID, opts ...Option) error {
+	if m.FindUserEmailOrEmptyIntoFunc == nil {
+		panic("MockQuerier.FindUserEmailOrEmptyIntoFunc is not set")
+	}
+	return m.FindUserEmailOrEmptyIntoFunc(ctx, dst, id, opts...)
+}
+
+func (m *MockQuerier) FindUserEmailOrEmpty(ctx context.Context, id UserID, opts ...Option) (*string, error) {
+	if m.FindUserEmailOrEmptyFunc == nil {
+		panic("MockQuerier.FindUserEmailOrEmptyFunc is not set")
+	}
+	return m.FindUserEmailOrEmptyFunc(ctx, id, opts...)
+}
+
+func (m *MockQuerier) GetUserEmailsOrEmptyScan(ctx context.Context, opts ...Option) (*GetUserEmailsOrEmptyResult, error) {
+	if m.GetUserEmailsOrEmptyScanFunc == nil {
+		panic("MockQuerier.GetUserEmailsOrEmptyScanFunc is not set")
+	}
+	return m.GetUserEmailsOrEmptyScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserEmailsOrEmpty(ctx context.Context, dst []GetUserEmailsOrEmptyOutput, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error) {
+	if m.AppendGetUserEmailsOrEmptyFunc == nil {
+		panic("MockQuerier.AppendGetUserEmailsOrEmptyFunc is not set")
+	}
+	return m.AppendGetUserEmailsOrEmptyFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserEmailsOrEmpty(ctx context.Context, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error) {
+	if m.GetUserEmailsOrEmptyFunc == nil {
+		panic("MockQuerier.GetUserEmailsOrEmptyFunc is not set")
+	}
+	return m.GetUserEmailsOrEmptyFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserAccountsScan(ctx context.Context, opts ...Option) (*GetUserAccountsResult, error) {
+	if m.GetUserAccountsScanFunc == nil {
+		panic("MockQuerier.GetUserAccountsScanFunc is not set")
+	}
+	return m.GetUserAccountsScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserAccounts(ctx context.Context, dst []UserAccount, opts ...Option) ([]UserAccount, error) {
+	if m.AppendGetUserAccountsFunc == nil {
+		panic("MockQuerier.AppendGetUserAccountsFunc is not set")
+	}
+	return m.AppendGetUserAccountsFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserAccounts(ctx context.Context, opts ...Option) ([]UserAccount, error) {
+	if m.GetUserAccountsFunc == nil {
+		panic("MockQuerier.GetUserAccountsFunc is not set")
+	}
+	return m.GetUserAccountsFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserContactsScan(ctx context.Context, opts ...Option) (*GetUserContactsResult, error) {
+	if m.GetUserContactsScanFunc == nil {
+		panic("MockQuerier.GetUserContactsScanFunc is not set")
+	}
+	return m.GetUserContactsScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserContacts(ctx context.Context, dst []UserContact, opts ...Option) ([]UserContact, error) {
+	if m.AppendGetUserContactsFunc == nil {
+		panic("MockQuerier.AppendGetUserContactsFunc is not set")
+	}
+	return m.AppendGetUserContactsFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetUserContacts(ctx context.Context, opts ...Option) ([]UserContact, error) {
+	if m.GetUserContactsFunc == nil {
+		panic("MockQuerier.GetUserContactsFunc is not set")
+	}
+	return m.GetUserContactsFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) FindUserEmailsByIDsScan(ctx context.Context, ids []UserID, domain string, opts ...Option) (*FindUserEmailsByIDsResult, error) {
+	if m.FindUserEmailsByIDsScanFunc == nil {
+		panic("MockQuerier.FindUserEmailsByIDsScanFunc is not set")
+	}
+	return m.FindUserEmailsByIDsScanFunc(ctx, ids, domain, opts...)
+}
+
+func (m *MockQuerier) AppendFindUserEmailsByIDs(ctx context.Context, dst []string, ids []UserID, domain string, opts ...Option) ([]string, error) {
+	if m.AppendFindUserEmailsByIDsFunc == nil {
+		panic("MockQuerier.AppendFindUserEmailsByIDsFunc is not set")
+	}
+	return m.AppendFindUserEmailsByIDsFunc(ctx, dst, ids, domain, opts...)
+}
+
+func (m *MockQuerier) FindUserEmailsByIDs(ctx context.Context, ids []UserID, domain string, opts ...Option) ([]string, error) {
+	if m.FindUserEmailsByIDsFunc == nil {
+		panic("MockQuerier.FindUserEmailsByIDsFunc is not set")
+	}
+	return m.FindUserEmailsByIDsFunc(ctx, ids, domain, opts...)
+}
+
+func (m *MockQuerier) GetTenantUserEmailsScan(ctx context.Context, opts ...Option) (*GetTenantUserEmailsResult, error) {
+	if m.GetTenantUserEmailsScanFunc == nil {
+		panic("MockQuerier.GetTenantUserEmailsScanFunc is not set")
+	}
+	return m.GetTenantUserEmailsScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetTenantUserEmails(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	if m.AppendGetTenantUserEmailsFunc == nil {
+		panic("MockQuerier.AppendGetTenantUserEmailsFunc is not set")
+	}
+	return m.AppendGetTenantUserEmailsFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetTenantUserEmails(ctx context.Context, opts ...Option) ([]string, error) {
+	if m.GetTenantUserEmailsFunc == nil {
+		panic("MockQuerier.GetTenantUserEmailsFunc is not set")
+	}
+	return m.GetTenantUserEmailsFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) FindUserEmailsExceptScan(ctx context.Context, ids []UserID, opts ...Option) (*FindUserEmailsExceptResult, error) {
+	if m.FindUserEmailsExceptScanFunc == nil {
+		panic("MockQuerier.FindUserEmailsExceptScanFunc is not set")
+	}
+	return m.FindUserEmailsExceptScanFunc(ctx, ids, opts...)
+}
+
+func (m *MockQuerier) AppendFindUserEmailsExcept(ctx context.Context, dst []string, ids []UserID, opts ...Option) ([]string, error) {
+	if m.AppendFindUserEmailsExceptFunc == nil {
+		panic("MockQuerier.AppendFindUserEmailsExceptFunc is not set")
+	}
+	return m.AppendFindUserEmailsExceptFunc(ctx, dst, ids, opts...)
+}
+
+func (m *MockQuerier) FindUserEmailsExcept(ctx context.Context, ids []UserID, opts ...Option) ([]string, error) {
+	if m.FindUserEmailsExceptFunc == nil {
+		panic("MockQuerier.FindUserEmailsExceptFunc is not set")
+	}
+	return m.FindUserEmailsExceptFunc(ctx, ids, opts...)
+}
+
+func (m *MockQuerier) CreateUserTable(ctx context.Context, opts ...Option) error {
+	if m.CreateUserTableFunc == nil {
+		panic("MockQuerier.CreateUserTableFunc is not set")
+	}
+	return m.CreateUserTableFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) CreateAuditEventTable(ctx context.Context, opts ...Option) error {
+	if m.CreateAuditEventTableFunc == nil {
+		panic("MockQuerier.CreateAuditEventTableFunc is not set")
+	}
+	return m.CreateAuditEventTableFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AddAuditEvent(ctx context.Context, msg string, opts ...Option) error {
+	if m.AddAuditEventFunc == nil {
+		panic("MockQuerier.AddAuditEventFunc is not set")
+	}
+	return m.AddAuditEventFunc(ctx, msg, opts...)
+}
+
+func (m *MockQuerier) GetAuditEventsScan(ctx context.Context, opts ...Option) (*GetAuditEventsResult, error) {
+	if m.GetAuditEventsScanFunc == nil {
+		panic("MockQuerier.GetAuditEventsScanFunc is not set")
+	}
+	return m.GetAuditEventsScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendGetAuditEvents(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	if m.AppendGetAuditEventsFunc == nil {
+		panic("MockQuerier.AppendGetAuditEventsFunc is not set")
+	}
+	return m.AppendGetAuditEventsFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) GetAuditEvents(ctx context.Context, opts ...Option) ([]string, error) {
+	if m.GetAuditEventsFunc == nil {
+		panic("MockQuerier.GetAuditEventsFunc is not set")
+	}
+	return m.GetAuditEventsFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) CreateMembershipTable(ctx context.Context, opts ...Option) error {
+	if m.CreateMembershipTableFunc == nil {
+		panic("MockQuerier.CreateMembershipTableFunc is not set")
+	}
+	return m.CreateMembershipTableFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AddMembership(ctx context.Context, key MembershipKey, role string, opts ...Option) error {
+	if m.AddMembershipFunc == nil {
+		panic("MockQuerier.AddMembershipFunc is not set")
+	}
+	return m.AddMembershipFunc(ctx, key, role, opts...)
+}
+
+func (m *MockQuerier) FindMembershipRoleInto(ctx context.Context, dst *string, key MembershipKey, opts ...Option) error {
+	if m.FindMembershipRoleIntoFunc == nil {
+		panic("MockQuerier.FindMembershipRoleIntoFunc is not set")
+	}
+	return m.FindMembershipRoleIntoFunc(ctx, dst, key, opts...)
+}
+
+func (m *MockQuerier) FindMembershipRole(ctx context.Context, key MembershipKey, opts ...Option) (*string, error) {
+	if m.FindMembershipRoleFunc == nil {
+		panic("MockQuerier.FindMembershipRoleFunc is not set")
+	}
+	return m.FindMembershipRoleFunc(ctx, key, opts...)
+}
+
+func (m *MockQuerier) DeleteMembership(ctx context.Context, key MembershipKey, opts ...Option) (int64, error) {
+	if m.DeleteMembershipFunc == nil {
+		panic("MockQuerier.DeleteMembershipFunc is not set")
+	}
+	return m.DeleteMembershipFunc(ctx, key, opts...)
+}
+
+func (m *MockQuerier) CreateUserDomainView(ctx context.Context) error {
+	if m.CreateUserDomainViewFunc == nil {
+		panic("MockQuerier.CreateUserDomainViewFunc is not set")
+	}
+	return m.CreateUserDomainViewFunc(ctx)
+}
+
+func (m *MockQuerier) ListUserDomainScan(ctx context.Context, opts ...Option) (*ListUserDomainResult, error) {
+	if m.ListUserDomainScanFunc == nil {
+		panic("MockQuerier.ListUserDomainScanFunc is not set")
+	}
+	return m.ListUserDomainScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendListUserDomain(ctx context.Context, dst []UserDomain, opts ...Option) ([]UserDomain, error) {
+	if m.AppendListUserDomainFunc == nil {
+		panic("MockQuerier.AppendListUserDomainFunc is not set")
+	}
+	return m.AppendListUserDomainFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) ListUserDomain(ctx context.Context, opts ...Option) ([]UserDomain, error) {
+	if m.ListUserDomainFunc == nil {
+		panic("MockQuerier.ListUserDomainFunc is not set")
+	}
+	return m.ListUserDomainFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) GetUserDomainsByDomainScan(ctx context.Context, domain string, opts ...Option) (*GetUserDomainsByDomainResult, error) {
+	if m.GetUserDomainsByDomainScanFunc == nil {
+		panic("MockQuerier.GetUserDomainsByDomainScanFunc is not set")
+	}
+	return m.GetUserDomainsByDomainScanFunc(ctx, domain, opts...)
+}
+
+func (m *MockQuerier) AppendGetUserDomainsByDomain(ctx context.Context, dst []UserDomain, domain string, opts ...Option) ([]UserDomain, error) {
+	if m.AppendGetUserDomainsByDomainFunc == nil {
+		panic("MockQuerier.AppendGetUserDomainsByDomainFunc is not set")
+	}
+	return m.AppendGetUserDomainsByDomainFunc(ctx, dst, domain, opts...)
+}
+
+func (m *MockQuerier) GetUserDomainsByDomain(ctx context.Context, domain string, opts ...Option) ([]UserDomain, error) {
+	if m.GetUserDomainsByDomainFunc == nil {
+		panic("MockQuerier.GetUserDomainsByDomainFunc is not set")
+	}
+	return m.GetUserDomainsByDomainFunc(ctx, domain, opts...)
+}
+
+func (m *MockQuerier) FindUserAsOfInto(ctx context.Context, dst *FindUserAsOfOutput, email string, asOf time.Time, opts ...Option) error {
+	if m.FindUserAsOfIntoFunc == nil {
+		panic("MockQuerier.FindUserAsOfIntoFunc is not set")
+	}
+	return m.FindUserAsOfIntoFunc(ctx, dst, email, asOf, opts...)
+}
+
+func (m *MockQuerier) FindUserAsOf(ctx context.Context, email string, asOf time.Time, opts ...Option) (*FindUserAsOfOutput, error) {
+	if m.FindUserAsOfFunc == nil {
+		panic("MockQuerier.FindUserAsOfFunc is not set")
+	}
+	return m.FindUserAsOfFunc(ctx, email, asOf, opts...)
+}
+
+func (m *MockQuerier) AddLogin(ctx context.Context, userID int, at time.Time, opts ...Option) error {
+	if m.AddLoginFunc == nil {
+		panic("MockQuerier.AddLoginFunc is not set")
+	}
+	return m.AddLoginFunc(ctx, userID, at, opts...)
+}
+
+func (m *MockQuerier) CreateUserSecretTable(ctx context.Context, opts ...Option) error {
+	if m.CreateUserSecretTableFunc == nil {
+		panic("MockQuerier.CreateUserSecretTableFunc is not set")
+	}
+	return m.CreateUserSecretTableFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) SetUserSSN(ctx context.Context, userID int, ssn string, opts ...Option) error {
+	if m.SetUserSSNFunc == nil {
+		panic("MockQuerier.SetUserSSNFunc is not set")
+	}
+	return m.SetUserSSNFunc(ctx, userID, ssn, opts...)
+}
+
+func (m *MockQuerier) GetUserSSNInto(ctx context.Context, dst *string, userID int, opts ...Option) error {
+	if m.GetUserSSNIntoFunc == nil {
+		panic("MockQuerier.GetUserSSNIntoFunc is not set")
+	}
+	return m.GetUserSSNIntoFunc(ctx, dst, userID, opts...)
+}
+
+func (m *MockQuerier) GetUserSSN(ctx context.Context, userID int, opts ...Option) (*string, error) {
+	if m.GetUserSSNFunc == nil {
+		panic("MockQuerier.GetUserSSNFunc is not set")
+	}
+	return m.GetUserSSNFunc(ctx, userID, opts...)
+}
+
+func (m *MockQuerier) GetUserByIDInto(ctx context.Context, dst *GetUserByIDOutput, id int, opts ...Option) error {
+	if m.GetUserByIDIntoFunc == nil {
+		panic("MockQuerier.GetUserByIDIntoFunc is not set")
+	}
+	return m.GetUserByIDIntoFunc(ctx, dst, id, opts...)
+}
+
+func (m *MockQuerier) GetUserByID(ctx context.Context, id int, opts ...Option) (*GetUserByIDOutput, error) {
+	if m.GetUserByIDFunc == nil {
+		panic("MockQuerier.GetUserByIDFunc is not set")
+	}
+	return m.GetUserByIDFunc(ctx, id, opts...)
+}
+
+func (m *MockQuerier) UpdateUserEmail(ctx context.Context, email string, id int, opts ...Option) error {
+	if m.UpdateUserEmailFunc == nil {
+		panic("MockQuerier.UpdateUserEmailFunc is not set")
+	}
+	return m.UpdateUserEmailFunc(ctx, email, id, opts...)
+}
+
+func (m *MockQuerier) UpdateUserEmailIfMatch(ctx context.Context, email string, id int, etag string) error {
+	if m.UpdateUserEmailIfMatchFunc == nil {
+		panic("MockQuerier.UpdateUserEmailIfMatchFunc is not set")
+	}
+	return m.UpdateUserEmailIfMatchFunc(ctx, email, id, etag)
+}
+
+func (m *MockQuerier) CountUsersByDomainScan(ctx context.Context, opts ...Option) (*CountUsersByDomainResult, error) {
+	if m.CountUsersByDomainScanFunc == nil {
+		panic("MockQuerier.CountUsersByDomainScanFunc is not set")
+	}
+	return m.CountUsersByDomainScanFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) AppendCountUsersByDomain(ctx context.Context, dst []CountUsersByDomainOutput, opts ...Option) ([]CountUsersByDomainOutput, error) {
+	if m.AppendCountUsersByDomainFunc == nil {
+		panic("MockQuerier.AppendCountUsersByDomainFunc is not set")
+	}
+	return m.AppendCountUsersByDomainFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) CountUsersByDomain(ctx context.Context, opts ...Option) ([]CountUsersByDomainOutput, error) {
+	if m.CountUsersByDomainFunc == nil {
+		panic("MockQuerier.CountUsersByDomainFunc is not set")
+	}
+	return m.CountUsersByDomainFunc(ctx, opts...)
+}
+
+func (m *MockQuerier) Maintain(ctx context.Context, dryRun bool) error {
+	if m.MaintainFunc == nil {
+		panic("MockQuerier.MaintainFunc is not set")
+	}
+	return m.MaintainFunc(ctx, dryRun)
+}
+
+func (m *MockQuerier) CountUsersInto(ctx context.Context, dst *int, opts ...Option) error {
+	if m.CountUsersIntoFunc == nil {
+		panic("MockQuerier.CountUsersIntoFunc is not set")
+	}
+	return m.CountUsersIntoFunc(ctx, dst, opts...)
+}
+
+func (m *MockQuerier) CountUsers(ctx context.Context, opts ...Option) (*int, error) {
+	if m.CountUsersFunc == nil {
+		panic("MockQuerier.CountUsersFunc is not set")
+	}
+	return m.CountUsersFunc(ctx, opts...)
+}
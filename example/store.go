@@ -1,266 +1,5289 @@
 // Code generated by norm. DO NOT EDIT.
-// Generated on: 2020-12-26 18:50:49.673523925 -0800 PST m=+0.002477716
+// Content hash: sha256:ac25f22909af6cf72d8a2fc810ca4412ebd18a18de1fcd2e8d301d806bdd01b8
 package example
 
 import (
+	"container/list"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type GetUserListNoModelResult struct {
-	stmt *sql.Stmt
-	rows *sql.Rows
+// DBTX is implemented by both *sql.DB and *sql.Tx, so that the queries of a
+// Norm can run inside a transaction.
+type DBTX interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
-func (res GetUserListNoModelResult) Next() bool {
-	return res.rows.Next()
+// Norm runs the queries of the norm file against a database, or inside a
+// transaction, see WithTx.
+type Norm struct {
+	db    DBTX
+	stmts *stmtCache
+	// replica runs the reads, see WithReplica.
+	replica    *Norm
+	middleware []Middleware
 }
 
-func (res GetUserListNoModelResult) Scan(ID *int, Email *string) error {
-	return res.rows.Scan(ID, Email)
+// NewNorm opens the database with Open and returns a Norm using it.
+func NewNorm(driverName, dataSourceName string) (*Norm, error) {
+	db, err := Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &Norm{db: db, stmts: newStmtCache(db)}, nil
 }
 
-func (res GetUserListNoModelResult) Close() {
-	if res.rows != nil {
-		res.rows.Close()
-	}
-	if res.stmt != nil {
-		res.stmt.Close()
-	}
+// NewNormFromDB returns a Norm using a database handle opened elsewhere, for
+// example with custom pool settings. Closing the Norm closes db.
+func NewNormFromDB(db *sql.DB) *Norm {
+	return &Norm{db: db, stmts: newStmtCache(db)}
 }
 
-// Retrieves all emails from the users table. Since there is no// intermediate model, an output struct is autocreated which will contain only// the fields specified in the output. Please make sure that the field names// are capitalized.
-func GetUserListNoModelScan(db *sql.DB) (*GetUserListNoModelResult, error) {
-	result := GetUserListNoModelResult{}
-	var err error
-	result.stmt, err = db.Prepare(`SELECT id, email
-FROM user
-ORDER BY email ASC`)
-	if err != nil {
-		return nil, err
+// WithTx returns a Norm running its queries inside tx. Committing or rolling
+// back tx is up to the caller.
+func (n *Norm) WithTx(tx *sql.Tx) *Norm {
+	return n.withDB(tx)
+}
+
+// withDB returns a Norm running the queries of n on db, with the same
+// statements and middleware.
+func (n *Norm) withDB(db DBTX) *Norm {
+	return &Norm{db: db, stmts: n.stmts, middleware: n.middleware}
+}
+
+// NormTx is a transaction started with Begin. It has the query methods of
+// Norm, which run inside the transaction.
+type NormTx struct {
+	*Norm
+	tx *sql.Tx
+}
+
+// Begin starts a transaction. It returns an error for a Norm that already runs
+// inside a transaction.
+func (n *Norm) Begin(ctx context.Context) (*NormTx, error) {
+	db, ok := n.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("norm: Begin called inside a transaction")
 	}
-	result.rows, err = result.stmt.Query()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		defer result.stmt.Close()
 		return nil, err
 	}
-	return &result, nil
+	return &NormTx{Norm: n.withDB(tx), tx: tx}, nil
 }
 
-type GetUserListNoModelOutput struct {
-	ID    int
-	Email string
+// Commit commits the transaction.
+func (t *NormTx) Commit() error {
+	return t.tx.Commit()
 }
 
-func GetUserListNoModel(db *sql.DB) ([]GetUserListNoModelOutput, error) {
-	res, err := GetUserListNoModelScan(db)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Close()
-	var ret []GetUserListNoModelOutput
-	for res.Next() {
-		var o GetUserListNoModelOutput
-		if err := res.Scan(&o.ID, &o.Email); err != nil {
-			return ret, err
+// Rollback aborts the transaction.
+func (t *NormTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// normContextKey is the key of the Norm carried by a context, see NewContext.
+type normContextKey struct{}
+
+// NewContext returns a copy of ctx carrying n, for middleware handing a
+// request scoped Norm, like one running inside the transaction of the
+// request, to the handlers, which get it back with FromContext.
+func NewContext(ctx context.Context, n *Norm) context.Context {
+	return context.WithValue(ctx, normContextKey{}, n)
+}
+
+// FromContext returns the Norm carried by ctx, and whether it carries one.
+func FromContext(ctx context.Context) (*Norm, bool) {
+	n, ok := ctx.Value(normContextKey{}).(*Norm)
+	return n, ok
+}
+
+// Close closes the prepared statements and the database of n. It does nothing
+// for a Norm returned by WithTx.
+func (n *Norm) Close() error {
+	if db, ok := n.db.(*sql.DB); ok {
+		if n.stmts != nil {
+			n.stmts.close()
 		}
-		ret = append(ret, o)
+		return db.Close()
 	}
-	return ret, nil
+	return nil
 }
 
-type GetUserEmailsNoModelResult struct {
-	stmt *sql.Stmt
-	rows *sql.Rows
+// StmtCacheSize is the number of prepared statements a Norm keeps, read when
+// the Norm is created. Preparing a query beyond it closes the least recently
+// used statement, so that long running services stay below the limit of
+// prepared statements of the server. 0 keeps every statement.
+var StmtCacheSize = 256
+
+// StmtStats are the statistics of the statement cache of a Norm, see Stats.
+type StmtStats struct {
+	// Cached is the number of statements in the cache.
+	Cached int
+	// Hits counts the statements found in the cache, and Misses those
+	// prepared for it.
+	Hits, Misses int64
+	// Evictions counts the statements closed to keep the cache within
+	// StmtCacheSize.
+	Evictions int64
+	// Executions counts the calls of every query, by the name of its
+	// function, including those not using the cache.
+	Executions map[string]int64
 }
 
-func (res GetUserEmailsNoModelResult) Next() bool {
-	return res.rows.Next()
+// stmtCache holds the statements prepared by a Norm and the Norms derived from
+// it, so that every query is prepared once per database instead of once per
+// call. The statements are closed by Close.
+type stmtCache struct {
+	db   *sql.DB
+	size int
+	mu   sync.Mutex
+	// stmts are the cached statements by query, and lru the same statements,
+	// the most recently used first.
+	stmts map[string]*cachedStmt
+	lru   *list.List
+	// preparing holds the queries being prepared, closing the channel once
+	// they are. Concurrent calls of the same query wait for it rather than
+	// preparing the query again.
+	preparing map[string]chan struct{}
+	hits      int64
+	misses    int64
+	evictions int64
+	calls     map[string]int64
 }
 
-func (res GetUserEmailsNoModelResult) Scan(Email *string) error {
-	return res.rows.Scan(Email)
+// cachedStmt is a statement of a stmtCache. It is closed once it is evicted
+// and no call uses it anymore.
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	elem    *list.Element
+	uses    int
+	evicted bool
 }
 
-func (res GetUserEmailsNoModelResult) Close() {
-	if res.rows != nil {
-		res.rows.Close()
-	}
-	if res.stmt != nil {
-		res.stmt.Close()
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{
+		db:        db,
+		size:      StmtCacheSize,
+		stmts:     map[string]*cachedStmt{},
+		lru:       list.New(),
+		preparing: map[string]chan struct{}{},
+		calls:     map[string]int64{},
 	}
 }
 
-// Retrieves all emails from the users table. In this example, there is// only one output field. Therefore an intermediate struct is also not needed,// we just return a slice of the output type (string in this case)
-func GetUserEmailsNoModelScan(db *sql.DB) (*GetUserEmailsNoModelResult, error) {
-	result := GetUserEmailsNoModelResult{}
-	var err error
-	result.stmt, err = db.Prepare(`SELECT email
-FROM user
-ORDER BY email ASC`)
-	if err != nil {
-		return nil, err
+// get returns the statement of query, preparing it on first use, and the
+// function to call once done with it.
+func (c *stmtCache) get(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	c.mu.Lock()
+	for {
+		if c.stmts == nil {
+			c.mu.Unlock()
+			return nil, nil, errors.New("norm: query on a closed Norm")
+		}
+		if cs, ok := c.stmts[query]; ok {
+			c.lru.MoveToFront(cs.elem)
+			c.hits++
+			cs.uses++
+			c.mu.Unlock()
+			return cs.stmt, func() { c.release(cs) }, nil
+		}
+		done, ok := c.preparing[query]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		c.mu.Lock()
 	}
-	result.rows, err = result.stmt.Query()
+	c.misses++
+	done := make(chan struct{})
+	c.preparing[query] = done
+	c.mu.Unlock()
+	stmt, err := c.db.PrepareContext(ctx, query)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.preparing, query)
+	close(done)
 	if err != nil {
-		defer result.stmt.Close()
-		return nil, err
+		return nil, nil, err
 	}
-	return &result, nil
+	if c.stmts == nil {
+		stmt.Close()
+		return nil, nil, errors.New("norm: query on a closed Norm")
+	}
+	cs := &cachedStmt{query: query, stmt: stmt, uses: 1}
+	cs.elem = c.lru.PushFront(cs)
+	c.stmts[query] = cs
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.evict(c.lru.Back())
+	}
+	return stmt, func() { c.release(cs) }, nil
 }
 
-func GetUserEmailsNoModel(db *sql.DB) ([]string, error) {
-	res, err := GetUserEmailsNoModelScan(db)
-	if err != nil {
-		return nil, err
+// evict removes the statement of elem from the cache, closing it unless a
+// call still uses it. c.mu must be held.
+func (c *stmtCache) evict(elem *list.Element) {
+	cs := c.lru.Remove(elem).(*cachedStmt)
+	delete(c.stmts, cs.query)
+	cs.evicted = true
+	c.evictions++
+	if cs.uses == 0 {
+		cs.stmt.Close()
 	}
-	defer res.Close()
-	var ret []string
-	for res.Next() {
-		var o string
-		if err := res.Scan(&o); err != nil {
-			return ret, err
-		}
-		ret = append(ret, o)
+}
+
+// release ends a use of cs returned by get.
+func (c *stmtCache) release(cs *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cs.uses--
+	if cs.evicted && cs.uses == 0 {
+		cs.stmt.Close()
 	}
-	return ret, nil
 }
 
-type GetUserListWithModelResult struct {
-	stmt *sql.Stmt
-	rows *sql.Rows
+// close closes the cached statements, or those still in use once they are
+// released. Later calls to get fail.
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cs := range c.stmts {
+		cs.evicted = true
+		if cs.uses == 0 {
+			cs.stmt.Close()
+		}
+	}
+	c.stmts = nil
+	c.lru.Init()
 }
 
-func (res GetUserListWithModelResult) Next() bool {
-	return res.rows.Next()
+// Stats returns the statistics of the statement cache of n, which is shared
+// with the Norms derived from it.
+func (n *Norm) Stats() StmtStats {
+	if n.stmts == nil {
+		return StmtStats{}
+	}
+	c := n.stmts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := StmtStats{
+		Cached:     len(c.stmts),
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+		Executions: make(map[string]int64, len(c.calls)),
+	}
+	for name, count := range c.calls {
+		stats.Executions[name] = count
+	}
+	return stats
 }
 
-func (res GetUserListWithModelResult) Scan(ID *int, Email *string) error {
-	return res.rows.Scan(ID, Email)
+// countCall counts a call of the query name in the Stats of n.
+func (n *Norm) countCall(name string) {
+	if n.stmts == nil {
+		return
+	}
+	n.stmts.mu.Lock()
+	n.stmts.calls[name]++
+	n.stmts.mu.Unlock()
 }
 
-func (res GetUserListWithModelResult) Close() {
-	if res.rows != nil {
-		res.rows.Close()
+// prepare returns the statement of query, and the function to call once done
+// with it. Only queries with reuse set are cached, as queries with expanded
+// slice inputs or read at a point in time differ from call to call. Inside a
+// transaction, the cached statement is rebound to the transaction.
+func (n *Norm) prepare(ctx context.Context, query string, reuse bool) (*sql.Stmt, func(), error) {
+	if !reuse || n.stmts == nil {
+		stmt, err := n.db.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stmt, func() { stmt.Close() }, nil
 	}
-	if res.stmt != nil {
-		res.stmt.Close()
+	stmt, release, err := n.stmts.get(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, ok := n.db.(*sql.Tx)
+	if !ok {
+		return stmt, release, nil
 	}
+	txStmt := tx.StmtContext(ctx, stmt)
+	return txStmt, func() {
+		txStmt.Close()
+		release()
+	}, nil
 }
 
-// Retrieves all emails from the users table. In this example, an// intermediate model is used. See `gen.go` for the model definition. This// allows users to specify an arbitrary intermediate struct.
-func GetUserListWithModelScan(db *sql.DB) (*GetUserListWithModelResult, error) {
-	result := GetUserListWithModelResult{}
-	var err error
-	result.stmt, err = db.Prepare(`SELECT id, email
-FROM user
-ORDER BY email ASC`)
-	if err != nil {
-		return nil, err
+// Option changes how a single call of a query method runs, see WithTimeout
+// and OnPrimary. The query methods of !read, !read_one, !exec, !exec_many and
+// !copy commands take options after their inputs.
+type Option func(*callOptions)
+
+// callOptions are the options of a call, set by its Options.
+type callOptions struct {
+	timeout   time.Duration
+	onPrimary bool
+}
+
+// WithTimeout cancels the call if it does not complete within d. For Scan
+// functions, d bounds the whole result, until it is closed.
+func WithTimeout(d time.Duration) Option {
+	return func(o *callOptions) {
+		o.timeout = d
 	}
-	result.rows, err = result.stmt.Query()
-	if err != nil {
-		defer result.stmt.Close()
-		return nil, err
+}
+
+// OnPrimary runs a read on the Norm it is called on instead of its replica,
+// see WithReplica, for example to read back a row written just before.
+func OnPrimary() Option {
+	return func(o *callOptions) {
+		o.onPrimary = true
 	}
-	return &result, nil
 }
 
-func GetUserListWithModel(db *sql.DB) ([]User, error) {
-	res, err := GetUserListWithModelScan(db)
-	if err != nil {
-		return nil, err
+func applyOptions(opts []Option) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
-	defer res.Close()
-	var ret []User
-	for res.Next() {
-		var o User
-		if err := res.Scan(&o.ID, &o.Email); err != nil {
-			return ret, err
-		}
-		ret = append(ret, o)
+	return o
+}
+
+// context returns ctx with the timeout of the call, if any, and the function
+// canceling it.
+func (o callOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
 	}
-	return ret, nil
+	return context.WithCancel(ctx)
 }
 
-// Add a user to the DB
-func AddUser(db *sql.DB, email string) error {
-	stmt, err := db.Prepare(`INSERT into user(email)
-VALUES ($1)`)
-	if err != nil {
-		return err
+// WithReplica returns a Norm running the reads of n on replica, unless they
+// are called with OnPrimary, and the other queries on n. The reads count in
+// the Stats of replica. Transactions, see WithTx and Begin, run all their
+// queries on n.
+func (n *Norm) WithReplica(replica *Norm) *Norm {
+	ret := *n
+	ret.replica = replica
+	return &ret
+}
+
+// reader returns the Norm to run a read called with o on.
+func (n *Norm) reader(o callOptions) *Norm {
+	if n.replica == nil || o.onPrimary {
+		return n
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(email)
-	if err != nil {
-		return err
+	return n.replica
+}
+
+// Call is a call of a query method of Norm, as seen by Middleware.
+type Call struct {
+	// Name is the name of the query, like the one of its method.
+	Name string
+	// Args are the inputs of the call, or the rows of !exec_many queries.
+	// The values of sensitive inputs are Redacted, see !sensitive. Changing
+	// them does not change the call.
+	Args []interface{}
+}
+
+// Redacted replaces the value of a sensitive input in Call.Args, so that
+// middleware logging or tracing calls cannot leak it.
+type Redacted struct{}
+
+func (Redacted) String() string {
+	return "[REDACTED]"
+}
+
+// Exec runs a call, see Middleware.
+type Exec func(ctx context.Context, call *Call) error
+
+// Middleware wraps the Exec running the calls of a Norm in another one, to
+// run code around every call, such as auth checks, rate limiting or fault
+// injection. The returned Exec fails the call by returning an error without
+// running next.
+type Middleware func(next Exec) Exec
+
+// Use returns a Norm running its calls through the middleware of n, then mw
+// in order, so that the first Middleware is the outermost. Transactions
+// started from it, see WithTx and Begin, keep the middleware.
+func (n *Norm) Use(mw ...Middleware) *Norm {
+	ret := *n
+	ret.middleware = append(append([]Middleware(nil), n.middleware...), mw...)
+	return &ret
+}
+
+// run runs call through the middleware of n, ending with fn.
+func (n *Norm) run(ctx context.Context, call *Call, fn func(ctx context.Context) error) error {
+	exec := Exec(func(ctx context.Context, call *Call) error {
+		return fn(ctx)
+	})
+	for ix := len(n.middleware) - 1; ix >= 0; ix-- {
+		exec = n.middleware[ix](exec)
+	}
+	return exec(ctx, call)
+}
+
+// bare returns n without its middleware, to run the query of a call once the
+// middleware let it through.
+func (n *Norm) bare() *Norm {
+	ret := *n
+	ret.middleware = nil
+	return &ret
+}
+
+// PrepareAll prepares the queries of the norm file, so that errors in them,
+// like references to missing tables or columns, are reported at startup
+// instead of on first use. The statements are kept for the later calls.
+// Bulk loads with COPY are not checked, as they are only prepared inside a
+// transaction, and neither are the queries with !no_prepare.
+func (n *Norm) PrepareAll(ctx context.Context) error {
+	for _, q := range []struct {
+		funcName, query string
+		reuse           bool
+	}{
+		{"GetUserListNoModel", `SELECT id, email
+FROM user
+ORDER BY email ASC`, true},
+		{"GetUserEmailsNoModel", `SELECT email
+FROM user
+ORDER BY email ASC`, true},
+		{"GetUserListLimited", `SELECT id, email
+FROM user
+ORDER BY id ASC`, true},
+		{"GetUserListPaged", `SELECT id, email
+FROM user
+ORDER BY id ASC
+LIMIT 100`, true},
+		{"GetUserRows", `SELECT id, email
+FROM user
+ORDER BY id ASC`, true},
+		{"GetUserListWithModel", `SELECT id, email
+FROM user
+ORDER BY email ASC`, true},
+		{"AddUser", `INSERT into user(email)
+VALUES ($1)`, true},
+		{"AddUsers", `INSERT INTO user (email)
+VALUES ($1)`, false},
+		{"DeleteAllUsers", `DELETE FROM user`, true},
+		{"AddUserResult", `INSERT into user(email)
+VALUES ($1)`, true},
+		{"AddUserReturning", `INSERT INTO user(email)
+VALUES ($1)
+RETURNING id, email`, true},
+		{"DeleteUser", `DELETE FROM user
+WHERE email = $1`, true},
+		{"FindUser", `SELECT id, email
+FROM USER
+WHERE email = $1`, true},
+		{"FindUserWithModel", `SELECT id, email
+FROM USER
+WHERE email = $1`, true},
+		{"FindUserSwappedColumns", `SELECT email, id
+FROM USER
+WHERE email = $1`, true},
+		{"FindUserByEmail", `SELECT email
+FROM USER
+WHERE email = $1`, true},
+		{"FindUserEmail", `SELECT email
+FROM USER
+WHERE email = $1`, true},
+		{"FindUserByID", `SELECT id, email
+FROM user
+WHERE id = $1`, true},
+		{"FindUserEmailOrEmpty", `SELECT email
+FROM user
+WHERE id = $1`, true},
+		{"GetUserEmailsOrEmpty", `SELECT id, email
+FROM user
+ORDER BY id ASC`, true},
+		{"GetUserAccounts", `SELECT id, email
+FROM user
+ORDER BY id ASC`, true},
+		{"GetUserContacts", `SELECT id, email
+FROM user
+ORDER BY id ASC`, true},
+		{"FindUserEmailsByIDs", `SELECT email
+FROM user
+WHERE id IN ($1) AND email LIKE '%@' || $2
+ORDER BY id ASC`, false},
+		{"GetTenantUserEmails", `SELECT email
+FROM user
+WHERE email LIKE '%@' || $1
+ORDER BY id ASC`, true},
+		{"FindUserEmailsExcept", `SELECT email
+FROM user
+WHERE user.id NOT IN ($1)
+ORDER BY id ASC`, false},
+		{"CreateUserTable", `CREATE TABLE user (
+	id integer primary key autoincrement,
+	email text
+)`, true},
+		{"CreateAuditEventTable", `CREATE TABLE IF NOT EXISTS audit.event (
+	id integer primary key autoincrement,
+	msg text
+)`, true},
+		{"AddAuditEvent", `INSERT INTO audit.event(msg)
+VALUES ($1)`, true},
+		{"GetAuditEvents", `SELECT msg
+FROM audit.event
+ORDER BY id ASC`, true},
+		{"CreateMembershipTable", `CREATE TABLE membership (
+	user_id integer NOT NULL,
+	group_name text NOT NULL,
+	role text NOT NULL,
+	PRIMARY KEY (user_id, group_name)
+)`, true},
+		{"AddMembership", `INSERT INTO membership (user_id, group_name, role)
+VALUES ($1, $2, $3)`, true},
+		{"FindMembershipRole", `SELECT role
+FROM membership
+WHERE user_id = $1 AND group_name = $2`, true},
+		{"DeleteMembership", `DELETE FROM membership
+WHERE user_id = $1 AND group_name = $2`, true},
+		{"ListUserDomain", `SELECT * FROM user_domain`, true},
+		{"GetUserDomainsByDomain", `SELECT id, domain
+FROM user_domain
+WHERE domain = $1
+ORDER BY id ASC`, true},
+		{"FindUserAsOf", `SELECT id, email
+FROM user
+WHERE email = $1`, true},
+		{"AddLogin", `INSERT INTO login (user_id, logged_in_at)
+VALUES ($1, $2)`, true},
+		{"CreateUserSecretTable", `CREATE TABLE user_secret (
+  user_id integer PRIMARY KEY,
+  ssn blob NOT NULL
+)`, true},
+		{"SetUserSSN", `INSERT OR REPLACE INTO user_secret (user_id, ssn)
+VALUES ($1, $2)`, true},
+		{"GetUserSSN", `SELECT ssn
+FROM user_secret
+WHERE user_id = $1`, true},
+		{"GetUserByID", `SELECT id, email
+FROM user
+WHERE id = $1`, true},
+		{"UpdateUserEmail", `UPDATE user
+SET email = $1
+WHERE id = $2`, true},
+		{"CountUsersByDomain", `WITH domains AS (
+  SELECT substr(email, instr(email, '@') + 1) AS domain
+  FROM user
+)
+
+SELECT domain, count(*) AS users
+FROM domains
+GROUP BY domain
+ORDER BY domain`, true},
+		{"CountUsers", `SELECT count(*) AS n
+FROM user`, true},
+	} {
+		_, release, err := n.prepare(ctx, q.query, q.reuse)
+		if err != nil {
+			return fmt.Errorf("%s: %v", q.funcName, err)
+		}
+		release()
 	}
 	return nil
 }
 
-// Deletes all users from the DB
-func DeleteAllUsers(db *sql.DB) error {
-	stmt, err := db.Prepare(`DELETE FROM user`)
-	if err != nil {
-		return err
+// WarmUpConns is the number of connections WarmUp opens at once when the Norm
+// uses a *sql.DB, leaving them in the pool for the first calls. Connections
+// over the idle limit of the pool, see sql.DB.SetMaxIdleConns, are closed
+// again.
+var WarmUpConns = 2
+
+// WarmUp readies the Norm before the program reports it is ready: it opens
+// WarmUpConns connections, prepares the queries with PrepareAll, and runs the
+// queries declared with !warmup, discarding their results, to fill the caches
+// of the database. The !warmup queries are counted as calls.
+func (n *Norm) WarmUp(ctx context.Context) error {
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		conns := make([]*sql.Conn, 0, WarmUpConns)
+		for len(conns) < WarmUpConns {
+			conn, err := sqlDB.Conn(ctx)
+			if err != nil {
+				for _, c := range conns {
+					c.Close()
+				}
+				return err
+			}
+			conns = append(conns, conn)
+		}
+		for _, c := range conns {
+			c.Close()
+		}
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec()
-	if err != nil {
+	if err := n.PrepareAll(ctx); err != nil {
 		return err
 	}
+	if _, err := n.GetUserListNoModel(ctx); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("GetUserListNoModel: %v", err)
+	}
 	return nil
 }
 
-type FindUserOutput struct {
-	ID    int
-	Email string
+// LargeResultThreshold is the number of rows above which the slice returning
+// functions report the result through OnLargeResult. Set to 0 to disable.
+var LargeResultThreshold = 1000
+
+// OnLargeResult is called when a slice returning function reads more than
+// LargeResultThreshold rows. By default it logs a suggestion to use the
+// streaming Scan variant instead.
+var OnLargeResult = func(funcName string, rows int) {
+	log.Printf("norm: %s returned %d rows (threshold %d), consider using %sScan", funcName, rows, LargeResultThreshold, funcName)
 }
 
-// Finds user by email
-func FindUser(db *sql.DB, email string) (*FindUserOutput, error) {
-	stmt, err := db.Prepare(`SELECT id, email
-FROM USER
-WHERE email = $1`)
+// connSetup holds the statements run on every new connection opened by Open.
+var connSetup = []string{
+	"PRAGMA foreign_keys=ON",
+	"PRAGMA busy_timeout=5000",
+	"CREATE TEMP TABLE IF NOT EXISTS session (k text, v text)",
+	"ATTACH DATABASE 'file:audit?mode=memory&cache=shared' AS audit",
+}
+
+// ApplicationName identifies the connections opened by Open to the database
+// server. It is set as application_name for the postgres and pgx drivers, and
+// as the program_name connection attribute for the mysql driver.
+var ApplicationName = "norm-example"
+
+// OnConnect, if set, is called for every new connection opened by Open, after
+// the statements in connSetup. Returning an error discards the connection.
+var OnConnect func(ctx context.Context, conn driver.Conn) error
+
+// Open opens a database like sql.Open, but runs the connection setup
+// statements of the norm file and OnConnect on every new connection of the
+// pool.
+func Open(driverName, dataSourceName string) (*sql.DB, error) {
+	if driverName == "mysql" && ApplicationName != "" {
+		dataSourceName = mysqlProgramName(dataSourceName, ApplicationName)
+	}
+	db, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
-	var o FindUserOutput
-	if err = stmt.QueryRow(email).Scan(&o.ID, &o.Email); err != nil {
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
 		return nil, err
 	}
-	return &o, nil
+	var connector driver.Connector = dsnConnector{drv, dataSourceName}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dataSourceName); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(setupConnector{connector, driverName == "postgres" || driverName == "pgx"}), nil
 }
 
-// Finds user by email.
-func FindUserEmail(db *sql.DB, email string) (*string, error) {
-	stmt, err := db.Prepare(`SELECT email
-FROM USER
-WHERE email = $1`)
+// mysqlProgramName adds the program_name connection attribute to a mysql DSN.
+func mysqlProgramName(dsn, name string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "connectionAttributes=program_name:" + name
+}
+
+// dsnConnector is the connector of drivers not implementing
+// driver.DriverContext.
+type dsnConnector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// setupConnector runs connSetup and OnConnect on the new connections of the
+// wrapped connector.
+type setupConnector struct {
+	driver.Connector
+	// postgres is set if ApplicationName is set with SET application_name.
+	postgres bool
+}
+
+func (c setupConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
-	var o string
-	if err = stmt.QueryRow(email).Scan(&o); err != nil {
-		return nil, err
+	if c.postgres && ApplicationName != "" {
+		query := "SET application_name = '" + strings.Replace(ApplicationName, "'", "''", -1) + "'"
+		if err := execConn(ctx, conn, query); err != nil {
+			conn.Close()
+			return nil, err
+		}
 	}
-	return &o, nil
+	for _, query := range connSetup {
+		if err := execConn(ctx, conn, query); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if OnConnect != nil {
+		if err := OnConnect(ctx, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
 }
 
-// Creates the user table
-func CreateUserTable(db *sql.DB) error {
-	stmt, err := db.Prepare(`CREATE TABLE user (
-	id integer primary key autoincrement,
-	email text
-)`)
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec()
-	if err != nil {
+	if execer, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = execer.ExecContext(ctx, nil)
+	} else {
+		_, err = stmt.Exec(nil)
+	}
+	return err
+}
+
+// ErrConcurrencyLimit is returned by queries with a !max_concurrency nowait
+// limit when the limit is reached.
+var ErrConcurrencyLimit = errors.New("norm: concurrency limit reached")
+
+// acquire takes a slot of sem. Unless nowait is set, it waits for a free slot
+// until ctx is done.
+func acquire(ctx context.Context, sem chan struct{}, nowait bool) error {
+	if nowait {
+		select {
+		case sem <- struct{}{}:
+			return nil
+		default:
+			return ErrConcurrencyLimit
+		}
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Limiter limits the rate of the queries of a group, see Limiters. Wait
+// blocks until a query may run, or returns an error if ctx is done first.
+// NewTokenBucket returns one, and *rate.Limiter of golang.org/x/time/rate
+// implements it too.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Limiters are the limiters of the query groups declared with !limit_group,
+// by group name. They are set by the program before running queries. The
+// queries of a group without a limiter are not limited.
+var Limiters = map[string]Limiter{}
+
+// tokenBucket is the Limiter returned by NewTokenBucket.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a Limiter letting perSecond queries run per second
+// on average, and bursts of up to burst queries at once.
+func NewTokenBucket(perSecond float64, burst int) Limiter {
+	return &tokenBucket{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait takes a token, waiting for it to be refilled if there is none left.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// The token was taken ahead of time, and is given back.
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// UserID is an ID type, distinct from other IDs of type int64.
+type UserID int64
+
+// Scan implements sql.Scanner.
+func (id *UserID) Scan(src interface{}) error {
+	var v sql.NullInt64
+	if err := v.Scan(src); err != nil {
 		return err
 	}
+	if !v.Valid {
+		return errors.New("norm: cannot scan NULL into UserID")
+	}
+	*id = UserID(v.Int64)
 	return nil
 }
+
+// Value implements driver.Valuer.
+func (id UserID) Value() (driver.Value, error) {
+	return int64(id), nil
+}
+
+// MembershipKey is the key taken by the queries declared with !key MembershipKey.
+type MembershipKey struct {
+	UserID int64
+	Group  string
+}
+
+// ErrNoContextValue is returned by the queries with an !input_ctx when their
+// context does not carry the value of the input.
+var ErrNoContextValue = errors.New("norm: no value in the context")
+
+// tenantDomainContextKey is the key of the tenantDomain value of contexts.
+type tenantDomainContextKey struct{}
+
+// WithTenantDomain returns a copy of ctx carrying tenantDomain, which the queries
+// with an !input_ctx of key tenantDomain read instead of taking it as a
+// parameter.
+func WithTenantDomain(ctx context.Context, tenantDomain string) context.Context {
+	return context.WithValue(ctx, tenantDomainContextKey{}, tenantDomain)
+}
+
+// TenantDomainFromContext returns the tenantDomain value carried by ctx, and whether
+// it carries one.
+func TenantDomainFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(tenantDomainContextKey{}).(string)
+	return v, ok
+}
+
+// UserContact is the model of the queries declared with !model UserContact and
+// !model_gen.
+type UserContact struct {
+	ID    int64  `json:"id" db:"id"`
+	Email string `json:"email" db:"email"`
+}
+
+// AuditLogTable is the table the queries declared with !audit_log write a row
+// to, created by CreateAuditLogTable.
+const AuditLogTable = "norm_audit_log"
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a copy of ctx naming actor as the one running the
+// queries, for the rows written to the audit log.
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActor returns the actor set on ctx with WithAuditActor, or "" if there
+// is none.
+func AuditActor(ctx context.Context) string {
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return actor
+}
+
+// CreateAuditLogTable creates the audit log table if it does not exist. Every
+// row records the actor, the name of the query, when it ran and the values
+// of its key inputs as a JSON object.
+func (n *Norm) CreateAuditLogTable(ctx context.Context) error {
+	_, err := n.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS norm_audit_log (
+	actor text NOT NULL,
+	query text NOT NULL,
+	logged_at timestamp NOT NULL,
+	key_values text NOT NULL
+)`)
+	return err
+}
+
+// writeAuditLog writes the row of a call of query to the audit log with db,
+// which runs the query in the same transaction.
+func writeAuditLog(ctx context.Context, db DBTX, query string, keys map[string]interface{}) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("%s: encoding the audit log keys: %v", query, err)
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO norm_audit_log (actor, query, logged_at, key_values) VALUES ($1, $2, $3, $4)", AuditActor(ctx), query, time.Now().UTC(), string(data))
+	if err != nil {
+		return fmt.Errorf("%s: writing the audit log: %v", query, err)
+	}
+	return nil
+}
+
+// ScriptStep describes a statement run by a function generated for a !script
+// block, passed to OnScriptStep.
+type ScriptStep struct {
+	// Script is the name of the function running the script.
+	Script string
+	// Step is the index of the statement in the script, starting at 1.
+	Step      int
+	Statement string
+	// DryRun is set when the statement was not run.
+	DryRun  bool
+	Elapsed time.Duration
+	Err     error
+}
+
+// OnScriptStep, if set, is called after every statement of a script, or for
+// every statement of a dry run.
+var OnScriptStep func(step ScriptStep)
+
+// partitionKey is a partition of the database db.
+type partitionKey struct {
+	db   *sql.DB
+	name string
+}
+
+// createdPartitions holds the partitionKey of the partitions created by
+// createPartition outside of a transaction, which are not created again in the
+// same database.
+var createdPartitions sync.Map
+
+// createPartition creates the partition of table holding the rows of t, for
+// a table partitioned by range with one partition per day, month or year as
+// given by interval. Partitions are named after the table and the start of
+// their range, like events_2024_01 for the monthly partition of January 2024.
+func createPartition(ctx context.Context, db DBTX, table, interval string, t time.Time) error {
+	t = t.UTC()
+	start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	var end time.Time
+	var layout string
+	switch interval {
+	case "daily":
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		end, layout = start.AddDate(0, 0, 1), "2006_01_02"
+	case "monthly":
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end, layout = start.AddDate(0, 1, 0), "2006_01"
+	default:
+		end, layout = start.AddDate(1, 0, 0), "2006"
+	}
+	name := table + "_" + start.Format(layout)
+	// A partition created in a transaction is gone if it rolls back, so only
+	// those created outside of one are remembered.
+	sqlDB, ok := db.(*sql.DB)
+	if ok {
+		if _, done := createdPartitions.Load(partitionKey{sqlDB, name}); done {
+			return nil
+		}
+	}
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')", name, table, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("creating partition %s of %s: %v", name, table, err)
+	}
+	if ok {
+		createdPartitions.Store(partitionKey{sqlDB, name}, true)
+	}
+	return nil
+}
+
+// CreateLoginPartition creates the monthly partition of login holding the
+// rows of t if it does not exist. The inserts declared with !partition_by call
+// it before writing their row.
+func (n *Norm) CreateLoginPartition(ctx context.Context, t time.Time) error {
+	return createPartition(ctx, n.db, "login", "monthly", t)
+}
+
+// FieldCodec encrypts and decrypts the values of the columns declared with
+// !encrypted. column is the name of the input or output, lowercased, so that
+// the input ssn and the output SSN share the keys of the ssn column.
+type FieldCodec interface {
+	Encrypt(column string, plaintext []byte) ([]byte, error)
+	Decrypt(column string, ciphertext []byte) ([]byte, error)
+}
+
+// Encryption is the codec of the encrypted columns. Queries with encrypted
+// columns fail while it is nil, rather than writing or reading plaintext.
+var Encryption FieldCodec
+
+// encrypted is the value of an encrypted input, encrypting v, a string or a
+// []byte, when it is passed to the database. As a scan destination, it
+// decrypts the column into v, a *string or a *[]byte, storing the zero value
+// for NULL.
+type encrypted struct {
+	column string
+	v      interface{}
+}
+
+func (e encrypted) Value() (driver.Value, error) {
+	if Encryption == nil {
+		return nil, fmt.Errorf("norm: no Encryption codec to encrypt %s", e.column)
+	}
+	var plaintext []byte
+	switch v := e.v.(type) {
+	case string:
+		plaintext = []byte(v)
+	case []byte:
+		plaintext = v
+	default:
+		return nil, errors.New("norm: unsupported type for an encrypted input")
+	}
+	return Encryption.Encrypt(e.column, plaintext)
+}
+
+func (e encrypted) Scan(src interface{}) error {
+	var plaintext []byte
+	switch src := src.(type) {
+	case nil:
+	case []byte, string:
+		if Encryption == nil {
+			return fmt.Errorf("norm: no Encryption codec to decrypt %s", e.column)
+		}
+		ciphertext, ok := src.([]byte)
+		if !ok {
+			ciphertext = []byte(src.(string))
+		}
+		var err error
+		if plaintext, err = Encryption.Decrypt(e.column, ciphertext); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("norm: cannot decrypt %s from %T", e.column, src)
+	}
+	switch dst := e.v.(type) {
+	case *string:
+		*dst = string(plaintext)
+	case *[]byte:
+		*dst = plaintext
+	default:
+		return errors.New("norm: unsupported type for an encrypted output")
+	}
+	return nil
+}
+
+// ErrETagMismatch is returned by the IfMatch functions when the row changed
+// since its ETag was read.
+var ErrETagMismatch = errors.New("norm: ETag mismatch")
+
+// cacheKey returns the cache key of a call of query with inputs, the name of
+// the query followed by the inputs as a JSON object. The keys of the object
+// are sorted, so the key does not depend on the order of the parameters.
+func cacheKey(query string, inputs map[string]interface{}) string {
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		// Inputs JSON cannot encode are written in Go syntax, which also
+		// sorts the keys.
+		return fmt.Sprintf("%s%#v", query, inputs)
+	}
+	return query + string(data)
+}
+
+// Diff is the difference between the rows a query reads from two databases,
+// see the Compare functions. Rows are compared by value, in any order.
+type Diff struct {
+	// Missing are the rows only read from the first database, and Extra the
+	// rows only read from the other one.
+	Missing []interface{}
+	Extra   []interface{}
+}
+
+// Equal reports whether both databases returned the same rows.
+func (d Diff) Equal() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0
+}
+
+// rowKey returns the value rows are compared by in a Diff, their JSON
+// encoding, which compares pointers by the values they point to.
+func rowKey(row interface{}) string {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return fmt.Sprintf("%#v", row)
+	}
+	return string(data)
+}
+
+// diffRows returns the Diff of the rows read from two databases. A row read
+// several times from one database must be read as many times from the other.
+func diffRows(rows, otherRows []interface{}) Diff {
+	pending := map[string]int{}
+	for _, row := range otherRows {
+		pending[rowKey(row)]++
+	}
+	var d Diff
+	for _, row := range rows {
+		key := rowKey(row)
+		if pending[key] > 0 {
+			pending[key]--
+			continue
+		}
+		d.Missing = append(d.Missing, row)
+	}
+	for _, row := range otherRows {
+		key := rowKey(row)
+		if pending[key] > 0 {
+			pending[key]--
+			d.Extra = append(d.Extra, row)
+		}
+	}
+	return d
+}
+
+// nullable scans a column that can be NULL into dst, a pointer to a type
+// that cannot hold NULL, storing the zero value for NULL. It is used for the
+// outputs declared with !output <name> <type> null.
+type nullable struct {
+	dst interface{}
+}
+
+func (n nullable) Scan(src interface{}) error {
+	switch dst := n.dst.(type) {
+	case *string:
+		var v sql.NullString
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.String
+	case *int:
+		var v sql.NullInt64
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = int(v.Int64)
+	case *int32:
+		var v sql.NullInt32
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Int32
+	case *int64:
+		var v sql.NullInt64
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Int64
+	case *float64:
+		var v sql.NullFloat64
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Float64
+	case *bool:
+		var v sql.NullBool
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Bool
+	case *time.Time:
+		var v sql.NullTime
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Time
+	default:
+		return errors.New("norm: unsupported type for a nullable output")
+	}
+	return nil
+}
+
+// queryCounts holds the number of calls of every query, by name.
+var queryCounts = map[string]*int64{
+	"GetUserListNoModel":     new(int64),
+	"GetUserEmailsNoModel":   new(int64),
+	"GetUserListLimited":     new(int64),
+	"GetUserListPaged":       new(int64),
+	"GetUserRows":            new(int64),
+	"GetUserListWithModel":   new(int64),
+	"AddUser":                new(int64),
+	"AddUsers":               new(int64),
+	"CopyUsers":              new(int64),
+	"DeleteAllUsers":         new(int64),
+	"AddUserResult":          new(int64),
+	"AddUserReturning":       new(int64),
+	"DeleteUser":             new(int64),
+	"FindUser":               new(int64),
+	"FindUserWithModel":      new(int64),
+	"FindUserSwappedColumns": new(int64),
+	"FindUserByEmail":        new(int64),
+	"FindUsersNamed":         new(int64),
+	"FindUserEmail":          new(int64),
+	"FindUserByID":           new(int64),
+	"FindUserEmailOrEmpty":   new(int64),
+	"GetUserEmailsOrEmpty":   new(int64),
+	"GetUserAccounts":        new(int64),
+	"GetUserContacts":        new(int64),
+	"FindUserEmailsByIDs":    new(int64),
+	"GetTenantUserEmails":    new(int64),
+	"FindUserEmailsExcept":   new(int64),
+	"CreateUserTable":        new(int64),
+	"CreateAuditEventTable":  new(int64),
+	"AddAuditEvent":          new(int64),
+	"GetAuditEvents":         new(int64),
+	"CreateMembershipTable":  new(int64),
+	"AddMembership":          new(int64),
+	"FindMembershipRole":     new(int64),
+	"DeleteMembership":       new(int64),
+	"ListUserDomain":         new(int64),
+	"GetUserDomainsByDomain": new(int64),
+	"FindUserAsOf":           new(int64),
+	"AddLogin":               new(int64),
+	"CreateUserSecretTable":  new(int64),
+	"SetUserSSN":             new(int64),
+	"GetUserSSN":             new(int64),
+	"GetUserByID":            new(int64),
+	"UpdateUserEmail":        new(int64),
+	"CountUsersByDomain":     new(int64),
+	"Maintain":               new(int64),
+	"CountUsers":             new(int64),
+}
+
+// QueryCounts returns the number of calls of every query of the norm file
+// since the program started, by the name of the query. Written as JSON and
+// summed across instances, it is the usage report read by norm prune to find
+// the queries that are never called.
+func QueryCounts() map[string]int64 {
+	ret := make(map[string]int64, len(queryCounts))
+	for name, count := range queryCounts {
+		ret[name] = atomic.LoadInt64(count)
+	}
+	return ret
+}
+
+// emptyIn is an IN or NOT IN predicate of a query holding only the
+// placeholder of a slice input, from start to end in the query. question is
+// set if the placeholder is ?.
+type emptyIn struct {
+	start, end, input int
+	not, question     bool
+}
+
+// expandSlices rewrites the placeholders of query for the slice inputs of a
+// query. counts holds the length of every slice input, and -1 for the other
+// inputs. The placeholder of a slice becomes one placeholder per element, and
+// the following placeholders are renumbered. The predicates in preds become
+// 1=0 for IN and 1=1 for NOT IN when their slice is empty, other placeholders
+// of an empty slice become NULL. The returned slice has room for the
+// arguments.
+func expandSlices(query string, counts []int, preds []emptyIn) (string, []interface{}) {
+	offsets := make([]int, len(counts))
+	total := 0
+	for ix, count := range counts {
+		offsets[ix] = total
+		if count < 0 {
+			total++
+		} else {
+			total += count
+		}
+	}
+	var b strings.Builder
+	positional := 0
+	for i := 0; i < len(query); i++ {
+		for len(preds) > 0 && preds[0].start < i {
+			preds = preds[1:]
+		}
+		if len(preds) > 0 && preds[0].start == i && counts[preds[0].input] == 0 {
+			if preds[0].not {
+				b.WriteString("1=1")
+			} else {
+				b.WriteString("1=0")
+			}
+			if preds[0].question {
+				positional++
+			}
+			i = preds[0].end - 1
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '\x60':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				end = len(query) - i - 2
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		case c == '?' && positional < len(counts):
+			writePlaceholders(&b, "?", counts[positional], 0)
+			positional++
+			continue
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			end := i + 1
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			n, _ := strconv.Atoi(query[i+1 : end])
+			if n < 1 || n > len(counts) {
+				break
+			}
+			writePlaceholders(&b, "$", counts[n-1], offsets[n-1]+1)
+			i = end - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), make([]interface{}, 0, total)
+}
+
+// writePlaceholders writes the placeholders of an input with count elements,
+// or -1 if it is not a slice, numbered from first if it is not 0.
+func writePlaceholders(b *strings.Builder, prefix string, count, first int) {
+	if count == 0 {
+		b.WriteString("NULL")
+		return
+	}
+	if count < 0 {
+		count = 1
+	}
+	for ix := 0; ix < count; ix++ {
+		if ix > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(prefix)
+		if first > 0 {
+			b.WriteString(strconv.Itoa(first + ix))
+		}
+	}
+}
+
+// asOfQuery returns the query declared with !as_of reading the rows as they
+// were at asOf: clause, formatted with the time, is inserted between the
+// parts of the query. The parts are joined as they are if asOf is the zero
+// time, reading the current rows.
+func asOfQuery(asOf time.Time, clause string, parts ...string) string {
+	if asOf.IsZero() {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts, fmt.Sprintf(clause, asOf.UTC().Format("2006-01-02 15:04:05.999999")))
+}
+
+// ShouldFallback decides whether a query with a !fallback that failed with err
+// runs its fallback query instead. It is not called when ctx is done. It can
+// be replaced to restrict fallbacks to some errors. By default, all errors but
+// sql.ErrNoRows run the fallback.
+var ShouldFallback = func(ctx context.Context, funcName string, err error) bool {
+	return !errors.Is(err, sql.ErrNoRows)
+}
+
+type degradedKey struct{}
+
+// WithDegraded returns a copy of ctx holding whether a query with a !fallback
+// called with it ran its fallback, as reported by Degraded.
+func WithDegraded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, degradedKey{}, new(int32))
+}
+
+// Degraded reports whether a query with a !fallback called with ctx, or with
+// a context derived from it, ran its fallback, so that its result may be
+// stale or partial. It is false unless ctx comes from WithDegraded.
+func Degraded(ctx context.Context) bool {
+	d, ok := ctx.Value(degradedKey{}).(*int32)
+	return ok && atomic.LoadInt32(d) != 0
+}
+
+// setDegraded records in the holder of WithDegraded in ctx, if any, that a
+// query ran its fallback.
+func setDegraded(ctx context.Context) {
+	if d, ok := ctx.Value(degradedKey{}).(*int32); ok {
+		atomic.StoreInt32(d, 1)
+	}
+}
+
+// ErrRowTimeout is returned by the Err method of a result that was not
+// advanced within its row timeout, see SetRowTimeout.
+var ErrRowTimeout = errors.New("norm: row timeout exceeded")
+
+// resultDeadline cancels the query of a result when Next is not called within
+// the row timeout. It also holds the slot of queries with a !max_concurrency
+// limit until the result is closed.
+type resultDeadline struct {
+	cancel   context.CancelFunc
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut int32
+	sem      chan struct{}
+	released int32
+}
+
+func (d *resultDeadline) next() {
+	if d.timeout <= 0 {
+		return
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.timeout, func() {
+			atomic.StoreInt32(&d.timedOut, 1)
+			d.cancel()
+		})
+		return
+	}
+	d.timer.Reset(d.timeout)
+}
+
+func (d *resultDeadline) stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.cancel()
+	if d.sem != nil && atomic.CompareAndSwapInt32(&d.released, 0, 1) {
+		<-d.sem
+	}
+}
+
+func (d *resultDeadline) err(err error) error {
+	if atomic.LoadInt32(&d.timedOut) == 1 {
+		return ErrRowTimeout
+	}
+	return err
+}
+
+// CheckColumns enables comparing the columns returned by a query with its
+// declared outputs before scanning, so that a reordered SELECT list results
+// in an error instead of values in the wrong fields.
+var CheckColumns = true
+
+// checkColumns returns an error if the columns of rows do not match outputs.
+// Names are compared ignoring case and underscores.
+func checkColumns(funcName string, rows *sql.Rows, outputs ...string) error {
+	if !CheckColumns {
+		return nil
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	mismatch := len(cols) != len(outputs)
+	for ix := 0; !mismatch && ix < len(cols); ix++ {
+		mismatch = normalizeColumn(cols[ix]) != normalizeColumn(outputs[ix])
+	}
+	if mismatch {
+		return fmt.Errorf("%s: query returned columns %v, but outputs are %v", funcName, cols, outputs)
+	}
+	return nil
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+// OnDeprecatedUse is called the first time a deprecated function is used, with
+// the deprecation message from the norm file.
+var OnDeprecatedUse func(funcName, msg string)
+
+func reportDeprecatedUse(once *sync.Once, funcName, msg string) {
+	if OnDeprecatedUse == nil {
+		return
+	}
+	once.Do(func() {
+		OnDeprecatedUse(funcName, msg)
+	})
+}
+
+type GetUserListNoModelResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserListNoModelResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserListNoModelResult) Scan(ID *int, Email *string) error {
+	return res.rows.Scan(ID, Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserListNoModelScan instead.
+func (res GetUserListNoModelResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserListNoModelResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserListNoModelResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Retrieves all emails from the users table. Since there is no
+// intermediate model, an output struct is autocreated which will contain
+// only the fields specified in the output. Please make sure that the field
+// names are capitalized.
+func (n *Norm) GetUserListNoModelScan(ctx context.Context, opts ...Option) (*GetUserListNoModelResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserListNoModelResult
+		err := n.run(ctx, &Call{Name: "GetUserListNoModel"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserListNoModelScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserListNoModel")
+	atomic.AddInt64(queryCounts["GetUserListNoModel"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserListNoModelResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY email ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserListNoModel", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+type GetUserListNoModelOutput struct {
+	ID    int
+	Email string
+}
+
+// AppendGetUserListNoModel is like GetUserListNoModel but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserListNoModel(ctx context.Context, dst []GetUserListNoModelOutput, opts ...Option) ([]GetUserListNoModelOutput, error) {
+	res, err := n.GetUserListNoModelScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o GetUserListNoModelOutput
+		if err := res.Scan(&o.ID, &o.Email); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserListNoModel", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserListNoModel(ctx context.Context, opts ...Option) ([]GetUserListNoModelOutput, error) {
+	return n.AppendGetUserListNoModel(ctx, nil, opts...)
+}
+
+// GetUserListNoModelCacheKey returns a stable key of a call of GetUserListNoModel with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserListNoModelCacheKey() string {
+	return cacheKey("GetUserListNoModel", map[string]interface{}{})
+}
+
+type GetUserEmailsNoModelResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserEmailsNoModelResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserEmailsNoModelResult) Scan(Email *string) error {
+	return res.rows.Scan(Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserEmailsNoModelScan instead.
+func (res GetUserEmailsNoModelResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserEmailsNoModelResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserEmailsNoModelResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+var semGetUserEmailsNoModel = make(chan struct{}, 2)
+
+// Retrieves all emails from the users table. In this example, there is
+// only one output field. Therefore an intermediate struct is also not
+// needed, we just return a slice of the output type (string in this case).
+// At most two calls can run at once because of !max_concurrency, further
+// calls fail with ErrConcurrencyLimit. Without nowait, they wait for a
+// free slot.
+func (n *Norm) GetUserEmailsNoModelScan(ctx context.Context, opts ...Option) (*GetUserEmailsNoModelResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserEmailsNoModelResult
+		err := n.run(ctx, &Call{Name: "GetUserEmailsNoModel"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserEmailsNoModelScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserEmailsNoModel")
+	atomic.AddInt64(queryCounts["GetUserEmailsNoModel"], 1)
+	if err := acquire(ctx, semGetUserEmailsNoModel, true); err != nil {
+		return nil, err
+	}
+	ctx, cancel := call.context(ctx)
+	result := GetUserEmailsNoModelResult{deadline: &resultDeadline{cancel: cancel, sem: semGetUserEmailsNoModel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT email
+FROM user
+ORDER BY email ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserEmailsNoModel", result.rows, "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendGetUserEmailsNoModel is like GetUserEmailsNoModel but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserEmailsNoModel(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	res, err := n.GetUserEmailsNoModelScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o string
+		if err := res.Scan(&o); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserEmailsNoModel", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserEmailsNoModel(ctx context.Context, opts ...Option) ([]string, error) {
+	return n.AppendGetUserEmailsNoModel(ctx, nil, opts...)
+}
+
+// GetUserEmailsNoModelCacheKey returns a stable key of a call of GetUserEmailsNoModel with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserEmailsNoModelCacheKey() string {
+	return cacheKey("GetUserEmailsNoModel", map[string]interface{}{})
+}
+
+type GetUserListLimitedResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserListLimitedResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserListLimitedResult) Scan(ID *int, Email *string) error {
+	return res.rows.Scan(ID, Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserListLimitedScan instead.
+func (res GetUserListLimitedResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserListLimitedResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserListLimitedResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+var semGetUserListLimited = make(chan struct{}, 1)
+
+// Retrieves users, one call at a time. When the query fails, here because
+// of the limit, the cheaper query named by !fallback is run instead, when
+// the generated ShouldFallback allows it. The fallback must read into the
+// same struct, and Degraded reports whether it ran.
+func (n *Norm) GetUserListLimitedScan(ctx context.Context, opts ...Option) (*GetUserListLimitedResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserListLimitedResult
+		err := n.run(ctx, &Call{Name: "GetUserListLimited"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserListLimitedScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserListLimited")
+	atomic.AddInt64(queryCounts["GetUserListLimited"], 1)
+	if err := acquire(ctx, semGetUserListLimited, true); err != nil {
+		return nil, err
+	}
+	ctx, cancel := call.context(ctx)
+	result := GetUserListLimitedResult{deadline: &resultDeadline{cancel: cancel, sem: semGetUserListLimited}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserListLimited", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+type GetUserListLimitedOutput struct {
+	ID    int
+	Email string
+}
+
+// AppendGetUserListLimited is like GetUserListLimited but appends the rows to dst.
+// This allows reusing the same slice across calls.
+// If the query fails, AppendGetUserListPaged is run instead when ShouldFallback
+// allows it, which Degraded reports. GetUserListLimitedScan has no fallback.
+func (n *Norm) AppendGetUserListLimited(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	ret, err := n.primaryAppendGetUserListLimited(ctx, dst, opts...)
+	if err == nil || ctx.Err() != nil || !ShouldFallback(ctx, "GetUserListLimited", err) {
+		return ret, err
+	}
+	setDegraded(ctx)
+	return n.AppendGetUserListPaged(ctx, dst, opts...)
+}
+
+func (n *Norm) primaryAppendGetUserListLimited(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	res, err := n.GetUserListLimitedScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o GetUserListLimitedOutput
+		if err := res.Scan(&o.ID, &o.Email); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserListLimited", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserListLimited(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	return n.AppendGetUserListLimited(ctx, nil, opts...)
+}
+
+// GetUserListLimitedCacheKey returns a stable key of a call of GetUserListLimited with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserListLimitedCacheKey() string {
+	return cacheKey("GetUserListLimited", map[string]interface{}{})
+}
+
+type GetUserListPagedResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserListPagedResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserListPagedResult) Scan(ID *int, Email *string) error {
+	return res.rows.Scan(ID, Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserListPagedScan instead.
+func (res GetUserListPagedResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserListPagedResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserListPagedResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Retrieves the first 100 users, into the struct of GetUserListLimited
+// it is the fallback of.
+func (n *Norm) GetUserListPagedScan(ctx context.Context, opts ...Option) (*GetUserListPagedResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserListPagedResult
+		err := n.run(ctx, &Call{Name: "GetUserListPaged"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserListPagedScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserListPaged")
+	atomic.AddInt64(queryCounts["GetUserListPaged"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserListPagedResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY id ASC
+LIMIT 100`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserListPaged", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendGetUserListPaged is like GetUserListPaged but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserListPaged(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	res, err := n.GetUserListPagedScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o GetUserListLimitedOutput
+		if err := res.Scan(&o.ID, &o.Email); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserListPaged", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserListPaged(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	return n.AppendGetUserListPaged(ctx, nil, opts...)
+}
+
+// GetUserListPagedCacheKey returns a stable key of a call of GetUserListPaged with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserListPagedCacheKey() string {
+	return cacheKey("GetUserListPaged", map[string]interface{}{})
+}
+
+type GetUserRowsResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserRowsResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserRowsResult) Scan(ID *UserID, Email *sql.NullString) error {
+	return res.rows.Scan(ID, Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserRowsScan instead.
+func (res GetUserRowsResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserRowsResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserRowsResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Without !output lines, the outputs are derived from the SELECT list and
+// typed from the columns of the user table: id is an int64, and email,
+// which can be NULL, a sql.NullString.
+func (n *Norm) GetUserRowsScan(ctx context.Context, opts ...Option) (*GetUserRowsResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserRowsResult
+		err := n.run(ctx, &Call{Name: "GetUserRows"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserRowsScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserRows")
+	atomic.AddInt64(queryCounts["GetUserRows"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserRowsResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserRows", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+type GetUserRowsOutput struct {
+	ID    UserID
+	Email sql.NullString
+}
+
+// AppendGetUserRows is like GetUserRows but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserRows(ctx context.Context, dst []GetUserRowsOutput, opts ...Option) ([]GetUserRowsOutput, error) {
+	res, err := n.GetUserRowsScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o GetUserRowsOutput
+		if err := res.Scan(&o.ID, &o.Email); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserRows", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserRows(ctx context.Context, opts ...Option) ([]GetUserRowsOutput, error) {
+	return n.AppendGetUserRows(ctx, nil, opts...)
+}
+
+// GetUserRowsCacheKey returns a stable key of a call of GetUserRows with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserRowsCacheKey() string {
+	return cacheKey("GetUserRows", map[string]interface{}{})
+}
+
+type GetUserListWithModelResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserListWithModelResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserListWithModelResult) Scan(ID *int, Email *string) error {
+	return res.rows.Scan(ID, Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserListWithModelScan instead.
+func (res GetUserListWithModelResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserListWithModelResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserListWithModelResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Retrieves all emails from the users table. In this example, an
+// intermediate model is used. See `gen.go` for the model definition. This
+// allows users to specify an arbitrary intermediate struct. Because of
+// !compare, CompareGetUserListWithModel reports the users that differ
+// between two databases.
+func (n *Norm) GetUserListWithModelScan(ctx context.Context, opts ...Option) (*GetUserListWithModelResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserListWithModelResult
+		err := n.run(ctx, &Call{Name: "GetUserListWithModel"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserListWithModelScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserListWithModel")
+	atomic.AddInt64(queryCounts["GetUserListWithModel"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserListWithModelResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY email ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserListWithModel", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendGetUserListWithModel is like GetUserListWithModel but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserListWithModel(ctx context.Context, dst []User, opts ...Option) ([]User, error) {
+	res, err := n.GetUserListWithModelScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o User
+		if err := res.Scan(&o.ID, &o.Email); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserListWithModel", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserListWithModel(ctx context.Context, opts ...Option) ([]User, error) {
+	return n.AppendGetUserListWithModel(ctx, nil, opts...)
+}
+
+// GetUserListWithModelCacheKey returns a stable key of a call of GetUserListWithModel with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserListWithModelCacheKey() string {
+	return cacheKey("GetUserListWithModel", map[string]interface{}{})
+}
+
+// CompareGetUserListWithModel runs GetUserListWithModel against n and other, such as the
+// databases before and after a migration, and returns the rows that differ.
+func (n *Norm) CompareGetUserListWithModel(ctx context.Context, other *Norm) (Diff, error) {
+	var rows, otherRows []interface{}
+	res, err := n.GetUserListWithModel(ctx)
+	if err != nil {
+		return Diff{}, err
+	}
+	for _, row := range res {
+		rows = append(rows, row)
+	}
+	otherRes, err := other.GetUserListWithModel(ctx)
+	if err != nil {
+		return Diff{}, fmt.Errorf("other database: %v", err)
+	}
+	for _, row := range otherRes {
+		otherRows = append(otherRows, row)
+	}
+	return diffRows(rows, otherRows), nil
+}
+
+// Add a user to the DB
+func (n *Norm) AddUser(ctx context.Context, email string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "AddUser", Args: []interface{}{Redacted{}}}, func(ctx context.Context) error {
+			return n.bare().AddUser(ctx, email, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("AddUser")
+	atomic.AddInt64(queryCounts["AddUser"], 1)
+	stmt, release, err := n.prepare(ctx, `INSERT into user(email)
+VALUES ($1)`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddUsersRow holds the inputs of one statement run by AddUsers.
+type AddUsersRow struct {
+	Email string
+}
+
+// Adds users in one transaction, running the statement once per row. The
+// rows are AddUsersRow structs with a field per input, or the struct named
+// with !model.
+func (n *Norm) AddUsers(ctx context.Context, rows []AddUsersRow, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "AddUsers", Args: []interface{}{Redacted{}}}, func(ctx context.Context) error {
+			return n.bare().AddUsers(ctx, rows, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("AddUsers")
+	atomic.AddInt64(queryCounts["AddUsers"], 1)
+	if len(rows) == 0 {
+		return nil
+	}
+	db := n.db
+	var tx *sql.Tx
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		var err error
+		if tx, err = sqlDB.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		db = tx
+	}
+	stmt, err := db.PrepareContext(ctx, `INSERT INTO user (email)
+VALUES ($1)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row.Email); err != nil {
+			return err
+		}
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// CopyUsersRow holds the values of one row copied by CopyUsers.
+type CopyUsersRow struct {
+	Email string
+}
+
+// Bulk loads users into a postgres table with COPY FROM STDIN, through the
+// lib/pq driver. The body is the table name and the inputs are its
+// columns. CopyUsersFrom reads the rows from a function instead of a
+// slice.
+func (n *Norm) CopyUsers(ctx context.Context, rows []CopyUsersRow, opts ...Option) error {
+	return n.CopyUsersFrom(ctx, func() (CopyUsersRow, bool, error) {
+		if len(rows) == 0 {
+			return CopyUsersRow{}, false, nil
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, true, nil
+	}, opts...)
+}
+
+// CopyUsersFrom is like CopyUsers, but reads the rows from next until it
+// returns false or an error.
+func (n *Norm) CopyUsersFrom(ctx context.Context, next func() (CopyUsersRow, bool, error), opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "CopyUsers"}, func(ctx context.Context) error {
+			return n.bare().CopyUsersFrom(ctx, next, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("CopyUsers")
+	atomic.AddInt64(queryCounts["CopyUsers"], 1)
+	db := n.db
+	var tx *sql.Tx
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		var err error
+		if tx, err = sqlDB.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		db = tx
+	}
+	stmt, err := db.PrepareContext(ctx, "COPY \"user\" (\"email\") FROM STDIN")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for {
+		row, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if _, err := stmt.ExecContext(ctx, row.Email); err != nil {
+			return err
+		}
+	}
+	// Flushes the buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// Deletes all users from the DB
+func (n *Norm) DeleteAllUsers(ctx context.Context, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "DeleteAllUsers"}, func(ctx context.Context) error {
+			return n.bare().DeleteAllUsers(ctx, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("DeleteAllUsers")
+	atomic.AddInt64(queryCounts["DeleteAllUsers"], 1)
+	stmt, release, err := n.prepare(ctx, `DELETE FROM user`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Adds a user, returning the sql.Result to read the new ID from. With
+// !rows_affected instead, only the number of affected rows is returned.
+func (n *Norm) AddUserResult(ctx context.Context, email string, opts ...Option) (sql.Result, error) {
+	if n.middleware != nil {
+		var ret sql.Result
+		err := n.run(ctx, &Call{Name: "AddUserResult", Args: []interface{}{email}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().AddUserResult(ctx, email, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("AddUserResult")
+	atomic.AddInt64(queryCounts["AddUserResult"], 1)
+	stmt, release, err := n.prepare(ctx, `INSERT into user(email)
+VALUES ($1)`, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.ExecContext(ctx, email)
+}
+
+// AddUserReturningInto is like AddUserReturning but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) AddUserReturningInto(ctx context.Context, dst *User, email string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "AddUserReturning", Args: []interface{}{email}}, func(ctx context.Context) error {
+			return n.bare().AddUserReturningInto(ctx, dst, email, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("AddUserReturning")
+	atomic.AddInt64(queryCounts["AddUserReturning"], 1)
+	stmt, release, err := n.prepare(ctx, `INSERT INTO user(email)
+VALUES ($1)
+RETURNING id, email`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("AddUserReturning", rows, "ID", "Email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(&dst.ID, &dst.Email); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Adds a user and reads back the new row. Statements with a RETURNING
+// clause are generated like read_one commands. This needs sqlite 3.35 or
+// newer, or postgres.
+func (n *Norm) AddUserReturning(ctx context.Context, email string, opts ...Option) (*User, error) {
+	var o User
+	if err := n.AddUserReturningInto(ctx, &o, email, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Deletes a user by email, returning the number of users deleted
+func (n *Norm) DeleteUser(ctx context.Context, email string, opts ...Option) (int64, error) {
+	if n.middleware != nil {
+		var ret int64
+		err := n.run(ctx, &Call{Name: "DeleteUser", Args: []interface{}{email}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().DeleteUser(ctx, email, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("DeleteUser")
+	atomic.AddInt64(queryCounts["DeleteUser"], 1)
+	stmt, release, err := n.prepare(ctx, `DELETE FROM user
+WHERE email = $1`, true)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	res, err := stmt.ExecContext(ctx, email)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type FindUserOutput struct {
+	ID    int
+	Email string
+}
+
+// FindUserInto is like FindUser but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) FindUserInto(ctx context.Context, dst *FindUserOutput, email string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUser", Args: []interface{}{email}}, func(ctx context.Context) error {
+			return n.bare().FindUserInto(ctx, dst, email, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("FindUser")
+	atomic.AddInt64(queryCounts["FindUser"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT id, email
+FROM USER
+WHERE email = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUser", rows, "ID", "Email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(&dst.ID, &dst.Email); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Finds user by email
+func (n *Norm) FindUser(ctx context.Context, email string, opts ...Option) (*FindUserOutput, error) {
+	var o FindUserOutput
+	if err := n.FindUserInto(ctx, &o, email, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserCacheKey returns a stable key of a call of FindUser with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserCacheKey(email string) string {
+	return cacheKey("FindUser", map[string]interface{}{"email": strings.ToLower(email)})
+}
+
+// CompareFindUser runs FindUser against n and other, such as the
+// databases before and after a migration, and returns the rows that differ.
+func (n *Norm) CompareFindUser(ctx context.Context, other *Norm, email string) (Diff, error) {
+	var rows, otherRows []interface{}
+	row, err := n.FindUser(ctx, email)
+	if err != nil && err != sql.ErrNoRows {
+		return Diff{}, err
+	}
+	if err == nil {
+		rows = append(rows, *row)
+	}
+	otherRow, err := other.FindUser(ctx, email)
+	if err != nil && err != sql.ErrNoRows {
+		return Diff{}, fmt.Errorf("other database: %v", err)
+	}
+	if err == nil {
+		otherRows = append(otherRows, *otherRow)
+	}
+	return diffRows(rows, otherRows), nil
+}
+
+// FindUserWithModelInto is like FindUserWithModel but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) FindUserWithModelInto(ctx context.Context, dst *User, email string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUserWithModel", Args: []interface{}{email}}, func(ctx context.Context) error {
+			return n.bare().FindUserWithModelInto(ctx, dst, email, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("FindUserWithModel")
+	atomic.AddInt64(queryCounts["FindUserWithModel"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT id, email
+FROM USER
+WHERE email = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUserWithModel", rows, "ID", "Email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(&dst.ID, &dst.Email); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Finds user by email, reading into the User model. A
+// FindUserWithModelInto variant is also generated to read into an existing
+// User.
+func (n *Norm) FindUserWithModel(ctx context.Context, email string, opts ...Option) (*User, error) {
+	var o User
+	if err := n.FindUserWithModelInto(ctx, &o, email, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserWithModelCacheKey returns a stable key of a call of FindUserWithModel with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserWithModelCacheKey(email string) string {
+	return cacheKey("FindUserWithModel", map[string]interface{}{"email": email})
+}
+
+type FindUserSwappedColumnsOutput struct {
+	ID    int
+	Email string
+}
+
+// FindUserSwappedColumnsInto is like FindUserSwappedColumns but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) FindUserSwappedColumnsInto(ctx context.Context, dst *FindUserSwappedColumnsOutput, email string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUserSwappedColumns", Args: []interface{}{email}}, func(ctx context.Context) error {
+			return n.bare().FindUserSwappedColumnsInto(ctx, dst, email, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("FindUserSwappedColumns")
+	atomic.AddInt64(queryCounts["FindUserSwappedColumns"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT email, id
+FROM USER
+WHERE email = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUserSwappedColumns", rows, "ID", "Email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(&dst.ID, &dst.Email); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Selects the columns in a different order than the outputs, which
+// !check_columns reports as an error instead of scanning the email into
+// ID.
+func (n *Norm) FindUserSwappedColumns(ctx context.Context, email string, opts ...Option) (*FindUserSwappedColumnsOutput, error) {
+	var o FindUserSwappedColumnsOutput
+	if err := n.FindUserSwappedColumnsInto(ctx, &o, email, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserSwappedColumnsCacheKey returns a stable key of a call of FindUserSwappedColumns with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserSwappedColumnsCacheKey(email string) string {
+	return cacheKey("FindUserSwappedColumns", map[string]interface{}{"email": email})
+}
+
+var deprecatedFindUserByEmailOnce sync.Once
+
+// FindUserByEmailInto is like FindUserByEmail but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+//
+// Deprecated: use FindUserEmail instead.
+func (n *Norm) FindUserByEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUserByEmail", Args: []interface{}{email}}, func(ctx context.Context) error {
+			return n.bare().FindUserByEmailInto(ctx, dst, email, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	reportDeprecatedUse(&deprecatedFindUserByEmailOnce, "FindUserByEmail", "use FindUserEmail instead.")
+	n.countCall("FindUserByEmail")
+	atomic.AddInt64(queryCounts["FindUserByEmail"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT email
+FROM USER
+WHERE email = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUserByEmail", rows, "email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(dst); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Finds user by email. Deprecated commands get a Deprecated paragraph in
+// their doc comments, and report their first use through the generated
+// OnDeprecatedUse hook.
+//
+// Deprecated: use FindUserEmail instead.
+func (n *Norm) FindUserByEmail(ctx context.Context, email string, opts ...Option) (*string, error) {
+	var o string
+	if err := n.FindUserByEmailInto(ctx, &o, email, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserByEmailCacheKey returns a stable key of a call of FindUserByEmail with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserByEmailCacheKey(email string) string {
+	return cacheKey("FindUserByEmail", map[string]interface{}{"email": email})
+}
+
+type FindUsersNamedResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res FindUsersNamedResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res FindUsersNamedResult) Scan(ID *int) error {
+	return res.rows.Scan(ID)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of FindUsersNamedScan instead.
+func (res FindUsersNamedResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res FindUsersNamedResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res FindUsersNamedResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Inputs can be referred to by name, like :email, instead of by position.
+// The query is run without preparing it first, because of !no_prepare.
+func (n *Norm) FindUsersNamedScan(ctx context.Context, email string, domain string, opts ...Option) (*FindUsersNamedResult, error) {
+	if n.middleware != nil {
+		var ret *FindUsersNamedResult
+		err := n.run(ctx, &Call{Name: "FindUsersNamed", Args: []interface{}{email, domain}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().FindUsersNamedScan(ctx, email, domain, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("FindUsersNamed")
+	atomic.AddInt64(queryCounts["FindUsersNamed"], 1)
+	ctx, cancel := call.context(ctx)
+	result := FindUsersNamedResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	result.rows, err = n.db.QueryContext(ctx, `SELECT id
+FROM user
+WHERE email = $1 OR ($2 <> '' AND email LIKE '%@' || $2)
+ORDER BY id ASC`, email, domain)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("FindUsersNamed", result.rows, "ID"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendFindUsersNamed is like FindUsersNamed but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendFindUsersNamed(ctx context.Context, dst []int, email string, domain string, opts ...Option) ([]int, error) {
+	res, err := n.FindUsersNamedScan(ctx, email, domain, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o int
+		if err := res.Scan(&o); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("FindUsersNamed", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) FindUsersNamed(ctx context.Context, email string, domain string, opts ...Option) ([]int, error) {
+	return n.AppendFindUsersNamed(ctx, nil, email, domain, opts...)
+}
+
+// FindUsersNamedCacheKey returns a stable key of a call of FindUsersNamed with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUsersNamedCacheKey(email string, domain string) string {
+	return cacheKey("FindUsersNamed", map[string]interface{}{"email": email, "domain": domain})
+}
+
+// FindUserEmailInto is like FindUserEmail but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) FindUserEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUserEmail", Args: []interface{}{email}}, func(ctx context.Context) error {
+			return n.bare().FindUserEmailInto(ctx, dst, email, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("FindUserEmail")
+	atomic.AddInt64(queryCounts["FindUserEmail"], 1)
+	if l := Limiters["lookups"]; l != nil {
+		if err := l.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	stmt, release, err := n.prepare(ctx, `SELECT email
+FROM USER
+WHERE email = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUserEmail", rows, "email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(dst); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Finds user by email. The query is in the lookups group of !limit_group,
+// whose rate is limited by Limiters["lookups"] when it is set.
+func (n *Norm) FindUserEmail(ctx context.Context, email string, opts ...Option) (*string, error) {
+	var o string
+	if err := n.FindUserEmailInto(ctx, &o, email, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserEmailCacheKey returns a stable key of a call of FindUserEmail with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserEmailCacheKey(email string) string {
+	return cacheKey("FindUserEmail", map[string]interface{}{"email": email})
+}
+
+type FindUserByIDOutput struct {
+	ID    int
+	Email string
+}
+
+// FindUserByIDInto is like FindUserByID but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) FindUserByIDInto(ctx context.Context, dst *FindUserByIDOutput, id UserID, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUserByID", Args: []interface{}{id}}, func(ctx context.Context) error {
+			return n.bare().FindUserByIDInto(ctx, dst, id, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("FindUserByID")
+	atomic.AddInt64(queryCounts["FindUserByID"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT id, email
+FROM user
+WHERE id = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUserByID", rows, "ID", "Email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(&dst.ID, &dst.Email); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Without !input lines, inputs are derived from the placeholders. The
+// input here is named and typed after the id column of the user table.
+func (n *Norm) FindUserByID(ctx context.Context, id UserID, opts ...Option) (*FindUserByIDOutput, error) {
+	var o FindUserByIDOutput
+	if err := n.FindUserByIDInto(ctx, &o, id, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserByIDCacheKey returns a stable key of a call of FindUserByID with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserByIDCacheKey(id UserID) string {
+	return cacheKey("FindUserByID", map[string]interface{}{"id": id})
+}
+
+// FindUserEmailOrEmptyInto is like FindUserEmailOrEmpty but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) FindUserEmailOrEmptyInto(ctx context.Context, dst *string, id UserID, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUserEmailOrEmpty", Args: []interface{}{id}}, func(ctx context.Context) error {
+			return n.bare().FindUserEmailOrEmptyInto(ctx, dst, id, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		// The transaction is only there to reject writes, there is nothing
+		// to commit.
+		defer tx.Rollback()
+		return (&Norm{db: tx, stmts: n.stmts}).FindUserEmailOrEmptyInto(ctx, dst, id, opts...)
+	}
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("FindUserEmailOrEmpty")
+	atomic.AddInt64(queryCounts["FindUserEmailOrEmpty"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT email
+FROM user
+WHERE id = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUserEmailOrEmpty", rows, "email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(nullable{dst}); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// The email column can be NULL, which the null marker on the output scans
+// as an empty string.
+func (n *Norm) FindUserEmailOrEmpty(ctx context.Context, id UserID, opts ...Option) (*string, error) {
+	var o string
+	if err := n.FindUserEmailOrEmptyInto(ctx, &o, id, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserEmailOrEmptyCacheKey returns a stable key of a call of FindUserEmailOrEmpty with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserEmailOrEmptyCacheKey(id UserID) string {
+	return cacheKey("FindUserEmailOrEmpty", map[string]interface{}{"id": id})
+}
+
+type GetUserEmailsOrEmptyResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserEmailsOrEmptyResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserEmailsOrEmptyResult) Scan(ID *int64, Email *string) error {
+	return res.rows.Scan(ID, nullable{Email})
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserEmailsOrEmptyScan instead.
+func (res GetUserEmailsOrEmptyResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserEmailsOrEmptyResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserEmailsOrEmptyResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Runs in a read only transaction because of !readonly, in which the
+// database rejects writes.
+func (n *Norm) GetUserEmailsOrEmptyScan(ctx context.Context, opts ...Option) (*GetUserEmailsOrEmptyResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserEmailsOrEmptyResult
+		err := n.run(ctx, &Call{Name: "GetUserEmailsOrEmpty"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserEmailsOrEmptyScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		res, err := (&Norm{db: tx, stmts: n.stmts}).GetUserEmailsOrEmptyScan(ctx, opts...)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		// The transaction ends with the result, and has nothing to commit.
+		release := res.release
+		res.release = func() {
+			if release != nil {
+				release()
+			}
+			tx.Rollback()
+		}
+		return res, nil
+	}
+	n.countCall("GetUserEmailsOrEmpty")
+	atomic.AddInt64(queryCounts["GetUserEmailsOrEmpty"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserEmailsOrEmptyResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserEmailsOrEmpty", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+type GetUserEmailsOrEmptyOutput struct {
+	ID    int64
+	Email string
+}
+
+// AppendGetUserEmailsOrEmpty is like GetUserEmailsOrEmpty but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserEmailsOrEmpty(ctx context.Context, dst []GetUserEmailsOrEmptyOutput, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error) {
+	res, err := n.GetUserEmailsOrEmptyScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o GetUserEmailsOrEmptyOutput
+		if err := res.Scan(&o.ID, &o.Email); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserEmailsOrEmpty", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserEmailsOrEmpty(ctx context.Context, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error) {
+	return n.AppendGetUserEmailsOrEmpty(ctx, nil, opts...)
+}
+
+// GetUserEmailsOrEmptyCacheKey returns a stable key of a call of GetUserEmailsOrEmpty with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserEmailsOrEmptyCacheKey() string {
+	return cacheKey("GetUserEmailsOrEmpty", map[string]interface{}{})
+}
+
+type GetUserAccountsResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserAccountsResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserAccountsResult) Scan(ID *int64, Email *string) error {
+	return res.rows.Scan(ID, Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserAccountsScan instead.
+func (res GetUserAccountsResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserAccountsResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserAccountsResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Rows are scanned into GetUserAccountsRow and passed to newUserAccount,
+// named with !mapper, which builds the UserAccount model.
+func (n *Norm) GetUserAccountsScan(ctx context.Context, opts ...Option) (*GetUserAccountsResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserAccountsResult
+		err := n.run(ctx, &Call{Name: "GetUserAccounts"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserAccountsScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserAccounts")
+	atomic.AddInt64(queryCounts["GetUserAccounts"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserAccountsResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserAccounts", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetUserAccountsRow holds the columns of a row of GetUserAccounts, from which
+// newUserAccount builds the UserAccount.
+type GetUserAccountsRow struct {
+	ID    int64
+	Email string
+}
+
+// AppendGetUserAccounts is like GetUserAccounts but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserAccounts(ctx context.Context, dst []UserAccount, opts ...Option) ([]UserAccount, error) {
+	res, err := n.GetUserAccountsScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var row GetUserAccountsRow
+		if err := res.Scan(&row.ID, &row.Email); err != nil {
+			return dst, err
+		}
+		o, err := newUserAccount(row)
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserAccounts", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserAccounts(ctx context.Context, opts ...Option) ([]UserAccount, error) {
+	return n.AppendGetUserAccounts(ctx, nil, opts...)
+}
+
+// GetUserAccountsCacheKey returns a stable key of a call of GetUserAccounts with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserAccountsCacheKey() string {
+	return cacheKey("GetUserAccounts", map[string]interface{}{})
+}
+
+type GetUserContactsResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserContactsResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserContactsResult) Scan(ID *int64, Email *string) error {
+	return res.rows.Scan(ID, Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserContactsScan instead.
+func (res GetUserContactsResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserContactsResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserContactsResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// The UserContact model is generated from the outputs because of
+// !model_gen, instead of being declared next to the generated file. Its
+// fields get json and db tags in snake case because of !tags.
+func (n *Norm) GetUserContactsScan(ctx context.Context, opts ...Option) (*GetUserContactsResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserContactsResult
+		err := n.run(ctx, &Call{Name: "GetUserContacts"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserContactsScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserContacts")
+	atomic.AddInt64(queryCounts["GetUserContacts"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserContactsResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, email
+FROM user
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserContacts", result.rows, "ID", "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendGetUserContacts is like GetUserContacts but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserContacts(ctx context.Context, dst []UserContact, opts ...Option) ([]UserContact, error) {
+	res, err := n.GetUserContactsScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o UserContact
+		if err := res.Scan(&o.ID, &o.Email); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserContacts", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserContacts(ctx context.Context, opts ...Option) ([]UserContact, error) {
+	return n.AppendGetUserContacts(ctx, nil, opts...)
+}
+
+// GetUserContactsCacheKey returns a stable key of a call of GetUserContacts with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserContactsCacheKey() string {
+	return cacheKey("GetUserContacts", map[string]interface{}{})
+}
+
+type FindUserEmailsByIDsResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res FindUserEmailsByIDsResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res FindUserEmailsByIDsResult) Scan(Email *string) error {
+	return res.rows.Scan(Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of FindUserEmailsByIDsScan instead.
+func (res FindUserEmailsByIDsResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res FindUserEmailsByIDsResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res FindUserEmailsByIDsResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Slice inputs, other than []byte, are expanded into one placeholder per
+// element when the query runs, here in an IN list. An empty slice makes
+// the IN predicate false, so it matches no rows. The !bind directive
+// generates BindFindUserEmailsByIDs, returning this function with domain
+// fixed.
+func (n *Norm) FindUserEmailsByIDsScan(ctx context.Context, ids []UserID, domain string, opts ...Option) (*FindUserEmailsByIDsResult, error) {
+	if n.middleware != nil {
+		var ret *FindUserEmailsByIDsResult
+		err := n.run(ctx, &Call{Name: "FindUserEmailsByIDs", Args: []interface{}{ids, domain}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().FindUserEmailsByIDsScan(ctx, ids, domain, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("FindUserEmailsByIDs")
+	atomic.AddInt64(queryCounts["FindUserEmailsByIDs"], 1)
+	ctx, cancel := call.context(ctx)
+	result := FindUserEmailsByIDsResult{deadline: &resultDeadline{cancel: cancel}}
+	query, args := expandSlices(`SELECT email
+FROM user
+WHERE id IN ($1) AND email LIKE '%@' || $2
+ORDER BY id ASC`, []int{len(ids), -1}, []emptyIn{{29, 39, 0, false, false}})
+	for _, v := range ids {
+		args = append(args, v)
+	}
+	args = append(args, domain)
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, query, false)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx, args...)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("FindUserEmailsByIDs", result.rows, "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendFindUserEmailsByIDs is like FindUserEmailsByIDs but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendFindUserEmailsByIDs(ctx context.Context, dst []string, ids []UserID, domain string, opts ...Option) ([]string, error) {
+	res, err := n.FindUserEmailsByIDsScan(ctx, ids, domain, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o string
+		if err := res.Scan(&o); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("FindUserEmailsByIDs", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) FindUserEmailsByIDs(ctx context.Context, ids []UserID, domain string, opts ...Option) ([]string, error) {
+	return n.AppendFindUserEmailsByIDs(ctx, nil, ids, domain, opts...)
+}
+
+// FindUserEmailsByIDsCacheKey returns a stable key of a call of FindUserEmailsByIDs with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserEmailsByIDsCacheKey(ids []UserID, domain string) string {
+	return cacheKey("FindUserEmailsByIDs", map[string]interface{}{"ids": ids, "domain": domain})
+}
+
+// BindFindUserEmailsByIDs returns FindUserEmailsByIDs with domain fixed, for calling
+// it repeatedly with the same value.
+func (n *Norm) BindFindUserEmailsByIDs(domain string) func(ctx context.Context, ids []UserID) ([]string, error) {
+	return func(ctx context.Context, ids []UserID) ([]string, error) {
+		return n.FindUserEmailsByIDs(ctx, ids, domain)
+	}
+}
+
+type GetTenantUserEmailsResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetTenantUserEmailsResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetTenantUserEmailsResult) Scan(Email *string) error {
+	return res.rows.Scan(Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetTenantUserEmailsScan instead.
+func (res GetTenantUserEmailsResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetTenantUserEmailsResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetTenantUserEmailsResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Reads the emails of the users of the tenant carried by the context, see
+// WithTenantDomain, instead of taking the domain as a parameter.
+func (n *Norm) GetTenantUserEmailsScan(ctx context.Context, opts ...Option) (*GetTenantUserEmailsResult, error) {
+	if n.middleware != nil {
+		var ret *GetTenantUserEmailsResult
+		err := n.run(ctx, &Call{Name: "GetTenantUserEmails"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetTenantUserEmailsScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetTenantUserEmails")
+	atomic.AddInt64(queryCounts["GetTenantUserEmails"], 1)
+	domain, ok := TenantDomainFromContext(ctx)
+	if !ok {
+		err := fmt.Errorf("GetTenantUserEmails: %w: tenantDomain", ErrNoContextValue)
+		return nil, err
+	}
+	ctx, cancel := call.context(ctx)
+	result := GetTenantUserEmailsResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT email
+FROM user
+WHERE email LIKE '%@' || $1
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx, domain)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetTenantUserEmails", result.rows, "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendGetTenantUserEmails is like GetTenantUserEmails but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetTenantUserEmails(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	res, err := n.GetTenantUserEmailsScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o string
+		if err := res.Scan(&o); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetTenantUserEmails", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetTenantUserEmails(ctx context.Context, opts ...Option) ([]string, error) {
+	return n.AppendGetTenantUserEmails(ctx, nil, opts...)
+}
+
+type FindUserEmailsExceptResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res FindUserEmailsExceptResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res FindUserEmailsExceptResult) Scan(Email *string) error {
+	return res.rows.Scan(Email)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of FindUserEmailsExceptScan instead.
+func (res FindUserEmailsExceptResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res FindUserEmailsExceptResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res FindUserEmailsExceptResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// An empty slice makes a NOT IN predicate true, so it matches every row.
+func (n *Norm) FindUserEmailsExceptScan(ctx context.Context, ids []UserID, opts ...Option) (*FindUserEmailsExceptResult, error) {
+	if n.middleware != nil {
+		var ret *FindUserEmailsExceptResult
+		err := n.run(ctx, &Call{Name: "FindUserEmailsExcept", Args: []interface{}{ids}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().FindUserEmailsExceptScan(ctx, ids, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("FindUserEmailsExcept")
+	atomic.AddInt64(queryCounts["FindUserEmailsExcept"], 1)
+	ctx, cancel := call.context(ctx)
+	result := FindUserEmailsExceptResult{deadline: &resultDeadline{cancel: cancel}}
+	query, args := expandSlices(`SELECT email
+FROM user
+WHERE user.id NOT IN ($1)
+ORDER BY id ASC`, []int{len(ids)}, []emptyIn{{29, 48, 0, true, false}})
+	for _, v := range ids {
+		args = append(args, v)
+	}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, query, false)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx, args...)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("FindUserEmailsExcept", result.rows, "Email"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendFindUserEmailsExcept is like FindUserEmailsExcept but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendFindUserEmailsExcept(ctx context.Context, dst []string, ids []UserID, opts ...Option) ([]string, error) {
+	res, err := n.FindUserEmailsExceptScan(ctx, ids, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o string
+		if err := res.Scan(&o); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("FindUserEmailsExcept", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) FindUserEmailsExcept(ctx context.Context, ids []UserID, opts ...Option) ([]string, error) {
+	return n.AppendFindUserEmailsExcept(ctx, nil, ids, opts...)
+}
+
+// FindUserEmailsExceptCacheKey returns a stable key of a call of FindUserEmailsExcept with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserEmailsExceptCacheKey(ids []UserID) string {
+	return cacheKey("FindUserEmailsExcept", map[string]interface{}{"ids": ids})
+}
+
+// Creates the user table
+func (n *Norm) CreateUserTable(ctx context.Context, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "CreateUserTable"}, func(ctx context.Context) error {
+			return n.bare().CreateUserTable(ctx, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("CreateUserTable")
+	atomic.AddInt64(queryCounts["CreateUserTable"], 1)
+	stmt, release, err := n.prepare(ctx, `CREATE TABLE user (
+	id integer primary key autoincrement,
+	email text
+)`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Creates the event table in the attached audit database
+func (n *Norm) CreateAuditEventTable(ctx context.Context, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "CreateAuditEventTable"}, func(ctx context.Context) error {
+			return n.bare().CreateAuditEventTable(ctx, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("CreateAuditEventTable")
+	atomic.AddInt64(queryCounts["CreateAuditEventTable"], 1)
+	stmt, release, err := n.prepare(ctx, `CREATE TABLE IF NOT EXISTS audit.event (
+	id integer primary key autoincrement,
+	msg text
+)`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Adds an event to the attached audit database
+func (n *Norm) AddAuditEvent(ctx context.Context, msg string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "AddAuditEvent", Args: []interface{}{msg}}, func(ctx context.Context) error {
+			return n.bare().AddAuditEvent(ctx, msg, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("AddAuditEvent")
+	atomic.AddInt64(queryCounts["AddAuditEvent"], 1)
+	stmt, release, err := n.prepare(ctx, `INSERT INTO audit.event(msg)
+VALUES ($1)`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx, msg)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type GetAuditEventsResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetAuditEventsResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetAuditEventsResult) Scan(Msg *string) error {
+	return res.rows.Scan(Msg)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetAuditEventsScan instead.
+func (res GetAuditEventsResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetAuditEventsResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetAuditEventsResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Reads the messages in the attached audit database
+func (n *Norm) GetAuditEventsScan(ctx context.Context, opts ...Option) (*GetAuditEventsResult, error) {
+	if n.middleware != nil {
+		var ret *GetAuditEventsResult
+		err := n.run(ctx, &Call{Name: "GetAuditEvents"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetAuditEventsScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetAuditEvents")
+	atomic.AddInt64(queryCounts["GetAuditEvents"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetAuditEventsResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT msg
+FROM audit.event
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetAuditEvents", result.rows, "Msg"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendGetAuditEvents is like GetAuditEvents but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetAuditEvents(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	res, err := n.GetAuditEventsScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o string
+		if err := res.Scan(&o); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetAuditEvents", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetAuditEvents(ctx context.Context, opts ...Option) ([]string, error) {
+	return n.AppendGetAuditEvents(ctx, nil, opts...)
+}
+
+// GetAuditEventsCacheKey returns a stable key of a call of GetAuditEvents with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetAuditEventsCacheKey() string {
+	return cacheKey("GetAuditEvents", map[string]interface{}{})
+}
+
+// UserDomain is a row of the user_domain view.
+// Views declared in the file get a generated model struct, a function
+// creating the view and a List function reading all of its rows. Add
+// !materialized to the block to also generate a Refresh function.
+type UserDomain struct {
+	ID     int
+	Domain string
+}
+
+// CreateUserDomainView creates the user_domain view.
+func (n *Norm) CreateUserDomainView(ctx context.Context) error {
+	_, err := n.db.ExecContext(ctx, `CREATE VIEW user_domain AS
+SELECT id, substr(email, instr(email, '@') + 1) AS domain
+FROM user`)
+	return err
+}
+
+type ListUserDomainResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res ListUserDomainResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res ListUserDomainResult) Scan(ID *int, Domain *string) error {
+	return res.rows.Scan(ID, Domain)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of ListUserDomainScan instead.
+func (res ListUserDomainResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res ListUserDomainResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res ListUserDomainResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// ListUserDomain reads all rows of the user_domain view.
+func (n *Norm) ListUserDomainScan(ctx context.Context, opts ...Option) (*ListUserDomainResult, error) {
+	if n.middleware != nil {
+		var ret *ListUserDomainResult
+		err := n.run(ctx, &Call{Name: "ListUserDomain"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().ListUserDomainScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("ListUserDomain")
+	atomic.AddInt64(queryCounts["ListUserDomain"], 1)
+	ctx, cancel := call.context(ctx)
+	result := ListUserDomainResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT * FROM user_domain`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("ListUserDomain", result.rows, "ID", "Domain"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendListUserDomain is like ListUserDomain but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendListUserDomain(ctx context.Context, dst []UserDomain, opts ...Option) ([]UserDomain, error) {
+	res, err := n.ListUserDomainScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o UserDomain
+		if err := res.Scan(&o.ID, &o.Domain); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("ListUserDomain", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) ListUserDomain(ctx context.Context, opts ...Option) ([]UserDomain, error) {
+	return n.AppendListUserDomain(ctx, nil, opts...)
+}
+
+type GetUserDomainsByDomainResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res GetUserDomainsByDomainResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res GetUserDomainsByDomainResult) Scan(ID *int, Domain *string) error {
+	return res.rows.Scan(ID, Domain)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of GetUserDomainsByDomainScan instead.
+func (res GetUserDomainsByDomainResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res GetUserDomainsByDomainResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res GetUserDomainsByDomainResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Other commands can read into the model of a view. Their outputs are
+// checked against the columns of the view.
+func (n *Norm) GetUserDomainsByDomainScan(ctx context.Context, domain string, opts ...Option) (*GetUserDomainsByDomainResult, error) {
+	if n.middleware != nil {
+		var ret *GetUserDomainsByDomainResult
+		err := n.run(ctx, &Call{Name: "GetUserDomainsByDomain", Args: []interface{}{domain}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().GetUserDomainsByDomainScan(ctx, domain, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("GetUserDomainsByDomain")
+	atomic.AddInt64(queryCounts["GetUserDomainsByDomain"], 1)
+	ctx, cancel := call.context(ctx)
+	result := GetUserDomainsByDomainResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `SELECT id, domain
+FROM user_domain
+WHERE domain = $1
+ORDER BY id ASC`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx, domain)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("GetUserDomainsByDomain", result.rows, "ID", "Domain"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AppendGetUserDomainsByDomain is like GetUserDomainsByDomain but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendGetUserDomainsByDomain(ctx context.Context, dst []UserDomain, domain string, opts ...Option) ([]UserDomain, error) {
+	res, err := n.GetUserDomainsByDomainScan(ctx, domain, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o UserDomain
+		if err := res.Scan(&o.ID, &o.Domain); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("GetUserDomainsByDomain", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) GetUserDomainsByDomain(ctx context.Context, domain string, opts ...Option) ([]UserDomain, error) {
+	return n.AppendGetUserDomainsByDomain(ctx, nil, domain, opts...)
+}
+
+// GetUserDomainsByDomainCacheKey returns a stable key of a call of GetUserDomainsByDomain with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserDomainsByDomainCacheKey(domain string) string {
+	return cacheKey("GetUserDomainsByDomain", map[string]interface{}{"domain": domain})
+}
+
+type FindUserAsOfOutput struct {
+	ID    int
+	Email string
+}
+
+// FindUserAsOfInto is like FindUserAsOf but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) FindUserAsOfInto(ctx context.Context, dst *FindUserAsOfOutput, email string, asOf time.Time, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "FindUserAsOf", Args: []interface{}{email, asOf}}, func(ctx context.Context) error {
+			return n.bare().FindUserAsOfInto(ctx, dst, email, asOf, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("FindUserAsOf")
+	atomic.AddInt64(queryCounts["FindUserAsOf"], 1)
+	query := asOfQuery(asOf, " FOR SYSTEM_TIME AS OF TIMESTAMP '%s'", `SELECT id, email
+FROM user`, `
+WHERE email = $1`)
+	stmt, release, err := n.prepare(ctx, query, asOf.IsZero())
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, email)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("FindUserAsOf", rows, "ID", "Email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(&dst.ID, &dst.Email); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Finds user by email as of a point in time, on a system-versioned user
+// table. The zero time reads the current rows, which is all sqlite does.
+func (n *Norm) FindUserAsOf(ctx context.Context, email string, asOf time.Time, opts ...Option) (*FindUserAsOfOutput, error) {
+	var o FindUserAsOfOutput
+	if err := n.FindUserAsOfInto(ctx, &o, email, asOf, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// FindUserAsOfCacheKey returns a stable key of a call of FindUserAsOf with
+// these parameters, for caches and request coalescers, see cacheKey.
+func FindUserAsOfCacheKey(email string, asOf time.Time) string {
+	return cacheKey("FindUserAsOf", map[string]interface{}{"email": email, "asOf": asOf})
+}
+
+// Records a login of a user. The login table is partitioned by month on
+// postgres, and the partition of the login is created first if needed.
+func (n *Norm) AddLogin(ctx context.Context, userID int, at time.Time, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "AddLogin", Args: []interface{}{userID, at}}, func(ctx context.Context) error {
+			return n.bare().AddLogin(ctx, userID, at, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("AddLogin")
+	atomic.AddInt64(queryCounts["AddLogin"], 1)
+	if err := n.CreateLoginPartition(ctx, at); err != nil {
+		return err
+	}
+	stmt, release, err := n.prepare(ctx, `INSERT INTO login (user_id, logged_in_at)
+VALUES ($1, $2)`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx, userID, at)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Creates the table of the encrypted personal data of the users
+func (n *Norm) CreateUserSecretTable(ctx context.Context, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "CreateUserSecretTable"}, func(ctx context.Context) error {
+			return n.bare().CreateUserSecretTable(ctx, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("CreateUserSecretTable")
+	atomic.AddInt64(queryCounts["CreateUserSecretTable"], 1)
+	stmt, release, err := n.prepare(ctx, `CREATE TABLE user_secret (
+  user_id integer PRIMARY KEY,
+  ssn blob NOT NULL
+)`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Stores the social security number of a user, encrypted by Encryption.
+func (n *Norm) SetUserSSN(ctx context.Context, userID int, ssn string, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "SetUserSSN", Args: []interface{}{userID, Redacted{}}}, func(ctx context.Context) error {
+			return n.bare().SetUserSSN(ctx, userID, ssn, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("SetUserSSN")
+	atomic.AddInt64(queryCounts["SetUserSSN"], 1)
+	stmt, release, err := n.prepare(ctx, `INSERT OR REPLACE INTO user_secret (user_id, ssn)
+VALUES ($1, $2)`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx, userID, encrypted{"ssn", ssn})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUserSSNInto is like GetUserSSN but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) GetUserSSNInto(ctx context.Context, dst *string, userID int, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "GetUserSSN", Args: []interface{}{userID}}, func(ctx context.Context) error {
+			return n.bare().GetUserSSNInto(ctx, dst, userID, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("GetUserSSN")
+	atomic.AddInt64(queryCounts["GetUserSSN"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT ssn
+FROM user_secret
+WHERE user_id = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("GetUserSSN", rows, "SSN"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(encrypted{"ssn", dst}); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Reads the social security number of a user, decrypted by Encryption.
+func (n *Norm) GetUserSSN(ctx context.Context, userID int, opts ...Option) (*string, error) {
+	var o string
+	if err := n.GetUserSSNInto(ctx, &o, userID, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// GetUserSSNCacheKey returns a stable key of a call of GetUserSSN with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserSSNCacheKey(userID int) string {
+	return cacheKey("GetUserSSN", map[string]interface{}{"userID": userID})
+}
+
+type GetUserByIDOutput struct {
+	ID    int
+	Email string
+}
+
+// GetUserByIDInto is like GetUserByID but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) GetUserByIDInto(ctx context.Context, dst *GetUserByIDOutput, id int, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "GetUserByID", Args: []interface{}{id}}, func(ctx context.Context) error {
+			return n.bare().GetUserByIDInto(ctx, dst, id, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("GetUserByID")
+	atomic.AddInt64(queryCounts["GetUserByID"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT id, email
+FROM user
+WHERE id = $1`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("GetUserByID", rows, "ID", "Email"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(&dst.ID, &dst.Email); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Gets a user by id, with the ETag of the row for UpdateUserEmailIfMatch.
+func (n *Norm) GetUserByID(ctx context.Context, id int, opts ...Option) (*GetUserByIDOutput, error) {
+	var o GetUserByIDOutput
+	if err := n.GetUserByIDInto(ctx, &o, id, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// ETag returns the entity tag of the row, a hash of its ID, Email,
+// quoted as in an ETag header.
+func (o GetUserByIDOutput) ETag() string {
+	data, _ := json.Marshal([]interface{}{o.ID, o.Email})
+	return fmt.Sprintf("\"%x\"", sha256.Sum256(data))
+}
+
+// GetUserByIDCacheKey returns a stable key of a call of GetUserByID with
+// these parameters, for caches and request coalescers, see cacheKey.
+func GetUserByIDCacheKey(id int) string {
+	return cacheKey("GetUserByID", map[string]interface{}{"id": id})
+}
+
+// Changes the email of a user.
+func (n *Norm) UpdateUserEmail(ctx context.Context, email string, id int, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "UpdateUserEmail", Args: []interface{}{email, id}}, func(ctx context.Context) error {
+			return n.bare().UpdateUserEmail(ctx, email, id, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("UpdateUserEmail")
+	atomic.AddInt64(queryCounts["UpdateUserEmail"], 1)
+	stmt, release, err := n.prepare(ctx, `UPDATE user
+SET email = $1
+WHERE id = $2`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = stmt.ExecContext(ctx, email, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateUserEmailIfMatch is like UpdateUserEmail, but only runs the query if the
+// ETag of the row read by GetUserByID is etag, and returns ErrETagMismatch
+// otherwise. The row is read and written in one serializable transaction,
+// unless the Norm already runs in a transaction.
+func (n *Norm) UpdateUserEmailIfMatch(ctx context.Context, email string, id int, etag string) error {
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := n.withDB(tx).UpdateUserEmailIfMatch(ctx, email, id, etag); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	current, err := n.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if current.ETag() != etag {
+		err = ErrETagMismatch
+		return err
+	}
+	return n.UpdateUserEmail(ctx, email, id)
+}
+
+type CountUsersByDomainResult struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res CountUsersByDomainResult) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res CountUsersByDomainResult) Scan(Domain *string, Users *int) error {
+	return res.rows.Scan(Domain, Users)
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of CountUsersByDomainScan instead.
+func (res CountUsersByDomainResult) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res CountUsersByDomainResult) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res CountUsersByDomainResult) Close() {
+	res.deadline.stop()
+	if res.rows != nil {
+		res.rows.Close()
+	}
+	if res.release != nil {
+		res.release()
+	}
+}
+
+// Counts the users of every email domain. The query keeps its blank lines,
+// since the block ends at !end rather than at the first blank line.
+func (n *Norm) CountUsersByDomainScan(ctx context.Context, opts ...Option) (*CountUsersByDomainResult, error) {
+	if n.middleware != nil {
+		var ret *CountUsersByDomainResult
+		err := n.run(ctx, &Call{Name: "CountUsersByDomain"}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().CountUsersByDomainScan(ctx, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	n.countCall("CountUsersByDomain")
+	atomic.AddInt64(queryCounts["CountUsersByDomain"], 1)
+	ctx, cancel := call.context(ctx)
+	result := CountUsersByDomainResult{deadline: &resultDeadline{cancel: cancel}}
+	var err error
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, `WITH domains AS (
+  SELECT substr(email, instr(email, '@') + 1) AS domain
+  FROM user
+)
+
+SELECT domain, count(*) AS users
+FROM domains
+GROUP BY domain
+ORDER BY domain`, true)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	result.rows, err = stmt.QueryContext(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if err = checkColumns("CountUsersByDomain", result.rows, "Domain", "Users"); err != nil {
+		result.Close()
+		return nil, err
+	}
+	return &result, nil
+}
+
+type CountUsersByDomainOutput struct {
+	Domain string
+	Users  int
+}
+
+// AppendCountUsersByDomain is like CountUsersByDomain but appends the rows to dst.
+// This allows reusing the same slice across calls.
+func (n *Norm) AppendCountUsersByDomain(ctx context.Context, dst []CountUsersByDomainOutput, opts ...Option) ([]CountUsersByDomainOutput, error) {
+	res, err := n.CountUsersByDomainScan(ctx, opts...)
+	if err != nil {
+		return dst, err
+	}
+	defer res.Close()
+	start := len(dst)
+	for res.Next() {
+		var o CountUsersByDomainOutput
+		if err := res.Scan(&o.Domain, &o.Users); err != nil {
+			return dst, err
+		}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("CountUsersByDomain", len(dst)-start)
+	}
+	return dst, nil
+}
+
+func (n *Norm) CountUsersByDomain(ctx context.Context, opts ...Option) ([]CountUsersByDomainOutput, error) {
+	return n.AppendCountUsersByDomain(ctx, nil, opts...)
+}
+
+// CountUsersByDomainCacheKey returns a stable key of a call of CountUsersByDomain with
+// these parameters, for caches and request coalescers, see cacheKey.
+func CountUsersByDomainCacheKey() string {
+	return cacheKey("CountUsersByDomain", map[string]interface{}{})
+}
+
+// MaintainSteps are the statements run by Maintain, in order.
+var MaintainSteps = []string{
+	"REINDEX",
+	"ANALYZE",
+	"VACUUM",
+}
+
+// Maintain runs MaintainSteps one after the other, outside of a
+// transaction unless the Norm runs inside one, and stops at the first
+// error. With dryRun, the statements are only reported to OnScriptStep.
+//
+// Rebuilds the indexes and the statistics of the query planner.
+func (n *Norm) Maintain(ctx context.Context, dryRun bool) error {
+	n.countCall("Maintain")
+	atomic.AddInt64(queryCounts["Maintain"], 1)
+	for ix, stmt := range MaintainSteps {
+		step := ScriptStep{Script: "Maintain", Step: ix + 1, Statement: stmt, DryRun: dryRun}
+		if !dryRun {
+			start := time.Now()
+			_, step.Err = n.db.ExecContext(ctx, stmt)
+			step.Elapsed = time.Since(start)
+		}
+		if OnScriptStep != nil {
+			OnScriptStep(step)
+		}
+		if step.Err != nil {
+			return fmt.Errorf("Maintain step %d: %v", step.Step, step.Err)
+		}
+	}
+	return nil
+}
+
+// CountUsersInto is like CountUsers but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+func (n *Norm) CountUsersInto(ctx context.Context, dst *int, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "CountUsers"}, func(ctx context.Context) error {
+			return n.bare().CountUsersInto(ctx, dst, opts...)
+		})
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+	n.countCall("CountUsers")
+	atomic.AddInt64(queryCounts["CountUsers"], 1)
+	stmt, release, err := n.prepare(ctx, `SELECT count(*) AS n
+FROM user`, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("CountUsers", rows, "n"); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan(dst); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Counts the users.
+func (n *Norm) CountUsers(ctx context.Context, opts ...Option) (*int, error) {
+	var o int
+	if err := n.CountUsersInto(ctx, &o, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// CountUsersCacheKey returns a stable key of a call of CountUsers with
+// these parameters, for caches and request coalescers, see cacheKey.
+func CountUsersCacheKey() string {
+	return cacheKey("CountUsers", map[string]interface{}{})
+}
+
+// Reader has the methods of Norm reading rows. Code depending on it, like
+// reports, cannot change data, see ReadOnlyNorm.
+type Reader interface {
+	GetUserListNoModelScan(ctx context.Context, opts ...Option) (*GetUserListNoModelResult, error)
+	AppendGetUserListNoModel(ctx context.Context, dst []GetUserListNoModelOutput, opts ...Option) ([]GetUserListNoModelOutput, error)
+	GetUserListNoModel(ctx context.Context, opts ...Option) ([]GetUserListNoModelOutput, error)
+	GetUserEmailsNoModelScan(ctx context.Context, opts ...Option) (*GetUserEmailsNoModelResult, error)
+	AppendGetUserEmailsNoModel(ctx context.Context, dst []string, opts ...Option) ([]string, error)
+	GetUserEmailsNoModel(ctx context.Context, opts ...Option) ([]string, error)
+	GetUserListLimitedScan(ctx context.Context, opts ...Option) (*GetUserListLimitedResult, error)
+	AppendGetUserListLimited(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserListLimited(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserListPagedScan(ctx context.Context, opts ...Option) (*GetUserListPagedResult, error)
+	AppendGetUserListPaged(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserListPaged(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error)
+	GetUserRowsScan(ctx context.Context, opts ...Option) (*GetUserRowsResult, error)
+	AppendGetUserRows(ctx context.Context, dst []GetUserRowsOutput, opts ...Option) ([]GetUserRowsOutput, error)
+	GetUserRows(ctx context.Context, opts ...Option) ([]GetUserRowsOutput, error)
+	GetUserListWithModelScan(ctx context.Context, opts ...Option) (*GetUserListWithModelResult, error)
+	AppendGetUserListWithModel(ctx context.Context, dst []User, opts ...Option) ([]User, error)
+	GetUserListWithModel(ctx context.Context, opts ...Option) ([]User, error)
+	CompareGetUserListWithModel(ctx context.Context, other *Norm) (Diff, error)
+	FindUserInto(ctx context.Context, dst *FindUserOutput, email string, opts ...Option) error
+	FindUser(ctx context.Context, email string, opts ...Option) (*FindUserOutput, error)
+	CompareFindUser(ctx context.Context, other *Norm, email string) (Diff, error)
+	FindUserWithModelInto(ctx context.Context, dst *User, email string, opts ...Option) error
+	FindUserWithModel(ctx context.Context, email string, opts ...Option) (*User, error)
+	FindUserSwappedColumnsInto(ctx context.Context, dst *FindUserSwappedColumnsOutput, email string, opts ...Option) error
+	FindUserSwappedColumns(ctx context.Context, email string, opts ...Option) (*FindUserSwappedColumnsOutput, error)
+	FindUserByEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error
+	FindUserByEmail(ctx context.Context, email string, opts ...Option) (*string, error)
+	FindUsersNamedScan(ctx context.Context, email string, domain string, opts ...Option) (*FindUsersNamedResult, error)
+	AppendFindUsersNamed(ctx context.Context, dst []int, email string, domain string, opts ...Option) ([]int, error)
+	FindUsersNamed(ctx context.Context, email string, domain string, opts ...Option) ([]int, error)
+	FindUserEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error
+	FindUserEmail(ctx context.Context, email string, opts ...Option) (*string, error)
+	FindUserByIDInto(ctx context.Context, dst *FindUserByIDOutput, id UserID, opts ...Option) error
+	FindUserByID(ctx context.Context, id UserID, opts ...Option) (*FindUserByIDOutput, error)
+	FindUserEmailOrEmptyInto(ctx context.Context, dst *string, id UserID, opts ...Option) error
+	FindUserEmailOrEmpty(ctx context.Context, id UserID, opts ...Option) (*string, error)
+	GetUserEmailsOrEmptyScan(ctx context.Context, opts ...Option) (*GetUserEmailsOrEmptyResult, error)
+	AppendGetUserEmailsOrEmpty(ctx context.Context, dst []GetUserEmailsOrEmptyOutput, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error)
+	GetUserEmailsOrEmpty(ctx context.Context, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error)
+	GetUserAccountsScan(ctx context.Context, opts ...Option) (*GetUserAccountsResult, error)
+	AppendGetUserAccounts(ctx context.Context, dst []UserAccount, opts ...Option) ([]UserAccount, error)
+	GetUserAccounts(ctx context.Context, opts ...Option) ([]UserAccount, error)
+	GetUserContactsScan(ctx context.Context, opts ...Option) (*GetUserContactsResult, error)
+	AppendGetUserContacts(ctx context.Context, dst []UserContact, opts ...Option) ([]UserContact, error)
+	GetUserContacts(ctx context.Context, opts ...Option) ([]UserContact, error)
+	FindUserEmailsByIDsScan(ctx context.Context, ids []UserID, domain string, opts ...Option) (*FindUserEmailsByIDsResult, error)
+	AppendFindUserEmailsByIDs(ctx context.Context, dst []string, ids []UserID, domain string, opts ...Option) ([]string, error)
+	FindUserEmailsByIDs(ctx context.Context, ids []UserID, domain string, opts ...Option) ([]string, error)
+	GetTenantUserEmailsScan(ctx context.Context, opts ...Option) (*GetTenantUserEmailsResult, error)
+	AppendGetTenantUserEmails(ctx context.Context, dst []string, opts ...Option) ([]string, error)
+	GetTenantUserEmails(ctx context.Context, opts ...Option) ([]string, error)
+	FindUserEmailsExceptScan(ctx context.Context, ids []UserID, opts ...Option) (*FindUserEmailsExceptResult, error)
+	AppendFindUserEmailsExcept(ctx context.Context, dst []string, ids []UserID, opts ...Option) ([]string, error)
+	FindUserEmailsExcept(ctx context.Context, ids []UserID, opts ...Option) ([]string, error)
+	GetAuditEventsScan(ctx context.Context, opts ...Option) (*GetAuditEventsResult, error)
+	AppendGetAuditEvents(ctx context.Context, dst []string, opts ...Option) ([]string, error)
+	GetAuditEvents(ctx context.Context, opts ...Option) ([]string, error)
+	FindMembershipRoleInto(ctx context.Context, dst *string, key MembershipKey, opts ...Option) error
+	FindMembershipRole(ctx context.Context, key MembershipKey, opts ...Option) (*string, error)
+	ListUserDomainScan(ctx context.Context, opts ...Option) (*ListUserDomainResult, error)
+	AppendListUserDomain(ctx context.Context, dst []UserDomain, opts ...Option) ([]UserDomain, error)
+	ListUserDomain(ctx context.Context, opts ...Option) ([]UserDomain, error)
+	GetUserDomainsByDomainScan(ctx context.Context, domain string, opts ...Option) (*GetUserDomainsByDomainResult, error)
+	AppendGetUserDomainsByDomain(ctx context.Context, dst []UserDomain, domain string, opts ...Option) ([]UserDomain, error)
+	GetUserDomainsByDomain(ctx context.Context, domain string, opts ...Option) ([]UserDomain, error)
+	FindUserAsOfInto(ctx context.Context, dst *FindUserAsOfOutput, email string, asOf time.Time, opts ...Option) error
+	FindUserAsOf(ctx context.Context, email string, asOf time.Time, opts ...Option) (*FindUserAsOfOutput, error)
+	GetUserSSNInto(ctx context.Context, dst *string, userID int, opts ...Option) error
+	GetUserSSN(ctx context.Context, userID int, opts ...Option) (*string, error)
+	GetUserByIDInto(ctx context.Context, dst *GetUserByIDOutput, id int, opts ...Option) error
+	GetUserByID(ctx context.Context, id int, opts ...Option) (*GetUserByIDOutput, error)
+	CountUsersByDomainScan(ctx context.Context, opts ...Option) (*CountUsersByDomainResult, error)
+	AppendCountUsersByDomain(ctx context.Context, dst []CountUsersByDomainOutput, opts ...Option) ([]CountUsersByDomainOutput, error)
+	CountUsersByDomain(ctx context.Context, opts ...Option) ([]CountUsersByDomainOutput, error)
+	CountUsersInto(ctx context.Context, dst *int, opts ...Option) error
+	CountUsers(ctx context.Context, opts ...Option) (*int, error)
+}
+
+// Writer has the methods of Norm running statements, and the other methods
+// not in Reader.
+type Writer interface {
+	WarmUp(ctx context.Context) error
+	CreateAuditLogTable(ctx context.Context) error
+	CreateLoginPartition(ctx context.Context, t time.Time) error
+	AddUser(ctx context.Context, email string, opts ...Option) error
+	AddUsers(ctx context.Context, rows []AddUsersRow, opts ...Option) error
+	CopyUsers(ctx context.Context, rows []CopyUsersRow, opts ...Option) error
+	CopyUsersFrom(ctx context.Context, next func() (CopyUsersRow, bool, error), opts ...Option) error
+	DeleteAllUsers(ctx context.Context, opts ...Option) error
+	AddUserResult(ctx context.Context, email string, opts ...Option) (sql.Result, error)
+	AddUserReturningInto(ctx context.Context, dst *User, email string, opts ...Option) error
+	AddUserReturning(ctx context.Context, email string, opts ...Option) (*User, error)
+	DeleteUser(ctx context.Context, email string, opts ...Option) (int64, error)
+	CreateUserTable(ctx context.Context, opts ...Option) error
+	CreateAuditEventTable(ctx context.Context, opts ...Option) error
+	AddAuditEvent(ctx context.Context, msg string, opts ...Option) error
+	CreateMembershipTable(ctx context.Context, opts ...Option) error
+	AddMembership(ctx context.Context, key MembershipKey, role string, opts ...Option) error
+	DeleteMembership(ctx context.Context, key MembershipKey, opts ...Option) (int64, error)
+	CreateUserDomainView(ctx context.Context) error
+	AddLogin(ctx context.Context, userID int, at time.Time, opts ...Option) error
+	CreateUserSecretTable(ctx context.Context, opts ...Option) error
+	SetUserSSN(ctx context.Context, userID int, ssn string, opts ...Option) error
+	UpdateUserEmail(ctx context.Context, email string, id int, opts ...Option) error
+	UpdateUserEmailIfMatch(ctx context.Context, email string, id int, etag string) error
+	Maintain(ctx context.Context, dryRun bool) error
+}
+
+// Querier has the query methods of Norm. Code depending on it instead of *Norm
+// can be tested with fakes.
+type Querier interface {
+	Reader
+	Writer
+}
+
+var _ Querier = (*Norm)(nil)
+
+// ReadOnlyNorm has the methods of Norm in Reader and none writing to the
+// database, so that the compiler rejects writes from the code using it. Open
+// it with the DSN of a read only user or replica, so that the database
+// rejects them too.
+type ReadOnlyNorm struct {
+	n *Norm
+}
+
+// NewReadOnlyNorm opens the database like NewNorm and returns a ReadOnlyNorm
+// using it.
+func NewReadOnlyNorm(driverName, dataSourceName string) (*ReadOnlyNorm, error) {
+	n, err := NewNorm(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadOnlyNorm{n}, nil
+}
+
+// ReadOnly returns a ReadOnlyNorm running the queries of n.
+func (n *Norm) ReadOnly() *ReadOnlyNorm {
+	return &ReadOnlyNorm{n}
+}
+
+// Close closes the Norm of r.
+func (r *ReadOnlyNorm) Close() error {
+	return r.n.Close()
+}
+
+var _ Reader = (*ReadOnlyNorm)(nil)
+
+func (r *ReadOnlyNorm) GetUserListNoModelScan(ctx context.Context, opts ...Option) (*GetUserListNoModelResult, error) {
+	return r.n.GetUserListNoModelScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserListNoModel(ctx context.Context, dst []GetUserListNoModelOutput, opts ...Option) ([]GetUserListNoModelOutput, error) {
+	return r.n.AppendGetUserListNoModel(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserListNoModel(ctx context.Context, opts ...Option) ([]GetUserListNoModelOutput, error) {
+	return r.n.GetUserListNoModel(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserEmailsNoModelScan(ctx context.Context, opts ...Option) (*GetUserEmailsNoModelResult, error) {
+	return r.n.GetUserEmailsNoModelScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserEmailsNoModel(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	return r.n.AppendGetUserEmailsNoModel(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserEmailsNoModel(ctx context.Context, opts ...Option) ([]string, error) {
+	return r.n.GetUserEmailsNoModel(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserListLimitedScan(ctx context.Context, opts ...Option) (*GetUserListLimitedResult, error) {
+	return r.n.GetUserListLimitedScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserListLimited(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	return r.n.AppendGetUserListLimited(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserListLimited(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	return r.n.GetUserListLimited(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserListPagedScan(ctx context.Context, opts ...Option) (*GetUserListPagedResult, error) {
+	return r.n.GetUserListPagedScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserListPaged(ctx context.Context, dst []GetUserListLimitedOutput, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	return r.n.AppendGetUserListPaged(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserListPaged(ctx context.Context, opts ...Option) ([]GetUserListLimitedOutput, error) {
+	return r.n.GetUserListPaged(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserRowsScan(ctx context.Context, opts ...Option) (*GetUserRowsResult, error) {
+	return r.n.GetUserRowsScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserRows(ctx context.Context, dst []GetUserRowsOutput, opts ...Option) ([]GetUserRowsOutput, error) {
+	return r.n.AppendGetUserRows(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserRows(ctx context.Context, opts ...Option) ([]GetUserRowsOutput, error) {
+	return r.n.GetUserRows(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserListWithModelScan(ctx context.Context, opts ...Option) (*GetUserListWithModelResult, error) {
+	return r.n.GetUserListWithModelScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserListWithModel(ctx context.Context, dst []User, opts ...Option) ([]User, error) {
+	return r.n.AppendGetUserListWithModel(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserListWithModel(ctx context.Context, opts ...Option) ([]User, error) {
+	return r.n.GetUserListWithModel(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) CompareGetUserListWithModel(ctx context.Context, other *Norm) (Diff, error) {
+	return r.n.CompareGetUserListWithModel(ctx, other)
+}
+
+func (r *ReadOnlyNorm) FindUserInto(ctx context.Context, dst *FindUserOutput, email string, opts ...Option) error {
+	return r.n.FindUserInto(ctx, dst, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUser(ctx context.Context, email string, opts ...Option) (*FindUserOutput, error) {
+	return r.n.FindUser(ctx, email, opts...)
+}
+
+func (r *ReadOnlyNorm) CompareFindUser(ctx context.Context, other *Norm, email string) (Diff, error) {
+	return r.n.CompareFindUser(ctx, other, email)
+}
+
+func (r *ReadOnlyNorm) FindUserWithModelInto(ctx context.Context, dst *User, email string, opts ...Option) error {
+	return r.n.FindUserWithModelInto(ctx, dst, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserWithModel(ctx context.Context, email string, opts ...Option) (*User, error) {
+	return r.n.FindUserWithModel(ctx, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserSwappedColumnsInto(ctx context.Context, dst *FindUserSwappedColumnsOutput, email string, opts ...Option) error {
+	return r.n.FindUserSwappedColumnsInto(ctx, dst, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserSwappedColumns(ctx context.Context, email string, opts ...Option) (*FindUserSwappedColumnsOutput, error) {
+	return r.n.FindUserSwappedColumns(ctx, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserByEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error {
+	return r.n.FindUserByEmailInto(ctx, dst, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserByEmail(ctx context.Context, email string, opts ...Option) (*string, error) {
+	return r.n.FindUserByEmail(ctx, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUsersNamedScan(ctx context.Context, email string, domain string, opts ...Option) (*FindUsersNamedResult, error) {
+	return r.n.FindUsersNamedScan(ctx, email, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendFindUsersNamed(ctx context.Context, dst []int, email string, domain string, opts ...Option) ([]int, error) {
+	return r.n.AppendFindUsersNamed(ctx, dst, email, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUsersNamed(ctx context.Context, email string, domain string, opts ...Option) ([]int, error) {
+	return r.n.FindUsersNamed(ctx, email, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmailInto(ctx context.Context, dst *string, email string, opts ...Option) error {
+	return r.n.FindUserEmailInto(ctx, dst, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmail(ctx context.Context, email string, opts ...Option) (*string, error) {
+	return r.n.FindUserEmail(ctx, email, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserByIDInto(ctx context.Context, dst *FindUserByIDOutput, id UserID, opts ...Option) error {
+	return r.n.FindUserByIDInto(ctx, dst, id, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserByID(ctx context.Context, id UserID, opts ...Option) (*FindUserByIDOutput, error) {
+	return r.n.FindUserByID(ctx, id, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmailOrEmptyInto(ctx context.Context, dst *string, id UserID, opts ...Option) error {
+	return r.n.FindUserEmailOrEmptyInto(ctx, dst, id, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmailOrEmpty(ctx context.Context, id UserID, opts ...Option) (*string, error) {
+	return r.n.FindUserEmailOrEmpty(ctx, id, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserEmailsOrEmptyScan(ctx context.Context, opts ...Option) (*GetUserEmailsOrEmptyResult, error) {
+	return r.n.GetUserEmailsOrEmptyScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserEmailsOrEmpty(ctx context.Context, dst []GetUserEmailsOrEmptyOutput, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error) {
+	return r.n.AppendGetUserEmailsOrEmpty(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserEmailsOrEmpty(ctx context.Context, opts ...Option) ([]GetUserEmailsOrEmptyOutput, error) {
+	return r.n.GetUserEmailsOrEmpty(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserAccountsScan(ctx context.Context, opts ...Option) (*GetUserAccountsResult, error) {
+	return r.n.GetUserAccountsScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserAccounts(ctx context.Context, dst []UserAccount, opts ...Option) ([]UserAccount, error) {
+	return r.n.AppendGetUserAccounts(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserAccounts(ctx context.Context, opts ...Option) ([]UserAccount, error) {
+	return r.n.GetUserAccounts(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserContactsScan(ctx context.Context, opts ...Option) (*GetUserContactsResult, error) {
+	return r.n.GetUserContactsScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserContacts(ctx context.Context, dst []UserContact, opts ...Option) ([]UserContact, error) {
+	return r.n.AppendGetUserContacts(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserContacts(ctx context.Context, opts ...Option) ([]UserContact, error) {
+	return r.n.GetUserContacts(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmailsByIDsScan(ctx context.Context, ids []UserID, domain string, opts ...Option) (*FindUserEmailsByIDsResult, error) {
+	return r.n.FindUserEmailsByIDsScan(ctx, ids, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendFindUserEmailsByIDs(ctx context.Context, dst []string, ids []UserID, domain string, opts ...Option) ([]string, error) {
+	return r.n.AppendFindUserEmailsByIDs(ctx, dst, ids, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmailsByIDs(ctx context.Context, ids []UserID, domain string, opts ...Option) ([]string, error) {
+	return r.n.FindUserEmailsByIDs(ctx, ids, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) GetTenantUserEmailsScan(ctx context.Context, opts ...Option) (*GetTenantUserEmailsResult, error) {
+	return r.n.GetTenantUserEmailsScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetTenantUserEmails(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	return r.n.AppendGetTenantUserEmails(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetTenantUserEmails(ctx context.Context, opts ...Option) ([]string, error) {
+	return r.n.GetTenantUserEmails(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmailsExceptScan(ctx context.Context, ids []UserID, opts ...Option) (*FindUserEmailsExceptResult, error) {
+	return r.n.FindUserEmailsExceptScan(ctx, ids, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendFindUserEmailsExcept(ctx context.Context, dst []string, ids []UserID, opts ...Option) ([]string, error) {
+	return r.n.AppendFindUserEmailsExcept(ctx, dst, ids, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserEmailsExcept(ctx context.Context, ids []UserID, opts ...Option) ([]string, error) {
+	return r.n.FindUserEmailsExcept(ctx, ids, opts...)
+}
+
+func (r *ReadOnlyNorm) GetAuditEventsScan(ctx context.Context, opts ...Option) (*GetAuditEventsResult, error) {
+	return r.n.GetAuditEventsScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetAuditEvents(ctx context.Context, dst []string, opts ...Option) ([]string, error) {
+	return r.n.AppendGetAuditEvents(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) GetAuditEvents(ctx context.Context, opts ...Option) ([]string, error) {
+	return r.n.GetAuditEvents(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) FindMembershipRoleInto(ctx context.Context, dst *string, key MembershipKey, opts ...Option) error {
+	return r.n.FindMembershipRoleInto(ctx, dst, key, opts...)
+}
+
+func (r *ReadOnlyNorm) FindMembershipRole(ctx context.Context, key MembershipKey, opts ...Option) (*string, error) {
+	return r.n.FindMembershipRole(ctx, key, opts...)
+}
+
+func (r *ReadOnlyNorm) ListUserDomainScan(ctx context.Context, opts ...Option) (*ListUserDomainResult, error) {
+	return r.n.ListUserDomainScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendListUserDomain(ctx context.Context, dst []UserDomain, opts ...Option) ([]UserDomain, error) {
+	return r.n.AppendListUserDomain(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) ListUserDomain(ctx context.Context, opts ...Option) ([]UserDomain, error) {
+	return r.n.ListUserDomain(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserDomainsByDomainScan(ctx context.Context, domain string, opts ...Option) (*GetUserDomainsByDomainResult, error) {
+	return r.n.GetUserDomainsByDomainScan(ctx, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendGetUserDomainsByDomain(ctx context.Context, dst []UserDomain, domain string, opts ...Option) ([]UserDomain, error) {
+	return r.n.AppendGetUserDomainsByDomain(ctx, dst, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserDomainsByDomain(ctx context.Context, domain string, opts ...Option) ([]UserDomain, error) {
+	return r.n.GetUserDomainsByDomain(ctx, domain, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserAsOfInto(ctx context.Context, dst *FindUserAsOfOutput, email string, asOf time.Time, opts ...Option) error {
+	return r.n.FindUserAsOfInto(ctx, dst, email, asOf, opts...)
+}
+
+func (r *ReadOnlyNorm) FindUserAsOf(ctx context.Context, email string, asOf time.Time, opts ...Option) (*FindUserAsOfOutput, error) {
+	return r.n.FindUserAsOf(ctx, email, asOf, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserSSNInto(ctx context.Context, dst *string, userID int, opts ...Option) error {
+	return r.n.GetUserSSNInto(ctx, dst, userID, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserSSN(ctx context.Context, userID int, opts ...Option) (*string, error) {
+	return r.n.GetUserSSN(ctx, userID, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserByIDInto(ctx context.Context, dst *GetUserByIDOutput, id int, opts ...Option) error {
+	return r.n.GetUserByIDInto(ctx, dst, id, opts...)
+}
+
+func (r *ReadOnlyNorm) GetUserByID(ctx context.Context, id int, opts ...Option) (*GetUserByIDOutput, error) {
+	return r.n.GetUserByID(ctx, id, opts...)
+}
+
+func (r *ReadOnlyNorm) CountUsersByDomainScan(ctx context.Context, opts ...Option) (*CountUsersByDomainResult, error) {
+	return r.n.CountUsersByDomainScan(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) AppendCountUsersByDomain(ctx context.Context, dst []CountUsersByDomainOutput, opts ...Option) ([]CountUsersByDomainOutput, error) {
+	return r.n.AppendCountUsersByDomain(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) CountUsersByDomain(ctx context.Context, opts ...Option) ([]CountUsersByDomainOutput, error) {
+	return r.n.CountUsersByDomain(ctx, opts...)
+}
+
+func (r *ReadOnlyNorm) CountUsersInto(ctx context.Context, dst *int, opts ...Option) error {
+	return r.n.CountUsersInto(ctx, dst, opts...)
+}
+
+func (r *ReadOnlyNorm) CountUsers(ctx context.Context, opts ...Option) (*int, error) {
+	return r.n.CountUsers(ctx, opts...)
+}
@@ -1,16 +1,26 @@
 package example
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var db *sql.DB
+var store *Norm
+var ctx = context.Background()
 
 func TestMain(m *testing.M) {
 	// Setup
@@ -19,15 +29,36 @@ func TestMain(m *testing.M) {
 		panic("Could not create tmp file")
 	}
 	defer os.Remove(f.Name())
-	db, err = sql.Open("sqlite3", fmt.Sprintf("file:%s", f.Name()))
+	db, err = Open("sqlite3", fmt.Sprintf("file:%s", f.Name()))
 	if err != nil {
 		panic("Could not open database")
 	}
-	defer db.Close()
-	err = CreateUserTable(db)
+	store = NewNormFromDB(db)
+	defer store.Close()
+	err = store.CreateUserTable(ctx)
 	if err != nil {
 		panic("Could not create user table")
 	}
+	err = store.CreateUserDomainView(ctx)
+	if err != nil {
+		panic("Could not create user_domain view")
+	}
+	err = store.CreateAuditEventTable(ctx)
+	if err != nil {
+		panic("Could not create audit event table")
+	}
+	err = store.CreateMembershipTable(ctx)
+	if err != nil {
+		panic("Could not create membership table")
+	}
+	err = store.CreateAuditLogTable(ctx)
+	if err != nil {
+		panic("Could not create audit log table")
+	}
+	err = store.CreateUserSecretTable(ctx)
+	if err != nil {
+		panic("Could not create user secret table")
+	}
 
 	code := m.Run()
 
@@ -35,7 +66,7 @@ func TestMain(m *testing.M) {
 }
 
 func deleteAllUsers() {
-	err := DeleteAllUsers(db)
+	err := store.DeleteAllUsers(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -43,12 +74,12 @@ func deleteAllUsers() {
 
 func TestReadOneMultiOutput(t *testing.T) {
 	email := "test@dummyemail.com"
-	err := AddUser(db, "test@dummyemail.com")
+	err := store.AddUser(ctx, "test@dummyemail.com")
 	if err != nil {
 		panic(err)
 	}
 	defer deleteAllUsers()
-	output, err := FindUser(db, email)
+	output, err := store.FindUser(ctx, email)
 	if err != nil {
 		panic(err)
 	}
@@ -59,12 +90,12 @@ func TestReadOneMultiOutput(t *testing.T) {
 
 func TestReadOneSingleOutput(t *testing.T) {
 	email := "test@dummyemail.com"
-	err := AddUser(db, "test@dummyemail.com")
+	err := store.AddUser(ctx, "test@dummyemail.com")
 	if err != nil {
 		panic(err)
 	}
 	defer deleteAllUsers()
-	foundEmail, err := FindUserEmail(db, email)
+	foundEmail, err := store.FindUserEmail(ctx, email)
 	if err != nil {
 		panic(err)
 	}
@@ -75,7 +106,7 @@ func TestReadOneSingleOutput(t *testing.T) {
 
 func TestReadOneSingleOutputWhenNotFound(t *testing.T) {
 	email := "test@dummyemail.com"
-	_, err := FindUserEmail(db, email)
+	_, err := store.FindUserEmail(ctx, email)
 	if err == nil {
 		t.Error("Should have an error because this user does not exist")
 	}
@@ -85,13 +116,13 @@ func TestMultiReadNoModel(t *testing.T) {
 	// keep sorted
 	emails := []string{"a@a.com", "b@b.com"}
 	for _, e := range emails {
-		err := AddUser(db, e)
+		err := store.AddUser(ctx, e)
 		if err != nil {
 			panic(err)
 		}
 	}
 	defer deleteAllUsers()
-	userlist, err := GetUserListNoModel(db)
+	userlist, err := store.GetUserListNoModel(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -109,13 +140,13 @@ func TestMultiReadWithModel(t *testing.T) {
 	// keep sorted
 	emails := []string{"a@a.com", "b@b.com"}
 	for _, e := range emails {
-		err := AddUser(db, e)
+		err := store.AddUser(ctx, e)
 		if err != nil {
 			panic(err)
 		}
 	}
 	defer deleteAllUsers()
-	userlist, err := GetUserListWithModel(db)
+	userlist, err := store.GetUserListWithModel(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -133,13 +164,13 @@ func TestMultiReadSingleColumnNoModel(t *testing.T) {
 	// keep sorted
 	emails := []string{"a@a.com", "b@b.com"}
 	for _, e := range emails {
-		err := AddUser(db, e)
+		err := store.AddUser(ctx, e)
 		if err != nil {
 			panic(err)
 		}
 	}
 	defer deleteAllUsers()
-	userlist, err := GetUserEmailsNoModel(db)
+	userlist, err := store.GetUserEmailsNoModel(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -152,3 +183,1455 @@ func TestMultiReadSingleColumnNoModel(t *testing.T) {
 		}
 	}
 }
+
+func TestLargeResultHook(t *testing.T) {
+	emails := []string{"a@a.com", "b@b.com"}
+	for _, e := range emails {
+		err := store.AddUser(ctx, e)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	oldThreshold, oldHook := LargeResultThreshold, OnLargeResult
+	defer func() {
+		LargeResultThreshold, OnLargeResult = oldThreshold, oldHook
+	}()
+	var reported string
+	var reportedRows int
+	OnLargeResult = func(funcName string, rows int) {
+		reported, reportedRows = funcName, rows
+	}
+	LargeResultThreshold = len(emails)
+	if _, err := store.GetUserEmailsNoModel(ctx); err != nil {
+		panic(err)
+	}
+	if reported != "" {
+		t.Error("Hook should not be called at the threshold")
+	}
+	LargeResultThreshold = len(emails) - 1
+	if _, err := store.GetUserEmailsNoModel(ctx); err != nil {
+		panic(err)
+	}
+	if reported != "GetUserEmailsNoModel" || reportedRows != len(emails) {
+		t.Errorf("Unexpected hook call: %q %d", reported, reportedRows)
+	}
+}
+
+func TestAppendReusesSlice(t *testing.T) {
+	emails := []string{"a@a.com", "b@b.com"}
+	for _, e := range emails {
+		err := store.AddUser(ctx, e)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	buf := make([]User, 0, 8)
+	for i := 0; i < 3; i++ {
+		userlist, err := store.AppendGetUserListWithModel(ctx, buf[:0])
+		if err != nil {
+			panic(err)
+		}
+		if len(userlist) != len(emails) {
+			t.Error("Did not find all emails")
+		}
+		if &userlist[0] != &buf[:1][0] {
+			t.Error("Append did not reuse the provided slice")
+		}
+	}
+	prefix := []string{"z@z.com"}
+	all, err := store.AppendGetUserEmailsNoModel(ctx, prefix)
+	if err != nil {
+		panic(err)
+	}
+	if len(all) != 3 || all[0] != "z@z.com" || all[1] != emails[0] {
+		t.Errorf("Unexpected appended result: %v", all)
+	}
+}
+
+func TestReadOneInto(t *testing.T) {
+	email := "test@dummyemail.com"
+	err := store.AddUser(ctx, email)
+	if err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	var output FindUserOutput
+	if err := store.FindUserInto(ctx, &output, email); err != nil {
+		panic(err)
+	}
+	if output.Email != email {
+		t.Error("Emails did not match round trip")
+	}
+	user := User{ID: -1}
+	if err := store.FindUserWithModelInto(ctx, &user, email); err != nil {
+		panic(err)
+	}
+	if user.Email != email || user.ID == -1 {
+		t.Error("User was not read into the model")
+	}
+	var foundEmail string
+	if err := store.FindUserEmailInto(ctx, &foundEmail, email); err != nil {
+		panic(err)
+	}
+	if foundEmail != email {
+		t.Error("Emails did not match")
+	}
+}
+
+func TestView(t *testing.T) {
+	emails := []string{"a@a.com", "b@b.com", "c@a.com"}
+	for _, e := range emails {
+		err := store.AddUser(ctx, e)
+		if err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	domains, err := store.ListUserDomain(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(domains) != len(emails) {
+		t.Error("Did not find all rows of the view")
+	}
+	found, err := store.GetUserDomainsByDomain(ctx, "a.com")
+	if err != nil {
+		panic(err)
+	}
+	if len(found) != 2 || found[0].Domain != "a.com" {
+		t.Errorf("Unexpected rows: %v", found)
+	}
+}
+
+func TestCheckColumns(t *testing.T) {
+	email := "test@dummyemail.com"
+	err := store.AddUser(ctx, email)
+	if err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	if _, err := store.FindUserSwappedColumns(ctx, email); err == nil {
+		t.Error("Should have an error because the columns are swapped")
+	}
+	if _, err := store.FindUser(ctx, "nobody@dummyemail.com"); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestDeprecatedHook(t *testing.T) {
+	email := "test@dummyemail.com"
+	err := store.AddUser(ctx, email)
+	if err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	oldHook := OnDeprecatedUse
+	defer func() {
+		OnDeprecatedUse = oldHook
+	}()
+	var reported []string
+	OnDeprecatedUse = func(funcName, msg string) {
+		reported = append(reported, funcName+": "+msg)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := store.FindUserByEmail(ctx, email); err != nil {
+			panic(err)
+		}
+	}
+	if len(reported) != 1 || reported[0] != "FindUserByEmail: use FindUserEmail instead." {
+		t.Errorf("Unexpected hook calls: %q", reported)
+	}
+}
+
+func TestIfdef(t *testing.T) {
+	for _, e := range []string{"a@a.com", "b@b.com"} {
+		if err := store.AddUser(ctx, e); err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	n, err := store.CountUsers(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if *n != 2 {
+		t.Errorf("Expected 2 users, got %d", *n)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	for _, msg := range []string{"a", "b"} {
+		if err := store.AddAuditEvent(ctx, msg); err != nil {
+			panic(err)
+		}
+	}
+	msgs, err := store.GetAuditEvents(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(msgs) != 2 || msgs[0] != "a" || msgs[1] != "b" {
+		t.Errorf("Unexpected messages: %v", msgs)
+	}
+}
+
+func TestPragma(t *testing.T) {
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		panic(err)
+	}
+	if timeout != 5000 {
+		t.Errorf("Expected busy_timeout 5000, got %d", timeout)
+	}
+}
+
+func TestOnConnect(t *testing.T) {
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM temp.session").Scan(&n); err != nil {
+		t.Errorf("Temp table from !on_connect is missing: %v", err)
+	}
+	oldHook := OnConnect
+	defer func() {
+		OnConnect = oldHook
+	}()
+	connects := 0
+	OnConnect = func(ctx context.Context, conn driver.Conn) error {
+		connects++
+		return nil
+	}
+	db2, err := Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	defer db2.Close()
+	if err := db2.Ping(); err != nil {
+		panic(err)
+	}
+	if connects != 1 {
+		t.Errorf("Expected 1 call to OnConnect, got %d", connects)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.GetUserEmailsNoModel(canceled); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if err := store.AddUser(canceled, "test@dummyemail.com"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMySQLProgramName(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"user@tcp(db)/app":          "user@tcp(db)/app?connectionAttributes=program_name:norm-example",
+		"user@tcp(db)/app?tls=true": "user@tcp(db)/app?tls=true&connectionAttributes=program_name:norm-example",
+	} {
+		if got := mysqlProgramName(dsn, ApplicationName); got != want {
+			t.Errorf("mysqlProgramName(%q) = %q, want %q", dsn, got, want)
+		}
+	}
+}
+
+func TestNewNorm(t *testing.T) {
+	n, err := NewNorm("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	defer n.Close()
+	if err := n.CreateUserTable(ctx); err != nil {
+		panic(err)
+	}
+	email := "test@dummyemail.com"
+	if err := n.AddUser(ctx, email); err != nil {
+		panic(err)
+	}
+	foundEmail, err := n.FindUserEmail(ctx, email)
+	if err != nil {
+		panic(err)
+	}
+	if *foundEmail != email {
+		t.Error("Emails did not match")
+	}
+	if _, err := store.FindUserEmail(ctx, email); err == nil {
+		t.Error("Should have an error because the user was added to another database")
+	}
+}
+
+func TestRowTimeout(t *testing.T) {
+	for _, e := range []string{"a@a.com", "b@b.com", "c@c.com"} {
+		if err := store.AddUser(ctx, e); err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	res, err := store.GetUserEmailsNoModelScan(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer res.Close()
+	res.SetRowTimeout(10 * time.Millisecond)
+	if !res.Next() {
+		t.Fatalf("Expected a row, got %v", res.Err())
+	}
+	time.Sleep(50 * time.Millisecond)
+	if res.Next() {
+		t.Error("Expected the result to be closed after the row timeout")
+	}
+	if res.Err() != ErrRowTimeout {
+		t.Errorf("Expected ErrRowTimeout, got %v", res.Err())
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	defer deleteAllUsers()
+	tx, err := db.Begin()
+	if err != nil {
+		panic(err)
+	}
+	txStore := store.WithTx(tx)
+	email := "test@dummyemail.com"
+	if err := txStore.AddUser(ctx, email); err != nil {
+		panic(err)
+	}
+	if _, err := txStore.FindUserEmail(ctx, email); err != nil {
+		t.Errorf("User should be visible inside the transaction: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		panic(err)
+	}
+	if _, err := store.FindUserEmail(ctx, email); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows after rollback, got %v", err)
+	}
+}
+
+func TestBegin(t *testing.T) {
+	defer deleteAllUsers()
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if err := tx.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	if err := tx.AddUser(ctx, "b@b.com"); err != nil {
+		panic(err)
+	}
+	if _, err := tx.Begin(ctx); err == nil {
+		t.Error("Should have an error because of the nested transaction")
+	}
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+	emails, err := store.GetUserEmailsNoModel(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(emails) != 2 {
+		t.Errorf("Expected 2 committed users, got %v", emails)
+	}
+}
+
+func TestStatementCache(t *testing.T) {
+	// The transaction holds a connection, so the database is shared between
+	// connections.
+	n, err := NewNorm("sqlite3", "file:stmt_cache?mode=memory&cache=shared")
+	if err != nil {
+		panic(err)
+	}
+	if err := n.CreateUserTable(ctx); err != nil {
+		panic(err)
+	}
+	email := "test@dummyemail.com"
+	if err := n.AddUser(ctx, email); err != nil {
+		panic(err)
+	}
+	prepared := len(n.stmts.stmts)
+	tx, err := n.Begin(ctx)
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := n.FindUserEmail(ctx, email); err != nil {
+			panic(err)
+		}
+		if _, err := tx.FindUserEmail(ctx, email); err != nil {
+			panic(err)
+		}
+	}
+	if err := tx.Rollback(); err != nil {
+		panic(err)
+	}
+	if got := len(n.stmts.stmts); got != prepared+1 {
+		t.Errorf("Expected %d cached statements, got %d", prepared+1, got)
+	}
+	if err := n.Close(); err != nil {
+		panic(err)
+	}
+	if len(n.stmts.stmts) != 0 {
+		t.Error("Close should close the cached statements")
+	}
+}
+
+func TestPrepareAll(t *testing.T) {
+	// The sqlite version of the driver does not support RETURNING.
+	err := store.PrepareAll(ctx)
+	if err == nil || !strings.HasPrefix(err.Error(), "AddUserReturning: ") {
+		t.Errorf("Expected an error preparing AddUserReturning, got %v", err)
+	}
+}
+
+func TestExecMany(t *testing.T) {
+	defer deleteAllUsers()
+	rows := []AddUsersRow{{"a@a.com"}, {"b@b.com"}, {"c@c.com"}}
+	if err := store.AddUsers(ctx, rows); err != nil {
+		panic(err)
+	}
+	emails, err := store.GetUserEmailsNoModel(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(emails) != 3 {
+		t.Errorf("Expected 3 users, got %v", emails)
+	}
+
+	// Inside a transaction, the rows are added to it.
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if err := tx.AddUsers(ctx, rows); err != nil {
+		panic(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		panic(err)
+	}
+	emails, err = store.GetUserEmailsNoModel(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(emails) != 3 {
+		t.Errorf("Expected the rolled back rows to be discarded, got %v", emails)
+	}
+}
+
+func TestKey(t *testing.T) {
+	key := MembershipKey{UserID: 1, Group: "admins"}
+	if err := store.AddMembership(ctx, key, "owner"); err != nil {
+		panic(err)
+	}
+	if err := store.AddMembership(ctx, MembershipKey{UserID: 1, Group: "users"}, "member"); err != nil {
+		panic(err)
+	}
+	role, err := store.FindMembershipRole(ctx, key)
+	if err != nil {
+		panic(err)
+	}
+	if *role != "owner" {
+		t.Errorf("FindMembershipRole(%v) = %q, want owner", key, *role)
+	}
+	for _, want := range []int64{1, 0} {
+		deleted, err := store.DeleteMembership(ctx, key)
+		if err != nil {
+			panic(err)
+		}
+		if deleted != want {
+			t.Errorf("DeleteMembership(%v) deleted %d rows, want %d", key, deleted, want)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	key := MembershipKey{UserID: 2, Group: "auditors"}
+	if err := store.AddMembership(ctx, key, "member"); err != nil {
+		panic(err)
+	}
+	if _, err := store.DeleteMembership(WithAuditActor(ctx, "alice"), key); err != nil {
+		panic(err)
+	}
+	var query, keys string
+	err := db.QueryRowContext(ctx, "SELECT query, key_values FROM norm_audit_log WHERE actor = $1", "alice").Scan(&query, &keys)
+	if err != nil {
+		t.Fatalf("Reading the audit log: %v", err)
+	}
+	if query != "DeleteMembership" || keys != `{"group":"auditors","userID":2}` {
+		t.Errorf("Expected the audit log row of DeleteMembership, got %q %s", query, keys)
+	}
+}
+
+func TestScript(t *testing.T) {
+	defer func(f func(ScriptStep)) {
+		OnScriptStep = f
+	}(OnScriptStep)
+	var steps []ScriptStep
+	OnScriptStep = func(step ScriptStep) {
+		steps = append(steps, step)
+	}
+	if err := store.Maintain(ctx, true); err != nil {
+		t.Fatalf("Maintain dry run: %v", err)
+	}
+	if len(steps) != 3 || !steps[0].DryRun || steps[2].Statement != "VACUUM" {
+		t.Errorf("Expected the 3 steps of the dry run, got %v", steps)
+	}
+	steps = nil
+	if err := store.Maintain(ctx, false); err != nil {
+		t.Fatalf("Maintain: %v", err)
+	}
+	if len(steps) != 3 || steps[0].DryRun || steps[0].Step != 1 {
+		t.Errorf("Expected the 3 steps, got %v", steps)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	// COPY is postgres only, sqlite fails to prepare it.
+	if err := store.CopyUsers(ctx, []CopyUsersRow{{"a@a.com"}}); err == nil {
+		t.Error("Expected an error, sqlite has no COPY")
+	}
+	emails, err := store.GetUserEmailsNoModel(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(emails) != 0 {
+		t.Errorf("Expected no users, got %v", emails)
+	}
+}
+
+// fakeQuerier overrides one method of Querier, the others panic.
+type fakeQuerier struct {
+	Querier
+	emails []string
+}
+
+func (q fakeQuerier) GetUserEmailsNoModel(ctx context.Context, opts ...Option) ([]string, error) {
+	return q.emails, nil
+}
+
+func countEmails(q Querier) int {
+	emails, err := q.GetUserEmailsNoModel(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return len(emails)
+}
+
+func TestQuerier(t *testing.T) {
+	if n := countEmails(fakeQuerier{emails: []string{"a@a.com"}}); n != 1 {
+		t.Errorf("Expected 1 email from the fake, got %d", n)
+	}
+	if n := countEmails(store); n != 0 {
+		t.Errorf("Expected no emails in the database, got %d", n)
+	}
+}
+
+func TestMockQuerier(t *testing.T) {
+	mock := &MockQuerier{
+		GetUserEmailsNoModelFunc: func(ctx context.Context, opts ...Option) ([]string, error) {
+			return []string{"a@a.com", "b@b.com"}, nil
+		},
+	}
+	if n := countEmails(mock); n != 2 {
+		t.Errorf("Expected 2 emails from the mock, got %d", n)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Calling a method that is not set should panic")
+		}
+	}()
+	mock.AddUser(ctx, "a@a.com")
+}
+
+func TestExecReturns(t *testing.T) {
+	defer deleteAllUsers()
+	res, err := store.AddUserResult(ctx, "a@a.com")
+	if err != nil {
+		panic(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		panic(err)
+	}
+	found, err := store.FindUser(ctx, "a@a.com")
+	if err != nil {
+		panic(err)
+	}
+	if int64(found.ID) != id {
+		t.Errorf("Expected ID %d, got %d", id, found.ID)
+	}
+	for _, want := range []int64{1, 0} {
+		n, err := store.DeleteUser(ctx, "a@a.com")
+		if err != nil {
+			panic(err)
+		}
+		if n != want {
+			t.Errorf("Expected %d rows affected, got %d", want, n)
+		}
+	}
+}
+
+func TestNamedInputs(t *testing.T) {
+	for _, e := range []string{"a@a.com", "b@b.com"} {
+		if err := store.AddUser(ctx, e); err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	for _, c := range []struct {
+		email, domain string
+		want          int
+	}{
+		{"a@a.com", "", 1},
+		{"x@x.com", "b.com", 1},
+		{"a@a.com", "b.com", 2},
+	} {
+		ids, err := store.FindUsersNamed(ctx, c.email, c.domain)
+		if err != nil {
+			panic(err)
+		}
+		if len(ids) != c.want {
+			t.Errorf("FindUsersNamed(%q, %q) found %d users, want %d", c.email, c.domain, len(ids), c.want)
+		}
+	}
+}
+
+func TestNoPrepare(t *testing.T) {
+	prepared := len(store.stmts.stmts)
+	if _, err := store.FindUsersNamed(ctx, "a@a.com", ""); err != nil {
+		panic(err)
+	}
+	if got := len(store.stmts.stmts); got != prepared {
+		t.Errorf("FindUsersNamed should not be prepared, %d statements were cached", got-prepared)
+	}
+}
+
+func TestNullOutputs(t *testing.T) {
+	res, err := db.Exec("INSERT INTO user (email) VALUES (NULL)")
+	if err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	id, err := res.LastInsertId()
+	if err != nil {
+		panic(err)
+	}
+	email, err := store.FindUserEmailOrEmpty(ctx, UserID(id))
+	if err != nil {
+		t.Fatalf("FindUserEmailOrEmpty: %v", err)
+	}
+	if *email != "" {
+		t.Errorf("Expected an empty email, got %q", *email)
+	}
+	users, err := store.GetUserEmailsOrEmpty(ctx)
+	if err != nil {
+		t.Fatalf("GetUserEmailsOrEmpty: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != id || users[0].Email != "" {
+		t.Errorf("Unexpected users %v", users)
+	}
+}
+
+func TestQueryCounts(t *testing.T) {
+	before := QueryCounts()
+	if _, ok := before["ListUserDomain"]; !ok {
+		t.Errorf("Expected a count for ListUserDomain in %v", before)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := store.GetUserEmailsNoModel(ctx); err != nil {
+			panic(err)
+		}
+	}
+	if _, err := store.FindUserEmail(ctx, "nobody@a.com"); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+	after := QueryCounts()
+	for name, want := range map[string]int64{"GetUserEmailsNoModel": 2, "FindUserEmail": 1, "AddUser": 0} {
+		if got := after[name] - before[name]; got != want {
+			t.Errorf("%s was counted %d times, want %d", name, got, want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	res, err := store.GetUserEmailsOrEmptyScan(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if !res.Next() {
+		t.Fatalf("Expected a row, got %v", res.Err())
+	}
+	res.Close()
+	// The read only transaction ends with the result.
+	if err := store.AddUser(ctx, "b@b.com"); err != nil {
+		t.Errorf("AddUser after the read only query: %v", err)
+	}
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+	if err := tx.AddUser(ctx, "c@c.com"); err != nil {
+		panic(err)
+	}
+	users, err := tx.GetUserEmailsOrEmpty(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(users) != 3 {
+		t.Errorf("Expected the query to run in the transaction, got %v", users)
+	}
+}
+
+func TestDerivedInputs(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	added, err := store.FindUser(ctx, "a@a.com")
+	if err != nil {
+		panic(err)
+	}
+	id := UserID(added.ID)
+	found, err := store.FindUserByID(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+	if found.Email != "a@a.com" {
+		t.Errorf("FindUserByID(%d) found %q, want %q", id, found.Email, "a@a.com")
+	}
+}
+
+func TestDerivedOutputs(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	rows, err := store.GetUserRows(ctx)
+	if err != nil {
+		panic(err)
+	}
+	want := sql.NullString{String: "a@a.com", Valid: true}
+	if len(rows) != 1 || rows[0].Email != want {
+		t.Errorf("GetUserRows returned %v, want one row with email %v", rows, want)
+	}
+	id := rows[0].ID
+	if _, err := store.FindUserByID(ctx, id); err != nil {
+		t.Errorf("FindUserByID(%d): %v", id, err)
+	}
+}
+
+func TestIDType(t *testing.T) {
+	var id UserID
+	if err := id.Scan(int64(3)); err != nil || id != 3 {
+		t.Errorf("Scan(3) = %v, %v, want 3", id, err)
+	}
+	if err := id.Scan(nil); err == nil {
+		t.Errorf("Scan(nil) succeeded, want an error")
+	}
+	if v, err := id.Value(); err != nil || v != int64(3) {
+		t.Errorf("Value() = %v, %v, want int64 3", v, err)
+	}
+}
+
+func TestMapper(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	accounts, err := store.GetUserAccounts(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(accounts) != 1 || accounts[0].Domain != "a.com" {
+		t.Errorf("Expected the account built by newUserAccount, got %v", accounts)
+	}
+	if err := store.AddUser(ctx, "invalid"); err != nil {
+		panic(err)
+	}
+	if _, err := store.GetUserAccounts(ctx); err == nil {
+		t.Error("Expected the error of newUserAccount")
+	}
+}
+
+func TestModelGen(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	var contacts []UserContact
+	contacts, err := store.GetUserContacts(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(contacts) != 1 || contacts[0].Email != "a@a.com" {
+		t.Errorf("Expected the user, got %v", contacts)
+	}
+}
+
+func TestTags(t *testing.T) {
+	data, err := json.Marshal(UserContact{ID: 1, Email: "a@a.com"})
+	if err != nil {
+		panic(err)
+	}
+	if want := `{"id":1,"email":"a@a.com"}`; string(data) != want {
+		t.Errorf("Expected %s, got %s", want, data)
+	}
+}
+
+func TestSliceInputs(t *testing.T) {
+	var ids []UserID
+	for _, e := range []string{"a@a.com", "b@a.com", "c@c.com"} {
+		if err := store.AddUser(ctx, e); err != nil {
+			panic(err)
+		}
+		u, err := store.FindUser(ctx, e)
+		if err != nil {
+			panic(err)
+		}
+		ids = append(ids, UserID(u.ID))
+	}
+	defer deleteAllUsers()
+	for _, c := range []struct {
+		ids    []UserID
+		domain string
+		want   []string
+	}{
+		{ids, "a.com", []string{"a@a.com", "b@a.com"}},
+		{ids[1:], "a.com", []string{"b@a.com"}},
+		{ids[2:], "c.com", []string{"c@c.com"}},
+		{nil, "a.com", nil},
+	} {
+		emails, err := store.FindUserEmailsByIDs(ctx, c.ids, c.domain)
+		if err != nil {
+			t.Fatalf("FindUserEmailsByIDs(%v, %q): %v", c.ids, c.domain, err)
+		}
+		if !reflect.DeepEqual(emails, c.want) {
+			t.Errorf("FindUserEmailsByIDs(%v, %q) = %v, want %v", c.ids, c.domain, emails, c.want)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	var ids []UserID
+	for _, e := range []string{"a@a.com", "b@b.com"} {
+		if err := store.AddUser(ctx, e); err != nil {
+			panic(err)
+		}
+		u, err := store.FindUser(ctx, e)
+		if err != nil {
+			panic(err)
+		}
+		ids = append(ids, UserID(u.ID))
+	}
+	defer deleteAllUsers()
+	inDomain := store.BindFindUserEmailsByIDs("b.com")
+	emails, err := inDomain(ctx, ids)
+	if err != nil {
+		panic(err)
+	}
+	if !reflect.DeepEqual(emails, []string{"b@b.com"}) {
+		t.Errorf("Bound FindUserEmailsByIDs(%v) = %v, want [b@b.com]", ids, emails)
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	var open []*GetUserEmailsNoModelResult
+	for i := 0; i < 2; i++ {
+		res, err := store.GetUserEmailsNoModelScan(ctx)
+		if err != nil {
+			panic(err)
+		}
+		open = append(open, res)
+	}
+	if _, err := store.GetUserEmailsNoModel(ctx); err != ErrConcurrencyLimit {
+		t.Errorf("Expected ErrConcurrencyLimit, got %v", err)
+	}
+	for _, res := range open {
+		res.Close()
+	}
+	if _, err := store.GetUserEmailsNoModel(ctx); err != nil {
+		t.Errorf("Expected the slots to be released, got %v", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	// Holds the only slot of GetUserListLimited.
+	res, err := store.GetUserListLimitedScan(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer res.Close()
+
+	defer func(f func(context.Context, string, error) bool) {
+		ShouldFallback = f
+	}(ShouldFallback)
+	var degraded []string
+	ShouldFallback = func(ctx context.Context, funcName string, err error) bool {
+		if err != ErrConcurrencyLimit {
+			t.Errorf("ShouldFallback(%q) got error %v, want ErrConcurrencyLimit", funcName, err)
+		}
+		degraded = append(degraded, funcName)
+		return true
+	}
+	dctx := WithDegraded(ctx)
+	users, err := store.GetUserListLimited(dctx)
+	if err != nil {
+		t.Fatalf("GetUserListLimited: %v", err)
+	}
+	if len(users) != 1 || users[0].Email != "a@a.com" {
+		t.Errorf("GetUserListLimited returned %v, want the user", users)
+	}
+	if len(degraded) != 1 || degraded[0] != "GetUserListLimited" {
+		t.Errorf("ShouldFallback called for %v, want GetUserListLimited", degraded)
+	}
+	if !Degraded(dctx) {
+		t.Errorf("Expected the result of the fallback to be degraded")
+	}
+
+	ShouldFallback = func(context.Context, string, error) bool {
+		return false
+	}
+	dctx = WithDegraded(ctx)
+	if _, err := store.GetUserListLimited(dctx); err != ErrConcurrencyLimit {
+		t.Errorf("GetUserListLimited without fallback returned %v, want ErrConcurrencyLimit", err)
+	}
+	if Degraded(dctx) {
+		t.Errorf("Expected no degraded result without the fallback")
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	u, err := store.FindUserAsOf(ctx, "a@a.com", time.Time{})
+	if err != nil {
+		panic(err)
+	}
+	if u.Email != "a@a.com" {
+		t.Errorf("Expected the current row, got %v", u)
+	}
+	// sqlite has no system-versioned tables, and fails on the clause.
+	asOf := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := store.FindUserAsOf(ctx, "a@a.com", asOf); err == nil || !strings.Contains(err.Error(), "SYSTEM_TIME") {
+		t.Errorf("Expected the clause to be rejected by sqlite, got %v", err)
+	}
+	want := "SELECT id FROM user FOR SYSTEM_TIME AS OF TIMESTAMP '2020-01-02 03:04:05' WHERE id = 1"
+	if got := asOfQuery(asOf, " FOR SYSTEM_TIME AS OF TIMESTAMP '%s'", "SELECT id FROM user", " WHERE id = 1"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestPartitionBy(t *testing.T) {
+	// sqlite has no partitions, and fails to create the one of the login.
+	at := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	err := store.AddLogin(ctx, 1, at)
+	if err == nil || !strings.HasPrefix(err.Error(), "creating partition login_2024_01 of login: ") {
+		t.Errorf("Expected an error creating the partition, got %v", err)
+	}
+	// A partition created in another database is created again in this one.
+	other := &sql.DB{}
+	createdPartitions.Store(partitionKey{other, "login_2024_01"}, true)
+	defer createdPartitions.Delete(partitionKey{other, "login_2024_01"})
+	if err := store.AddLogin(ctx, 1, at); err == nil {
+		t.Error("Expected the partition of another database not to be skipped")
+	}
+	createdPartitions.Store(partitionKey{db, "login_2024_01"}, true)
+	defer createdPartitions.Delete(partitionKey{db, "login_2024_01"})
+	if err := store.AddLogin(ctx, 1, at); err != nil && strings.HasPrefix(err.Error(), "creating partition") {
+		t.Errorf("Expected the partition created in this database to be skipped, got %v", err)
+	}
+}
+
+// reverseCodec "encrypts" values by reversing their bytes.
+type reverseCodec struct{}
+
+func (reverseCodec) reverse(column string, data []byte) ([]byte, error) {
+	if column != "ssn" {
+		return nil, fmt.Errorf("unexpected column %s", column)
+	}
+	ret := make([]byte, len(data))
+	for ix, b := range data {
+		ret[len(data)-1-ix] = b
+	}
+	return ret, nil
+}
+
+func (c reverseCodec) Encrypt(column string, plaintext []byte) ([]byte, error) {
+	return c.reverse(column, plaintext)
+}
+
+func (c reverseCodec) Decrypt(column string, ciphertext []byte) ([]byte, error) {
+	return c.reverse(column, ciphertext)
+}
+
+func TestEncrypted(t *testing.T) {
+	if err := store.SetUserSSN(ctx, 1, "123-45-6789"); err == nil {
+		t.Error("Expected an error writing without a codec")
+	}
+	defer func(c FieldCodec) {
+		Encryption = c
+	}(Encryption)
+	Encryption = reverseCodec{}
+	if err := store.SetUserSSN(ctx, 1, "123-45-6789"); err != nil {
+		panic(err)
+	}
+	var stored []byte
+	if err := db.QueryRow("SELECT ssn FROM user_secret WHERE user_id = 1").Scan(&stored); err != nil {
+		panic(err)
+	}
+	if string(stored) != "9876-54-321" {
+		t.Errorf("Expected the encrypted value to be stored, got %q", stored)
+	}
+	ssn, err := store.GetUserSSN(ctx, 1)
+	if err != nil {
+		panic(err)
+	}
+	if *ssn != "123-45-6789" {
+		t.Errorf("Expected the decrypted value, got %q", *ssn)
+	}
+}
+
+func TestETag(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	u, err := store.FindUser(ctx, "a@a.com")
+	if err != nil {
+		panic(err)
+	}
+	read, err := store.GetUserByID(ctx, u.ID)
+	if err != nil {
+		panic(err)
+	}
+	etag := read.ETag()
+	if !strings.HasPrefix(etag, `"`) || etag != (GetUserByIDOutput{u.ID, "a@a.com"}).ETag() {
+		t.Errorf("Expected the quoted hash of the row, got %s", etag)
+	}
+	if err := store.UpdateUserEmailIfMatch(ctx, "b@b.com", u.ID, etag); err != nil {
+		t.Fatalf("UpdateUserEmailIfMatch: %v", err)
+	}
+	// The row changed, the ETag is stale.
+	if err := store.UpdateUserEmailIfMatch(ctx, "c@c.com", u.ID, etag); err != ErrETagMismatch {
+		t.Errorf("Expected ErrETagMismatch, got %v", err)
+	}
+	read, err = store.GetUserByID(ctx, u.ID)
+	if err != nil {
+		panic(err)
+	}
+	if read.Email != "b@b.com" {
+		t.Errorf("Expected the email of the matching update, got %s", read.Email)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	if got, want := GetUserByIDCacheKey(1), `GetUserByID{"id":1}`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	asOf := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got, want := FindUserAsOfCacheKey("a@a.com", asOf), `FindUserAsOf{"asOf":"2020-01-02T03:04:05Z","email":"a@a.com"}`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if GetUserSSNCacheKey(1) != `GetUserSSN{"userID":1}` {
+		t.Errorf("Unexpected key %s", GetUserSSNCacheKey(1))
+	}
+	// The key of FindUser lowercases the email with !cache_key.
+	if got, want := FindUserCacheKey("A@a.com"), `FindUser{"email":"a@a.com"}`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	// WarmUp stops at the queries PrepareAll fails on, see TestPrepareAll.
+	err := store.WarmUp(ctx)
+	if err == nil || !strings.HasPrefix(err.Error(), "AddUserReturning: ") {
+		t.Errorf("Expected an error preparing AddUserReturning, got %v", err)
+	}
+	if open := db.Stats().OpenConnections; open < WarmUpConns {
+		t.Errorf("Expected %d connections in the pool, got %d", WarmUpConns, open)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	f, err := ioutil.TempFile("", "norm_compare_*.db")
+	if err != nil {
+		panic(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	other, err := NewNorm("sqlite3", fmt.Sprintf("file:%s", f.Name()))
+	if err != nil {
+		panic(err)
+	}
+	defer other.Close()
+	if err := other.CreateUserTable(ctx); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	for _, email := range []string{"a@a.com", "b@b.com"} {
+		if err := store.AddUser(ctx, email); err != nil {
+			panic(err)
+		}
+	}
+	for _, email := range []string{"a@a.com", "c@c.com"} {
+		if err := other.AddUser(ctx, email); err != nil {
+			panic(err)
+		}
+	}
+	diff, err := store.CompareGetUserListWithModel(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The IDs of the users of store may follow those of the previous tests,
+	// in which case a@a.com differs too.
+	ids := map[*Norm]map[string]int{store: {}, other: {}}
+	for n, byEmail := range ids {
+		for _, email := range []string{"a@a.com", "b@b.com", "c@c.com"} {
+			if u, err := n.FindUser(ctx, email); err == nil {
+				byEmail[email] = u.ID
+			}
+		}
+	}
+	var expected Diff
+	if ids[store]["a@a.com"] != ids[other]["a@a.com"] {
+		expected.Missing = append(expected.Missing, User{ids[store]["a@a.com"], "a@a.com"})
+		expected.Extra = append(expected.Extra, User{ids[other]["a@a.com"], "a@a.com"})
+	}
+	expected.Missing = append(expected.Missing, User{ids[store]["b@b.com"], "b@b.com"})
+	expected.Extra = append(expected.Extra, User{ids[other]["c@c.com"], "c@c.com"})
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, diff)
+	}
+	diff, err = store.CompareFindUser(ctx, other, "b@b.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Missing) != 1 || len(diff.Extra) != 0 {
+		t.Errorf("Expected b@b.com to be missing from the other database, got %+v", diff)
+	}
+	diff, err = store.CompareFindUser(ctx, other, "d@d.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Equal() {
+		t.Errorf("Expected no difference for a user in neither database, got %+v", diff)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	defer deleteAllUsers()
+	for _, email := range []string{"a@a.com", "b@b.com", "c@a.com"} {
+		if err := store.AddUser(ctx, email); err != nil {
+			panic(err)
+		}
+	}
+	counts, err := store.CountUsersByDomain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []CountUsersByDomainOutput{{"a.com", 2}, {"b.com", 1}}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected %v, got %v", expected, counts)
+	}
+}
+
+func TestQueryCountsBatches(t *testing.T) {
+	before := QueryCounts()
+	if err := store.AddUsers(ctx, []AddUsersRow{{"a@a.com"}}); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	// sqlite has no COPY, but the call is counted before it fails.
+	store.CopyUsers(ctx, []CopyUsersRow{{"b@b.com"}})
+	after := QueryCounts()
+	for _, name := range []string{"AddUsers", "CopyUsers"} {
+		if got := after[name] - before[name]; got != 1 {
+			t.Errorf("%s was counted %d times, want 1", name, got)
+		}
+	}
+}
+
+func TestEmptySliceInputs(t *testing.T) {
+	for _, e := range []string{"a@a.com", "b@b.com"} {
+		if err := store.AddUser(ctx, e); err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	a, err := store.FindUser(ctx, "a@a.com")
+	if err != nil {
+		panic(err)
+	}
+	for _, c := range []struct {
+		ids  []UserID
+		want []string
+	}{
+		{nil, []string{"a@a.com", "b@b.com"}},
+		{[]UserID{UserID(a.ID)}, []string{"b@b.com"}},
+	} {
+		emails, err := store.FindUserEmailsExcept(ctx, c.ids)
+		if err != nil {
+			t.Fatalf("FindUserEmailsExcept(%v): %v", c.ids, err)
+		}
+		if !reflect.DeepEqual(emails, c.want) {
+			t.Errorf("FindUserEmailsExcept(%v) = %v, want %v", c.ids, emails, c.want)
+		}
+	}
+}
+
+func TestStatementCacheEviction(t *testing.T) {
+	defer func(size int) {
+		StmtCacheSize = size
+	}(StmtCacheSize)
+	StmtCacheSize = 2
+	n, err := NewNorm("sqlite3", "file:stmt_cache_eviction?mode=memory&cache=shared")
+	if err != nil {
+		panic(err)
+	}
+	defer n.Close()
+	if err := n.CreateUserTable(ctx); err != nil {
+		panic(err)
+	}
+	// Concurrent first calls of a query share one statement.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := n.FindUserEmail(ctx, "a@a.com"); err != nil && err != sql.ErrNoRows {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// The statements of CreateUserTable and FindUserEmail, the latter
+	// prepared once.
+	if stats := n.Stats(); stats.Cached != 2 || stats.Misses != 2 || stats.Hits != 7 || stats.Evictions != 0 {
+		t.Errorf("Expected two cached statements, got %+v", stats)
+	}
+	if got := n.Stats().Executions["FindUserEmail"]; got != 8 {
+		t.Errorf("Expected 8 executions of FindUserEmail, got %d", got)
+	}
+	if err := n.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	if _, err := n.FindUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	if stats := n.Stats(); stats.Cached != 2 || stats.Evictions != 2 {
+		t.Errorf("Expected two cached statements and two evictions, got %+v", stats)
+	}
+	// The evicted statement is prepared again.
+	if _, err := n.FindUserEmail(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	if stats := n.Stats(); stats.Cached != 2 || stats.Evictions != 3 {
+		t.Errorf("Expected two cached statements and three evictions, got %+v", stats)
+	}
+}
+
+func TestReadOnlyNorm(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	var r Reader = store.ReadOnly()
+	u, err := r.FindUser(ctx, "a@a.com")
+	if err != nil {
+		panic(err)
+	}
+	if u.Email != "a@a.com" {
+		t.Errorf("Expected a@a.com, got %+v", u)
+	}
+	if _, ok := r.(Writer); ok {
+		t.Error("ReadOnlyNorm should not have the methods of Writer")
+	}
+}
+
+func TestNormContext(t *testing.T) {
+	if _, ok := FromContext(ctx); ok {
+		t.Error("Expected no Norm in the context")
+	}
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+	n, ok := FromContext(NewContext(ctx, tx.Norm))
+	if !ok || n != tx.Norm {
+		t.Errorf("Expected the Norm of the transaction, got %v, %v", n, ok)
+	}
+}
+
+func TestContextInputs(t *testing.T) {
+	for _, e := range []string{"a@a.com", "b@b.com"} {
+		if err := store.AddUser(ctx, e); err != nil {
+			panic(err)
+		}
+	}
+	defer deleteAllUsers()
+	if _, err := store.GetTenantUserEmails(ctx); !errors.Is(err, ErrNoContextValue) {
+		t.Errorf("Expected ErrNoContextValue without a tenant, got %v", err)
+	}
+	tenantCtx := WithTenantDomain(ctx, "b.com")
+	if domain, ok := TenantDomainFromContext(tenantCtx); !ok || domain != "b.com" {
+		t.Errorf("Expected the domain b.com in the context, got %q, %v", domain, ok)
+	}
+	emails, err := store.GetTenantUserEmails(tenantCtx)
+	if err != nil {
+		panic(err)
+	}
+	if !reflect.DeepEqual(emails, []string{"b@b.com"}) {
+		t.Errorf("Expected the users of b.com, got %v", emails)
+	}
+}
+
+type countingLimiter struct {
+	waits int
+	err   error
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	return l.err
+}
+
+func TestLimitGroup(t *testing.T) {
+	if err := store.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	l := &countingLimiter{}
+	Limiters["lookups"] = l
+	defer delete(Limiters, "lookups")
+	if _, err := store.FindUserEmail(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	if l.waits != 1 {
+		t.Errorf("Expected the limiter to be waited for once, got %d", l.waits)
+	}
+	l.err = errors.New("limited")
+	if _, err := store.FindUserEmail(ctx, "a@a.com"); err != l.err {
+		t.Errorf("Expected the error of the limiter, got %v", err)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(1, 2)
+	for ix := 0; ix < 2; ix++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Errorf("Expected the burst to run right away, got %v", err)
+		}
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(timeoutCtx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the third call to time out, got %v", err)
+	}
+}
+
+func TestCallOptions(t *testing.T) {
+	replica, err := NewNorm("sqlite3", "file:replica?mode=memory&cache=shared")
+	if err != nil {
+		panic(err)
+	}
+	defer replica.Close()
+	if err := replica.CreateUserTable(ctx); err != nil {
+		panic(err)
+	}
+	if err := replica.AddUser(ctx, "replica@a.com"); err != nil {
+		panic(err)
+	}
+	n := store.WithReplica(replica)
+	if err := n.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	if _, err := n.FindUserEmail(ctx, "replica@a.com"); err != nil {
+		t.Errorf("Expected the read to run on the replica, got %v", err)
+	}
+	if _, err := n.FindUserEmail(ctx, "a@a.com", OnPrimary()); err != nil {
+		t.Errorf("Expected the read to run on the primary with OnPrimary, got %v", err)
+	}
+	// The sqlite of the tests has no RETURNING, so the statement fails on the
+	// primary rather than on the closed replica.
+	closed, err := NewNorm("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	closed.Close()
+	if _, err := store.WithReplica(closed).AddUserReturning(ctx, "b@a.com"); err == nil || strings.Contains(err.Error(), "database is closed") {
+		t.Errorf("Expected exec_returning to run on the primary, got %v", err)
+	}
+	res, err := n.GetUserEmailsNoModelScan(ctx, OnPrimary(), WithTimeout(time.Millisecond))
+	if err != nil {
+		panic(err)
+	}
+	defer res.Close()
+	time.Sleep(20 * time.Millisecond)
+	for res.Next() {
+	}
+	if err := res.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the result to time out, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	record := func(next Exec) Exec {
+		return func(ctx context.Context, call *Call) error {
+			calls = append(calls, fmt.Sprint(call.Name, call.Args))
+			return next(ctx, call)
+		}
+	}
+	errDenied := errors.New("denied")
+	deny := func(next Exec) Exec {
+		return func(ctx context.Context, call *Call) error {
+			if call.Name == "DeleteAllUsers" {
+				return errDenied
+			}
+			return next(ctx, call)
+		}
+	}
+	n := store.Use(record, deny)
+	if err := n.AddUser(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	defer deleteAllUsers()
+	if err := n.AddUsers(ctx, []AddUsersRow{{"b@b.com"}}); err != nil {
+		panic(err)
+	}
+	if _, err := n.FindUserEmail(ctx, "a@a.com"); err != nil {
+		panic(err)
+	}
+	if err := n.DeleteAllUsers(ctx); err != errDenied {
+		t.Errorf("Expected the middleware to deny the call, got %v", err)
+	}
+	tx, err := n.Begin(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.GetUserEmailsNoModel(ctx); err != nil {
+		panic(err)
+	}
+	// The email is a sensitive input of AddUser and AddUsers.
+	expected := []string{"AddUser[[REDACTED]]", "AddUsers[[REDACTED]]", "FindUserEmail[a@a.com]", "DeleteAllUsers[]", "GetUserEmailsNoModel[]"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected the calls %v, got %v", expected, calls)
+	}
+}
@@ -2,4 +2,8 @@ module github.com/agrewal/norm
 
 go 1.15
 
-require github.com/mattn/go-sqlite3 v1.14.6
+require (
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/lib/pq v1.10.0
+	github.com/mattn/go-sqlite3 v1.14.6
+)
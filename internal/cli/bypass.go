@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/agrewal/norm/internal/core"
+)
+
+// sqlMethods are the methods of the database/sql handles that run SQL.
+var sqlMethods = map[string]bool{
+	"Exec":            true,
+	"ExecContext":     true,
+	"Prepare":         true,
+	"PrepareContext":  true,
+	"Query":           true,
+	"QueryContext":    true,
+	"QueryRow":        true,
+	"QueryRowContext": true,
+}
+
+// sqlHandles are the database/sql types whose sqlMethods are reported.
+var sqlHandles = map[string]bool{
+	"DB":   true,
+	"Tx":   true,
+	"Conn": true,
+}
+
+// goPackage is the part of the output of go list used here.
+type goPackage struct {
+	ImportPath string
+	Dir        string
+	GoFiles    []string
+	Imports    []string
+}
+
+// bypass is a call running SQL directly through database/sql.
+type bypass struct {
+	Pos  token.Position
+	Call string
+}
+
+func (b bypass) String() string {
+	return fmt.Sprintf("%s: direct database/sql call %s, use the generated queries instead", b.Pos, b.Call)
+}
+
+// listPackages runs go list on patterns.
+func listPackages(patterns []string) ([]goPackage, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := osexec.Command("go", append([]string{"list", "-json"}, patterns...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var ret []goPackage
+	dec := json.NewDecoder(&stdout)
+	for {
+		var p goPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// isNormGenerated reports whether the Go file at path was generated by norm.
+func isNormGenerated(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	return scanner.Scan() && scanner.Text() == "// Code generated by norm. DO NOT EDIT."
+}
+
+// findBypasses reports the calls running SQL through a *sql.DB, *sql.Tx or
+// *sql.Conn in the packages matching patterns that import a package
+// generated by norm. Only generated packages among the matched ones are
+// recognized.
+func findBypasses(patterns []string) ([]bypass, error) {
+	pkgs, err := listPackages(patterns)
+	if err != nil {
+		return nil, err
+	}
+	generated := map[string]bool{}
+	for _, p := range pkgs {
+		for _, name := range p.GoFiles {
+			if isNormGenerated(filepath.Join(p.Dir, name)) {
+				generated[p.ImportPath] = true
+				break
+			}
+		}
+	}
+	var ret []bypass
+	for _, p := range pkgs {
+		if generated[p.ImportPath] || !importsAny(p.Imports, generated) {
+			continue
+		}
+		found, err := packageBypasses(p)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, found...)
+	}
+	return ret, nil
+}
+
+func importsAny(imports []string, set map[string]bool) bool {
+	for _, imp := range imports {
+		if set[imp] {
+			return true
+		}
+	}
+	return false
+}
+
+func packageBypasses(p goPackage) ([]bypass, error) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range p.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(p.Dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	info := &types.Info{Selections: map[*ast.SelectorExpr]*types.Selection{}}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		// Keep going on errors, the selections on database/sql types are
+		// still recorded.
+		Error: func(error) {},
+	}
+	conf.Check(p.ImportPath, fset, files, info)
+	var ret []bypass
+	for _, f := range files {
+		ast.Inspect(f, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || !sqlMethods[sel.Sel.Name] {
+				return true
+			}
+			s, ok := info.Selections[sel]
+			if !ok {
+				return true
+			}
+			// The receiver of the method itself, so that handles embedded in
+			// other types are found too.
+			if recv := s.Obj().Type().(*types.Signature).Recv(); recv != nil && isSQLHandle(recv.Type()) {
+				ret = append(ret, bypass{fset.Position(call.Pos()), core.NodeString(fset, sel)})
+			}
+			return true
+		})
+	}
+	return ret, nil
+}
+
+// isSQLHandle reports whether t is one of sqlHandles, or a pointer to one.
+func isSQLHandle(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == "database/sql" && sqlHandles[named.Obj().Name()]
+}
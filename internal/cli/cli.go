@@ -0,0 +1,517 @@
+// Package cli is the norm command, see Main. The subcommands running other
+// programs, such as go list, plugins or the type checker of the models, are
+// here rather than in core.
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/agrewal/norm/internal/core"
+)
+
+// subcommand is a norm subcommand, run with the arguments following its name.
+type subcommand struct {
+	name string
+	// args is the synopsis of the arguments, for the usage.
+	args string
+	help string
+	run  func(args []string)
+}
+
+// subcommands are listed in the usage in this order. Running norm without
+// one, with only flags and input files, is the same as norm generate. They are
+// set in init, since the usage of generate lists them.
+var subcommands []subcommand
+
+func init() {
+	subcommands = []subcommand{
+		{"generate", "[flags] <input files>", "write the code generated from the input files", runGenerate},
+		{"check", "[flags] <input files>", "fail if the generated files are out of date", runCheck},
+		{"fmt", "[-l] [-w] [flags] <input files>", "format norm files", runFmt},
+		{"vet", "[flags] <input file>", "report the warnings and mistakes of the queries, and suggested indexes", runVet},
+		{"doc", "[flags] <input file>", "print a Markdown catalog of the queries", runDoc},
+		{"erd", "[-format mermaid|dot] <input file>", "print a diagram of the tables created in the file", runERD},
+		{"deps", "[flags] <input file>", "print the tables each query reads and writes as JSON", runDeps},
+		{"ir", "[flags] <input file>", "print the queries as JSON, in the stable format of IR", runIR},
+		{"ast", "[flags] <input file>", "print the directives and commands as written as JSON", runAST},
+		{"openapi", "[flags] <input file>", "print OpenAPI schemas of the structs read by the queries", runOpenAPI},
+		{"diff-api", "[flags] <input file>", "report how the exported API would change if regenerated", runDiffAPI},
+		{"prune", "-usage <report> <input file>", "list the queries with no calls in a usage report", runPrune},
+		{"drift", "[-driver <name>] -dsn <dsn> <input file>", "compare the tables and indexes with a live database", runDrift},
+		{"analyze", "[packages]", "report SQL run directly through database/sql", runAnalyze},
+		{"help", "", "print this list of commands", runHelp},
+	}
+}
+
+func findSubcommand(name string) (subcommand, bool) {
+	for _, sub := range subcommands {
+		if sub.name == name {
+			return sub, true
+		}
+	}
+	return subcommand{}, false
+}
+
+func writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: norm <command> [flags] <input files>\n\nThe commands are:\n\n")
+	for _, sub := range subcommands {
+		fmt.Fprintf(w, "\tnorm %s %s\n\t\t%s\n", sub.name, sub.args, sub.help)
+	}
+	fmt.Fprintf(w, "\nnorm [flags] <input files> is the same as norm generate.\nRun norm <command> -h for the flags of a command.\n")
+}
+
+func runHelp(args []string) {
+	writeUsage(os.Stdout)
+}
+
+// inputFlags are the flags shared by the subcommands reading norm files,
+// selecting the directives that apply.
+type inputFlags struct {
+	env    *string
+	define *string
+}
+
+// newFlagSet returns the flag set of the subcommand name, with the flags
+// shared by all the subcommands reading norm files.
+func newFlagSet(name string) (*flag.FlagSet, inputFlags) {
+	fs := flag.NewFlagSet(strings.TrimSpace("norm "+name), flag.ContinueOnError)
+	if sub, ok := findSubcommand(name); ok {
+		fs.Usage = func() {
+			help := strings.ToUpper(sub.help[:1]) + sub.help[1:]
+			fmt.Fprintf(fs.Output(), "Usage: norm %s %s\n\n%s.\n\nThe flags are:\n", sub.name, sub.args, help)
+			fs.PrintDefaults()
+		}
+	}
+	return fs, inputFlags{
+		env:    fs.String("env", "", "environment selecting the !env directives that apply"),
+		define: fs.String("define", "", "comma separated names for which !ifdef sections are kept"),
+	}
+}
+
+// parseFlags parses the flags of fs in args. The flag package reports wrong
+// flags along with the usage, so norm exits with ExitUsage without another
+// message, and with 0 after the usage asked for with -h.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		panic(core.ExitStatus(0))
+	} else if err != nil {
+		panic(core.ExitStatus(core.ExitUsage))
+	}
+}
+
+func (f inputFlags) options() core.ParseOptions {
+	return core.ParseOptions{Env: *f.env, Defines: splitDefines(*f.define)}
+}
+
+// parseArg parses the only input file in the arguments left in fs.
+func (f inputFlags) parseArg(fs *flag.FlagSet) *core.NormFile {
+	if fs.NArg() != 1 {
+		panic(core.UsageError(fmt.Sprintf("Need exactly one input file for %s", strings.TrimPrefix(fs.Name(), "norm "))))
+	}
+	return core.ParseFile(fs.Arg(0), f.options())
+}
+
+// splitDefines splits the value of -define into names.
+func splitDefines(define string) []string {
+	var ret []string
+	for _, name := range strings.Split(define, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// Main runs the norm command with the arguments of the program, see the
+// documentation of the norm command.
+func Main() {
+	if code := run(os.Args[1:]); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// run runs norm with the arguments args, and returns its exit code. Errors
+// are reported on the standard error instead of with a stack trace.
+func run(args []string) (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			code = core.ReportFailure(os.Stderr, r)
+		}
+	}()
+	if len(args) > 0 {
+		if sub, ok := findSubcommand(args[0]); ok {
+			sub.run(args[1:])
+			return 0
+		}
+	}
+	generateMain("", args, false)
+	return 0
+}
+
+func runGenerate(args []string) {
+	generateMain("generate", args, false)
+}
+
+func runCheck(args []string) {
+	generateMain("check", args, true)
+}
+
+// generateMain generates the code of the input files in args, and compares it
+// with the files on disk instead of writing it with check. name is the
+// subcommand run, empty when norm is run without one.
+func generateMain(name string, args []string, check bool) {
+	fs, in := newFlagSet(name)
+	if name == "" {
+		fs.Usage = func() {
+			writeUsage(fs.Output())
+			fmt.Fprintf(fs.Output(), "\nThe flags of generate are:\n")
+			fs.PrintDefaults()
+		}
+	}
+	strict := fs.Bool("strict", false, "treat warnings as errors")
+	rewrite := fs.String("rewrite", "", "command to filter every query body through")
+	fuzz := fs.Bool("fuzz", false, "also write fuzz targets for the inputs of the queries")
+	outFile := fs.String("o", "", "output file, overriding the !file of the input")
+	stdout := false
+	if !check {
+		fs.BoolVar(&stdout, "stdout", false, "write the generated code to the standard output instead of files")
+		fs.BoolVar(&check, "check", false, "same as norm check: compare the generated code with the files on disk instead of writing them, and fail if they differ")
+	}
+	stamp := fs.String("stamp", "", "second line of the generated files, overriding !stamp: date, hash or none")
+	skipModelCheck := fs.Bool("skip-model-check", false, "do not check the !model structs against the outputs of the queries")
+	var plugins pluginFlags
+	fs.Var(&plugins, "plugin", "command reading the queries as JSON and returning more files to write, may be repeated")
+	parseFlags(fs, args)
+	if fs.NArg() == 0 {
+		panic(core.UsageError("Need at least one input file"))
+	}
+
+	core.LoadTemplates()
+	nf := core.ParseFiles(fs.Args(), in.options())
+	if *outFile != "" {
+		nf.OutFile = *outFile
+	}
+	if *stamp != "" {
+		if !core.Stamps[*stamp] {
+			panic(core.UsageError(fmt.Sprintf("Unknown -stamp %s, expected date, hash or none", *stamp)))
+		}
+		nf.Stamp = *stamp
+	}
+	if err := core.ResolvePackage(nf); err != nil {
+		panic(err)
+	}
+	if err := core.CheckPackage(nf); err != nil {
+		panic(err)
+	}
+	var warnings []core.Warning
+	if !*skipModelCheck {
+		modelWarnings, err := checkModels(nf)
+		if err != nil {
+			panic(core.NewParseError(nf, err.Error()))
+		}
+		warnings = modelWarnings
+	}
+	if err := rewriteQueries(nf, *rewrite); err != nil {
+		panic(err)
+	}
+	warnings = append(warnings, core.Analyze(nf)...)
+	severity := "warning"
+	if *strict {
+		severity = "error"
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", nf.Pos(w.Line), severity, w.Msg)
+	}
+	if *strict && len(warnings) > 0 {
+		panic(core.ExitStatus(core.ExitCheck))
+	}
+	src := core.GenerateSource(nf)
+	if stdout {
+		if nf.Stamp == "hash" {
+			src = core.StampHash(src)
+		}
+		if _, err := os.Stdout.Write(src); err != nil {
+			panic(core.WriteError{Err: err})
+		}
+		return
+	}
+	files := map[string][]byte{nf.OutFile: src}
+	if nf.HasGroups() {
+		files = core.SplitGroups(nf, src)
+	}
+	if nf.Stamp == "hash" {
+		for name, data := range files {
+			files[name] = core.StampHash(data)
+		}
+	}
+	if nf.Mocks {
+		files[core.MockFileName(nf.OutFile)] = core.GenerateMock(nf, src)
+	}
+	if len(nf.Models) > 0 && nf.ModelPkg != "" {
+		files[nf.ModelFile] = core.GenerateModels(nf)
+	}
+	if *fuzz {
+		files[core.FuzzFileName(nf.OutFile)] = core.GenerateFuzz(nf)
+	}
+	for _, plugin := range plugins {
+		pluginFiles, err := runPlugin(nf, plugin)
+		if err != nil {
+			panic(err)
+		}
+		for name, data := range pluginFiles {
+			if _, ok := files[name]; ok {
+				panic(fmt.Sprintf("plugin %s: %s is already generated", plugin, name))
+			}
+			files[name] = data
+		}
+	}
+	if check {
+		upToDate, err := core.CheckFiles(os.Stdout, files)
+		if err != nil {
+			panic(err)
+		}
+		if !upToDate {
+			panic(core.ExitStatus(core.ExitCheck))
+		}
+		return
+	}
+	if err := core.WriteFiles(files); err != nil {
+		panic(core.WriteError{Err: err})
+	}
+}
+
+func runFmt(args []string) {
+	fs, in := newFlagSet("fmt")
+	list := fs.Bool("l", false, "list the files whose formatting differs, and exit with status 1 if there are any")
+	write := fs.Bool("w", false, "write the formatted files instead of printing them")
+	parseFlags(fs, args)
+	if fs.NArg() == 0 {
+		panic(core.UsageError("Need at least one input file for fmt"))
+	}
+	files := map[string][]byte{}
+	var unformatted []string
+	// A file with errors is reported, and the others are formatted anyway.
+	var errs core.ParseErrors
+	for _, name := range fs.Args() {
+		data, formatted, fileErrs := core.FormatFile(name, in.options())
+		if fileErrs != nil {
+			errs = append(errs, fileErrs...)
+			continue
+		}
+		if string(formatted) != string(data) {
+			unformatted = append(unformatted, name)
+			files[name] = formatted
+		}
+		if !*list && !*write {
+			if _, err := os.Stdout.Write(formatted); err != nil {
+				panic(core.WriteError{Err: err})
+			}
+		}
+	}
+	if *write {
+		if err := core.WriteFiles(files); err != nil {
+			panic(core.WriteError{Err: err})
+		}
+	}
+	if *list {
+		for _, name := range unformatted {
+			fmt.Println(name)
+		}
+	}
+	if len(errs) > 0 {
+		panic(errs)
+	}
+	if *list && len(unformatted) > 0 && !*write {
+		panic(core.ExitStatus(core.ExitCheck))
+	}
+}
+
+func runVet(args []string) {
+	fs, in := newFlagSet("vet")
+	parseFlags(fs, args)
+	nf := in.parseArg(fs)
+	found := core.Vet(nf)
+	for _, w := range found {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", nf.Pos(w.Line), w.Msg)
+	}
+	if len(found) > 0 {
+		panic(core.ExitStatus(core.ExitCheck))
+	}
+}
+
+func runDoc(args []string) {
+	fs, in := newFlagSet("doc")
+	parseFlags(fs, args)
+	if err := core.WriteCatalog(os.Stdout, in.parseArg(fs)); err != nil {
+		panic(err)
+	}
+}
+
+func runERD(args []string) {
+	fs, in := newFlagSet("erd")
+	formatName := fs.String("format", "mermaid", "diagram format, mermaid or dot")
+	parseFlags(fs, args)
+	tables := core.ParseSchema(in.parseArg(fs))
+	var err error
+	switch *formatName {
+	case "mermaid":
+		err = core.WriteMermaid(os.Stdout, tables)
+	case "dot":
+		err = core.WriteDot(os.Stdout, tables)
+	default:
+		panic(core.UsageError(fmt.Sprintf("Unknown diagram format %q", *formatName)))
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+func runDeps(args []string) {
+	fs, in := newFlagSet("deps")
+	parseFlags(fs, args)
+	if err := core.WriteDeps(os.Stdout, in.parseArg(fs)); err != nil {
+		panic(err)
+	}
+}
+
+func runIR(args []string) {
+	fs, in := newFlagSet("ir")
+	parseFlags(fs, args)
+	nf := in.parseArg(fs)
+	if err := core.ResolvePackage(nf); err != nil {
+		panic(err)
+	}
+	if err := core.WriteIR(os.Stdout, nf); err != nil {
+		panic(err)
+	}
+}
+
+func runAST(args []string) {
+	fs, in := newFlagSet("ast")
+	parseFlags(fs, args)
+	if err := core.WriteAST(os.Stdout, in.parseArg(fs)); err != nil {
+		panic(err)
+	}
+}
+
+func runOpenAPI(args []string) {
+	fs, in := newFlagSet("openapi")
+	parseFlags(fs, args)
+	nf := in.parseArg(fs)
+	if err := core.ResolvePackage(nf); err != nil {
+		panic(err)
+	}
+	if err := core.WriteOpenAPI(os.Stdout, nf); err != nil {
+		panic(err)
+	}
+}
+
+func runDiffAPI(args []string) {
+	fs, in := newFlagSet("diff-api")
+	failOnBreaking := fs.Bool("fail-on-breaking", false, "exit with status 1 if a declaration was removed or changed")
+	rewrite := fs.String("rewrite", "", "command to filter every query body through")
+	parseFlags(fs, args)
+	core.LoadTemplates()
+	nf := in.parseArg(fs)
+	if err := core.ResolvePackage(nf); err != nil {
+		panic(err)
+	}
+	if err := rewriteQueries(nf, *rewrite); err != nil {
+		panic(err)
+	}
+	oldSrc, err := ioutil.ReadFile(nf.OutFile)
+	if err != nil {
+		panic(err)
+	}
+	oldSrcs := [][]byte{oldSrc}
+	// Files of !file groups not generated yet have no API to compare.
+	for _, name := range nf.GroupFiles() {
+		if src, err := ioutil.ReadFile(name); err == nil {
+			oldSrcs = append(oldSrcs, src)
+		}
+	}
+	changes, err := core.DiffAPI(oldSrcs, core.GenerateSource(nf))
+	if err != nil {
+		panic(err)
+	}
+	breaking, err := core.WriteAPIDiff(os.Stdout, changes)
+	if err != nil {
+		panic(err)
+	}
+	if breaking && *failOnBreaking {
+		panic(core.ExitStatus(core.ExitCheck))
+	}
+}
+
+func runPrune(args []string) {
+	fs, in := newFlagSet("prune")
+	usage := fs.String("usage", "", "JSON usage report of the calls of every query, from QueryCounts")
+	parseFlags(fs, args)
+	if *usage == "" {
+		panic(core.UsageError("Need -usage for prune"))
+	}
+	nf := in.parseArg(fs)
+	f, err := os.Open(*usage)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	unused, err := core.UnusedQueries(nf, f)
+	if err != nil {
+		panic(err)
+	}
+	for _, w := range unused {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", nf.Pos(w.Line), w.Msg)
+	}
+	if len(unused) > 0 {
+		panic(core.ExitStatus(core.ExitCheck))
+	}
+}
+
+func runDrift(args []string) {
+	fs, in := newFlagSet("drift")
+	driverName := fs.String("driver", "sqlite3", "database/sql driver of the database")
+	dsn := fs.String("dsn", "", "data source name of the database to compare with")
+	parseFlags(fs, args)
+	if *dsn == "" {
+		panic(core.UsageError("Need -dsn for drift"))
+	}
+	if !core.DriverRegistered(*driverName) {
+		panic(core.UsageError(fmt.Sprintf("Unknown -driver %s, this build of norm links %s", *driverName, strings.Join(sql.Drivers(), ", "))))
+	}
+	nf := in.parseArg(fs)
+	drift, err := core.CheckDrift(context.Background(), *driverName, *dsn, nf)
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range drift {
+		fmt.Println(d)
+	}
+	if len(drift) > 0 {
+		panic(core.ExitStatus(core.ExitCheck))
+	}
+}
+
+func runAnalyze(args []string) {
+	patterns := args
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	found, err := findBypasses(patterns)
+	if err != nil {
+		panic(err)
+	}
+	for _, b := range found {
+		fmt.Fprintln(os.Stderr, b)
+	}
+	if len(found) > 0 {
+		panic(core.ExitStatus(core.ExitCheck))
+	}
+}
@@ -0,0 +1,795 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/agrewal/norm/internal/core"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const queries = `-- !norm
+-- !package store
+-- !file store.go
+-- !stamp none
+-- !env prod file store_prod.go
+
+-- !read GetUsers
+-- !input domain string
+-- !output ID int
+-- !doc Lists the users of a domain.
+SELECT id FROM users WHERE domain = $1
+-- !ifdef extra
+
+-- !exec DeleteUser
+-- !input id int
+-- !doc Deletes a user.
+DELETE FROM users WHERE id = $1
+-- !endif
+`
+
+// withErrors has a directive with a wrong format on line 7, and a misspelled
+// one on line 10.
+const withErrors = `-- !norm
+-- !package store
+-- !file store.go
+
+-- !read GetUsers
+-- !output ID int
+-- !input domain
+-- !doc Lists the users of a domain.
+SELECT id FROM users WHERE domain = $1
+-- !deprecatd use ListUsers
+`
+
+// captureOutput redirects the standard output and error to files until the
+// returned function is called, which returns what was written to them.
+func captureOutput(t *testing.T) func() (string, string) {
+	stdout, stderr := os.Stdout, os.Stderr
+	outFile, err := ioutil.TempFile("", "norm_stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := ioutil.TempFile("", "norm_stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stderr = outFile, errFile
+	return func() (string, string) {
+		os.Stdout, os.Stderr = stdout, stderr
+		var ret []string
+		for _, f := range []*os.File{outFile, errFile} {
+			f.Close()
+			data, err := ioutil.ReadFile(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Remove(f.Name())
+			ret = append(ret, string(data))
+		}
+		return ret[0], ret[1]
+	}
+}
+
+// runNorm runs norm with args in dir, returning its standard output and
+// error and its exit code.
+func runNorm(t *testing.T, dir string, args ...string) (string, string, int) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	done := captureOutput(t)
+	code := run(args)
+	stdout, stderr := done()
+	return stdout, stderr, code
+}
+
+// writeFiles writes files, by name, to a new temporary directory, creating
+// the directories of their names.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "norm_cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		mode := os.FileMode(0644)
+		if strings.HasSuffix(name, ".sh") {
+			mode = 0755
+		}
+		if err := ioutil.WriteFile(path, []byte(data), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// The plugins of the tests: plugin saves its input and returns the file
+// api.txt, failPlugin fails, and escPlugin returns a file outside of the
+// directory of the output file.
+const (
+	plugin     = "#!/bin/sh\ncat > input.json\necho '{\"files\": [{\"name\": \"api.txt\", \"content\": \"GetUsers\\\\n\"}]}'\n"
+	failPlugin = "#!/bin/sh\necho 'no templates' >&2\nexit 3\n"
+	escPlugin  = "#!/bin/sh\necho '{\"files\": [{\"name\": \"../api.txt\", \"content\": \"\"}]}'\n"
+)
+
+func TestMain(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		args  []string
+		code  int
+		// stdout and stderr are the lines expected in the output.
+		stdout []string
+		stderr []string
+		// written are the files expected after the run, with a line they hold.
+		written map[string]string
+	}{
+		{
+			name:   "help",
+			args:   []string{"help"},
+			stdout: []string{"Usage: norm <command> [flags] <input files>", "\tnorm generate [flags] <input files>", "\tnorm analyze [packages]"},
+		},
+		{
+			name:   "subcommand help",
+			args:   []string{"doc", "-h"},
+			stderr: []string{"Usage: norm doc [flags] <input file>", "  -env string"},
+		},
+		{
+			name:   "generate without a subcommand",
+			files:  map[string]string{"q.norm.sql": queries},
+			args:   []string{"q.norm.sql"},
+			stderr: []string{},
+			written: map[string]string{
+				"store.go": "func (n *Norm) GetUsers(ctx context.Context, domain string, opts ...Option) ([]int, error) {",
+			},
+		},
+		{
+			name:    "generate",
+			files:   map[string]string{"q.norm.sql": queries},
+			args:    []string{"generate", "-o", "other.go", "q.norm.sql"},
+			written: map[string]string{"other.go": "package store"},
+		},
+		{
+			name:   "stdout",
+			files:  map[string]string{"q.norm.sql": queries},
+			args:   []string{"generate", "-stdout", "q.norm.sql"},
+			stdout: []string{"package store", "func (n *Norm) GetUsers(ctx context.Context, domain string, opts ...Option) ([]int, error) {"},
+		},
+		{
+			name:    "env",
+			files:   map[string]string{"q.norm.sql": queries},
+			args:    []string{"generate", "-env", "prod", "q.norm.sql"},
+			written: map[string]string{"store_prod.go": "package store"},
+		},
+		{
+			name:    "define",
+			files:   map[string]string{"q.norm.sql": queries},
+			args:    []string{"generate", "-define", "extra", "q.norm.sql"},
+			written: map[string]string{"store.go": "func (n *Norm) DeleteUser(ctx context.Context, id int, opts ...Option) error {"},
+		},
+		{
+			name:   "shared flags of another subcommand",
+			files:  map[string]string{"q.norm.sql": queries},
+			args:   []string{"ir", "-env", "prod", "-define", "extra", "q.norm.sql"},
+			stdout: []string{`  "out_file": "store_prod.go",`, `      "name": "DeleteUser",`},
+		},
+		{
+			name:   "unknown flag",
+			args:   []string{"generate", "-nope", "q.norm.sql"},
+			code:   core.ExitUsage,
+			stderr: []string{"flag provided but not defined: -nope", "Usage: norm generate [flags] <input files>"},
+		},
+		{
+			name:   "no input file",
+			args:   []string{"generate"},
+			code:   core.ExitUsage,
+			stderr: []string{"norm: Need at least one input file", "Run norm help for the commands, and norm <command> -h for their flags."},
+		},
+		{
+			name:   "several input files",
+			files:  map[string]string{"q.norm.sql": queries},
+			args:   []string{"doc", "q.norm.sql", "q.norm.sql"},
+			code:   core.ExitUsage,
+			stderr: []string{"norm: Need exactly one input file for doc"},
+		},
+		{
+			name:   "unknown stamp",
+			files:  map[string]string{"q.norm.sql": queries},
+			args:   []string{"-stamp", "sha1", "q.norm.sql"},
+			code:   core.ExitUsage,
+			stderr: []string{"norm: Unknown -stamp sha1, expected date, hash or none"},
+		},
+		{
+			name:   "missing input file",
+			args:   []string{"missing.norm.sql"},
+			code:   core.ExitParse,
+			stderr: []string{"stat missing.norm.sql: no such file or directory"},
+		},
+		{
+			name:  "all the errors at once",
+			files: map[string]string{"q.norm.sql": withErrors},
+			args:  []string{"q.norm.sql"},
+			code:  core.ExitParse,
+			stderr: []string{
+				`q.norm.sql:7:11: Format error: "-- !input domain"`,
+				`q.norm.sql:10:5: Unknown command: "-- !deprecatd use ListUsers"`,
+			},
+		},
+		{
+			name:  "carets",
+			files: map[string]string{"q.norm.sql": withErrors},
+			args:  []string{"vet", "q.norm.sql"},
+			code:  core.ExitParse,
+			stderr: []string{
+				"\t-- !input domain",
+				"\t          ^",
+				"\texpected -- !input <name> <type>",
+				"\tdid you mean -- !deprecated <message>?",
+			},
+		},
+		{
+			name:  "write error",
+			files: map[string]string{"q.norm.sql": queries},
+			args:  []string{"-o", filepath.Join("missing", "store.go"), "q.norm.sql"},
+			code:  core.ExitWrite,
+		},
+		{
+			name:   "package conflict",
+			files:  map[string]string{"q.norm.sql": queries, "api.go": "package api\n"},
+			args:   []string{"q.norm.sql"},
+			code:   core.ExitFailed,
+			stderr: []string{"norm: package store conflicts with package api declared in api.go"},
+		},
+		{
+			// Files generated by norm get the new package.
+			name:    "package of generated files",
+			files:   map[string]string{"q.norm.sql": queries, "users.go": "// Code generated by norm. DO NOT EDIT.\n\npackage api\n", "doc.go": "package store\n"},
+			args:    []string{"q.norm.sql"},
+			written: map[string]string{"store.go": "package store"},
+		},
+		{
+			name:  "strict",
+			files: map[string]string{"q.norm.sql": strings.Replace(queries, "WHERE domain = $1", "WHERE domain = 'a'", 1)},
+			args:  []string{"-strict", "q.norm.sql"},
+			code:  core.ExitCheck,
+			stderr: []string{
+				"q.norm.sql:7: error: GetUsers: input domain is never used as $1",
+			},
+		},
+		{
+			name:    "plugin",
+			files:   map[string]string{"q.norm.sql": queries, "plugin.sh": plugin},
+			args:    []string{"-plugin", "./plugin.sh", "q.norm.sql"},
+			written: map[string]string{"api.txt": "GetUsers", "input.json": `"name":"GetUsers"`},
+		},
+		{
+			name:   "failing plugin",
+			files:  map[string]string{"q.norm.sql": queries, "plugin.sh": failPlugin},
+			args:   []string{"-plugin", "./plugin.sh", "q.norm.sql"},
+			code:   core.ExitFailed,
+			stderr: []string{"norm: plugin ./plugin.sh: exit status 3: no templates"},
+		},
+		{
+			name:   "plugin writing outside",
+			files:  map[string]string{"q.norm.sql": queries, "plugin.sh": escPlugin},
+			args:   []string{"-plugin", "./plugin.sh", "q.norm.sql"},
+			code:   core.ExitFailed,
+			stderr: []string{`norm: plugin ./plugin.sh: file "../api.txt" is not inside the directory of the output file`},
+		},
+		{
+			name:  "ast",
+			files: map[string]string{"q.norm.sql": queries},
+			args:  []string{"ast", "q.norm.sql"},
+			stdout: []string{
+				`      "name": "env",`,
+				`      "args": "prod file store_prod.go",`,
+				`      "command": "read",`,
+				`      "name": "GetUsers",`,
+				`      "source": "q.norm.sql",`,
+				`      "line": 7,`,
+			},
+		},
+		{
+			name:  "vet",
+			files: map[string]string{"q.norm.sql": strings.Replace(queries, "-- !input domain string", "-- !input type string", 1)},
+			args:  []string{"vet", "q.norm.sql"},
+			code:  core.ExitCheck,
+			stderr: []string{
+				"q.norm.sql:7: GetUsers: input type is a Go keyword, which does not compile as a parameter",
+			},
+		},
+		{
+			name:  "vet without warnings",
+			files: map[string]string{"q.norm.sql": queries},
+			args:  []string{"vet", "q.norm.sql"},
+		},
+	}
+	for _, test := range tests {
+		if _, ok := test.files["plugin.sh"]; ok && runtime.GOOS == "windows" {
+			continue
+		}
+		dir := writeFiles(t, test.files)
+		stdout, stderr, code := runNorm(t, dir, test.args...)
+		if code != test.code {
+			t.Errorf("%s: expected exit code %d, got %d, with:\n%s", test.name, test.code, code, stderr)
+		}
+		for _, want := range test.stdout {
+			if !containsLine(stdout, want) {
+				t.Errorf("%s: expected the line %q in the output:\n%s", test.name, want, stdout)
+			}
+		}
+		for _, want := range test.stderr {
+			if !containsLine(stderr, want) {
+				t.Errorf("%s: expected the line %q in the errors:\n%s", test.name, want, stderr)
+			}
+		}
+		if test.stderr != nil && len(test.stderr) == 0 && stderr != "" {
+			t.Errorf("%s: expected no errors, got:\n%s", test.name, stderr)
+		}
+		for name, want := range test.written {
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			} else if !strings.Contains(string(data), want) {
+				t.Errorf("%s: expected %q in %s:\n%s", test.name, want, name, data)
+			}
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// containsLine reports whether line is one of the lines of s.
+func containsLine(s, line string) bool {
+	for _, l := range strings.Split(s, "\n") {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGenerateGlob(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"orders.sql": "-- !norm\n-- !package store\n-- !file store.go\n-- !stamp none\n\n-- !read GetOrders\n-- !output ID int\nSELECT id FROM orders\n",
+		"users.sql":  "-- !norm\n\n-- !read GetUsers\n-- !output ID int\nSELECT id FROM users\n",
+	})
+	defer os.RemoveAll(dir)
+	// The glob is expanded by norm, as it is when quoted in a shell or in a
+	// go:generate line. The files are read in order, so the header of
+	// orders.sql applies to both.
+	if _, stderr, code := runNorm(t, dir, "*.sql"); code != 0 {
+		t.Fatalf("Expected norm *.sql to succeed, got %d:\n%s", code, stderr)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "store.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"func (n *Norm) GetUsers(", "func (n *Norm) GetOrders("} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s in the code generated for *.sql:\n%s", want, data)
+		}
+	}
+	if _, stderr, code := runNorm(t, dir, "*.norm.sql"); code != core.ExitParse || !strings.Contains(stderr, "no files match *.norm.sql") {
+		t.Errorf("Expected norm *.norm.sql to fail with no files, got %d:\n%s", code, stderr)
+	}
+}
+
+func TestGenerateFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"users.sql":   "-- !norm\n-- !package store\n-- !file store.go\n-- !stamp none\n\n-- !read GetUsers\n-- !output ID int\nSELECT id FROM users\n",
+		"orders.sql":  "-- !norm\n\n-- !read GetOrders\n-- !output ID int\nSELECT id FROM orders\n",
+		"billing.sql": "-- !norm\n-- !file billing.go\n\n-- !read GetInvoices\n-- !output ID int\nSELECT id FROM invoices\n",
+	})
+	defer os.RemoveAll(dir)
+	if _, stderr, code := runNorm(t, dir, "users.sql", "orders.sql", "billing.sql"); code != 0 {
+		t.Fatalf("Expected norm to succeed, got %d:\n%s", code, stderr)
+	}
+	// The header of the first file applies to all of them, and the !file of
+	// billing.sql only to its own commands.
+	want := map[string][]string{
+		"store.go":   {"package store", "func (n *Norm) GetUsers(", "func (n *Norm) GetOrders("},
+		"billing.go": {"package store", "func (n *Norm) GetInvoices("},
+	}
+	for name, lines := range want {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(string(data), line) {
+				t.Errorf("Expected %s in %s:\n%s", line, name, data)
+			}
+		}
+	}
+
+	// The errors of all the files are reported at once, each at its position
+	// in its file.
+	broken := writeFiles(t, map[string]string{
+		"users.sql":  "-- !norm\n-- !package store\n\n-- !read GetUsers\n-- !output ID\nSELECT id FROM users\n",
+		"orders.sql": "-- !norm\n\n-- !read GetOrders\n-- !outptu ID int\nSELECT id FROM orders\n",
+	})
+	defer os.RemoveAll(broken)
+	_, stderr, code := runNorm(t, broken, "users.sql", "orders.sql")
+	if code != core.ExitParse {
+		t.Errorf("Expected exit code %d, got %d", core.ExitParse, code)
+	}
+	for _, want := range []string{`users.sql:5:12: Format error: "-- !output ID"`, `orders.sql:4:5: Unknown command: "-- !outptu ID int"`} {
+		if !containsLine(stderr, want) {
+			t.Errorf("Expected the line %q in the errors:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestOutputFlags(t *testing.T) {
+	grouped := queries + "\n-- !file users.go\n\n-- !read GetAdmins\n-- !output ID int\n-- !doc Lists the admins.\nSELECT id FROM admins\n"
+	dir := writeFiles(t, map[string]string{"q.norm.sql": grouped})
+	defer os.RemoveAll(dir)
+
+	// -stdout writes the code of all the commands as one file, and no file.
+	stdout, stderr, code := runNorm(t, dir, "-stdout", "q.norm.sql")
+	if code != 0 {
+		t.Fatalf("Expected -stdout to succeed, got %d:\n%s", code, stderr)
+	}
+	for _, want := range []string{"func (n *Norm) GetUsers(", "func (n *Norm) GetAdmins("} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %s in the output of -stdout", want)
+		}
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Errorf("Expected -stdout to write no file, got %d files", len(infos)-1)
+	}
+
+	// -o replaces the !file of the input, not the !file of the groups.
+	if _, stderr, code := runNorm(t, dir, "-o", filepath.Join("gen", "db.go"), "q.norm.sql"); code != core.ExitWrite {
+		t.Errorf("Expected -o into a missing directory to fail with %d, got %d:\n%s", core.ExitWrite, code, stderr)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "gen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runNorm(t, dir, "-o", filepath.Join("gen", "db.go"), "q.norm.sql"); code != 0 {
+		t.Fatalf("Expected -o to succeed, got %d:\n%s", code, stderr)
+	}
+	for name, want := range map[string]string{filepath.Join("gen", "db.go"): "func (n *Norm) GetUsers(", "users.go": "func (n *Norm) GetAdmins("} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		} else if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s in %s", want, name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "store.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected store.go, the !file of the input, not to be written with -o")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dated := strings.Replace(queries, "-- !stamp none", "-- !stamp date", 1)
+	dir := writeFiles(t, map[string]string{"q.norm.sql": dated})
+	defer os.RemoveAll(dir)
+
+	// check fails on files that were never generated.
+	stdout, _, code := runNorm(t, dir, "check", "q.norm.sql")
+	if code != core.ExitCheck || !containsLine(stdout, "store.go: not generated") {
+		t.Errorf("Expected check to fail with %d on a missing file, got %d:\n%s", core.ExitCheck, code, stdout)
+	}
+
+	// The generated files are up to date, whatever the date of their header.
+	if _, stderr, code := runNorm(t, dir, "q.norm.sql"); code != 0 {
+		t.Fatalf("Expected generate to succeed, got %d:\n%s", code, stderr)
+	}
+	path := filepath.Join(dir, "store.go")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(string(data), "\n", 3)
+	if !strings.HasPrefix(lines[1], "// Generated on: ") {
+		t.Fatalf("Expected the date on the second line of store.go, got %q", lines[1])
+	}
+	lines[1] = "// Generated on: 2001-02-03 04:05:06"
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"check", "q.norm.sql"}, {"-check", "q.norm.sql"}} {
+		if stdout, stderr, code := runNorm(t, dir, args...); code != 0 || stdout != "" {
+			t.Errorf("Expected %s to succeed silently, got %d:\n%s%s", args[0], code, stdout, stderr)
+		}
+	}
+
+	// A changed query makes the file stale, and the difference is printed.
+	stale := strings.Replace(dated, "FROM users WHERE", "FROM members WHERE", 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "q.norm.sql"), []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"check", "q.norm.sql"}, {"-check", "q.norm.sql"}} {
+		stdout, stderr, code := runNorm(t, dir, args...)
+		if code != core.ExitCheck {
+			t.Errorf("Expected %s to fail with %d on a stale file, got %d:\n%s", args[0], core.ExitCheck, code, stderr)
+		}
+		for _, want := range []string{"--- store.go", "+++ store.go (generated)"} {
+			if !containsLine(stdout, want) {
+				t.Errorf("Expected the line %q in the output of %s, got:\n%s", want, args[0], stdout)
+			}
+		}
+		if !strings.Contains(stdout, "\n@@ line ") || !strings.Contains(stdout, "members") {
+			t.Errorf("Expected the changed lines in the output of %s, got:\n%s", args[0], stdout)
+		}
+	}
+	after, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(after), "members") {
+		t.Errorf("Expected check not to write store.go")
+	}
+}
+
+func TestStamp(t *testing.T) {
+	grouped := queries + "\n-- !file users.go\n\n-- !read GetAdmins\n-- !output ID int\nSELECT id FROM admins\n"
+	dir := writeFiles(t, map[string]string{
+		"q.norm.sql":     grouped,
+		"dated.norm.sql": strings.Replace(grouped, "-- !stamp none", "-- !stamp date", 1),
+	})
+	defer os.RemoveAll(dir)
+
+	// generate returns the generated files, by name, split in lines.
+	generate := func(args ...string) map[string][]string {
+		if _, stderr, code := runNorm(t, dir, args...); code != 0 {
+			t.Fatalf("Expected %v to succeed, got %d:\n%s", args, code, stderr)
+		}
+		files := map[string][]string{}
+		for _, name := range []string{"store.go", "users.go"} {
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[name] = strings.Split(string(data), "\n")
+		}
+		return files
+	}
+
+	unstamped := generate("q.norm.sql")
+	for name, lines := range unstamped {
+		if strings.HasPrefix(lines[1], "// Generated on: ") || strings.HasPrefix(lines[1], "// Content hash: ") {
+			t.Errorf("Expected no stamp in %s with !stamp none, got %q", name, lines[1])
+		}
+	}
+	for _, args := range [][]string{{"dated.norm.sql"}, {"-stamp", "date", "q.norm.sql"}} {
+		for name, lines := range generate(args...) {
+			if !strings.HasPrefix(lines[1], "// Generated on: ") {
+				t.Errorf("Expected the date on the second line of %s with %v, got %q", name, args, lines[1])
+			}
+		}
+	}
+
+	// The hash is the SHA-256 of the file without the stamp, so it is the
+	// same each time the file is generated.
+	hashed := generate("-stamp", "hash", "dated.norm.sql")
+	for name, lines := range hashed {
+		want := fmt.Sprintf("// Content hash: sha256:%x", sha256.Sum256([]byte(strings.Join(unstamped[name], "\n"))))
+		if lines[1] != want {
+			t.Errorf("Expected the line %q in %s, got %q", want, name, lines[1])
+		}
+		if got := append(lines[:1:1], lines[2:]...); !reflect.DeepEqual(got, unstamped[name]) {
+			t.Errorf("Expected %s to only differ by its stamp", name)
+		}
+	}
+	if again := generate("-stamp", "hash", "q.norm.sql"); !reflect.DeepEqual(again, hashed) {
+		t.Errorf("Expected the hashed files to be the same when generated again")
+	}
+}
+
+func TestDiffAPI(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"q.norm.sql": queries})
+	defer os.RemoveAll(dir)
+	if _, stderr, code := runNorm(t, dir, "q.norm.sql"); code != 0 {
+		t.Fatalf("Expected generate to succeed, got %d:\n%s", code, stderr)
+	}
+	if stdout, stderr, code := runNorm(t, dir, "diff-api", "-fail-on-breaking", "q.norm.sql"); code != 0 || stdout != "" {
+		t.Errorf("Expected no change, got %d:\n%s%s", code, stdout, stderr)
+	}
+
+	// A new query is listed as added.
+	added := queries + "\n-- !exec DeleteAdmins\nDELETE FROM admins\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "q.norm.sql"), []byte(added), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := runNorm(t, dir, "diff-api", "q.norm.sql")
+	if code != 0 || !containsLine(stdout, "+ func (*Norm).DeleteAdmins(context.Context, ...Option) error") {
+		t.Errorf("Expected DeleteAdmins to be added, got %d:\n%s%s", code, stdout, stderr)
+	}
+
+	// A changed input breaks the callers, which only fails with
+	// -fail-on-breaking.
+	changed := strings.Replace(queries, "-- !input domain string", "-- !input domain int", 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "q.norm.sql"), []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"~ (*Norm).GetUsers", "    was: func (*Norm).GetUsers(context.Context, string, ...Option) ([]int, error)", "    now: func (*Norm).GetUsers(context.Context, int, ...Option) ([]int, error)"}
+	for _, args := range [][]string{{"diff-api", "q.norm.sql"}, {"diff-api", "-fail-on-breaking", "q.norm.sql"}} {
+		stdout, stderr, code := runNorm(t, dir, args...)
+		if wantCode := len(args) - 2; code != wantCode {
+			t.Errorf("Expected %v to exit with %d, got %d:\n%s", args, wantCode, code, stderr)
+		}
+		for _, line := range want {
+			if !containsLine(stdout, line) {
+				t.Errorf("Expected the line %q in the output of %v, got:\n%s", line, args, stdout)
+			}
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"q.norm.sql":  queries,
+		"used.json":   `{"GetUsers": 3, "DeleteUser": 1}`,
+		"unused.json": `{"GetUsers": 0}`,
+		"bad.json":    `["GetUsers"]`,
+	})
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		args   []string
+		code   int
+		stderr []string
+	}{
+		{[]string{"prune", "-usage", "used.json", "q.norm.sql"}, 0, nil},
+		{[]string{"prune", "-usage", "unused.json", "q.norm.sql"}, core.ExitCheck, []string{"q.norm.sql:7: GetUsers: no calls in the usage report"}},
+		{[]string{"prune", "-usage", "unused.json", "-define", "extra", "q.norm.sql"}, core.ExitCheck, []string{"q.norm.sql:7: GetUsers: no calls in the usage report", "q.norm.sql:14: DeleteUser: no calls in the usage report"}},
+		{[]string{"prune", "q.norm.sql"}, core.ExitUsage, []string{"norm: Need -usage for prune"}},
+		{[]string{"prune", "-usage", "bad.json", "q.norm.sql"}, core.ExitFailed, []string{"norm: reading usage report: json: cannot unmarshal array into Go value of type map[string]int64"}},
+	}
+	for _, test := range tests {
+		stdout, stderr, code := runNorm(t, dir, test.args...)
+		if code != test.code || stdout != "" {
+			t.Errorf("Expected %v to exit with %d and print nothing, got %d:\n%s%s", test.args, test.code, code, stdout, stderr)
+		}
+		for _, line := range test.stderr {
+			if !containsLine(stderr, line) {
+				t.Errorf("Expected the line %q from %v, got:\n%s", line, test.args, stderr)
+			}
+		}
+		if test.code == 0 && stderr != "" {
+			t.Errorf("Expected no unused query from %v, got:\n%s", test.args, stderr)
+		}
+	}
+}
+
+func TestDrift(t *testing.T) {
+	schema := `-- !norm
+-- !package store
+
+-- !exec CreateUserTable
+CREATE TABLE user (id INTEGER PRIMARY KEY, email TEXT NOT NULL, name TEXT)
+
+-- !exec CreateUserEmailIndex
+CREATE INDEX user_email ON user (email)
+`
+	dir := writeFiles(t, map[string]string{"q.norm.sql": schema})
+	defer os.RemoveAll(dir)
+	for name, stmts := range map[string][]string{
+		"same.db":  {"CREATE TABLE user (id INTEGER PRIMARY KEY, email TEXT NOT NULL, name TEXT)", "CREATE INDEX user_email ON user (email)"},
+		"drift.db": {"CREATE TABLE user (id INTEGER PRIMARY KEY, email TEXT NOT NULL)"},
+	} {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		db.Close()
+	}
+
+	if stdout, stderr, code := runNorm(t, dir, "drift", "-dsn", "same.db", "q.norm.sql"); code != 0 || stdout != "" {
+		t.Errorf("Expected no drift, got %d:\n%s%s", code, stdout, stderr)
+	}
+	stdout, stderr, code := runNorm(t, dir, "drift", "-driver", "sqlite3", "-dsn", "drift.db", "q.norm.sql")
+	if want := "user.name: missing column\nuser: missing index user_email\n"; code != core.ExitCheck || stdout != want {
+		t.Errorf("Expected the missing column and index, got %d:\n%s%s", code, stdout, stderr)
+	}
+	if _, stderr, code := runNorm(t, dir, "drift", "-dsn", "missing.db", "q.norm.sql"); code != core.ExitFailed || !strings.Contains(stderr, "missing.db") {
+		t.Errorf("Expected the missing database to fail, got %d:\n%s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.db")); !os.IsNotExist(err) {
+		t.Errorf("Expected no database to be created, got %v", err)
+	}
+	if _, stderr, code := runNorm(t, dir, "drift", "-driver", "pgx", "-dsn", "drift.db", "q.norm.sql"); code != core.ExitUsage || !strings.Contains(stderr, "norm: Unknown -driver pgx") {
+		t.Errorf("Expected an unknown driver to be rejected, got %d:\n%s", code, stderr)
+	}
+	if _, stderr, code := runNorm(t, dir, "drift", "q.norm.sql"); code != core.ExitUsage || !strings.Contains(stderr, "norm: Need -dsn for drift") {
+		t.Errorf("Expected drift without -dsn to be rejected, got %d:\n%s", code, stderr)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.15\n",
+		"store/db.go": `// Code generated by norm. DO NOT EDIT.
+
+package store
+
+import "database/sql"
+
+type Norm struct{ db *sql.DB }
+
+func (n *Norm) Ping() error { return n.db.Ping() }
+
+func (n *Norm) Get() (*sql.Rows, error) { return n.db.Query("SELECT 1") }
+`,
+		"app/app.go": `package app
+
+import (
+	"context"
+	"database/sql"
+
+	"example.com/app/store"
+)
+
+type server struct {
+	*sql.Tx
+	n *store.Norm
+}
+
+func run(ctx context.Context, db *sql.DB, s server) error {
+	if err := db.PingContext(ctx); err != nil {
+		return err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users")
+	if err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = s.Exec("DELETE FROM users")
+	return err
+}
+`,
+		// Packages not using the generated code are not reported.
+		"tool/tool.go": `package tool
+
+import "database/sql"
+
+func run(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM users")
+	return err
+}
+`,
+	})
+	defer os.RemoveAll(dir)
+	_, stderr, code := runNorm(t, dir, "analyze")
+	if code != core.ExitCheck {
+		t.Errorf("Expected analyze to exit with %d, got %d:\n%s", core.ExitCheck, code, stderr)
+	}
+	want := []string{
+		filepath.Join(dir, "app", "app.go") + ":19:15: direct database/sql call db.QueryContext, use the generated queries instead",
+		filepath.Join(dir, "app", "app.go") + ":24:11: direct database/sql call s.Exec, use the generated queries instead",
+	}
+	if got := strings.Split(strings.TrimSpace(stderr), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the calls:\n%s\ngot:\n%s", strings.Join(want, "\n"), stderr)
+	}
+
+	if stdout, stderr, code := runNorm(t, dir, "analyze", "./tool"); code != 0 || stdout+stderr != "" {
+		t.Errorf("Expected no call reported in a package not using norm, got %d:\n%s%s", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/agrewal/norm/internal/core"
+)
+
+// checkModels checks the !model structs of the commands of nf against the
+// outputs read into them: every output must name a field of the same type.
+// For commands taking rows, every input must name a field. Models are loaded
+// from the package of the output file, or from the imported package they are
+// qualified with. Types generated by norm, like the models of views and of
+// !model_gen, are not checked, nor are models built by a !mapper. The type
+// errors of a package, other than references to the types generated by norm,
+// are returned as warnings, since they may hide the fields of the models.
+func checkModels(nf *core.NormFile) ([]core.Warning, error) {
+	generated := map[string]bool{}
+	for _, cmd := range nf.Cmds {
+		for _, name := range cmd.Funcs() {
+			generated[name] = true
+		}
+		if c := cmd.Base(); c.ModelGen {
+			generated[c.Model] = true
+		}
+	}
+	for _, t := range nf.IDTypes {
+		generated[t.Name] = true
+	}
+	for _, k := range nf.Keys {
+		generated[k.Name] = true
+	}
+	var warnings []core.Warning
+	pkgs := map[string]*types.Package{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if c.Model == "" || generated[c.Model] || c.Mapper != "" {
+			continue
+		}
+		qual, name := "", c.Model
+		if ix := strings.Index(name, "."); ix >= 0 {
+			qual, name = name[:ix], name[ix+1:]
+		}
+		pkg, ok := pkgs[qual]
+		if !ok {
+			var typeErrs []error
+			var err error
+			if pkg, typeErrs, err = loadModelPackage(nf, qual, generated); err != nil {
+				return nil, fmt.Errorf("!model of %s on line %d: loading the package of %s: %v", c.FuncName, c.Line, c.Model, err)
+			}
+			if len(typeErrs) > 0 {
+				msg := fmt.Sprintf("!model of %s: package %s has type errors, so its models may not be checked correctly: %v", c.FuncName, pkg.Path(), typeErrs[0])
+				if len(typeErrs) > 1 {
+					msg += fmt.Sprintf(" (and %d more)", len(typeErrs)-1)
+				}
+				warnings = append(warnings, core.Warning{Line: c.Line, Msg: msg})
+			}
+			pkgs[qual] = pkg
+		}
+		obj := pkg.Scope().Lookup(name)
+		if obj == nil {
+			return nil, fmt.Errorf("!model of %s on line %d: %s is not declared in package %s", c.FuncName, c.Line, c.Model, pkg.Path())
+		}
+		if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("!model of %s on line %d: %s is not a struct", c.FuncName, c.Line, c.Model)
+		}
+		// Types of the model package are written qualified by its name in
+		// the generated package, unless it is the same package.
+		qualifier := func(p *types.Package) string {
+			if p == pkg && qual == "" {
+				return ""
+			}
+			return p.Name()
+		}
+		if core.TakesRows(cmd) {
+			for _, inp := range c.Inputs {
+				if modelField(obj, pkg, core.FieldName(inp.Name), qual != "") == nil {
+					return nil, fmt.Errorf("!model of %s on line %d: %s has no field %s for input %s", c.FuncName, c.Line, c.Model, core.FieldName(inp.Name), inp.Name)
+				}
+			}
+			continue
+		}
+		for _, o := range c.Outputs {
+			field := modelField(obj, pkg, o.Name, qual != "")
+			if field == nil {
+				return nil, fmt.Errorf("!model of %s on line %d: %s has no field %s for output %s", c.FuncName, c.Line, c.Model, o.Name, o.Name)
+			}
+			// Fields of types the package failed to resolve, like types
+			// generated by norm, cannot be compared.
+			if field.Type() == types.Typ[types.Invalid] {
+				continue
+			}
+			if typ := types.TypeString(field.Type(), qualifier); typ != o.Typ {
+				return nil, fmt.Errorf("!model of %s on line %d: field %s of %s has type %s, but output %s is declared as %s", c.FuncName, c.Line, o.Name, c.Model, typ, o.Name, o.Typ)
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// modelField returns the field named name of the model obj declared in pkg,
+// or nil if there is none. Fields of models in another package than the
+// generated one must be exported.
+func modelField(obj types.Object, pkg *types.Package, name string, exported bool) *types.Var {
+	found, _, _ := types.LookupFieldOrMethod(obj.Type(), false, pkg, name)
+	field, ok := found.(*types.Var)
+	if !ok || !field.IsField() || (exported && !field.Exported()) {
+		return nil
+	}
+	return field
+}
+
+// loadModelPackage type checks the package of the models qualified with qual:
+// the package of the output file of nf if qual is empty, or else the package
+// imported by nf under that name. Files generated by norm are left out, so
+// the references to the names they declare, or to those in generated, are not
+// type errors. The other type errors are returned along with the package.
+func loadModelPackage(nf *core.NormFile, qual string, generated map[string]bool) (*types.Package, []error, error) {
+	pattern := filepath.Dir(nf.OutFile)
+	if !filepath.IsAbs(pattern) {
+		pattern = "." + string(filepath.Separator) + pattern
+	}
+	if qual != "" {
+		pattern = ""
+		for _, spec := range nf.Imports {
+			alias, importPath := "", spec
+			if ix := strings.Index(spec, " "); ix >= 0 {
+				alias, importPath = spec[:ix], spec[ix+1:]
+			}
+			importPath, err := strconv.Unquote(importPath)
+			if err != nil {
+				continue
+			}
+			if alias == qual || (alias == "" && path.Base(importPath) == qual) {
+				pattern = importPath
+			}
+		}
+		if pattern == "" {
+			return nil, nil, fmt.Errorf("no import named %s", qual)
+		}
+	}
+	pkgs, err := listPackages([]string{pattern})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, nil, fmt.Errorf("%s matches %d packages", pattern, len(pkgs))
+	}
+	p := pkgs[0]
+	fset := token.NewFileSet()
+	var files []*ast.File
+	declared := map[string]bool{}
+	for name := range generated {
+		declared[name] = true
+	}
+	for _, name := range p.GoFiles {
+		filename := filepath.Join(p.Dir, name)
+		if isNormGenerated(filename) {
+			if f, err := parser.ParseFile(fset, filename, nil, 0); err == nil {
+				for name := range f.Scope.Objects {
+					declared[name] = true
+				}
+			}
+			continue
+		}
+		f, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	var typeErrs []error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if matches := rxUndefined.FindStringSubmatch(err.Error()); matches == nil || !declared[matches[1]] {
+				typeErrs = append(typeErrs, err)
+			}
+		},
+	}
+	pkg, _ := conf.Check(p.ImportPath, fset, files, nil)
+	return pkg, typeErrs, nil
+}
+
+// rxUndefined matches the type errors of references to undeclared names.
+var rxUndefined = regexp.MustCompile(`: (?:undefined|undeclared name): ([\pL_][\pL\pN_]*)$`)
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agrewal/norm/internal/core"
+)
+
+func TestCheckModelsTypeErrors(t *testing.T) {
+	input := `-- !norm
+-- !read_one GetUser
+-- !input id int64
+-- !output ID int64
+-- !output Email string
+-- !model User
+-- !mapper newUser
+SELECT id, email FROM users WHERE id = $1
+
+-- !read_one FindUser
+-- !input email string
+-- !output ID int64
+-- !output Email string
+-- !model User
+SELECT id, email FROM users WHERE email = $1
+`
+	nf := core.ParseData([]byte(input), []core.Source{{Name: "<input>"}}, core.ParseOptions{})
+	nf.OutFile = filepath.Join("testdata", "models", "db.go")
+	warnings, err := checkModels(nf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("Expected one warning, got %v", warnings)
+	}
+	w := warnings[0]
+	if w.Line != 10 || !strings.Contains(w.Msg, "has type errors") || !strings.Contains(w.Msg, "undefined: Missing") {
+		t.Errorf("Expected a warning about Missing on line 10, got %d: %s", w.Line, w.Msg)
+	}
+	if strings.Contains(w.Msg, "more") {
+		t.Errorf("Expected the reference to GetUserRow not to be reported, got %s", w.Msg)
+	}
+}
+
+func TestCheckModels(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.15\n",
+		"store/models.go": `package store
+
+import "time"
+
+type User struct {
+	ID      int64
+	Email   string
+	Name    *string
+	Created time.Time
+	secret  string
+}
+
+type Role string
+`,
+		"models/models.go": `package models
+
+type Account struct {
+	ID      int64
+	Balance float64
+	owner   string
+}
+`,
+	})
+	defer os.RemoveAll(dir)
+	// The commands of the tests start on line 6.
+	header := "-- !norm\n-- !package store\n-- !file store/db.go\n-- !import \"example.com/app/models\"\n\n"
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		err   string
+	}{
+		{
+			name:  "match",
+			input: "-- !read ListUsers\n-- !output ID int64\n-- !output Name *string\n-- !output Created time.Time\n-- !output secret string\n-- !model User\nSELECT id, name, created, secret FROM users\n",
+		},
+		{
+			name:  "missing field",
+			input: "-- !read_one GetUser\n-- !input id int64\n-- !output ID int64\n-- !output Phone string\n-- !model User\nSELECT id, phone FROM users WHERE id = $1\n",
+			err:   "q.norm.sql:6: !model of GetUser: User has no field Phone for output Phone",
+		},
+		{
+			name:  "type",
+			input: "-- !read ListUsers\n-- !output ID int64\n-- !output Email []byte\n-- !model User\nSELECT id, email FROM users\n",
+			err:   "q.norm.sql:6: !model of ListUsers: field Email of User has type string, but output Email is declared as []byte",
+		},
+		{
+			name:  "skipped",
+			input: "-- !read ListUsers\n-- !output ID int64\n-- !output Email []byte\n-- !model User\nSELECT id, email FROM users\n",
+			args:  []string{"-skip-model-check"},
+		},
+		{
+			name:  "imported",
+			input: "-- !read ListAccounts\n-- !output ID int64\n-- !output Balance float64\n-- !model models.Account\nSELECT id, balance FROM accounts\n",
+		},
+		{
+			// Only the exported fields of models of other packages are set.
+			name:  "unexported",
+			input: "-- !read ListAccounts\n-- !output ID int64\n-- !output owner string\n-- !model models.Account\nSELECT id, owner FROM accounts\n",
+			err:   "q.norm.sql:6: !model of ListAccounts: models.Account has no field owner for output owner",
+		},
+		{
+			name:  "rows",
+			input: "-- !exec_many InsertUsers\n-- !input email string\n-- !input phone string\n-- !model User\nINSERT INTO users (email, phone) VALUES ($1, $2)\n",
+			err:   "q.norm.sql:6: !model of InsertUsers: User has no field Phone for input phone",
+		},
+		{
+			// Models built by a mapper or generated by norm are not checked.
+			name:  "mapper and model_gen",
+			input: "-- !read ListUsers\n-- !output ID int64\n-- !output Phone string\n-- !model User\n-- !mapper toUser\nSELECT id, phone FROM users\n\n-- !read ListAdmins\n-- !output ID int64\n-- !output Level int\n-- !model Admin\n-- !model_gen\nSELECT id, level FROM admins\n",
+		},
+		{
+			name:  "not a struct",
+			input: "-- !read ListRoles\n-- !output ID int64\n-- !output Name string\n-- !model Role\nSELECT id, name FROM roles\n",
+			err:   "q.norm.sql:6: !model of ListRoles: Role is not a struct",
+		},
+		{
+			name:  "not declared",
+			input: "-- !read ListGroups\n-- !output ID int64\n-- !output Name string\n-- !model Group\nSELECT id, name FROM groups\n",
+			err:   "q.norm.sql:6: !model of ListGroups: Group is not declared in package example.com/app/store",
+		},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(filepath.Join(dir, "q.norm.sql"), []byte(header+test.input), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, stderr, code := runNorm(t, dir, append(append([]string{"-stdout"}, test.args...), "q.norm.sql")...)
+		if test.err == "" {
+			if code != 0 {
+				t.Errorf("%s: expected the models to match, got %d:\n%s", test.name, code, stderr)
+			}
+			continue
+		}
+		if code != core.ExitParse || !strings.Contains(stderr, test.err) {
+			t.Errorf("%s: expected the error %q, got %d:\n%s", test.name, test.err, code, stderr)
+		}
+	}
+}
+
+// TestModelPkgImport checks that the code generated for models in packages
+// whose path does not end with their name compiles.
+func TestModelPkgImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod":                 "module example.com/app\n\ngo 1.15\n",
+		"store/doc.go":           "package store\n",
+		"my-models/models.go":    "package models\n\ntype User struct {\n\tID    int64\n\tEmail string\n}\n",
+		"gopkg/foo.v2/foo.go":    "package foo\n\ntype User struct {\n\tID    int64\n\tEmail string\n}\n",
+		"versioned/v3/models.go": "package models\n\ntype User struct {\n\tID    int64\n\tEmail string\n}\n",
+	})
+	defer os.RemoveAll(dir)
+	for _, modelPkg := range []string{"example.com/app/my-models", "example.com/app/gopkg/foo.v2", "example.com/app/versioned/v3"} {
+		input := "-- !norm\n-- !package store\n-- !file store/db.go\n-- !model_pkg " + modelPkg + "\n\n" +
+			"-- !read_one GetUser\n-- !input id int64\n-- !output ID int64\n-- !output Email string\n-- !model User\nSELECT id, email FROM users WHERE id = $1\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, "q.norm.sql"), []byte(input), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, stderr, code := runNorm(t, dir, "q.norm.sql"); code != 0 {
+			t.Errorf("%s: expected generate to succeed, got %d:\n%s", modelPkg, code, stderr)
+			continue
+		}
+		cmd := osexec.Command("go", "build", "./store")
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%s: the generated code does not compile: %v\n%s", modelPkg, err, out)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/agrewal/norm/internal/core"
+)
+
+// pluginFlags are the commands given with -plugin, which may be repeated.
+type pluginFlags []string
+
+func (p *pluginFlags) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *pluginFlags) Set(cmd string) error {
+	if len(strings.Fields(cmd)) == 0 {
+		return fmt.Errorf("empty plugin command")
+	}
+	*p = append(*p, cmd)
+	return nil
+}
+
+// pluginResponse is what a plugin writes to its standard output: the files
+// to write along with the generated code.
+type pluginResponse struct {
+	Files []pluginFile `json:"files"`
+}
+
+// pluginFile is a file returned by a plugin. Name is relative to the
+// directory of the output file.
+type pluginFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// runPlugin runs the plugin command with the IR of nf, see norm ir, on its
+// standard input, and returns the files it returns, by path. The plugin runs
+// in the directory of the output file, and a failing plugin reports its
+// error on its standard error.
+func runPlugin(nf *core.NormFile, plugin string) (map[string][]byte, error) {
+	argv := strings.Fields(plugin)
+	var stdin, stdout, stderr bytes.Buffer
+	if err := json.NewEncoder(&stdin).Encode(core.BuildIR(nf)); err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(nf.OutFile)
+	cmd := osexec.Command(argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %v: %s", argv[0], err, strings.TrimSpace(stderr.String()))
+	}
+	var resp pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s: reading its response: %v", argv[0], err)
+	}
+	files := map[string][]byte{}
+	for _, f := range resp.Files {
+		name := filepath.Clean(filepath.FromSlash(f.Name))
+		if f.Name == "" || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("plugin %s: file %q is not inside the directory of the output file", argv[0], f.Name)
+		}
+		files[filepath.Join(dir, name)] = []byte(f.Content)
+	}
+	return files, nil
+}
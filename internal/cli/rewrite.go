@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"strings"
+
+	"github.com/agrewal/norm/internal/core"
+)
+
+// rewriteQueries pipes the body of every command in nf through the filter
+// command, replacing it with the output. The filter can tell the queries
+// apart using the NORM_QUERY and NORM_COMMAND environment variables. This is
+// the place to enforce organization wide query conventions, such as schema
+// qualification or mandatory WHERE clauses.
+func rewriteQueries(nf *core.NormFile, filter string) error {
+	argv := strings.Fields(filter)
+	if len(argv) == 0 {
+		return nil
+	}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		var stdout, stderr bytes.Buffer
+		rw := osexec.Command(argv[0], argv[1:]...)
+		rw.Env = append(os.Environ(), "NORM_QUERY="+c.FuncName, "NORM_COMMAND="+cmd.Kind())
+		rw.Stdin = strings.NewReader(c.BodyString())
+		rw.Stdout = &stdout
+		rw.Stderr = &stderr
+		if err := rw.Run(); err != nil {
+			return fmt.Errorf("rewriting %s: %v: %s", c.FuncName, err, strings.TrimSpace(stderr.String()))
+		}
+		body := strings.TrimRight(stdout.String(), "\n")
+		if strings.TrimSpace(body) == "" {
+			return fmt.Errorf("rewriting %s: filter returned an empty query", c.FuncName)
+		}
+		c.Body = strings.Split(body, "\n")
+	}
+	return nil
+}
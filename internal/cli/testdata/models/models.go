@@ -0,0 +1,12 @@
+package models
+
+// User is the model of the tests of checkModels.
+type User struct {
+	ID    int64
+	Email string
+	// Owner refers to a type that is not declared.
+	Owner Missing
+}
+
+// Refers to GetUserRow, which is generated by norm.
+var _ GetUserRow
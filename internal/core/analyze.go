@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Warning is a problem found in a norm file that does not stop generation.
+type Warning struct {
+	Line int
+	Msg  string
+}
+
+// Analyze checks the commands of nf for mistakes that would only show up as
+// errors at run time.
+func Analyze(nf *NormFile) []Warning {
+	var ret []Warning
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if _, ok := cmd.(*cmdCopy); ok {
+			continue
+		}
+		toks := tokenize(c.BodyString())
+		for _, msg := range checkInputs(c, toks) {
+			ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: %s", c.FuncName, msg)})
+		}
+		switch cmd.(type) {
+		case *cmdExec, *cmdExecMany, *cmdScript:
+			continue
+		}
+		if n := selectArity(toks); n > 0 && n != len(c.Outputs) {
+			ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: query returns %d columns but %d outputs are declared", c.FuncName, n, len(c.Outputs))})
+		}
+	}
+	ret = append(ret, checkViewModels(nf)...)
+	ret = append(ret, checkReturning(nf)...)
+	return append(ret, checkAttached(nf)...)
+}
+
+// checkInputs compares the declared inputs of c with the placeholders in its
+// body.
+func checkInputs(c *cmdBase, toks []sqlToken) []string {
+	var ret []string
+	numbered := map[int]bool{}
+	maxNumbered := 0
+	positional := 0
+	for _, t := range toks {
+		if t.Kind != tokPlaceholder {
+			continue
+		}
+		if t.Text == "?" {
+			positional++
+		} else if t.Text[0] == '$' {
+			n, _ := strconv.Atoi(t.Text[1:])
+			numbered[n] = true
+			if n > maxNumbered {
+				maxNumbered = n
+			}
+		}
+	}
+	if positional > 0 {
+		if positional != len(c.ArgInputs()) {
+			ret = append(ret, fmt.Sprintf("query has %d placeholders but %d inputs are declared", positional, len(c.ArgInputs())))
+		}
+		return ret
+	}
+	for ix, inp := range c.Inputs {
+		if !numbered[ix+1] {
+			ret = append(ret, fmt.Sprintf("input %s is never used as $%d", inp.Name, ix+1))
+		}
+	}
+	for n := len(c.Inputs) + 1; n <= maxNumbered; n++ {
+		if numbered[n] {
+			ret = append(ret, fmt.Sprintf("placeholder $%d has no matching input", n))
+		}
+	}
+	return ret
+}
+
+// selectArity returns the number of columns returned by the statement in
+// toks, or 0 if it cannot be determined, for example because of a *.
+func selectArity(toks []sqlToken) int {
+	cols := selectList(toks)
+	for _, col := range cols {
+		if len(col) == 0 || col[len(col)-1].Text == "*" {
+			return 0
+		}
+	}
+	return len(cols)
+}
+
+// selectList returns the expressions of the SELECT or RETURNING list of the
+// statement in toks, or nil if it has none.
+func selectList(toks []sqlToken) [][]sqlToken {
+	depth := 0
+	start := -1
+	for ix, t := range toks {
+		if t.Kind == tokPunct {
+			switch t.Text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			continue
+		}
+		if depth != 0 {
+			continue
+		}
+		if start < 0 && (t.is("SELECT") || t.is("RETURNING")) {
+			start = ix + 1
+			if start < len(toks) && (toks[start].is("DISTINCT") || toks[start].is("ALL")) {
+				start++
+			}
+			continue
+		}
+		if start >= 0 && (t.is("FROM") || t.is("INTO") || t.is("WHERE") || t.is("GROUP") || t.is("ORDER") || t.is("LIMIT") || t.is("UNION") || t.is("EXCEPT") || t.is("INTERSECT")) {
+			return splitColumns(toks[start:ix])
+		}
+	}
+	if start >= 0 {
+		return splitColumns(toks[start:])
+	}
+	return nil
+}
+
+func splitColumns(toks []sqlToken) [][]sqlToken {
+	if len(toks) > 0 && toks[len(toks)-1].Text == ";" {
+		toks = toks[:len(toks)-1]
+	}
+	return splitTopLevel(toks)
+}
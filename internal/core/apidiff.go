@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+)
+
+// apiChange is a difference in an exported declaration between two versions
+// of generated code.
+type apiChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// breaking reports whether code using the old declaration may no longer
+// compile against the new one.
+func (c apiChange) breaking() bool {
+	return c.Old != ""
+}
+
+func (c apiChange) String() string {
+	switch {
+	case c.Old == "":
+		return "+ " + c.New
+	case c.New == "":
+		return "- " + c.Old
+	default:
+		return fmt.Sprintf("~ %s\n    was: %s\n    now: %s", c.Name, c.Old, c.New)
+	}
+}
+
+// exportedAPI returns the exported declarations of a Go source file, keyed by
+// name. Parameter names are left out of function signatures, since renaming
+// them does not affect callers.
+func exportedAPI(src []byte) (map[string]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) == 1 {
+				recv := NodeString(fset, d.Recv.List[0].Type)
+				if !ast.IsExported(strings.TrimPrefix(recv, "*")) {
+					continue
+				}
+				name = "(" + recv + ")." + name
+			}
+			ret[name] = "func " + name + signatureString(fset, d.Type)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						ret[s.Name.Name] = "type " + s.Name.Name + " " + typeString(fset, s.Type)
+					}
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if !n.IsExported() {
+							continue
+						}
+						sig := d.Tok.String() + " " + n.Name
+						if s.Type != nil {
+							sig += " " + NodeString(fset, s.Type)
+						}
+						ret[n.Name] = sig
+					}
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+func signatureString(fset *token.FileSet, ft *ast.FuncType) string {
+	ret := "(" + strings.Join(fieldTypes(fset, ft.Params), ", ") + ")"
+	results := fieldTypes(fset, ft.Results)
+	switch len(results) {
+	case 0:
+	case 1:
+		ret += " " + results[0]
+	default:
+		ret += " (" + strings.Join(results, ", ") + ")"
+	}
+	return ret
+}
+
+func fieldTypes(fset *token.FileSet, fl *ast.FieldList) []string {
+	var ret []string
+	if fl == nil {
+		return ret
+	}
+	for _, f := range fl.List {
+		typ := NodeString(fset, f.Type)
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			ret = append(ret, typ)
+		}
+	}
+	return ret
+}
+
+// typeString renders a type on one line. Only the exported fields of structs
+// are included.
+func typeString(fset *token.FileSet, expr ast.Expr) string {
+	st, ok := expr.(*ast.StructType)
+	if !ok {
+		return NodeString(fset, expr)
+	}
+	var fields []string
+	for _, f := range st.Fields.List {
+		typ := NodeString(fset, f.Type)
+		if len(f.Names) == 0 {
+			fields = append(fields, typ)
+		}
+		for _, n := range f.Names {
+			if n.IsExported() {
+				fields = append(fields, n.Name+" "+typ)
+			}
+		}
+	}
+	return "struct { " + strings.Join(fields, "; ") + " }"
+}
+
+func NodeString(fset *token.FileSet, node ast.Node) string {
+	var bb bytes.Buffer
+	printer.Fprint(&bb, fset, node)
+	return strings.Join(strings.Fields(bb.String()), " ")
+}
+
+// DiffAPI compares the exported declarations of the previously generated
+// files oldSrcs with the newly generated source newSrc.
+func DiffAPI(oldSrcs [][]byte, newSrc []byte) ([]apiChange, error) {
+	oldAPI := map[string]string{}
+	for _, oldSrc := range oldSrcs {
+		api, err := exportedAPI(oldSrc)
+		if err != nil {
+			return nil, err
+		}
+		for name, sig := range api {
+			oldAPI[name] = sig
+		}
+	}
+	newAPI, err := exportedAPI(newSrc)
+	if err != nil {
+		return nil, err
+	}
+	var ret []apiChange
+	for name, sig := range oldAPI {
+		if newSig := newAPI[name]; newSig != sig {
+			ret = append(ret, apiChange{name, sig, newSig})
+		}
+	}
+	for name, sig := range newAPI {
+		if _, ok := oldAPI[name]; !ok {
+			ret = append(ret, apiChange{name, "", sig})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
+
+// WriteAPIDiff writes changes to w and reports whether any of them breaks
+// existing callers.
+func WriteAPIDiff(w io.Writer, changes []apiChange) (bool, error) {
+	breaking := false
+	for _, c := range changes {
+		if c.breaking() {
+			breaking = true
+		}
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return breaking, err
+		}
+	}
+	return breaking, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+const oldAPI = `package store
+
+type Norm struct{ db int }
+
+type GetUserOutput struct {
+	ID    int64
+	Email string
+	cache []byte
+}
+
+func (n *Norm) GetUser(ctx context.Context, id int64) (GetUserOutput, error) { return GetUserOutput{}, nil }
+
+func (n *Norm) DeleteUser(ctx context.Context, id int64) error { return nil }
+
+func (n *Norm) prepare() {}
+
+const Version = 1
+`
+
+func TestDiffAPI(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		diff     string
+		breaking bool
+	}{
+		{
+			// Parameter names and unexported declarations are not part of
+			// the API.
+			name: "same",
+			old:  oldAPI,
+			new: `package store
+type Norm struct{ conn int }
+type GetUserOutput struct { ID int64; Email string }
+func (n *Norm) GetUser(c context.Context, userID int64) (GetUserOutput, error) { return GetUserOutput{}, nil }
+func (n *Norm) DeleteUser(c context.Context, userID int64) error { return nil }
+const Version = 1
+`,
+		},
+		{
+			name: "added",
+			old:  oldAPI,
+			new:  oldAPI + "func (n *Norm) ListUsers(ctx context.Context) ([]int64, error) { return nil, nil }\n",
+			diff: "+ func (*Norm).ListUsers(context.Context) ([]int64, error)\n",
+		},
+		{
+			name: "removed and changed",
+			old:  oldAPI,
+			new: `package store
+type Norm struct{ db int }
+type GetUserOutput struct { ID int64; Email *string }
+func (n *Norm) GetUser(ctx context.Context, id int64) (GetUserOutput, error) { return GetUserOutput{}, nil }
+const Version = 1
+`,
+			diff: "- func (*Norm).DeleteUser(context.Context, int64) error\n" +
+				"~ GetUserOutput\n    was: type GetUserOutput struct { ID int64; Email string }\n    now: type GetUserOutput struct { ID int64; Email *string }\n",
+			breaking: true,
+		},
+	}
+	for _, test := range tests {
+		changes, err := DiffAPI([][]byte{[]byte(test.old)}, []byte(test.new))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var bb bytes.Buffer
+		breaking, err := WriteAPIDiff(&bb, changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bb.String() != test.diff {
+			t.Errorf("%s: expected the diff:\n%s\ngot:\n%s", test.name, test.diff, bb.String())
+		}
+		if breaking != test.breaking {
+			t.Errorf("%s: expected breaking %v, got %v", test.name, test.breaking, breaking)
+		}
+	}
+}
+
+// TestDiffAPIFiles checks that the declarations of all the old files are
+// compared with the new source.
+func TestDiffAPIFiles(t *testing.T) {
+	group := []byte("package store\n\nfunc (n *Norm) ListOrders() error { return nil }\n")
+	changes, err := DiffAPI([][]byte{[]byte(oldAPI), group}, []byte(oldAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].String() != "- func (*Norm).ListOrders() error" {
+		t.Errorf("Expected ListOrders to be removed, got %v", changes)
+	}
+}
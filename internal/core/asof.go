@@ -0,0 +1,211 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const asOfQuery = `
+// asOfQuery returns the query declared with !as_of reading the rows as they
+// were at asOf: clause, formatted with the time, is inserted between the
+// parts of the query. The parts are joined as they are if asOf is the zero
+// time, reading the current rows.
+func asOfQuery(asOf time.Time, clause string, parts ...string) string {
+	if asOf.IsZero() {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts, fmt.Sprintf(clause, asOf.UTC().Format("2006-01-02 15:04:05.999999")))
+}
+`
+
+var asOfQueryTmpl *template.Template
+
+// asOfClauses are the clauses reading a table at a point in time, by the
+// dialect named by !as_of. CockroachDB takes one clause for the whole query,
+// after the FROM clause, the other dialects one per system-versioned table.
+var asOfClauses = map[string]string{
+	"cockroach": " AS OF SYSTEM TIME '%s'",
+	"mariadb":   " FOR SYSTEM_TIME AS OF TIMESTAMP '%s'",
+	"sqlserver": " FOR SYSTEM_TIME AS OF '%s'",
+}
+
+// asOfEnd are the keywords ending the FROM clause of a query.
+var asOfEnd = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "WINDOW": true,
+	"ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true, "FOR": true,
+	"UNION": true, "EXCEPT": true, "INTERSECT": true,
+}
+
+// AsOfArgs returns the arguments of asOfQuery following the time: the clause
+// of the dialect and the parts of the query.
+func (c *cmdBase) AsOfArgs() string {
+	body := c.BodyString()
+	args := []string{strconv.Quote(asOfClauses[c.AsOf])}
+	prev := 0
+	for _, offset := range asOfOffsets(body, c.AsOf, c.AsOfTables) {
+		args = append(args, "`"+body[prev:offset]+"`")
+		prev = offset
+	}
+	args = append(args, "`"+body[prev:]+"`")
+	return strings.Join(args, ", ")
+}
+
+// Reuse returns the expression of whether the statement of the query can be
+// cached: queries with expanded slice inputs differ from call to call, as do
+// queries read at a point in time.
+func (c *cmdBase) Reuse() string {
+	switch {
+	case c.HasSliceInputs():
+		return "false"
+	case c.AsOf != "":
+		return "asOf.IsZero()"
+	}
+	return "true"
+}
+
+// asOfOffsets returns the offsets in body the clause of dialect is inserted
+// at. For cockroach, it is the end of the top level FROM clause. For the
+// other dialects, it is the end of the name of every table read, or only of
+// those in tables if any are listed. Names of common table expressions and
+// FROM in function calls, as in EXTRACT(x FROM y), are skipped.
+func asOfOffsets(body, dialect string, tables []string) []int {
+	toks := tokenize(body)
+	ctes := map[string]bool{}
+	for ix := 0; ix+2 < len(toks); ix++ {
+		if (toks[ix].is("WITH") || toks[ix].is("RECURSIVE") || toks[ix].Text == ",") && toks[ix+2].is("AS") {
+			ctes[strings.ToLower(toks[ix+1].name())] = true
+		}
+	}
+	end := func(t sqlToken) int {
+		return t.Pos + len(t.Text)
+	}
+	var ret []int
+	var inCall []bool
+	calls, depth := 0, 0
+	inFrom := false
+	for ix := 0; ix < len(toks); ix++ {
+		t := toks[ix]
+		if t.Kind == tokPunct {
+			switch t.Text {
+			case "(":
+				call := ix > 0 && toks[ix-1].Kind == tokIdent && !notAlias[strings.ToUpper(toks[ix-1].Text)] && !notCall[strings.ToUpper(toks[ix-1].Text)]
+				inCall = append(inCall, call)
+				if call {
+					calls++
+				}
+				depth++
+			case ")":
+				if len(inCall) > 0 {
+					if inCall[len(inCall)-1] {
+						calls--
+					}
+					inCall = inCall[:len(inCall)-1]
+				}
+				depth--
+			}
+			continue
+		}
+		if calls > 0 {
+			continue
+		}
+		if dialect == "cockroach" {
+			if depth != 0 {
+				continue
+			}
+			if inFrom && asOfEnd[strings.ToUpper(t.Text)] {
+				return []int{end(toks[ix-1])}
+			}
+			if t.is("FROM") {
+				inFrom = true
+			}
+			continue
+		}
+		if !t.is("FROM") && !t.is("JOIN") {
+			continue
+		}
+		next := ix + 1
+		for next < len(toks) {
+			if toks[next].is("ONLY") || toks[next].is("LATERAL") {
+				next++
+			}
+			name, after := qualifiedName(toks, next)
+			if name == "" || (after < len(toks) && toks[after].Text == "(") {
+				break
+			}
+			if !ctes[strings.ToLower(name)] && (len(tables) == 0 || containsString(tables, name)) {
+				ret = append(ret, end(toks[after-1]))
+			}
+			next = after
+			if next < len(toks) && toks[next].is("AS") {
+				next++
+			}
+			if next < len(toks) && toks[next].Kind != tokPunct && !notAlias[strings.ToUpper(toks[next].Text)] {
+				next++
+			}
+			if next < len(toks) && toks[next].Text == "," {
+				next++
+				continue
+			}
+			break
+		}
+	}
+	if inFrom && len(toks) > 0 {
+		last := len(toks) - 1
+		if toks[last].Text == ";" && last > 0 {
+			last--
+		}
+		return []int{end(toks[last])}
+	}
+	return ret
+}
+
+// hasAsOf reports whether any command of nf has a !as_of directive.
+func (nf *NormFile) hasAsOf() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().AsOf != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAsOf checks the !as_of directives of the commands of nf, which apply to
+// the queries reading rows with !read and !read_one. The time is passed as
+// the last parameter, asOf, and is written in the query rather than bound to
+// a placeholder, which the dialects do not all allow.
+func checkAsOf(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if c.AsOf == "" {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne:
+		default:
+			panic(fmt.Sprintf("!as_of of %s on line %d: %s commands cannot be read at a point in time", c.FuncName, c.Line, cmd.Kind()))
+		}
+		if _, ok := asOfClauses[c.AsOf]; !ok {
+			var dialects []string
+			for d := range asOfClauses {
+				dialects = append(dialects, d)
+			}
+			sort.Strings(dialects)
+			panic(fmt.Sprintf("!as_of of %s on line %d: unknown dialect %s, expected one of %s", c.FuncName, c.Line, c.AsOf, strings.Join(dialects, ", ")))
+		}
+		if c.AsOf == "cockroach" && len(c.AsOfTables) > 0 {
+			panic(fmt.Sprintf("!as_of of %s on line %d: cockroach reads all the tables at the same time, tables cannot be listed", c.FuncName, c.Line))
+		}
+		if c.HasSliceInputs() {
+			panic(fmt.Sprintf("!as_of of %s on line %d: queries with slice inputs cannot be read at a point in time", c.FuncName, c.Line))
+		}
+		if inputIndex(c.Inputs, "asOf") >= 0 {
+			panic(fmt.Sprintf("!as_of of %s on line %d: the input asOf is taken by the time of the query", c.FuncName, c.Line))
+		}
+		if len(asOfOffsets(c.BodyString(), c.AsOf, c.AsOfTables)) == 0 {
+			panic(fmt.Sprintf("!as_of of %s on line %d: no table read by the query to read at a point in time", c.FuncName, c.Line))
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// AST is the syntax of a norm file, as printed by norm ast: its directives and
+// commands as written, with their positions, for tools working on the norm
+// files themselves. Unlike the IR, it has no stability guarantee.
+type AST struct {
+	// Directives are the directives outside of the blocks of the commands.
+	Directives []ASTDirective `json:"directives"`
+	Commands   []ASTCommand   `json:"commands"`
+}
+
+// ASTDirective is a -- !name args line.
+type ASTDirective struct {
+	Name   string `json:"name"`
+	Args   string `json:"args,omitempty"`
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+}
+
+// ASTCommand is the block of a command.
+type ASTCommand struct {
+	// Command is the directive declaring the command, such as read_one.
+	Command string `json:"command"`
+	Name    string `json:"name"`
+	// Source and Line are the norm file and the line in it the command
+	// starts on.
+	Source string   `json:"source"`
+	Line   int      `json:"line"`
+	Doc    []string `json:"doc,omitempty"`
+	// Inputs and Outputs include those inferred from the query when the
+	// block declares none.
+	Inputs  []IRField `json:"inputs"`
+	Outputs []IRField `json:"outputs"`
+	Model   string    `json:"model,omitempty"`
+	// Directives are the directives of the block after its first line.
+	Directives []ASTDirective `json:"directives"`
+	SQL        string         `json:"sql"`
+}
+
+var rxASTDirective = regexp.MustCompile(`^-- !([a-z_]+)\s*(.*)$`)
+
+// blockEnd returns the index in input of the line after the block of the
+// command on input[ix], which includes its -- !end line.
+func blockEnd(input []string, ix int) int {
+	hasEnd := blockHasEnd(input, ix+2)
+	for ix++; ix < len(input); ix++ {
+		line := strings.TrimRight(input[ix], "\r")
+		if line == `-- !end` {
+			return ix + 1
+		}
+		blank := strings.TrimSpace(line) == "" || isConditional(line)
+		if rxCommand.MatchString(line) || (!hasEnd && blank) {
+			return ix
+		}
+	}
+	return ix
+}
+
+// isConditional reports whether line is an !ifdef or !endif line, which the
+// blocks of commands read as blank lines.
+func isConditional(line string) bool {
+	return strings.HasPrefix(line, `-- !ifdef`) || line == `-- !endif`
+}
+
+func (nf *NormFile) astDirective(ix int) (ASTDirective, bool) {
+	matches := rxASTDirective.FindStringSubmatch(strings.TrimRight(nf.Input[ix], "\r"))
+	if matches == nil {
+		return ASTDirective{}, false
+	}
+	source, line := nf.position(ix + 1)
+	return ASTDirective{Name: matches[1], Args: strings.TrimSpace(matches[2]), Source: source, Line: line}, true
+}
+
+// buildAST returns the syntax of nf. The blocks of commands left out by
+// !ifdef or !env are skipped.
+func buildAST(nf *NormFile) AST {
+	cmds := map[int]genAble{}
+	for _, cmd := range nf.Cmds {
+		cmds[cmd.Base().Line] = cmd
+	}
+	ast := AST{Directives: []ASTDirective{}, Commands: []ASTCommand{}}
+	for ix := 0; ix < len(nf.Input); ix++ {
+		if !rxCommand.MatchString(nf.Input[ix]) {
+			if d, ok := nf.astDirective(ix); ok {
+				ast.Directives = append(ast.Directives, d)
+			}
+			continue
+		}
+		end := blockEnd(nf.Input, ix)
+		if cmd, ok := cmds[ix+1]; ok {
+			c := cmd.Base()
+			source, line := nf.position(c.Line)
+			ac := ASTCommand{
+				Command:    cmd.Kind(),
+				Name:       c.FuncName,
+				Source:     source,
+				Line:       line,
+				Doc:        c.Doc,
+				Inputs:     irFields(c.Inputs),
+				Outputs:    irFields(c.Outputs),
+				Directives: []ASTDirective{},
+				Model:      c.Model,
+				SQL:        c.BodyString(),
+			}
+			for bx := ix + 1; bx < end; bx++ {
+				if d, ok := nf.astDirective(bx); ok && isConditional(nf.Input[bx]) {
+					ast.Directives = append(ast.Directives, d)
+				} else if ok {
+					ac.Directives = append(ac.Directives, d)
+				}
+			}
+			ast.Commands = append(ast.Commands, ac)
+		}
+		ix = end - 1
+	}
+	return ast
+}
+
+// WriteAST writes the syntax of nf as JSON.
+func WriteAST(w io.Writer, nf *NormFile) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(buildAST(nf))
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const auditLog = `
+// AuditLogTable is the table the queries declared with !audit_log write a row
+// to, created by CreateAuditLogTable.
+const AuditLogTable = "norm_audit_log"
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a copy of ctx naming actor as the one running the
+// queries, for the rows written to the audit log.
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActor returns the actor set on ctx with WithAuditActor, or "" if there
+// is none.
+func AuditActor(ctx context.Context) string {
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return actor
+}
+
+// CreateAuditLogTable creates the audit log table if it does not exist. Every
+// row records the actor, the name of the query, when it ran and the values
+// of its key inputs as a JSON object.
+func (n *Norm) CreateAuditLogTable(ctx context.Context) error {
+	_, err := n.db.ExecContext(ctx, ` + "`" + `CREATE TABLE IF NOT EXISTS norm_audit_log (
+	actor text NOT NULL,
+	query text NOT NULL,
+	logged_at timestamp NOT NULL,
+	key_values text NOT NULL
+)` + "`" + `)
+	return err
+}
+
+// writeAuditLog writes the row of a call of query to the audit log with db,
+// which runs the query in the same transaction.
+func writeAuditLog(ctx context.Context, db DBTX, query string, keys map[string]interface{}) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("%s: encoding the audit log keys: %v", query, err)
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO norm_audit_log (actor, query, logged_at, key_values) VALUES ($1, $2, $3, $4)", AuditActor(ctx), query, time.Now().UTC(), string(data))
+	if err != nil {
+		return fmt.Errorf("%s: writing the audit log: %v", query, err)
+	}
+	return nil
+}
+`
+
+var auditLogTmpl *template.Template
+
+// AuditKeys returns the map of the key inputs of the command, by name, passed
+// to writeAuditLog.
+func (c *cmdBase) AuditKeys() string {
+	var keys []string
+	for _, name := range c.AuditInputs {
+		keys = append(keys, strconv.Quote(name)+": "+c.InputExpr(name))
+	}
+	return "map[string]interface{}{" + strings.Join(keys, ", ") + "}"
+}
+
+// hasAuditLog reports whether any command of nf has a !audit_log directive.
+func (nf *NormFile) hasAuditLog() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().AuditLog {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuditLogs checks the !audit_log directives of the commands of nf, which
+// only apply to !exec commands. All inputs are recorded if none are listed,
+// except the sensitive ones, which are never written to the audit log.
+func checkAuditLogs(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if !c.AuditLog {
+			continue
+		}
+		if _, ok := cmd.(*cmdExec); !ok {
+			panic(fmt.Sprintf("!audit_log of %s on line %d: %s commands cannot be audited", c.FuncName, c.Line, cmd.Kind()))
+		}
+		if len(c.AuditInputs) == 0 {
+			for _, inp := range c.Inputs {
+				if !c.isSensitive(inp.Name) {
+					c.AuditInputs = append(c.AuditInputs, inp.Name)
+				}
+			}
+		}
+		for _, name := range c.AuditInputs {
+			if inputIndex(c.Inputs, name) < 0 {
+				panic(fmt.Sprintf("!audit_log of %s on line %d: unknown input %s", c.FuncName, c.Line, name))
+			}
+			if c.isEncrypted(name) {
+				panic(fmt.Sprintf("!audit_log of %s on line %d: input %s is encrypted", c.FuncName, c.Line, name))
+			}
+			if c.isSensitive(name) {
+				panic(fmt.Sprintf("!audit_log of %s on line %d: input %s is sensitive", c.FuncName, c.Line, name))
+			}
+		}
+	}
+}
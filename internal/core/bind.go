@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const bind = `
+// Bind{{.FuncName}} returns {{.FuncName}} with {{.BoundNames}} fixed, for calling
+// it repeatedly with the same {{if eq (len .Bound) 1}}value{{else}}values{{end}}.
+{{.DeprecatedDoc true -}}
+func (n *Norm) Bind{{.FuncName}}({{getFuncSig .Bound}}) func(ctx context.Context{{if .Free}}, {{getFuncSig .Free}}{{end}}) {{.Results}} {
+	return func(ctx context.Context{{if .Free}}, {{getFuncSig .Free}}{{end}}) {{.Results}} {
+		return n.{{.FuncName}}(ctx, {{getCallSig .Params}})
+	}
+}
+`
+
+var bindTmpl *template.Template
+
+// bindCmd is a command with a !bind directive.
+type bindCmd struct {
+	*cmdBase
+	// Results are the results of the function of the command.
+	Results string
+}
+
+// Bound returns the parameters fixed by the Bind function.
+func (c bindCmd) Bound() []arg {
+	var ret []arg
+	for _, p := range c.Params() {
+		if containsString(c.Bind, p.Name) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// Free returns the parameters of the function returned by Bind.
+func (c bindCmd) Free() []arg {
+	var ret []arg
+	for _, p := range c.Params() {
+		if !containsString(c.Bind, p.Name) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+func (c bindCmd) BoundNames() string {
+	return strings.Join(argNames(c.Bound()), " and ")
+}
+
+// bindResults returns the results of the function generated for cmd, or ""
+// if it cannot be bound.
+func bindResults(cmd genAble) string {
+	switch c := cmd.(type) {
+	case *cmdReadOne:
+		return "(*" + c.ResultType() + ", error)"
+	case *cmdExecReturning:
+		return "(*" + c.ResultType() + ", error)"
+	case *cmdRead:
+		return "([]" + c.ResultType() + ", error)"
+	case *cmdExec:
+		switch c.Returns {
+		case "result":
+			return "(sql.Result, error)"
+		case "rows_affected":
+			return "(int64, error)"
+		}
+		return "error"
+	}
+	return ""
+}
+
+// checkBinds checks that the commands of nf with a !bind directive can be
+// bound, and that the bound parameters exist.
+func checkBinds(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if len(c.Bind) == 0 {
+			continue
+		}
+		if bindResults(cmd) == "" {
+			panic(fmt.Sprintf("!bind of %s on line %d: %s commands cannot be bound", c.FuncName, c.Line, cmd.Kind()))
+		}
+		for _, name := range c.Bind {
+			if inputIndex(c.Params(), name) < 0 {
+				panic(fmt.Sprintf("!bind of %s on line %d: unknown input %s", c.FuncName, c.Line, name))
+			}
+		}
+	}
+}
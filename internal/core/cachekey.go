@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const cacheKeyFunc = `
+// cacheKey returns the cache key of a call of query with inputs, the name of
+// the query followed by the inputs as a JSON object. The keys of the object
+// are sorted, so the key does not depend on the order of the parameters.
+func cacheKey(query string, inputs map[string]interface{}) string {
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		// Inputs JSON cannot encode are written in Go syntax, which also
+		// sorts the keys.
+		return fmt.Sprintf("%s%#v", query, inputs)
+	}
+	return query + string(data)
+}
+`
+
+var cacheKeyFuncTmpl *template.Template
+
+const cacheKey = `
+// {{.FuncName}}CacheKey returns a stable key of a call of {{.FuncName}} with
+// these parameters, for caches and request coalescers, see cacheKey.
+func {{.FuncName}}CacheKey({{getFuncSig .Params}}) string {
+{{- range .CacheKeyLocals}}
+	{{.}}
+{{- end}}
+	return cacheKey("{{.FuncName}}", {{.CacheKeyInputs}})
+}
+`
+
+var cacheKeyTmpl *template.Template
+
+// CacheKeyInputs returns the map of the inputs of the command by name passed
+// to cacheKey, including the time of !as_of, or the map of the fields of its
+// !cache_key directives.
+func (c *cmdBase) CacheKeyInputs() string {
+	var inputs []string
+	if len(c.CacheKeyFields) > 0 {
+		for _, f := range c.CacheKeyFields {
+			inputs = append(inputs, strconv.Quote(f.Name)+": "+f.Expr)
+		}
+		return "map[string]interface{}{" + strings.Join(inputs, ", ") + "}"
+	}
+	for _, inp := range c.Inputs {
+		inputs = append(inputs, strconv.Quote(inp.Name)+": "+c.InputExpr(inp.Name))
+	}
+	if c.AsOf != "" {
+		inputs = append(inputs, `"asOf": asOf`)
+	}
+	return "map[string]interface{}{" + strings.Join(inputs, ", ") + "}"
+}
+
+// CacheKeyLocals returns the declarations of the inputs grouped by !key that
+// the !cache_key expressions of the command use, which refer to them by name.
+func (c *cmdBase) CacheKeyLocals() []string {
+	var ret []string
+	for _, inp := range c.Inputs {
+		if !c.isKeyInput(inp.Name) {
+			continue
+		}
+		for _, f := range c.CacheKeyFields {
+			if containsString(exprIdents(f.Expr), inp.Name) {
+				ret = append(ret, inp.Name+" := "+c.InputExpr(inp.Name))
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// exprIdents returns the names the Go expression expr refers to, leaving out
+// the selected names, like ToLower in strings.ToLower, or nil if expr does not
+// parse.
+func exprIdents(expr string) []string {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil
+	}
+	var ret []string
+	ast.Inspect(e, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, func(node ast.Node) bool {
+				if id, ok := node.(*ast.Ident); ok {
+					ret = append(ret, id.Name)
+				}
+				return true
+			})
+			return false
+		case *ast.Ident:
+			ret = append(ret, n.Name)
+		}
+		return true
+	})
+	return ret
+}
+
+// checkCacheKeyFields checks the !cache_key directives of c, whose fields
+// default to the input of their name. Their expressions may not use the
+// inputs read from the context, which CacheKey does not take, nor sensitive
+// ones.
+func checkCacheKeyFields(c *cmdBase) {
+	for ix, f := range c.CacheKeyFields {
+		if f.Expr == "" {
+			if inputIndex(c.Inputs, f.Name) < 0 {
+				panic(fmt.Sprintf("!cache_key of %s on line %d: no input %s, give the expression of the field", c.FuncName, c.Line, f.Name))
+			}
+			c.CacheKeyFields[ix].Expr = f.Name
+		} else if _, err := parser.ParseExpr(f.Expr); err != nil {
+			panic(fmt.Sprintf("!cache_key of %s on line %d: %s is not a Go expression: %v", c.FuncName, c.Line, f.Expr, err))
+		}
+		for _, name := range exprIdents(c.CacheKeyFields[ix].Expr) {
+			switch {
+			case inputIndex(c.Inputs, name) < 0:
+			case c.isContextInput(name):
+				panic(fmt.Sprintf("!cache_key of %s on line %d: input %s is read from the context", c.FuncName, c.Line, name))
+			case c.isSensitive(name):
+				panic(fmt.Sprintf("!cache_key of %s on line %d: input %s is sensitive", c.FuncName, c.Line, name))
+			}
+		}
+	}
+}
+
+// setCacheKeys marks the !read and !read_one commands of nf to get a
+// CacheKey function, when the file has a !cache_keys directive. Commands
+// with sensitive inputs, which include the encrypted ones, get none, as the
+// key would hold them in plaintext, and neither do those with !input_ctx
+// inputs, which the key could not tell apart, unless their !cache_key
+// directives leave those inputs out.
+func setCacheKeys(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		_, read := cmd.(*cmdRead)
+		_, readOne := cmd.(*cmdReadOne)
+		if len(c.CacheKeyFields) > 0 {
+			if !nf.CacheKeys {
+				panic(fmt.Sprintf("!cache_key of %s on line %d: needs a !cache_keys line before the commands", c.FuncName, c.Line))
+			}
+			if !read && !readOne {
+				panic(fmt.Sprintf("!cache_key of %s on line %d: %s commands have no cache key", c.FuncName, c.Line, cmd.Kind()))
+			}
+			checkCacheKeyFields(c)
+			c.CacheKey = true
+			continue
+		}
+		if nf.CacheKeys && (read || readOne) {
+			c.CacheKey = len(c.Sensitive) == 0 && len(c.ContextInputs) == 0
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCacheKeyFields(t *testing.T) {
+	input := `-- !norm
+-- !package store
+-- !import "strings"
+-- !cache_keys
+
+-- !read_one FindUser
+-- !input email string
+-- !input traceID string
+-- !output ID int
+-- !cache_key email strings.ToLower(email)
+SELECT id FROM users WHERE lower(email) = lower($1) AND $2 IS NOT NULL
+
+-- !read ListUsers
+-- !input tenant string
+-- !input password string
+-- !output ID int
+-- !key UserKey tenant password
+-- !sensitive password
+-- !cache_key tenant
+-- !cache_key version 2
+SELECT id FROM users WHERE tenant = $1 AND password = $2
+
+-- !read_one CountUsers
+-- !input tenant string
+-- !output Count int
+SELECT count(*) FROM users WHERE tenant = $1
+`
+	nf := ParseData([]byte(input), []Source{{"<input>", 0}}, ParseOptions{})
+	tests := []struct {
+		inputs string
+		locals []string
+	}{
+		{`map[string]interface{}{"email": strings.ToLower(email)}`, nil},
+		{`map[string]interface{}{"tenant": tenant, "version": 2}`, []string{"tenant := key.Tenant"}},
+		{`map[string]interface{}{"tenant": tenant}`, nil},
+	}
+	for ix, test := range tests {
+		c := nf.Cmds[ix].Base()
+		if !c.CacheKey {
+			t.Errorf("Expected a CacheKey function for %s", c.FuncName)
+		}
+		if got := c.CacheKeyInputs(); got != test.inputs {
+			t.Errorf("Expected the key inputs %s for %s, got %s", test.inputs, c.FuncName, got)
+		}
+		if got := c.CacheKeyLocals(); !reflect.DeepEqual(got, test.locals) {
+			t.Errorf("Expected the locals %v for %s, got %v", test.locals, c.FuncName, got)
+		}
+	}
+}
+
+func TestCacheKeyErrors(t *testing.T) {
+	header := "-- !norm\n-- !package store\n-- !cache_keys\n\n-- !read_one FindUser\n-- !input email string\n-- !input password string\n-- !output ID int\n-- !sensitive password\n"
+	body := "SELECT id FROM users WHERE email = $1 AND password = $2\n"
+	tests := []struct {
+		directives string
+		err        string
+	}{
+		{"-- !cache_key name\n", "<input>:5: !cache_key of FindUser: no input name, give the expression of the field"},
+		{"-- !cache_key email strings.ToLower(\n", "<input>:5: !cache_key of FindUser: strings.ToLower( is not a Go expression"},
+		{"-- !cache_key login email + password\n", "<input>:5: !cache_key of FindUser: input password is sensitive"},
+		{"-- !cache_key 1email\n", "<input>:10:15: Format error: \"-- !cache_key 1email\""},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected the error %q for %q, got %v", test.err, test.directives, err)
+				}
+			}()
+			ParseData([]byte(header+test.directives+body), []Source{{"<input>", 0}}, ParseOptions{})
+		}()
+	}
+
+	for input, want := range map[string]string{
+		"-- !norm\n-- !package store\n\n-- !read_one FindUser\n-- !input email string\n-- !output ID int\n-- !cache_key email\nSELECT id FROM users WHERE email = $1\n": "<input>:4: !cache_key of FindUser: needs a !cache_keys line before the commands",
+		"-- !norm\n-- !package store\n-- !cache_keys\n\n-- !exec AddUser\n-- !input email string\n-- !cache_key email\nINSERT INTO users (email) VALUES ($1)\n":         "<input>:5: !cache_key of AddUser: exec commands have no cache key",
+	} {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected the error %q, got %v", want, err)
+				}
+			}()
+			ParseData([]byte(input), []Source{{"<input>", 0}}, ParseOptions{})
+		}()
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"io"
+	"text/template"
+)
+
+const catalog = `# Queries
+{{range .}}
+## {{.FuncName}}
+
+Command: ` + "`{{.Kind}}`" + `
+{{if .Doc}}
+{{range .Doc}}{{.}}
+{{end}}{{end}}{{if .Inputs}}
+| Input | Type |
+| ----- | ---- |
+{{range .Inputs}}| {{.Name}} | ` + "`{{.Typ}}`" + ` |
+{{end}}{{end}}{{if .Outputs}}
+| Output | Type |
+| ------ | ---- |
+{{range .Outputs}}| {{.Name}} | ` + "`{{.Typ}}`" + ` |
+{{end}}{{end}}{{if .Model}}
+Model: ` + "`{{.Model}}`" + `
+{{end}}
+Generated: {{range $ix, $f := .Funcs}}{{if $ix}}, {{end}}` + "`{{$f}}`" + `{{end}}
+
+` + "```sql" + `
+{{.BodyString}}
+` + "```" + `
+{{end}}`
+
+var catalogTmpl = template.Must(template.New("catalog").Parse(catalog))
+
+type catalogEntry struct {
+	*cmdBase
+	Kind  string
+	Funcs []string
+}
+
+// WriteCatalog writes a Markdown catalog of the commands in nf to w, so that
+// the data access of a package can be reviewed without reading generated code.
+func WriteCatalog(w io.Writer, nf *NormFile) error {
+	var entries []catalogEntry
+	for _, cmd := range nf.Cmds {
+		entries = append(entries, catalogEntry{cmd.Base(), cmd.Kind(), cmd.Funcs()})
+	}
+	return catalogTmpl.Execute(w, entries)
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// checkGolden compares got with the content of the golden file, which go
+// test -update rewrites with got.
+func checkGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected %s:\n%s\ngot:\n%s", golden, want, got)
+	}
+}
+
+// parseTestFile parses the norm file name of testdata.
+func parseTestFile(t *testing.T, name string) *NormFile {
+	t.Helper()
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseData(data, []Source{{name, 0}}, ParseOptions{})
+}
+
+func TestWriteCatalog(t *testing.T) {
+	nf := parseTestFile(t, "testdata/doc/catalog.norm.sql")
+	var bb bytes.Buffer
+	if err := WriteCatalog(&bb, nf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/doc/catalog.md", bb.Bytes())
+}
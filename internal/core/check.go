@@ -0,0 +1,110 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CheckFiles compares the generated files, by name, with the files on disk,
+// writing a diff to w for every file that is missing or differs. The date of
+// the "Generated on" line of the header is ignored. It reports whether all the
+// files are up to date.
+func CheckFiles(w io.Writer, files map[string][]byte) (bool, error) {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	upToDate := true
+	for _, name := range names {
+		old, err := ioutil.ReadFile(name)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(w, "%s: not generated\n", name)
+			upToDate = false
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		oldLines, newLines := checkedLines(old), checkedLines(files[name])
+		if strings.Join(oldLines, "\n") == strings.Join(newLines, "\n") {
+			continue
+		}
+		upToDate = false
+		fmt.Fprintf(w, "--- %s\n+++ %s (generated)\n", name, name)
+		writeLineDiff(w, oldLines, newLines)
+	}
+	return upToDate, nil
+}
+
+// checkedLines returns the lines of the file src compared by CheckFiles,
+// without the date of the header.
+func checkedLines(src []byte) []string {
+	lines := strings.Split(string(bytes.TrimRight(src, "\n")), "\n")
+	for ix, line := range lines {
+		if strings.HasPrefix(line, "// Generated on: ") {
+			lines[ix] = "// Generated on: <date>"
+		}
+	}
+	return lines
+}
+
+// writeLineDiff writes the lines removed from a and added in b to w,
+// prefixed with - and +, along with the line number in a of every change. The
+// lines common to both are found as their longest common subsequence, after
+// leaving out the common prefix and suffix.
+func writeLineDiff(w io.Writer, a, b []string) {
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && a[endA-1] == b[endB-1] {
+		endA--
+		endB--
+	}
+	a, b = a[start:endA], b[start:endB]
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	inChange := false
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+			inChange = false
+			continue
+		case !inChange:
+			fmt.Fprintf(w, "@@ line %d\n", start+i+1)
+			inChange = true
+		}
+		if j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]) {
+			fmt.Fprintf(w, "-%s\n", a[i])
+			i++
+		} else {
+			fmt.Fprintf(w, "+%s\n", b[j])
+			j++
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	current := filepath.Join(dir, "current.go")
+	stale := filepath.Join(dir, "stale.go")
+	missing := filepath.Join(dir, "missing.go")
+	onDisk := map[string]string{
+		current: "// Code generated by norm. DO NOT EDIT.\n// Generated on: 2020-01-02\npackage store\n",
+		stale:   "package store\n\nfunc a() {}\nfunc b() {}\nfunc c() {}\n",
+	}
+	for name, data := range onDisk {
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		ok    bool
+		diff  string
+	}{
+		{
+			// The date of the header is not compared.
+			name:  "current",
+			files: map[string][]byte{current: []byte("// Code generated by norm. DO NOT EDIT.\n// Generated on: 2024-05-06\npackage store\n")},
+			ok:    true,
+		},
+		{
+			name:  "stale",
+			files: map[string][]byte{stale: []byte("package store\n\nfunc a() {}\nfunc B() {}\nfunc c() {}\nfunc d() {}\n")},
+			diff:  "--- " + stale + "\n+++ " + stale + " (generated)\n@@ line 4\n-func b() {}\n+func B() {}\n@@ line 6\n+func d() {}\n",
+		},
+		{
+			name:  "missing",
+			files: map[string][]byte{missing: []byte("package store\n")},
+			diff:  missing + ": not generated\n",
+		},
+	}
+	for _, test := range tests {
+		var bb bytes.Buffer
+		ok, err := CheckFiles(&bb, test.files)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if ok != test.ok {
+			t.Errorf("%s: expected up to date %v, got %v", test.name, test.ok, ok)
+		}
+		if bb.String() != test.diff {
+			t.Errorf("%s: expected the diff:\n%s\ngot:\n%s", test.name, test.diff, bb.String())
+		}
+	}
+}
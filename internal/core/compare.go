@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"text/template"
+)
+
+const compareRows = `
+// Diff is the difference between the rows a query reads from two databases,
+// see the Compare functions. Rows are compared by value, in any order.
+type Diff struct {
+	// Missing are the rows only read from the first database, and Extra the
+	// rows only read from the other one.
+	Missing []interface{}
+	Extra   []interface{}
+}
+
+// Equal reports whether both databases returned the same rows.
+func (d Diff) Equal() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0
+}
+
+// rowKey returns the value rows are compared by in a Diff, their JSON
+// encoding, which compares pointers by the values they point to.
+func rowKey(row interface{}) string {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return fmt.Sprintf("%#v", row)
+	}
+	return string(data)
+}
+
+// diffRows returns the Diff of the rows read from two databases. A row read
+// several times from one database must be read as many times from the other.
+func diffRows(rows, otherRows []interface{}) Diff {
+	pending := map[string]int{}
+	for _, row := range otherRows {
+		pending[rowKey(row)]++
+	}
+	var d Diff
+	for _, row := range rows {
+		key := rowKey(row)
+		if pending[key] > 0 {
+			pending[key]--
+			continue
+		}
+		d.Missing = append(d.Missing, row)
+	}
+	for _, row := range otherRows {
+		key := rowKey(row)
+		if pending[key] > 0 {
+			pending[key]--
+			d.Extra = append(d.Extra, row)
+		}
+	}
+	return d
+}
+`
+
+var compareRowsTmpl *template.Template
+
+const compare = `
+// Compare{{.FuncName}} runs {{.FuncName}} against n and other, such as the
+// databases before and after a migration, and returns the rows that differ.
+func (n *Norm) Compare{{.FuncName}}(ctx context.Context, other *Norm{{if .Params}}, {{end}}{{getFuncSig .Params}}) (Diff, error) {
+	var rows, otherRows []interface{}
+{{- if .One}}
+	row, err := n.{{.FuncName}}(ctx{{if .Params}}, {{end}}{{getCallSig .Params}})
+	if err != nil && err != sql.ErrNoRows {
+		return Diff{}, err
+	}
+	if err == nil {
+		rows = append(rows, *row)
+	}
+	otherRow, err := other.{{.FuncName}}(ctx{{if .Params}}, {{end}}{{getCallSig .Params}})
+	if err != nil && err != sql.ErrNoRows {
+		return Diff{}, fmt.Errorf("other database: %v", err)
+	}
+	if err == nil {
+		otherRows = append(otherRows, *otherRow)
+	}
+{{- else}}
+	res, err := n.{{.FuncName}}(ctx{{if .Params}}, {{end}}{{getCallSig .Params}})
+	if err != nil {
+		return Diff{}, err
+	}
+	for _, row := range res {
+		rows = append(rows, row)
+	}
+	otherRes, err := other.{{.FuncName}}(ctx{{if .Params}}, {{end}}{{getCallSig .Params}})
+	if err != nil {
+		return Diff{}, fmt.Errorf("other database: %v", err)
+	}
+	for _, row := range otherRes {
+		otherRows = append(otherRows, row)
+	}
+{{- end}}
+	return diffRows(rows, otherRows), nil
+}
+`
+
+var compareTmpl *template.Template
+
+// compareCmd is a command with a !compare directive.
+type compareCmd struct {
+	*cmdBase
+	// One is set for !read_one commands, which read one row or none.
+	One bool
+}
+
+// hasCompare reports whether any command of nf has a !compare directive.
+func (nf *NormFile) hasCompare() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().Compare {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCompares checks that the commands with a !compare directive read rows.
+func checkCompares(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if !c.Compare {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne:
+		default:
+			panic(fmt.Sprintf("!compare of %s on line %d: %s commands read no rows to compare", c.FuncName, c.Line, cmd.Kind()))
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const connect = `
+// connSetup holds the statements run on every new connection opened by Open.
+var connSetup = []string{
+{{range .ConnSetup}}	{{printf "%q" .}},
+{{end}}}
+{{if .ApplicationName}}
+// ApplicationName identifies the connections opened by Open to the database
+// server. It is set as application_name for the postgres and pgx drivers, and
+// as the program_name connection attribute for the mysql driver.
+var ApplicationName = {{printf "%q" .ApplicationName}}
+{{end}}
+// OnConnect, if set, is called for every new connection opened by Open, after
+// the statements in connSetup. Returning an error discards the connection.
+var OnConnect func(ctx context.Context, conn driver.Conn) error
+
+// Open opens a database like sql.Open, but runs the connection setup
+// statements of the norm file and OnConnect on every new connection of the
+// pool.
+func Open(driverName, dataSourceName string) (*sql.DB, error) {
+{{- if .ApplicationName}}
+	if driverName == "mysql" && ApplicationName != "" {
+		dataSourceName = mysqlProgramName(dataSourceName, ApplicationName)
+	}
+{{- end}}
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	var connector driver.Connector = dsnConnector{drv, dataSourceName}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dataSourceName); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(setupConnector{connector{{if .ApplicationName}}, driverName == "postgres" || driverName == "pgx"{{end}}}), nil
+}
+{{if .ApplicationName}}
+// mysqlProgramName adds the program_name connection attribute to a mysql DSN.
+func mysqlProgramName(dsn, name string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "connectionAttributes=program_name:" + name
+}
+{{end}}
+// dsnConnector is the connector of drivers not implementing
+// driver.DriverContext.
+type dsnConnector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// setupConnector runs connSetup and OnConnect on the new connections of the
+// wrapped connector.
+type setupConnector struct {
+	driver.Connector
+{{- if .ApplicationName}}
+	// postgres is set if ApplicationName is set with SET application_name.
+	postgres bool
+{{- end}}
+}
+
+func (c setupConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+{{- if .ApplicationName}}
+	if c.postgres && ApplicationName != "" {
+		query := "SET application_name = '" + strings.Replace(ApplicationName, "'", "''", -1) + "'"
+		if err := execConn(ctx, conn, query); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+{{- end}}
+	for _, query := range connSetup {
+		if err := execConn(ctx, conn, query); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if OnConnect != nil {
+		if err := OnConnect(ctx, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if execer, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = execer.ExecContext(ctx, nil)
+	} else {
+		_, err = stmt.Exec(nil)
+	}
+	return err
+}
+`
+
+var connectTmpl *template.Template
+
+// needsConnect reports whether nf has statements to run on every new
+// connection, or asks for the OnConnect hook, in which case an Open function
+// is generated.
+func (nf *NormFile) needsConnect() bool {
+	return len(nf.ConnSetup) > 0 || nf.OnConnect || nf.ApplicationName != ""
+}
+
+// attachStatement returns the statement attaching the sqlite database at path
+// as schema.
+func attachStatement(schema, path string) string {
+	return fmt.Sprintf("ATTACH DATABASE '%s' AS %s", strings.Replace(path, "'", "''", -1), schema)
+}
+
+// checkAttached warns about queries using a schema that is not attached, in
+// files attaching sqlite databases.
+func checkAttached(nf *NormFile) []Warning {
+	if len(nf.Attached) == 0 {
+		return nil
+	}
+	schemas := map[string]bool{"main": true, "temp": true}
+	for _, name := range nf.Attached {
+		schemas[strings.ToLower(name)] = true
+	}
+	var ret []Warning
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		reads, writes := tableRefs(c.BodyString())
+		seen := map[string]bool{}
+		for _, name := range append(reads, writes...) {
+			dot := strings.Index(name, ".")
+			if dot < 0 || schemas[strings.ToLower(name[:dot])] || seen[name] {
+				continue
+			}
+			seen[name] = true
+			ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: table %s is in schema %s, which is not attached", c.FuncName, name, name[:dot])})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+const copyFrom = `
+{{if not .Model}}
+// {{.FuncName}}Row holds the values of one row copied by {{.FuncName}}.
+type {{.FuncName}}Row struct {
+{{.RowFields}}
+}
+{{end}}
+{{if .Deprecated}}
+var deprecated{{.FuncName}}Once sync.Once
+{{end}}
+{{- template "semaphore" .}}
+{{range .Doc}}// {{print .}}
+{{end -}}
+{{.DeprecatedDoc (gt (len .Doc) 0) -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context, rows []{{.RowType}}, opts ...Option) error {
+	return n.{{.FuncName}}From(ctx, func() ({{.RowType}}, bool, error) {
+		if len(rows) == 0 {
+			return {{.RowType}}{}, false, nil
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, true, nil
+	}, opts...)
+}
+
+// {{.FuncName}}From is like {{.FuncName}}, but reads the rows from next until it
+// returns false or an error.
+{{.DeprecatedDoc true -}}
+func (n *Norm) {{.FuncName}}From(ctx context.Context, next func() ({{.RowType}}, bool, error), opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "{{.FuncName}}"}, func(ctx context.Context) error {
+			return n.bare().{{.FuncName}}From(ctx, next, opts...)
+		})
+	}
+	call := applyOptions(opts)
+{{- template "timeout"}}
+{{- if .Deprecated}}
+	reportDeprecatedUse(&deprecated{{.FuncName}}Once, "{{.FuncName}}", {{printf "%q" .Deprecated}})
+{{- end}}
+{{- template "count" .}}
+{{- template "limit" .}}
+{{- template "acquire" .}}
+{{- template "begin"}}
+	stmt, err := db.PrepareContext(ctx, {{printf "%q" .CopyStatement}})
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for {
+		row, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if _, err := stmt.ExecContext(ctx{{.RowArgs "row"}}); err != nil {
+			return err
+		}
+	}
+	// Flushes the buffered rows.
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+`
+
+var copyTmpl *template.Template
+
+// cmdCopy bulk loads rows into a postgres table with COPY FROM STDIN, through
+// the COPY support of the lib/pq driver. The body is the name of the table,
+// and the inputs name its columns.
+type cmdCopy struct {
+	cmdExecMany
+}
+
+func (c *cmdCopy) gen(w io.Writer) error {
+	return copyTmpl.Execute(w, c)
+}
+
+func (c *cmdCopy) Kind() string {
+	return "copy"
+}
+
+func (c *cmdCopy) Funcs() []string {
+	return append(c.cmdExecMany.Funcs(), c.FuncName+"From")
+}
+
+// CopyStatement returns the COPY statement, quoting the names like pq.CopyIn.
+func (c *cmdCopy) CopyStatement() string {
+	var names []string
+	for _, part := range strings.Split(strings.TrimSpace(c.BodyString()), ".") {
+		names = append(names, quoteIdentifier(part))
+	}
+	var cols []string
+	for _, inp := range c.Inputs {
+		cols = append(cols, quoteIdentifier(inp.Name))
+	}
+	return "COPY " + strings.Join(names, ".") + " (" + strings.Join(cols, ", ") + ") FROM STDIN"
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
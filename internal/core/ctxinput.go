@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"text/template"
+)
+
+const contextKeys = `
+// ErrNoContextValue is returned by the queries with an !input_ctx when their
+// context does not carry the value of the input.
+var ErrNoContextValue = errors.New("norm: no value in the context")
+{{range .}}
+// {{.Name}}ContextKey is the key of the {{.Name}} value of contexts.
+type {{.Name}}ContextKey struct{}
+
+// With{{.Field}} returns a copy of ctx carrying {{.Name}}, which the queries
+// with an !input_ctx of key {{.Name}} read instead of taking it as a
+// parameter.
+func With{{.Field}}(ctx context.Context, {{.Name}} {{.Typ}}) context.Context {
+	return context.WithValue(ctx, {{.Name}}ContextKey{}, {{.Name}})
+}
+
+// {{.Field}}FromContext returns the {{.Name}} value carried by ctx, and whether
+// it carries one.
+func {{.Field}}FromContext(ctx context.Context) ({{.Typ}}, bool) {
+	v, ok := ctx.Value({{.Name}}ContextKey{}).({{.Typ}})
+	return v, ok
+}
+{{end}}`
+
+var contextKeysTmpl *template.Template
+
+// contextInputs is the start of the generated functions of commands with
+// !input_ctx inputs, reading them from the context.
+const contextInputs = `
+{{- define "contextInputs"}}
+{{- range .ContextInputs}}
+	{{.Name}}, ok := {{.Field}}FromContext(ctx)
+	if !ok {
+		err := fmt.Errorf("{{$.FuncName}}: %w: {{.Key}}", ErrNoContextValue)
+		return {{$.ErrReturn}}
+	}
+{{- end}}
+{{- end}}`
+
+// contextInput is an input of a command read from the context, see
+// !input_ctx.
+type contextInput = IRContextInput
+
+// Field returns the name of the key in the names of the generated functions.
+func (ci contextInput) Field() string {
+	return FieldName(ci.Key)
+}
+
+// contextKey is the key of a value of contexts read by !input_ctx inputs.
+type contextKey struct {
+	Name string
+	Typ  string
+}
+
+// Field returns the name of the key in the names of the generated functions.
+func (k contextKey) Field() string {
+	return FieldName(k.Name)
+}
+
+// isContextInput reports whether the input named name of c is read from the
+// context.
+func (c *cmdBase) isContextInput(name string) bool {
+	for _, ci := range c.ContextInputs {
+		if ci.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveContextKeys checks the !input_ctx inputs of the commands of nf and
+// returns the keys to generate. Commands reading the same key must read a
+// value of the same type.
+func resolveContextKeys(nf *NormFile) []contextKey {
+	var ret []contextKey
+	byName := map[string]int{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if len(c.ContextInputs) == 0 {
+			continue
+		}
+		if TakesRows(cmd) {
+			panic(fmt.Sprintf("!input_ctx of %s on line %d: %s commands take rows instead", c.FuncName, c.Line, cmd.Kind()))
+		}
+		for _, ci := range c.ContextInputs {
+			typ := c.Inputs[inputIndex(c.Inputs, ci.Name)].Typ
+			if isSlice(typ) {
+				panic(fmt.Sprintf("!input_ctx of %s on line %d: input %s cannot be a slice", c.FuncName, c.Line, ci.Name))
+			}
+			if ix, ok := byName[ci.Key]; ok {
+				if ret[ix].Typ != typ {
+					panic(fmt.Sprintf("!input_ctx of %s on line %d: key %s holds a %s in another query", c.FuncName, c.Line, ci.Key, ret[ix].Typ))
+				}
+				continue
+			}
+			byName[ci.Key] = len(ret)
+			ret = append(ret, contextKey{ci.Key, typ})
+		}
+	}
+	return ret
+}
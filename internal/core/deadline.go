@@ -0,0 +1,66 @@
+package core
+
+import "text/template"
+
+const resultDeadline = `
+// ErrRowTimeout is returned by the Err method of a result that was not
+// advanced within its row timeout, see SetRowTimeout.
+var ErrRowTimeout = errors.New("norm: row timeout exceeded")
+
+// resultDeadline cancels the query of a result when Next is not called within
+// the row timeout. It also holds the slot of queries with a !max_concurrency
+// limit until the result is closed.
+type resultDeadline struct {
+	cancel   context.CancelFunc
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut int32
+	sem      chan struct{}
+	released int32
+}
+
+func (d *resultDeadline) next() {
+	if d.timeout <= 0 {
+		return
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.timeout, func() {
+			atomic.StoreInt32(&d.timedOut, 1)
+			d.cancel()
+		})
+		return
+	}
+	d.timer.Reset(d.timeout)
+}
+
+func (d *resultDeadline) stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.cancel()
+	if d.sem != nil && atomic.CompareAndSwapInt32(&d.released, 0, 1) {
+		<-d.sem
+	}
+}
+
+func (d *resultDeadline) err(err error) error {
+	if atomic.LoadInt32(&d.timedOut) == 1 {
+		return ErrRowTimeout
+	}
+	return err
+}
+`
+
+var resultDeadlineTmpl *template.Template
+
+// hasResults reports whether nf has commands generating a streaming Result
+// type.
+func (nf *NormFile) hasResults() bool {
+	for _, cmd := range nf.Cmds {
+		switch cmd.(type) {
+		case *cmdRead, *cmdView:
+			return true
+		}
+	}
+	return false
+}
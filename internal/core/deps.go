@@ -0,0 +1,161 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// queryDeps lists the tables a command reads from and writes to.
+type queryDeps struct {
+	Name      string   `json:"name"`
+	Command   string   `json:"command"`
+	Functions []string `json:"functions"`
+	Reads     []string `json:"reads"`
+	Writes    []string `json:"writes"`
+}
+
+var notAlias = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "LIMIT": true, "OFFSET": true,
+	"HAVING": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"FULL": true, "CROSS": true, "OUTER": true, "NATURAL": true, "ON": true,
+	"USING": true, "UNION": true, "EXCEPT": true, "INTERSECT": true,
+	"WINDOW": true, "RETURNING": true, "SET": true, "VALUES": true, "FOR": true,
+	"LATERAL": true, "SELECT": true, "DEFAULT": true, "FETCH": true,
+}
+
+// notCall are the keywords that may be followed by a parenthesis without it
+// being a function call.
+var notCall = map[string]bool{
+	"FROM": true, "AND": true, "OR": true, "NOT": true, "IN": true,
+	"EXISTS": true, "AS": true, "ANY": true, "ALL": true, "SOME": true,
+	"WITH": true, "RECURSIVE": true, "THEN": true, "ELSE": true, "WHEN": true,
+	"CASE": true, "IS": true, "BY": true, "INTO": true,
+}
+
+// tableRefs returns the tables read and written by the statements in sql.
+// Names of common table expressions are not reported.
+func tableRefs(sql string) (reads []string, writes []string) {
+	toks := tokenize(sql)
+	ctes := map[string]bool{}
+	for ix := 0; ix+2 < len(toks); ix++ {
+		if (toks[ix].is("WITH") || toks[ix].is("RECURSIVE") || toks[ix].Text == ",") && toks[ix+2].is("AS") {
+			ctes[strings.ToLower(toks[ix+1].name())] = true
+		}
+	}
+	readSet := map[string]bool{}
+	writeSet := map[string]bool{}
+	add := func(set map[string]bool, name string) {
+		if name != "" && !ctes[strings.ToLower(name)] {
+			set[name] = true
+		}
+	}
+
+	// inCall tracks, for every open parenthesis, whether it belongs to a
+	// function call, where FROM is not a table reference (EXTRACT(x FROM y)).
+	var inCall []bool
+	calls := 0
+	for ix := 0; ix < len(toks); ix++ {
+		t := toks[ix]
+		if t.Kind == tokPunct {
+			switch t.Text {
+			case "(":
+				call := ix > 0 && toks[ix-1].Kind == tokIdent && !notAlias[strings.ToUpper(toks[ix-1].Text)] && !notCall[strings.ToUpper(toks[ix-1].Text)]
+				inCall = append(inCall, call)
+				if call {
+					calls++
+				}
+			case ")":
+				if len(inCall) > 0 {
+					if inCall[len(inCall)-1] {
+						calls--
+					}
+					inCall = inCall[:len(inCall)-1]
+				}
+			}
+			continue
+		}
+		if calls > 0 {
+			continue
+		}
+		switch {
+		case t.is("INTO") && ix > 0 && (toks[ix-1].is("INSERT") || toks[ix-1].is("REPLACE") || toks[ix-1].is("MERGE") || toks[ix-1].is("OR") || toks[ix-1].is("IGNORE")):
+			name, _ := qualifiedName(toks, ix+1)
+			add(writeSet, name)
+		case t.is("UPDATE") && !(ix > 0 && (toks[ix-1].is("FOR") || toks[ix-1].is("DO") || toks[ix-1].is("ON"))):
+			next := ix + 1
+			if next < len(toks) && toks[next].is("ONLY") {
+				next++
+			}
+			name, _ := qualifiedName(toks, next)
+			add(writeSet, name)
+		case t.is("FROM") && ix > 0 && toks[ix-1].is("DELETE"):
+			name, _ := qualifiedName(toks, ix+1)
+			add(writeSet, name)
+		case (t.is("TABLE") && ix > 0 && (toks[ix-1].is("TRUNCATE") || toks[ix-1].is("DROP") || toks[ix-1].is("ALTER") || toks[ix-1].is("CREATE") || toks[ix-1].is("TEMP") || toks[ix-1].is("TEMPORARY"))) || t.is("TRUNCATE") && ix+1 < len(toks) && !toks[ix+1].is("TABLE"):
+			next := ix + 1
+			for next+1 < len(toks) && (toks[next].is("IF") || toks[next].is("NOT") || toks[next].is("EXISTS") || toks[next].is("ONLY")) {
+				next++
+			}
+			name, _ := qualifiedName(toks, next)
+			add(writeSet, name)
+		case t.is("FROM") || t.is("JOIN") || (t.is("USING") && ix+1 < len(toks) && toks[ix+1].Text != "("):
+			next := ix + 1
+			for next < len(toks) {
+				if toks[next].is("ONLY") || toks[next].is("LATERAL") {
+					next++
+				}
+				name, after := qualifiedName(toks, next)
+				if name == "" || (after < len(toks) && toks[after].Text == "(") {
+					break
+				}
+				add(readSet, name)
+				next = after
+				if next < len(toks) && toks[next].is("AS") {
+					next++
+				}
+				if next < len(toks) && toks[next].Kind != tokPunct && !notAlias[strings.ToUpper(toks[next].Text)] {
+					next++
+				}
+				if next < len(toks) && toks[next].Text == "," {
+					next++
+					continue
+				}
+				break
+			}
+		}
+	}
+	return sortedKeys(readSet), sortedKeys(writeSet)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	ret := []string{}
+	for k := range set {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// WriteDeps writes the table dependencies of every command in nf as JSON.
+func WriteDeps(w io.Writer, nf *NormFile) error {
+	deps := []queryDeps{}
+	for _, cmd := range nf.Cmds {
+		reads, writes := tableRefs(cmd.Base().BodyString())
+		if _, ok := cmd.(*cmdCopy); ok {
+			// The body of a copy is the table it writes.
+			writes = []string{strings.TrimSpace(cmd.Base().BodyString())}
+		}
+		deps = append(deps, queryDeps{
+			Name:      cmd.Base().FuncName,
+			Command:   cmd.Kind(),
+			Functions: cmd.Funcs(),
+			Reads:     reads,
+			Writes:    writes,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(deps)
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTableRefs(t *testing.T) {
+	tests := []struct {
+		sql    string
+		reads  []string
+		writes []string
+	}{
+		{"SELECT id FROM users WHERE id = $1", []string{"users"}, []string{}},
+		{"SELECT * FROM users u, public.orders AS o WHERE u.id = o.user_id", []string{"public.orders", "users"}, []string{}},
+		{"SELECT * FROM users LEFT JOIN orders ON true CROSS JOIN items", []string{"items", "orders", "users"}, []string{}},
+		{"SELECT * FROM users WHERE id IN (SELECT user_id FROM admins)", []string{"admins", "users"}, []string{}},
+		// Names of common table expressions are not tables.
+		{"WITH recent AS (SELECT * FROM orders), big AS (SELECT * FROM recent) SELECT * FROM big", []string{"orders"}, []string{}},
+		// FROM in a function call is not a table reference.
+		{"SELECT EXTRACT(YEAR FROM created) FROM orders", []string{"orders"}, []string{}},
+		{"SELECT * FROM generate_series(1, 10)", []string{}, []string{}},
+		{"INSERT INTO archive SELECT * FROM orders", []string{"orders"}, []string{"archive"}},
+		{"INSERT OR REPLACE INTO users (id) VALUES (1)", []string{}, []string{"users"}},
+		{"UPDATE users SET name = n.name FROM names n WHERE n.id = users.id", []string{"names"}, []string{"users"}},
+		{"DELETE FROM users USING banned WHERE users.id = banned.id", []string{"banned"}, []string{"users"}},
+		{"SELECT * FROM users FOR UPDATE", []string{"users"}, []string{}},
+		{"INSERT INTO users (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET id = 2", []string{}, []string{"users"}},
+		{"TRUNCATE orders; DROP TABLE IF EXISTS items", []string{}, []string{"items", "orders"}},
+		{"CREATE TABLE IF NOT EXISTS logs (id INT)", []string{}, []string{"logs"}},
+	}
+	for _, test := range tests {
+		reads, writes := tableRefs(test.sql)
+		if !reflect.DeepEqual(reads, test.reads) || !reflect.DeepEqual(writes, test.writes) {
+			t.Errorf("%s: expected reads %v and writes %v, got %v and %v", test.sql, test.reads, test.writes, reads, writes)
+		}
+	}
+}
+
+func TestWriteDeps(t *testing.T) {
+	nf := parseTestFile(t, "testdata/deps/deps.norm.sql")
+	var bb bytes.Buffer
+	if err := WriteDeps(&bb, nf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/deps/deps.json", bb.Bytes())
+}
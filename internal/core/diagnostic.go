@@ -0,0 +1,168 @@
+package core
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// directiveSyntax is the form of every directive, shown as a hint along with
+// the errors of its lines.
+var directiveSyntax = map[string]string{
+	"application_name":       "-- !application_name <name>",
+	"as_of":                  "-- !as_of <cockroach|mariadb|sqlserver> [tables...]",
+	"attach":                 "-- !attach <schema> <path>",
+	"audit_log":              "-- !audit_log [inputs...]",
+	"bind":                   "-- !bind <inputs...>",
+	"cache_key":              "-- !cache_key <name> [Go expression]",
+	"cache_keys":             "-- !cache_keys",
+	"check_columns":          "-- !check_columns",
+	"compare":                "-- !compare",
+	"copy":                   "-- !copy <Name>",
+	"deprecated":             "-- !deprecated <message>",
+	"dialect":                "-- !dialect <postgres|sqlite|mysql>",
+	"doc":                    "-- !doc [text]",
+	"encrypted":              "-- !encrypted <names...>",
+	"end":                    "-- !end",
+	"endif":                  "-- !endif",
+	"env":                    "-- !env <name> <directive>",
+	"etag":                   "-- !etag [outputs...]",
+	"exec":                   "-- !exec <Name>",
+	"exec_many":              "-- !exec_many <Name>",
+	"exec_returning":         "-- !exec_returning <Name>",
+	"fallback":               "-- !fallback <Name>",
+	"file":                   "-- !file <path>",
+	"id_type":                "-- !id_type <Name> <base type> [table.column...]",
+	"if_match":               "-- !if_match <Name>",
+	"ifdef":                  "-- !ifdef <name>",
+	"import":                 "-- !import [alias] <path>",
+	"input":                  "-- !input <name> <type>",
+	"input_ctx":              "-- !input_ctx <name> <type> key:<key>",
+	"key":                    "-- !key <Name> [inputs...]",
+	"large_result_threshold": "-- !large_result_threshold <rows>",
+	"limit_group":            "-- !limit_group <name>",
+	"mapper":                 "-- !mapper <function>",
+	"materialized":           "-- !materialized",
+	"max_concurrency":        "-- !max_concurrency <calls> [nowait]",
+	"mocks":                  "-- !mocks",
+	"model":                  "-- !model <Type>",
+	"model_file":             "-- !model_file <path>",
+	"model_gen":              "-- !model_gen",
+	"model_pkg":              "-- !model_pkg <import path>",
+	"no_prepare":             "-- !no_prepare",
+	"not_sensitive":          "-- !not_sensitive <inputs...>",
+	"on_connect":             "-- !on_connect [statement]",
+	"output":                 "-- !output <Name> <type> [null]",
+	"package":                "-- !package <name>",
+	"partition_by":           "-- !partition_by <input> <daily|monthly|yearly>",
+	"pragma":                 "-- !pragma <name>=<value>...",
+	"read":                   "-- !read <Name>",
+	"read_one":               "-- !read_one <Name>",
+	"readonly":               "-- !readonly",
+	"result":                 "-- !result",
+	"rows_affected":          "-- !rows_affected",
+	"script":                 "-- !script <Name>",
+	"sensitive":              "-- !sensitive <inputs...>",
+	"sensitive_by_default":   "-- !sensitive_by_default",
+	"stamp":                  "-- !stamp <date|hash|none>",
+	"tags":                   "-- !tags <keys,...> [snake|camel]",
+	"usage_counts":           "-- !usage_counts",
+	"view":                   "-- !view <Name> <view name>",
+	"warmup":                 "-- !warmup",
+}
+
+var rxDirectiveName = regexp.MustCompile(`^-- !([a-z_]*)`)
+
+// describe adds the source line of e to it, along with the column and a hint
+// for the format errors and unknown directives msg reports.
+func (e *parseError) describe(source, msg string) {
+	source = strings.TrimRight(source, "\r")
+	e.Source = source
+	matches := rxDirectiveName.FindStringSubmatch(source)
+	if matches == nil {
+		return
+	}
+	name := matches[1]
+	switch {
+	case strings.HasPrefix(msg, "Format error"):
+		// The arguments of the directive are wrong.
+		e.Column = len(matches[0]) + 2
+		if e.Column > len(source)+1 {
+			e.Column = len(source) + 1
+		}
+		if syntax, ok := directiveSyntax[name]; ok {
+			e.Hint = "expected " + syntax
+		}
+	case strings.HasPrefix(msg, "Unknown command"):
+		e.Column = len("-- !") + 1
+		if _, ok := directiveSyntax[name]; ok {
+			e.Hint = "!" + name + " is not allowed here"
+		} else if near := nearestDirective(name); near != "" {
+			e.Hint = "did you mean " + directiveSyntax[near] + "?"
+		}
+	}
+}
+
+// nearestDirective returns the directive whose name is at most two edits away
+// from name, or "" if there is none. Of several, the closest one is returned,
+// and of those, the first in alphabetical order.
+func nearestDirective(name string) string {
+	var names []string
+	for known := range directiveSyntax {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	best, bestDist := "", 3
+	for _, known := range names {
+		if d := editDistance(name, known); d < bestDist {
+			best, bestDist = known, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// caret returns the line marking column col of source with a caret, keeping
+// the tabs of source before it so that it lines up.
+func caret(source string, col int) string {
+	var b strings.Builder
+	for ix := 0; ix < col-1; ix++ {
+		if ix < len(source) && source[ix] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteByte('^')
+	return b.String()
+}
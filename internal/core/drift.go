@@ -0,0 +1,223 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// index is an index created by a CREATE INDEX statement.
+type index struct {
+	Name    string
+	Table   string
+	Columns []string
+}
+
+// parseIndexes collects the indexes created by the exec commands of nf.
+func parseIndexes(nf *NormFile) []index {
+	var ret []index
+	for _, cmd := range nf.Cmds {
+		if _, ok := cmd.(*cmdExec); !ok {
+			continue
+		}
+		toks := tokenize(cmd.Base().BodyString())
+		for ix := 0; ix < len(toks); ix++ {
+			if !toks[ix].is("CREATE") {
+				continue
+			}
+			j := ix + 1
+			if j < len(toks) && toks[j].is("UNIQUE") {
+				j++
+			}
+			if j >= len(toks) || !toks[j].is("INDEX") {
+				continue
+			}
+			j++
+			if j < len(toks) && toks[j].is("CONCURRENTLY") {
+				j++
+			}
+			if j+2 < len(toks) && toks[j].is("IF") && toks[j+1].is("NOT") && toks[j+2].is("EXISTS") {
+				j += 3
+			}
+			name, j := qualifiedName(toks, j)
+			if name == "" || j >= len(toks) || !toks[j].is("ON") {
+				continue
+			}
+			j++
+			if j < len(toks) && toks[j].is("ONLY") {
+				j++
+			}
+			tableName, j := qualifiedName(toks, j)
+			ret = append(ret, index{name, tableName, parenNames(toks, j)})
+		}
+	}
+	return ret
+}
+
+// indexQueries list the names of the indexes of the database, by driver.
+var indexQueries = map[string]string{
+	"sqlite3":  `SELECT name FROM sqlite_master WHERE type = 'index'`,
+	"postgres": `SELECT indexname FROM pg_indexes`,
+	"mysql":    `SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_schema = DATABASE()`,
+}
+
+// typeAliases maps the names of column types to the name the databases
+// report for them.
+var typeAliases = map[string]string{
+	"INT":                         "INTEGER",
+	"INT4":                        "INTEGER",
+	"SERIAL":                      "INTEGER",
+	"SERIAL4":                     "INTEGER",
+	"INT8":                        "BIGINT",
+	"BIGSERIAL":                   "BIGINT",
+	"SERIAL8":                     "BIGINT",
+	"INT2":                        "SMALLINT",
+	"SMALLSERIAL":                 "SMALLINT",
+	"BOOL":                        "BOOLEAN",
+	"CHARACTER VARYING":           "VARCHAR",
+	"CHARACTER":                   "CHAR",
+	"BPCHAR":                      "CHAR",
+	"FLOAT8":                      "DOUBLE PRECISION",
+	"DOUBLE":                      "DOUBLE PRECISION",
+	"FLOAT4":                      "REAL",
+	"DECIMAL":                     "NUMERIC",
+	"TIMESTAMP WITHOUT TIME ZONE": "TIMESTAMP",
+	"TIMESTAMP WITH TIME ZONE":    "TIMESTAMPTZ",
+	"TIME WITHOUT TIME ZONE":      "TIME",
+	"TIME WITH TIME ZONE":         "TIMETZ",
+}
+
+// canonicalType returns the name of a column type without its parameters,
+// in the form the databases report it.
+func canonicalType(typ string) string {
+	typ = strings.ToUpper(typ)
+	if ix := strings.IndexByte(typ, '('); ix >= 0 {
+		typ = typ[:ix] + typ[strings.LastIndexByte(typ, ')')+1:]
+	}
+	typ = strings.Join(strings.Fields(typ), " ")
+	if alias, ok := typeAliases[typ]; ok {
+		return alias
+	}
+	return typ
+}
+
+// driftQuote quotes a possibly qualified name for the database of driverName.
+func driftQuote(driverName, name string) string {
+	var parts []string
+	for _, part := range strings.Split(name, ".") {
+		if driverName == "mysql" {
+			parts = append(parts, "`"+strings.Replace(part, "`", "``", -1)+"`")
+		} else {
+			parts = append(parts, quoteIdentifier(part))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// DriverRegistered reports whether a database/sql driver named name is linked
+// into the program. The norm command links the sqlite3, postgres and mysql
+// drivers, those of indexQueries.
+func DriverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sqliteReadOnly returns dsn opening the sqlite database read only, so that
+// comparing with a file that does not exist fails instead of creating an
+// empty database.
+func sqliteReadOnly(dsn string) (string, error) {
+	path := strings.TrimPrefix(dsn, "file:")
+	params := ""
+	if ix := strings.IndexByte(path, '?'); ix >= 0 {
+		path, params = path[:ix], path[ix+1:]+"&"
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return "file:" + path + "?" + params + "mode=ro", nil
+}
+
+// CheckDrift compares the tables and indexes created by the norm file with
+// the database at dsn, and returns a line per difference. Tables are read with
+// an empty SELECT, so that any driver reporting column types works. Indexes
+// are only compared for the drivers of indexQueries.
+func CheckDrift(ctx context.Context, driverName, dsn string, nf *NormFile) ([]string, error) {
+	if driverName == "sqlite3" {
+		var err error
+		if dsn, err = sqliteReadOnly(dsn); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var ret []string
+	for _, t := range ParseSchema(nf) {
+		rows, err := db.QueryContext(ctx, "SELECT * FROM "+driftQuote(driverName, t.Name)+" WHERE 1 = 0")
+		if err != nil {
+			ret = append(ret, fmt.Sprintf("%s: cannot read table: %v", t.Name, err))
+			continue
+		}
+		colTypes, err := rows.ColumnTypes()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		live := map[string]*sql.ColumnType{}
+		for _, ct := range colTypes {
+			live[strings.ToLower(ct.Name())] = ct
+		}
+		declared := map[string]bool{}
+		for _, col := range t.Columns {
+			declared[strings.ToLower(col.Name)] = true
+			ct, ok := live[strings.ToLower(col.Name)]
+			if !ok {
+				ret = append(ret, fmt.Sprintf("%s.%s: missing column", t.Name, col.Name))
+				continue
+			}
+			if liveType := ct.DatabaseTypeName(); liveType != "" && canonicalType(liveType) != canonicalType(col.Type) {
+				ret = append(ret, fmt.Sprintf("%s.%s: type is %s, the norm file declares %s", t.Name, col.Name, liveType, col.Type))
+			}
+		}
+		for _, ct := range colTypes {
+			if !declared[strings.ToLower(ct.Name())] {
+				ret = append(ret, fmt.Sprintf("%s.%s: column not in the norm file", t.Name, ct.Name()))
+			}
+		}
+	}
+	indexes := parseIndexes(nf)
+	query, ok := indexQueries[driverName]
+	if len(indexes) == 0 || !ok {
+		return ret, nil
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	live := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		live[strings.ToLower(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, ix := range indexes {
+		if !live[strings.ToLower(lastPart(ix.Name))] {
+			ret = append(ret, fmt.Sprintf("%s: missing index %s", ix.Table, ix.Name))
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const driftSchema = `-- !norm
+-- !package store
+
+-- !exec CreateUserTable
+CREATE TABLE user (
+  id INTEGER PRIMARY KEY,
+  email TEXT NOT NULL,
+  name TEXT
+)
+
+-- !exec CreateUserEmailIndex
+CREATE INDEX user_email ON user (email)
+`
+
+// newDriftDB creates a sqlite database running the statements stmts, and
+// returns its path.
+func newDriftDB(t *testing.T, stmts ...string) string {
+	dir, err := ioutil.TempDir("", "norm_drift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "store.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestCheckDrift(t *testing.T) {
+	nf := ParseData([]byte(driftSchema), []Source{{"<input>", 0}}, ParseOptions{})
+	tests := []struct {
+		name  string
+		stmts []string
+		want  []string
+	}{
+		{
+			"same",
+			[]string{"CREATE TABLE user (id INTEGER PRIMARY KEY, email TEXT NOT NULL, name TEXT)", "CREATE INDEX user_email ON user (email)"},
+			nil,
+		},
+		{
+			"missing column and index",
+			[]string{"CREATE TABLE user (id INTEGER PRIMARY KEY, email TEXT NOT NULL)"},
+			[]string{"user.name: missing column", "user: missing index user_email"},
+		},
+		{
+			"other type and column",
+			[]string{"CREATE TABLE user (id INTEGER PRIMARY KEY, email VARCHAR(20) NOT NULL, name TEXT, age INTEGER)", "CREATE INDEX user_email ON user (email)"},
+			[]string{"user.email: type is VARCHAR(20), the norm file declares TEXT", "user.age: column not in the norm file"},
+		},
+		{
+			"missing table",
+			[]string{"CREATE TABLE account (id INTEGER PRIMARY KEY)"},
+			[]string{"user: cannot read table: no such table: user", "user: missing index user_email"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CheckDrift(context.Background(), "sqlite3", newDriftDB(t, test.stmts...), nf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckDriftMissingFile(t *testing.T) {
+	nf := ParseData([]byte(driftSchema), []Source{{"<input>", 0}}, ParseOptions{})
+	path := filepath.Join(os.TempDir(), "norm_drift_missing.db")
+	if _, err := CheckDrift(context.Background(), "sqlite3", path, nf); !os.IsNotExist(err) {
+		t.Errorf("Expected the missing database to be reported, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no database to be created, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const encryptedField = `
+// FieldCodec encrypts and decrypts the values of the columns declared with
+// !encrypted. column is the name of the input or output, lowercased, so that
+// the input ssn and the output SSN share the keys of the ssn column.
+type FieldCodec interface {
+	Encrypt(column string, plaintext []byte) ([]byte, error)
+	Decrypt(column string, ciphertext []byte) ([]byte, error)
+}
+
+// Encryption is the codec of the encrypted columns. Queries with encrypted
+// columns fail while it is nil, rather than writing or reading plaintext.
+var Encryption FieldCodec
+
+// encrypted is the value of an encrypted input, encrypting v, a string or a
+// []byte, when it is passed to the database. As a scan destination, it
+// decrypts the column into v, a *string or a *[]byte, storing the zero value
+// for NULL.
+type encrypted struct {
+	column string
+	v      interface{}
+}
+
+func (e encrypted) Value() (driver.Value, error) {
+	if Encryption == nil {
+		return nil, fmt.Errorf("norm: no Encryption codec to encrypt %s", e.column)
+	}
+	var plaintext []byte
+	switch v := e.v.(type) {
+	case string:
+		plaintext = []byte(v)
+	case []byte:
+		plaintext = v
+	default:
+		return nil, errors.New("norm: unsupported type for an encrypted input")
+	}
+	return Encryption.Encrypt(e.column, plaintext)
+}
+
+func (e encrypted) Scan(src interface{}) error {
+	var plaintext []byte
+	switch src := src.(type) {
+	case nil:
+	case []byte, string:
+		if Encryption == nil {
+			return fmt.Errorf("norm: no Encryption codec to decrypt %s", e.column)
+		}
+		ciphertext, ok := src.([]byte)
+		if !ok {
+			ciphertext = []byte(src.(string))
+		}
+		var err error
+		if plaintext, err = Encryption.Decrypt(e.column, ciphertext); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("norm: cannot decrypt %s from %T", e.column, src)
+	}
+	switch dst := e.v.(type) {
+	case *string:
+		*dst = string(plaintext)
+	case *[]byte:
+		*dst = plaintext
+	default:
+		return errors.New("norm: unsupported type for an encrypted output")
+	}
+	return nil
+}
+`
+
+var encryptedFieldTmpl *template.Template
+
+// isEncrypted reports whether the input or output named name is declared
+// encrypted.
+func (c *cmdBase) isEncrypted(name string) bool {
+	return containsString(c.Encrypted, name)
+}
+
+// encryptedValue wraps expr, the value or scan destination of the input or
+// output named name, in encrypted.
+func encryptedValue(name, expr string) string {
+	return fmt.Sprintf("encrypted{%q, %s}", strings.ToLower(name), expr)
+}
+
+// ArgExpr returns the expression of the argument of the query for the input
+// named name: its value, encrypted if it is declared so.
+func (c *cmdBase) ArgExpr(name string) string {
+	if c.isEncrypted(name) {
+		return encryptedValue(name, c.InputExpr(name))
+	}
+	return c.InputExpr(name)
+}
+
+// scanDest returns the scan destination expr of the output named name,
+// decrypted if it is declared encrypted, or else wrapped in nullable if it is
+// declared null.
+func (c *cmdBase) scanDest(name, expr string) string {
+	if c.isEncrypted(name) {
+		return encryptedValue(name, expr)
+	}
+	return c.nullableDest(name, expr)
+}
+
+// hasEncrypted reports whether any command of nf has encrypted inputs or
+// outputs.
+func (nf *NormFile) hasEncrypted() bool {
+	for _, cmd := range nf.Cmds {
+		if len(cmd.Base().Encrypted) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEncrypted checks the !encrypted directives of the commands of nf,
+// which name string or []byte inputs and outputs of !read, !read_one, !exec
+// and !exec_returning commands. The names of a !encrypted directive before
+// the commands apply to the inputs and outputs of that name, ignoring case,
+// of every command, so that no query writes them in plaintext.
+func checkEncrypted(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		for _, a := range append(append([]arg{}, c.Inputs...), c.Outputs...) {
+			for _, name := range nf.Encrypted {
+				if strings.EqualFold(a.Name, name) && !c.isEncrypted(a.Name) {
+					c.Encrypted = append(c.Encrypted, a.Name)
+				}
+			}
+		}
+		if len(c.Encrypted) == 0 {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne, *cmdExec, *cmdExecReturning:
+		default:
+			panic(fmt.Sprintf("!encrypted of %s on line %d: %s commands cannot have encrypted columns", c.FuncName, c.Line, cmd.Kind()))
+		}
+		for _, name := range c.Encrypted {
+			typ := ""
+			if ix := inputIndex(c.Inputs, name); ix >= 0 {
+				typ = c.Inputs[ix].Typ
+			} else if ix := inputIndex(c.Outputs, name); ix >= 0 {
+				typ = c.Outputs[ix].Typ
+			} else {
+				panic(fmt.Sprintf("!encrypted of %s on line %d: no input or output %s", c.FuncName, c.Line, name))
+			}
+			if typ != "string" && typ != "[]byte" {
+				panic(fmt.Sprintf("!encrypted of %s on line %d: %s is a %s, only string and []byte columns can be encrypted", c.FuncName, c.Line, name, typ))
+			}
+		}
+	}
+}
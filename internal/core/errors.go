@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// The exit codes of norm. A failed check, such as warnings with -strict or
+// out of date files with -check, exits with ExitCheck.
+const (
+	ExitCheck = 1
+	ExitUsage = 2
+	ExitParse = 3
+	ExitWrite = 4
+	// ExitFailed is the code of the other errors, such as a query failing to
+	// run against a database.
+	ExitFailed = 5
+)
+
+// UsageError is a wrong use of the command line.
+type UsageError string
+
+func (e UsageError) Error() string {
+	return string(e)
+}
+
+// parseError is an error in a norm file, found while parsing it.
+type parseError struct {
+	// File, Line and Column are the position of the error, as far as it is
+	// known.
+	File   string
+	Line   int
+	Column int
+	Msg    string
+	// Source is the line of the error, and Hint a suggestion to fix it, see
+	// describe.
+	Source string
+	Hint   string
+}
+
+func (e *parseError) Error() string {
+	var b strings.Builder
+	switch {
+	case e.File != "" && e.Column > 0:
+		fmt.Fprintf(&b, "%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+	case e.File != "" && e.Line > 0:
+		fmt.Fprintf(&b, "%s:%d: %s", e.File, e.Line, e.Msg)
+	case e.File != "":
+		fmt.Fprintf(&b, "%s: %s", e.File, e.Msg)
+	default:
+		b.WriteString(e.Msg)
+	}
+	if e.Source != "" {
+		b.WriteString("\n\t" + e.Source)
+		if e.Column > 0 {
+			b.WriteString("\n\t" + caret(e.Source, e.Column))
+		}
+	}
+	if e.Hint != "" {
+		b.WriteString("\n\t" + e.Hint)
+	}
+	return b.String()
+}
+
+// ParseErrors are the errors found in the lines of a norm file, reported
+// together, see ParseFiles.
+type ParseErrors []*parseError
+
+func (e ParseErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// lineError is the panic of parse for an error in a line, as opposed to the
+// errors of the commands as a whole.
+type lineError string
+
+// WriteError is a failure to write the generated files.
+type WriteError struct {
+	Err error
+}
+
+func (e WriteError) Error() string {
+	return e.Err.Error()
+}
+
+var rxErrLine = regexp.MustCompile(`( on)? line ([0-9]+)`)
+
+// errorLine returns the first line of the input the error message msg refers
+// to, or 0.
+func errorLine(msg string) int {
+	matches := rxErrLine.FindStringSubmatch(msg)
+	if matches == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(matches[2])
+	return line
+}
+
+// NewParseError returns the error of the panic message msg of parsing the
+// input of nf, positioned at the first line msg refers to and showing it. The
+// " on line n" naming the position is left out of the message.
+func NewParseError(nf *NormFile, msg string) *parseError {
+	loc := rxErrLine.FindStringSubmatchIndex(msg)
+	if loc == nil {
+		e := &parseError{Msg: msg}
+		if len(nf.Sources) == 1 {
+			e.File = nf.Sources[0].Name
+		}
+		return e
+	}
+	line, _ := strconv.Atoi(msg[loc[4]:loc[5]])
+	e := &parseError{}
+	e.File, e.Line = nf.position(line)
+	if line > 0 && line <= len(nf.Input) {
+		e.describe(nf.Input[line-1], msg)
+	}
+	if loc[2] >= 0 {
+		msg = msg[:loc[0]] + msg[loc[1]:]
+	}
+	e.Msg = nf.locate(msg)
+	return e
+}
+
+// exitCode returns the exit code of err, see the exit constants.
+func exitCode(err error) int {
+	switch err.(type) {
+	case UsageError:
+		return ExitUsage
+	case *parseError, ParseErrors:
+		return ExitParse
+	case WriteError:
+		return ExitWrite
+	}
+	return ExitFailed
+}
+
+// panicError returns the error norm panicked with as r. Runtime errors are
+// bugs of norm, and panic again to get their stack trace.
+func panicError(r interface{}) error {
+	switch r := r.(type) {
+	case runtime.Error:
+		panic(r)
+	case error:
+		return r
+	}
+	return fmt.Errorf("%v", r)
+}
+
+// ExitStatus is panicked with to exit with the status without reporting an
+// error, such as ExitCheck once the out of date files are shown.
+type ExitStatus int
+
+// ReportFailure reports the error norm panicked with as r to w and returns the
+// exit code of norm.
+func ReportFailure(w io.Writer, r interface{}) int {
+	if status, ok := r.(ExitStatus); ok {
+		return int(status)
+	}
+	err := panicError(r)
+	switch err.(type) {
+	case *parseError, ParseErrors:
+		fmt.Fprintln(w, err)
+	case UsageError:
+		fmt.Fprintf(w, "norm: %s\nRun norm help for the commands, and norm <command> -h for their flags.\n", err)
+	default:
+		fmt.Fprintf(w, "norm: %s\n", err)
+	}
+	return exitCode(err)
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const etagMethod = `
+// ETag returns the entity tag of the row, a hash of its {{join .ETag ", "}},
+// quoted as in an ETag header.
+func (o {{.ResultType}}) ETag() string {
+	data, _ := json.Marshal([]interface{}{ {{- .ETagFields -}} })
+	return fmt.Sprintf("\"%x\"", sha256.Sum256(data))
+}
+`
+
+var etagMethodTmpl *template.Template
+
+const etagMismatch = `
+// ErrETagMismatch is returned by the IfMatch functions when the row changed
+// since its ETag was read.
+var ErrETagMismatch = errors.New("norm: ETag mismatch")
+`
+
+var etagMismatchTmpl *template.Template
+
+const ifMatch = `
+// {{.FuncName}}IfMatch is like {{.FuncName}}, but only runs the query if the
+// ETag of the row read by {{.IfMatch}} is etag, and returns ErrETagMismatch
+// otherwise. The row is read and written in one serializable transaction,
+// unless the Norm already runs in a transaction.
+func (n *Norm) {{.FuncName}}IfMatch(ctx context.Context{{if .Params}}, {{end}}{{getFuncSig .Params}}, etag string) {{.ReturnSig}} {
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		if err != nil {
+			return {{.ErrReturn}}
+		}
+		defer tx.Rollback()
+{{- if .Returns}}
+		ret, err := n.withDB(tx).{{.FuncName}}IfMatch(ctx{{if .Params}}, {{end}}{{getCallSig .Params}}, etag)
+		if err != nil {
+			return {{.ErrReturn}}
+		}
+		return ret, tx.Commit()
+{{- else}}
+		if err := n.withDB(tx).{{.FuncName}}IfMatch(ctx{{if .Params}}, {{end}}{{getCallSig .Params}}, etag); err != nil {
+			return err
+		}
+		return tx.Commit()
+{{- end}}
+	}
+	current, err := n.{{.IfMatch}}(ctx{{.IfMatchArgs}})
+	if err != nil {
+		return {{.ErrReturn}}
+	}
+	if current.ETag() != etag {
+		err = ErrETagMismatch
+		return {{.ErrReturn}}
+	}
+	return n.{{.FuncName}}(ctx{{if .Params}}, {{end}}{{getCallSig .Params}})
+}
+`
+
+var ifMatchTmpl *template.Template
+
+// ETagFields returns the values hashed by the ETag method of the result.
+func (c *cmdBase) ETagFields() string {
+	var fields []string
+	for _, name := range c.ETag {
+		fields = append(fields, "o."+name)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// ReturnSig returns the results of the function generated for the command.
+func (c *cmdExec) ReturnSig() string {
+	switch c.Returns {
+	case "result":
+		return "(sql.Result, error)"
+	case "rows_affected":
+		return "(int64, error)"
+	}
+	return "error"
+}
+
+// IfMatchArgs returns the arguments of the call of the !if_match query
+// following the context, with a leading comma: the parameters of the same
+// names.
+func (c *cmdExec) IfMatchArgs() string {
+	var ret strings.Builder
+	for _, p := range c.ifMatchRead.Params() {
+		ret.WriteString(", " + p.Name)
+	}
+	return ret.String()
+}
+
+// hasIfMatch reports whether any command of nf has a !if_match directive.
+func (nf *NormFile) hasIfMatch() bool {
+	for _, cmd := range nf.Cmds {
+		if c, ok := cmd.(*cmdExec); ok && c.IfMatch != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasETags reports whether any command of nf has a !etag directive.
+func (nf *NormFile) hasETags() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().ETagMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// checkETags checks the !etag and !if_match directives of the commands of nf.
+// !etag applies to !read and !read_one commands reading into a type norm
+// generates, which gets the ETag method hashing the listed outputs, or all of
+// them. Commands sharing a !model_gen model must hash the same outputs.
+// !if_match names the !read_one command reading the row an !exec changes,
+// whose parameters are passed from the parameters of the same name.
+func checkETags(nf *NormFile) {
+	byType := map[string]*cmdBase{}
+	byName := map[string]genAble{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		byName[c.FuncName] = cmd
+		if c.ETag == nil {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne:
+		default:
+			panic(fmt.Sprintf("!etag of %s on line %d: %s commands cannot have an ETag", c.FuncName, c.Line, cmd.Kind()))
+		}
+		generated := c.Model == "" && len(c.Outputs) > 1 || c.ModelGen && nf.ModelPkg == ""
+		if !generated {
+			panic(fmt.Sprintf("!etag of %s on line %d: the ETag method needs a result type generated by norm, an Output struct or a !model_gen model", c.FuncName, c.Line))
+		}
+		if len(c.ETag) == 0 {
+			for _, o := range c.Outputs {
+				c.ETag = append(c.ETag, o.Name)
+			}
+		}
+		for _, name := range c.ETag {
+			if inputIndex(c.Outputs, name) < 0 {
+				panic(fmt.Sprintf("!etag of %s on line %d: unknown output %s", c.FuncName, c.Line, name))
+			}
+		}
+		if other, ok := byType[c.ResultType()]; ok {
+			if strings.Join(other.ETag, ",") != strings.Join(c.ETag, ",") {
+				panic(fmt.Sprintf("!etag of %s on line %d: %s hashes other outputs in %s", c.FuncName, c.Line, c.ResultType(), other.FuncName))
+			}
+			continue
+		}
+		byType[c.ResultType()] = c
+		c.ETagMethod = true
+	}
+	for _, cmd := range nf.Cmds {
+		c, ok := cmd.(*cmdExec)
+		if !ok {
+			if b := cmd.Base(); b.IfMatch != "" {
+				panic(fmt.Sprintf("!if_match of %s on line %d: %s commands cannot be conditional", b.FuncName, b.Line, cmd.Kind()))
+			}
+			continue
+		}
+		if c.IfMatch == "" {
+			continue
+		}
+		read, ok := byName[c.IfMatch].(*cmdReadOne)
+		if !ok || read.ETag == nil {
+			panic(fmt.Sprintf("!if_match of %s on line %d: %s is not a !read_one command with an !etag", c.FuncName, c.Line, c.IfMatch))
+		}
+		params := c.Params()
+		for _, p := range read.Params() {
+			ix := inputIndex(params, p.Name)
+			if ix < 0 || params[ix].Typ != p.Typ {
+				panic(fmt.Sprintf("!if_match of %s on line %d: no parameter %s %s to pass to %s", c.FuncName, c.Line, p.Name, p.Typ, c.IfMatch))
+			}
+		}
+		if inputIndex(params, "etag") >= 0 {
+			panic(fmt.Sprintf("!if_match of %s on line %d: the parameter etag is taken by the ETag to match", c.FuncName, c.Line))
+		}
+		c.ifMatchRead = &read.cmdBase
+	}
+}
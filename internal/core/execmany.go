@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+const execMany = `
+{{if not .Model}}
+// {{.FuncName}}Row holds the inputs of one statement run by {{.FuncName}}.
+type {{.FuncName}}Row struct {
+{{.RowFields}}
+}
+{{end}}
+{{if .Deprecated}}
+var deprecated{{.FuncName}}Once sync.Once
+{{end}}
+{{- template "semaphore" .}}
+{{range .Doc}}// {{print .}}
+{{end -}}
+{{.DeprecatedDoc (gt (len .Doc) 0) -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context, rows []{{.RowType}}, opts ...Option) error {
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "{{.FuncName}}", Args: []interface{}{ {{- .RowsCallArg -}} }}, func(ctx context.Context) error {
+			return n.bare().{{.FuncName}}(ctx, rows, opts...)
+		})
+	}
+	call := applyOptions(opts)
+{{- template "timeout"}}
+{{- if .Deprecated}}
+	reportDeprecatedUse(&deprecated{{.FuncName}}Once, "{{.FuncName}}", {{printf "%q" .Deprecated}})
+{{- end}}
+{{- template "count" .}}
+{{- template "limit" .}}
+{{- template "acquire" .}}
+	if len(rows) == 0 {
+		return nil
+	}
+{{- template "begin"}}
+	stmt, err := db.PrepareContext(ctx, ` + "`{{.BodyString}}`" + `)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx{{.RowArgs "row"}}); err != nil {
+			return err
+		}
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+`
+
+var execManyTmpl *template.Template
+
+// beginTx starts the transaction of commands running several statements,
+// unless the Norm already runs inside one. The statements run on db.
+const beginTx = `
+{{- define "begin"}}
+	db := n.db
+	var tx *sql.Tx
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		var err error
+		if tx, err = sqlDB.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		db = tx
+	}
+{{- end}}`
+
+// cmdExecMany runs a statement once per element of a slice of rows, inside
+// one transaction. The inputs of the command are the fields of the rows.
+type cmdExecMany struct {
+	cmdBase
+}
+
+func (c *cmdExecMany) gen(w io.Writer) error {
+	return execManyTmpl.Execute(w, c)
+}
+
+func (c *cmdExecMany) Kind() string {
+	return "exec_many"
+}
+
+func (c *cmdExecMany) Funcs() []string {
+	if c.Model == "" {
+		return []string{c.FuncName, c.FuncName + "Row"}
+	}
+	return []string{c.FuncName}
+}
+
+// RowType returns the type of the rows: the model, or the generated Row
+// struct.
+func (c *cmdExecMany) RowType() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	return c.FuncName + "Row"
+}
+
+// RowFields returns the fields of the generated Row struct.
+func (c *cmdExecMany) RowFields() string {
+	var fields []arg
+	for _, inp := range c.Inputs {
+		fields = append(fields, arg{Name: FieldName(inp.Name), Typ: inp.Typ})
+	}
+	return getStructSig(fields)
+}
+
+// RowArgs returns the arguments of a statement for the row in v, with a
+// leading comma.
+func (c *cmdExecMany) RowArgs(v string) string {
+	var ret strings.Builder
+	for _, name := range c.ArgInputs() {
+		ret.WriteString(", " + v + "." + FieldName(name))
+	}
+	return ret.String()
+}
+
+// TakesRows reports whether the generated function of cmd takes a slice of
+// rows instead of its inputs.
+func TakesRows(cmd genAble) bool {
+	switch cmd.(type) {
+	case *cmdExecMany, *cmdCopy:
+		return true
+	}
+	return false
+}
+
+// modelDirective handles the !model directive of commands taking rows, naming
+// the type of the rows.
+func modelDirective(cmd *cmdBase) func(line string, i int) bool {
+	return func(line string, i int) bool {
+		if !strings.HasPrefix(line, `-- !model`) {
+			return false
+		}
+		matches := rxModel.FindStringSubmatch(line)
+		if len(matches) != 2 {
+			panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+		}
+		cmd.Model = matches[1]
+		return true
+	}
+}
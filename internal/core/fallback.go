@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"text/template"
+)
+
+const fallback = `
+// ShouldFallback decides whether a query with a !fallback that failed with err
+// runs its fallback query instead. It is not called when ctx is done. It can
+// be replaced to restrict fallbacks to some errors. By default, all errors but
+// sql.ErrNoRows run the fallback.
+var ShouldFallback = func(ctx context.Context, funcName string, err error) bool {
+	return !errors.Is(err, sql.ErrNoRows)
+}
+
+type degradedKey struct{}
+
+// WithDegraded returns a copy of ctx holding whether a query with a !fallback
+// called with it ran its fallback, as reported by Degraded.
+func WithDegraded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, degradedKey{}, new(int32))
+}
+
+// Degraded reports whether a query with a !fallback called with ctx, or with
+// a context derived from it, ran its fallback, so that its result may be
+// stale or partial. It is false unless ctx comes from WithDegraded.
+func Degraded(ctx context.Context) bool {
+	d, ok := ctx.Value(degradedKey{}).(*int32)
+	return ok && atomic.LoadInt32(d) != 0
+}
+
+// setDegraded records in the holder of WithDegraded in ctx, if any, that a
+// query ran its fallback.
+func setDegraded(ctx context.Context) {
+	if d, ok := ctx.Value(degradedKey{}).(*int32); ok {
+		atomic.StoreInt32(d, 1)
+	}
+}
+`
+
+var fallbackTmpl *template.Template
+
+// hasFallbacks reports whether any command of nf has a !fallback.
+func (nf *NormFile) hasFallbacks() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().Fallback != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveFallbacks checks that the fallback of every command with a !fallback
+// is a command of the same kind taking the same inputs, and reading the same
+// outputs into the same type. The fallback of a command reading into its
+// Output struct names that struct with a !model.
+func resolveFallbacks(nf *NormFile) {
+	byName := map[string]genAble{}
+	for _, cmd := range nf.Cmds {
+		byName[cmd.Base().FuncName] = cmd
+	}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if c.Fallback == "" {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdReadOne, *cmdRead:
+		default:
+			panic(fmt.Sprintf("!fallback of %s on line %d: only read and read_one commands can have a fallback", c.FuncName, c.Line))
+		}
+		target, ok := byName[c.Fallback]
+		if !ok {
+			panic(fmt.Sprintf("!fallback of %s on line %d: unknown command %s", c.FuncName, c.Line, c.Fallback))
+		}
+		fb := target.Base()
+		switch {
+		case target.Kind() != cmd.Kind():
+			panic(fmt.Sprintf("!fallback of %s on line %d: %s is a %s command, not %s", c.FuncName, c.Line, fb.FuncName, target.Kind(), cmd.Kind()))
+		case fb.Fallback != "":
+			panic(fmt.Sprintf("!fallback of %s on line %d: %s has a fallback itself", c.FuncName, c.Line, fb.FuncName))
+		case getTypeSig(fb.Params()) != getTypeSig(c.Params()):
+			panic(fmt.Sprintf("!fallback of %s on line %d: %s takes (%s), not (%s)", c.FuncName, c.Line, fb.FuncName, getTypeSig(fb.Params()), getTypeSig(c.Params())))
+		}
+		if c.Model == "" && len(c.Outputs) == 1 {
+			if fb.Model != "" || len(fb.Outputs) != 1 || fb.Outputs[0].Typ != c.Outputs[0].Typ {
+				panic(fmt.Sprintf("!fallback of %s on line %d: %s does not read a %s", c.FuncName, c.Line, fb.FuncName, c.Outputs[0].Typ))
+			}
+			continue
+		}
+		if c.Model == "" && getStructSig(fb.Outputs) != getStructSig(c.Outputs) {
+			panic(fmt.Sprintf("!fallback of %s on line %d: %s has other outputs", c.FuncName, c.Line, fb.FuncName))
+		}
+		if fb.ResultType() != c.ResultType() {
+			panic(fmt.Sprintf("!fallback of %s on line %d: %s reads a %s, not %s, give it a !model %s", c.FuncName, c.Line, fb.FuncName, fb.ResultType(), c.ResultType(), c.ResultType()))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFallbackErrors(t *testing.T) {
+	header := "-- !norm\n-- !package store\n\n-- !read ListUsers\n-- !output ID int\n-- !output Email string\n-- !fallback ListCachedUsers\nSELECT id, email FROM users\n\n-- !read ListCachedUsers\n"
+	tests := []struct {
+		fallback string
+		err      string
+	}{
+		{"-- !output ID int\n-- !output Email string\n", "<input>:4: !fallback of ListUsers: ListCachedUsers reads a ListCachedUsersOutput, not ListUsersOutput, give it a !model ListUsersOutput"},
+		{"-- !output ID int\n-- !output Email string\n-- !model User\n", "<input>:4: !fallback of ListUsers: ListCachedUsers reads a User, not ListUsersOutput"},
+		{"-- !output ID int\n-- !output Name string\n-- !model ListUsersOutput\n", "<input>:4: !fallback of ListUsers: ListCachedUsers has other outputs"},
+		{"-- !input id int\n-- !output ID int\n-- !output Email string\n-- !model ListUsersOutput\n", "<input>:4: !fallback of ListUsers: ListCachedUsers takes (int), not ()"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected the error %q for %q, got %v", test.err, test.fallback, err)
+				}
+			}()
+			ParseData([]byte(header+test.fallback+"SELECT id, email FROM users_cache\n"), []Source{{"<input>", 0}}, ParseOptions{})
+		}()
+	}
+
+	// The fallback reads into the Output struct of the query.
+	nf := ParseData([]byte(header+"-- !output ID int\n-- !output Email string\n-- !model ListUsersOutput\nSELECT id, email FROM users_cache\n"), []Source{{"<input>", 0}}, ParseOptions{})
+	if got := nf.Cmds[1].Base().ResultType(); got != "ListUsersOutput" {
+		t.Errorf("Expected the fallback to read into ListUsersOutput, got %s", got)
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// File is a parsed norm file, as returned by the parser package and
+// generated by the codegen package.
+type File struct {
+	nf *NormFile
+}
+
+// ParseInput are the settings of the parser package, see ParseReader.
+type ParseInput struct {
+	// Name is the name the errors of the input are reported with.
+	Name string
+	ParseOptions
+	// Package is used when the input has no !package directive.
+	Package string
+	// ResolvePackage looks for the package in the directory of the output
+	// file when there is no !package directive, as the norm command does.
+	ResolvePackage bool
+}
+
+var templatesOnce sync.Once
+
+// recoverError sets *err to the error norm panicked with, if any.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = panicError(r)
+	}
+}
+
+// ParseReader parses the norm file read from r. The errors of all the lines
+// are returned at once, as ParseErrors.
+func ParseReader(r io.Reader, in ParseInput) (f *File, err error) {
+	defer recoverError(&err)
+	templatesOnce.Do(LoadTemplates)
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	if in.Name == "" {
+		in.Name = "<input>"
+	}
+	nf := ParseData(data, []Source{{in.Name, 0}}, in.ParseOptions)
+	if nf.Package == "" {
+		nf.Package = in.Package
+	}
+	if in.ResolvePackage {
+		if err := ResolvePackage(nf); err != nil {
+			return nil, err
+		}
+	}
+	return &File{nf}, nil
+}
+
+// OutFile returns the output file named by the !file directive of f.
+func (f *File) OutFile() string {
+	return f.nf.OutFile
+}
+
+// Package returns the package of the generated code, or "" if the file has
+// no !package directive and none was given to the parser.
+func (f *File) Package() string {
+	return f.nf.Package
+}
+
+// Generate writes the Go code generated for f to w, as one file.
+func Generate(f *File, w io.Writer) (err error) {
+	defer recoverError(&err)
+	if f.nf.Package == "" {
+		return errors.New("no package for the generated code: the file has no !package directive")
+	}
+	templatesOnce.Do(LoadTemplates)
+	src := GenerateSource(f.nf)
+	if f.nf.Stamp == "hash" {
+		src = StampHash(src)
+	}
+	_, err = w.Write(src)
+	return err
+}
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// docWidth is the width norm fmt wraps the -- !doc lines of a command at.
+const docWidth = 80
+
+var rxFormatDirective = regexp.MustCompile(`^-- !([a-z_]+)(\s.*)?$`)
+
+// formatNorm returns the norm file data in its canonical layout, as written
+// by norm fmt: lines end with \n, comment and directive lines lose their
+// trailing spaces, lines holding only spaces become empty, runs of blank lines
+// become one blank line, and the file ends with a single newline. Directives
+// are written with single spaces, see formatDirective, and the directives
+// following the first line of a command are ordered by formatBlock. The blank
+// lines of blocks ending with -- !end are kept, since they may be part of the
+// query, and the other lines of queries are left as they are.
+func formatNorm(data []byte) []byte {
+	formatted, _ := formatNormLines(data)
+	return formatted
+}
+
+// fmtLine is a line of the formatted norm file, with the line of the input it
+// comes from, starting at 1.
+type fmtLine struct {
+	text string
+	line int
+}
+
+// formatNormLines is formatNorm, also returning the line of data each line of
+// the formatted data comes from, so that the errors found in the formatted
+// data are reported on the lines of data. The lines of a wrapped !doc
+// paragraph come from its first line.
+func formatNormLines(data []byte) ([]byte, []int) {
+	var lines []fmtLine
+	for ix, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "--") || strings.TrimSpace(line) == "" {
+			line = formatDirective(strings.TrimRight(line, " \t"))
+		}
+		lines = append(lines, fmtLine{line, ix + 1})
+	}
+	var out []fmtLine
+	inEnd := false
+	for ix := 0; ix < len(lines); ix++ {
+		line := lines[ix].text
+		switch {
+		case rxCommand.MatchString(line):
+			inEnd = fmtBlockHasEnd(lines, ix+2)
+			end := ix + 1
+			for end < len(lines) && inBlockHeader(lines[end].text) {
+				end++
+			}
+			out = append(out, lines[ix])
+			out = append(out, formatBlock(lines[ix+1:end])...)
+			ix = end - 1
+			continue
+		case line == `-- !end`:
+			inEnd = false
+		case line == "" && !inEnd && (len(out) == 0 || out[len(out)-1].text == ""):
+			continue
+		}
+		out = append(out, lines[ix])
+	}
+	for len(out) > 0 && out[len(out)-1].text == "" {
+		out = out[:len(out)-1]
+	}
+	texts := make([]string, len(out))
+	origins := make([]int, len(out))
+	for ix, line := range out {
+		texts[ix], origins[ix] = line.text, line.line
+	}
+	return []byte(strings.Join(texts, "\n") + "\n"), origins
+}
+
+// FormatFile returns the formatted content of the norm file name, along with
+// its content as read. The formatted file is parsed, so that files which do
+// not parse once formatted are left as they are, as gofmt does, and the errors
+// are reported on the lines of the file as read.
+func FormatFile(name string, opts ParseOptions) (data, formatted []byte, errs ParseErrors) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, ParseErrors{{File: name, Msg: err.Error()}}
+	}
+	formatted, origins := formatNormLines(data)
+	defer func() {
+		if r := recover(); r != nil {
+			switch err := r.(type) {
+			case *parseError:
+				errs = ParseErrors{err}
+			case ParseErrors:
+				errs = err
+			default:
+				panic(r)
+			}
+			lines := strings.Split(string(data), "\n")
+			for _, e := range errs {
+				e.unformat(lines, origins)
+			}
+			formatted = nil
+		}
+	}()
+	ParseData(formatted, []Source{{name, 0}}, opts)
+	return data, formatted, nil
+}
+
+// unformat moves e, found in formatted data, to the lines the formatted data
+// comes from, see formatNormLines. The column is dropped when the line was
+// changed by the formatting.
+func (e *parseError) unformat(lines []string, origins []int) {
+	origin := func(line int) int {
+		if line < 1 || line > len(origins) {
+			return line
+		}
+		return origins[line-1]
+	}
+	e.Msg = rxLineRef.ReplaceAllStringFunc(e.Msg, func(ref string) string {
+		line, _ := strconv.Atoi(strings.TrimPrefix(ref, "line "))
+		return fmt.Sprintf("line %d", origin(line))
+	})
+	if e.Line == 0 {
+		return
+	}
+	e.Line = origin(e.Line)
+	if e.Source != "" && e.Line <= len(lines) && strings.TrimRight(lines[e.Line-1], "\r") != e.Source {
+		e.Source = strings.TrimRight(lines[e.Line-1], "\r")
+		e.Column = 0
+	}
+}
+
+// fmtBlockHasEnd is blockHasEnd for the lines being formatted.
+func fmtBlockHasEnd(lines []fmtLine, from int) bool {
+	texts := make([]string, len(lines))
+	for ix, line := range lines {
+		texts[ix] = line.text
+	}
+	return blockHasEnd(texts, from)
+}
+
+// formatDirective returns the directive line with single spaces between its
+// arguments. The text of !deprecated and !on_connect is only trimmed, as is
+// the text of !doc unless it is code, see isDocCode, and the directive of !env
+// is formatted in turn. Other lines are returned as they are.
+func formatDirective(line string) string {
+	matches := rxFormatDirective.FindStringSubmatch(line)
+	if matches == nil {
+		return line
+	}
+	name, args := matches[1], strings.TrimSpace(matches[2])
+	switch name {
+	case "doc":
+		if matches[2] != "" && isDocCode(matches[2][1:]) {
+			args = matches[2][1:]
+		}
+	case "deprecated", "on_connect":
+	case "env":
+		fields := strings.SplitN(args, " ", 2)
+		if len(fields) == 2 {
+			inner := formatDirective("-- !" + strings.TrimSpace(fields[1]))
+			args = fields[0] + " " + strings.TrimPrefix(inner, "-- !")
+		}
+	default:
+		args = strings.Join(strings.Fields(args), " ")
+	}
+	if args == "" {
+		return "-- !" + name
+	}
+	return "-- !" + name + " " + args
+}
+
+// inBlockHeader reports whether line is one of the directives following the
+// first line of a command which formatBlock may reorder. !env, !ifdef and
+// !endif lines are left in place, along with everything after them.
+func inBlockHeader(line string) bool {
+	matches := rxFormatDirective.FindStringSubmatch(line)
+	if matches == nil || rxCommand.MatchString(line) {
+		return false
+	}
+	switch matches[1] {
+	case "env", "ifdef", "endif", "end":
+		return false
+	}
+	return true
+}
+
+// formatBlock returns the directives following the first line of a command
+// with the !input and !input_ctx lines first, then the !output lines, then
+// the others, each in the order they are written in, and the paragraphs of
+// !doc lines wrapped at docWidth. Blank !doc lines, list items and code lines,
+// see isDocParagraphBreak, are kept as they are and end the paragraphs.
+func formatBlock(directives []fmtLine) []fmtLine {
+	var inputs, outputs, others []fmtLine
+	for _, line := range directives {
+		switch rxFormatDirective.FindStringSubmatch(line.text)[1] {
+		case "input", "input_ctx":
+			inputs = append(inputs, line)
+		case "output":
+			outputs = append(outputs, line)
+		default:
+			others = append(others, line)
+		}
+	}
+	ret := append(inputs, outputs...)
+	var words []string
+	first := 0
+	for _, line := range others {
+		text := strings.TrimPrefix(line.text, "-- !doc ")
+		if text != line.text && !isDocParagraphBreak(text) {
+			if words == nil {
+				first = line.line
+			}
+			words = append(words, strings.Fields(text)...)
+			continue
+		}
+		ret = append(ret, wrapDoc(words, first)...)
+		words = nil
+		ret = append(ret, line)
+	}
+	return append(ret, wrapDoc(words, first)...)
+}
+
+// isDocParagraphBreak reports whether the text of a !doc line is kept on a
+// line of its own: a list item, starting with "- ", or code, see isDocCode.
+func isDocParagraphBreak(text string) bool {
+	return strings.HasPrefix(text, "- ") || isDocCode(text)
+}
+
+// isDocCode reports whether the text of a !doc line is indented by a tab or
+// two spaces, which makes it a code block of the doc comment. Such lines keep
+// their indentation and are not wrapped.
+func isDocCode(text string) bool {
+	return strings.HasPrefix(text, "\t") || strings.HasPrefix(text, "  ")
+}
+
+// wrapDoc returns words as -- !doc lines of at most docWidth characters,
+// unless a word is longer, coming from the input line first.
+func wrapDoc(words []string, first int) []fmtLine {
+	var ret []fmtLine
+	line := ""
+	for _, word := range words {
+		if line != "" && len(line)+1+len(word) > docWidth {
+			ret = append(ret, fmtLine{line, first})
+			line = ""
+		}
+		if line == "" {
+			line = "-- !doc " + word
+		} else {
+			line += " " + word
+		}
+	}
+	if line != "" {
+		ret = append(ret, fmtLine{line, first})
+	}
+	return ret
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// TestFormatGolden formats the norm files of testdata/fmt and compares them
+// with their .golden files, which parse and which formatting leaves as they
+// are. Run go test -update to rewrite the golden files.
+func TestFormatGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/fmt/*.norm.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		golden := strings.TrimSuffix(input, ".norm.sql") + ".golden"
+		t.Run(filepath.Base(golden), func(t *testing.T) {
+			data, err := ioutil.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := formatNorm(data)
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Expected\n%s\ngot\n%s", want, got)
+			}
+			if again := formatNorm(got); string(again) != string(got) {
+				t.Errorf("Formatting again changed the file to\n%s", again)
+			}
+			if _, _, errs := FormatFile(golden, ParseOptions{}); errs != nil {
+				t.Errorf("The golden file does not parse: %v", errs)
+			}
+		})
+	}
+}
+
+func TestFormatFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "bad.norm.sql")
+	// The !input line moves up to line 5 once formatted, but is reported on
+	// line 8, where it is written.
+	data := `-- !norm
+-- !file store.go
+
+-- !read_one FindUser
+-- !doc Finds
+-- !doc a user.
+-- !output ID int
+-- !input  email
+SELECT id FROM user WHERE email = $1
+`
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, formatted, errs := FormatFile(name, ParseOptions{})
+	if formatted != nil {
+		t.Errorf("Expected no formatted file, got\n%s", formatted)
+	}
+	if len(errs) != 1 || errs[0].Line != 8 || errs[0].Source != "-- !input  email" {
+		t.Fatalf("Expected an error on line 8, got %v", errs)
+	}
+	if _, _, errs := FormatFile(filepath.Join(dir, "missing.norm.sql"), ParseOptions{}); len(errs) != 1 {
+		t.Errorf("Expected an error for a missing file, got %v", errs)
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+const fuzz = `// Code generated by norm. DO NOT EDIT.
+
+//go:build go1.18
+// +build go1.18
+
+package {{.Package}}
+
+import (
+	"context"
+{{- if not .Connect}}
+	"database/sql"
+{{- end}}
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// fuzzNorm returns a Norm on an in-memory sqlite database with the tables and
+// views of the norm file.
+func fuzzNorm(f *testing.F) *Norm {
+	db, err := {{if .Connect}}Open{{else}}sql.Open{{end}}("sqlite3", ":memory:")
+	if err != nil {
+		f.Fatal(err)
+	}
+	// Every connection to :memory: is a new database.
+	db.SetMaxOpenConns(1)
+	f.Cleanup(func() {
+		db.Close()
+	})
+	store := NewNormFromDB(db)
+{{- range .Setup}}
+	if err := store.{{.}}(context.Background()); err != nil {
+		f.Fatal(err)
+	}
+{{- end}}
+	return store
+}
+{{range .Targets}}
+func Fuzz{{.FuncName}}(f *testing.F) {
+	fuzzStore := fuzzNorm(f)
+	f.Add({{.Seed}})
+	f.Fuzz(func(t *testing.T, {{getFuncSig .Inputs}}) {
+		// Errors are expected for most inputs, only panics fail.
+		fuzzStore.{{.FuncName}}(context.Background(), {{getCallSig .Inputs}})
+	})
+}
+{{end}}`
+
+var fuzzTmpl *template.Template
+
+// fuzzZero holds the input types supported by fuzzing, with the literal of
+// their zero value.
+var fuzzZero = map[string]string{
+	"string":  `""`,
+	"[]byte":  "[]byte(nil)",
+	"bool":    "false",
+	"int":     "int(0)",
+	"int8":    "int8(0)",
+	"int16":   "int16(0)",
+	"int32":   "int32(0)",
+	"int64":   "int64(0)",
+	"uint":    "uint(0)",
+	"uint8":   "uint8(0)",
+	"uint16":  "uint16(0)",
+	"uint32":  "uint32(0)",
+	"uint64":  "uint64(0)",
+	"float32": "float32(0)",
+	"float64": "float64(0)",
+	"rune":    "rune(0)",
+	"byte":    "byte(0)",
+}
+
+// fuzzTarget is a command that gets a fuzz target.
+type fuzzTarget struct {
+	*cmdBase
+	Seed string
+}
+
+// FuzzFileName returns the name of the fuzz test file for the output file.
+func FuzzFileName(outFile string) string {
+	return strings.TrimSuffix(outFile, ".go") + "_fuzz_test.go"
+}
+
+// GenerateFuzz returns a test file with a fuzz target for every command with
+// inputs of types the fuzzer supports. The targets run against an in-memory
+// sqlite database, set up with the exec commands creating tables and with the
+// views of the file.
+func GenerateFuzz(nf *NormFile) []byte {
+	var setup []string
+	var targets []fuzzTarget
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if v, ok := cmd.(*cmdView); ok {
+			setup = append(setup, "Create"+v.FuncName+"View")
+			continue
+		}
+		if _, ok := cmd.(*cmdExec); ok && len(c.Inputs) == 0 {
+			if toks := tokenize(c.BodyString()); len(toks) > 0 && toks[0].is("CREATE") {
+				setup = append(setup, c.FuncName)
+			}
+			continue
+		}
+		if TakesRows(cmd) || len(c.Inputs) == 0 || c.Key != "" || c.AsOf != "" || len(c.ContextInputs) > 0 {
+			continue
+		}
+		var seed []string
+		for _, inp := range c.Inputs {
+			zero, ok := fuzzZero[inp.Typ]
+			if !ok {
+				seed = nil
+				break
+			}
+			seed = append(seed, zero)
+		}
+		if seed != nil {
+			targets = append(targets, fuzzTarget{c, strings.Join(seed, ", ")})
+		}
+	}
+	var bb bytes.Buffer
+	if err := fuzzTmpl.Execute(&bb, map[string]interface{}{
+		"Package": nf.Package,
+		"Connect": nf.needsConnect(),
+		"Setup":   setup,
+		"Targets": targets,
+	}); err != nil {
+		panic(err)
+	}
+	formatted, err := format.Source(bb.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return formatted
+}
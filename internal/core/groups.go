@@ -0,0 +1,209 @@
+package core
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// fileGroup is a !file directive after the first command, starting a group
+// of commands written to File. The group starts with the command at index
+// Start.
+type fileGroup struct {
+	Start int
+	File  string
+}
+
+// assignFiles sets the output file of the commands of nf in the groups. A
+// group naming the main output file ends the previous group.
+func assignFiles(nf *NormFile, groups []fileGroup) {
+	for ix, g := range groups {
+		end := len(nf.Cmds)
+		if ix+1 < len(groups) {
+			end = groups[ix+1].Start
+		}
+		file := g.File
+		if file == nf.OutFile {
+			file = ""
+		}
+		for _, cmd := range nf.Cmds[g.Start:end] {
+			cmd.Base().File = file
+		}
+	}
+}
+
+// HasGroups reports whether any command of nf is in a !file group.
+func (nf *NormFile) HasGroups() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().File != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupFiles returns the output files of the !file groups of nf.
+func (nf *NormFile) GroupFiles() []string {
+	var ret []string
+	for _, cmd := range nf.Cmds {
+		if file := cmd.Base().File; file != "" && !containsString(ret, file) {
+			ret = append(ret, file)
+		}
+	}
+	return ret
+}
+
+// SplitGroups returns the files to write for nf, by name: the generated
+// source src without the code of the commands in !file groups, and a file per
+// group with that code. Every file only keeps the imports it uses.
+func SplitGroups(nf *NormFile, src []byte) map[string][]byte {
+	names := nf.GroupFiles()
+	bodies := map[string]*bytes.Buffer{}
+	for _, name := range names {
+		bb := &bytes.Buffer{}
+		if err := headerTmpl.Execute(bb, headerData(nf)); err != nil {
+			panic(err)
+		}
+		bodies[name] = bb
+	}
+	for _, cmd := range nf.Cmds {
+		if file := cmd.Base().File; file != "" {
+			genCmd(bodies[file], cmd)
+		}
+	}
+	ret := map[string][]byte{}
+	moved := map[string]bool{}
+	for _, name := range names {
+		formatted, err := format.Source(bodies[name].Bytes())
+		if err != nil {
+			panic(err)
+		}
+		f, _ := parseSource(formatted)
+		for _, decl := range f.Decls {
+			for _, key := range declKeys(decl) {
+				moved[key] = true
+			}
+		}
+		ret[name] = pruneImports(formatted)
+	}
+	// The declarations of the groups are cut out of src, last first so that
+	// the offsets of the others stay valid.
+	f, fset := parseSource(src)
+	type cut struct{ start, end int }
+	var cuts []cut
+	for _, decl := range f.Decls {
+		keys := declKeys(decl)
+		if len(keys) == 0 || !moved[keys[0]] {
+			continue
+		}
+		start := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			start = doc.Pos()
+		}
+		cuts = append(cuts, cut{fset.Position(start).Offset, fset.Position(decl.End()).Offset})
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].start > cuts[j].start })
+	main := append([]byte(nil), src...)
+	for _, c := range cuts {
+		main = append(main[:c.start], main[c.end:]...)
+	}
+	ret[nf.OutFile] = pruneImports(main)
+	return ret
+}
+
+// parseSource parses the generated source src.
+func parseSource(src []byte) (*ast.File, *token.FileSet) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	return f, fset
+}
+
+// declKeys returns the names declared by the top level declaration decl,
+// with methods named after their receiver. Imports have no keys.
+func declKeys(decl ast.Decl) []string {
+	var ret []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		name := d.Name.Name
+		if d.Recv != nil && len(d.Recv.List) == 1 {
+			typ := d.Recv.List[0].Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			if ident, ok := typ.(*ast.Ident); ok {
+				name = ident.Name + "." + name
+			}
+		}
+		ret = append(ret, name)
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				ret = append(ret, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					ret = append(ret, n.Name)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// declDoc returns the doc comment of decl, or nil.
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+// pruneImports removes the imports src does not use, and formats it.
+func pruneImports(src []byte) []byte {
+	f, fset := parseSource(src)
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+	var unused []*ast.ImportSpec
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := packageName(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name != "_" && name != "." && !used[name] {
+			unused = append(unused, imp)
+		}
+	}
+	out := append([]byte(nil), src...)
+	for ix := len(unused) - 1; ix >= 0; ix-- {
+		start := fset.Position(unused[ix].Pos()).Offset
+		end := fset.Position(unused[ix].End()).Offset
+		start = bytes.LastIndexByte(out[:start], '\n') + 1
+		if nl := bytes.IndexByte(out[end:], '\n'); nl >= 0 {
+			end += nl + 1
+		}
+		out = append(out[:start], out[end:]...)
+	}
+	formatted, err := format.Source(out)
+	if err != nil {
+		panic(err)
+	}
+	return formatted
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"strings"
+	"text/template"
+)
+
+const idTypes = `{{range .}}
+// {{.Name}} is an ID type, distinct from other IDs of type {{.Base}}.
+type {{.Name}} {{.Base}}
+
+// Scan implements sql.Scanner.
+func (id *{{.Name}}) Scan(src interface{}) error {
+	var v {{.NullType}}
+	if err := v.Scan(src); err != nil {
+		return err
+	}
+	if !v.Valid {
+		return errors.New("norm: cannot scan NULL into {{.Name}}")
+	}
+	*id = {{.Name}}(v.{{.NullField}})
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (id {{.Name}}) Value() (driver.Value, error) {
+	return {{.ValueType}}(id), nil
+}
+{{end}}`
+
+var idTypesTmpl *template.Template
+
+// idTypeNulls maps the base types supported by !id_type to the sql.Null type
+// scanning them and the driver.Value type they are passed as.
+var idTypeNulls = map[string][2]string{
+	"int":    {"sql.NullInt64", "int64"},
+	"int32":  {"sql.NullInt32", "int64"},
+	"int64":  {"sql.NullInt64", "int64"},
+	"string": {"sql.NullString", "string"},
+}
+
+// idType is a defined type declared with !id_type, used for the derived
+// inputs and outputs of Columns.
+type idType struct {
+	Name string
+	Base string
+	// Columns are the table.column names the type is used for, in lower
+	// case.
+	Columns []string
+}
+
+func (t idType) NullType() string {
+	return idTypeNulls[t.Base][0]
+}
+
+// NullField is the field of NullType holding the value.
+func (t idType) NullField() string {
+	return strings.TrimPrefix(t.NullType(), "sql.Null")
+}
+
+func (t idType) ValueType() string {
+	return idTypeNulls[t.Base][1]
+}
+
+// columnType returns the Go type of the column col of table tableName: the
+// ID type declared for it, or typ.
+func (nf *NormFile) columnType(tableName string, col *column, typ string) string {
+	name := strings.ToLower(lastPart(tableName) + "." + col.Name)
+	for _, t := range nf.IDTypes {
+		if containsString(t.Columns, name) {
+			return t.Name
+		}
+	}
+	return typ
+}
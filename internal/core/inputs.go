@@ -0,0 +1,234 @@
+package core
+
+import (
+	"fmt"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// reservedInputNames are the identifiers used by the generated functions,
+// which derived inputs must not shadow.
+var reservedInputNames = map[string]bool{
+	"ctx":    true,
+	"dst":    true,
+	"err":    true,
+	"n":      true,
+	"o":      true,
+	"res":    true,
+	"result": true,
+	"stmt":   true,
+}
+
+// comparisonOps are the operators after which a placeholder is taken to have
+// the type of the column before them.
+var comparisonOps = map[string]bool{
+	"=": true, "<": true, ">": true, "<=": true, ">=": true, "<>": true, "!=": true,
+}
+
+// deriveInputs synthesizes the inputs of the commands of nf that declare none
+// but have $n or ? placeholders in their body. An input compared with or
+// inserted into a column of a table created in the file is named after the
+// column and gets a Go type matching its SQL type. Other inputs are named
+// argN and have type interface{}.
+func deriveInputs(nf *NormFile) {
+	var schema []*table
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if len(c.Inputs) > 0 {
+			continue
+		}
+		toks := tokenize(c.BodyString())
+		slots := placeholderSlots(toks, c.Line)
+		if len(slots) == 0 {
+			continue
+		}
+		if schema == nil {
+			schema = ParseSchema(nf)
+		}
+		count := 0
+		for _, n := range slots {
+			if n > count {
+				count = n
+			}
+		}
+		tables := make([]*table, count)
+		cols := make([]*column, count)
+		for ix := range toks {
+			if n := slots[ix]; n > 0 && cols[n-1] == nil {
+				tables[n-1], cols[n-1] = placeholderColumn(schema, toks, ix)
+			}
+		}
+		used := map[string]bool{}
+		for ix, col := range cols {
+			inp := arg{Name: fmt.Sprintf("arg%d", ix+1), Typ: "interface{}"}
+			if col != nil {
+				if name := inputName(col.Name); name != "" && !used[name] {
+					inp.Name = name
+				}
+				inp.Typ = nf.columnType(tables[ix].Name, col, goInputType(col.Type))
+				if inp.Typ == "time.Time" {
+					nf.addImport("", "time")
+				}
+			}
+			used[inp.Name] = true
+			c.Inputs = append(c.Inputs, inp)
+		}
+	}
+}
+
+// placeholderSlots maps the index of every token of toks to the number of
+// the input bound to it, if it is a placeholder. Named placeholders must be
+// declared, so bodies using them get no slots.
+func placeholderSlots(toks []sqlToken, line int) map[int]int {
+	ret := map[int]int{}
+	positional := 0
+	for ix, t := range toks {
+		if t.Kind != tokPlaceholder {
+			continue
+		}
+		switch t.Text[0] {
+		case '?':
+			positional++
+			ret[ix] = positional
+		case '$':
+			n, err := strconv.Atoi(t.Text[1:])
+			if err != nil || n == 0 {
+				panic(fmt.Sprintf("Invalid placeholder %s in the query on line %d", t.Text, line))
+			}
+			ret[ix] = n
+		default:
+			return nil
+		}
+	}
+	return ret
+}
+
+// placeholderColumn returns the column of schema the placeholder toks[ix] is
+// compared with or inserted into, and its table, or nil if it is not known.
+func placeholderColumn(schema []*table, toks []sqlToken, ix int) (*table, *column) {
+	if ix >= 2 && comparisonOps[toks[ix-1].Text] && toks[ix-2].Kind != tokPlaceholder {
+		return findColumn(schema, "", toks[ix-2].name())
+	}
+	tableName, cols := insertColumns(toks)
+	if cols == nil {
+		return nil, nil
+	}
+	values := -1
+	for j, t := range toks {
+		if t.is("VALUES") && j+1 < len(toks) && toks[j+1].Text == "(" {
+			values = j + 1
+			break
+		}
+	}
+	if values < 0 || ix <= values {
+		return nil, nil
+	}
+	end := matchParen(toks, values)
+	if end >= 0 && ix > end {
+		return nil, nil
+	}
+	exprs := splitTopLevel(toks[values+1 : ix+1])
+	// The placeholder must be a whole value, not part of an expression.
+	if len(exprs) > len(cols) || len(exprs[len(exprs)-1]) != 1 {
+		return nil, nil
+	}
+	return findColumn(schema, tableName, cols[len(exprs)-1])
+}
+
+// insertColumns returns the table and the column list of an INSERT
+// statement in toks, or nil columns if there is none.
+func insertColumns(toks []sqlToken) (string, []string) {
+	for ix := 0; ix+2 < len(toks); ix++ {
+		if !toks[ix].is("INSERT") {
+			continue
+		}
+		j := ix + 1
+		for j < len(toks) && !toks[j].is("INTO") {
+			j++
+		}
+		name, j := qualifiedName(toks, j+1)
+		if name == "" || j >= len(toks) || toks[j].Text != "(" {
+			return "", nil
+		}
+		end := matchParen(toks, j)
+		if end < 0 {
+			return "", nil
+		}
+		var cols []string
+		for _, def := range splitTopLevel(toks[j+1 : end]) {
+			if len(def) == 0 {
+				return "", nil
+			}
+			cols = append(cols, def[len(def)-1].name())
+		}
+		return name, cols
+	}
+	return "", nil
+}
+
+// findColumn returns the column named name of tableName, or of any table of
+// schema if tableName is empty, and its table. Qualified names are matched by
+// their last part.
+func findColumn(schema []*table, tableName, name string) (*table, *column) {
+	for _, t := range schema {
+		if tableName != "" && !strings.EqualFold(lastPart(t.Name), lastPart(tableName)) {
+			continue
+		}
+		for ix := range t.Columns {
+			if strings.EqualFold(t.Columns[ix].Name, name) {
+				return t, &t.Columns[ix]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func lastPart(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// inputName returns the Go parameter name of an input bound to the column
+// named col, or "" if the name cannot be used.
+func inputName(col string) string {
+	parts := strings.FieldsFunc(col, func(r rune) bool {
+		return r == '_' || !isIdentPart(r)
+	})
+	if len(parts) == 0 {
+		return ""
+	}
+	name := strings.ToLower(parts[0])
+	for _, p := range parts[1:] {
+		if strings.EqualFold(p, "id") {
+			name += "ID"
+			continue
+		}
+		name += strings.ToUpper(p[:1]) + strings.ToLower(p[1:])
+	}
+	if token.IsKeyword(name) || reservedInputNames[name] || !isIdentStart(rune(name[0])) {
+		return ""
+	}
+	return name
+}
+
+// goInputType returns the Go type of an input bound to a column of SQL type
+// typ, following the sqlite type affinity rules.
+func goInputType(typ string) string {
+	typ = strings.ToUpper(typ)
+	switch {
+	case strings.Contains(typ, "BOOL"):
+		return "bool"
+	case strings.Contains(typ, "INT"):
+		return "int64"
+	case strings.Contains(typ, "CHAR"), strings.Contains(typ, "CLOB"), strings.Contains(typ, "TEXT"):
+		return "string"
+	case strings.Contains(typ, "BLOB"), strings.Contains(typ, "BYTEA"):
+		return "[]byte"
+	case strings.Contains(typ, "TIME"), strings.Contains(typ, "DATE"):
+		return "time.Time"
+	case strings.Contains(typ, "REAL"), strings.Contains(typ, "FLOA"), strings.Contains(typ, "DOUB"),
+		strings.Contains(typ, "NUMERIC"), strings.Contains(typ, "DECIMAL"):
+		return "float64"
+	}
+	return "interface{}"
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// IRVersion is the version of the IR printed by norm ir. Fields are only ever
+// added to the IR within a version: a field is removed, renamed or given a
+// different meaning only along with a new version, so emitters built outside
+// of norm can rely on the version to keep working across releases. The
+// templates of norm itself render the IRCommand of every command, along with
+// the Go code derived from it, so that what they read is what the IR shows.
+const IRVersion = 1
+
+// IR is the intermediate representation of a norm file, as printed by norm ir:
+// the queries of the file, after the directives are applied.
+type IR struct {
+	Version int    `json:"version"`
+	Package string `json:"package,omitempty"`
+	OutFile string `json:"out_file"`
+	// Dialect is set by the !dialect directive.
+	Dialect string `json:"dialect,omitempty"`
+	// Imports are the packages imported with !import, and the package of the
+	// models named by !model_pkg. The packages the generated code imports for
+	// itself are left out.
+	Imports []IRImport `json:"imports,omitempty"`
+	Queries []IRQuery  `json:"queries"`
+}
+
+// IRImport is a package imported by the generated code.
+type IRImport struct {
+	// Name is the name the package is imported under, if it is given, such as
+	// _ for the drivers imported for their side effects.
+	Name string `json:"name,omitempty"`
+	Path string `json:"path"`
+}
+
+// IRQuery is a command of the norm file. Every generated function takes
+// options, see WithTimeout, and runs through the Middleware set with Use
+// under the name of the query.
+type IRQuery struct {
+	// Command is the directive declaring the command, such as read_one.
+	Command string `json:"command"`
+	IRCommand
+	SQL string `json:"sql"`
+	// Functions are the names of the Go functions generated for the command.
+	Functions []string `json:"functions"`
+	// ResultType is the Go type a row is read into, for the commands reading
+	// rows.
+	ResultType string `json:"result_type,omitempty"`
+	// Reader is set for the queries run on the replica set with WithReplica,
+	// unless they are called with OnPrimary.
+	Reader bool `json:"reader,omitempty"`
+}
+
+// IRCommand is what the block of a command declares, after the directives of
+// the file are applied.
+type IRCommand struct {
+	FuncName string `json:"name"`
+	// Line is the line of the norm file the command starts on.
+	Line int `json:"line"`
+	// File is the output file of the command if it is in a !file group
+	// rather than in the main output file.
+	File    string   `json:"file,omitempty"`
+	Doc     []string `json:"doc,omitempty"`
+	Inputs  []arg    `json:"inputs"`
+	Outputs []arg    `json:"outputs"`
+	// Args are the names of the inputs bound to the ? placeholders of the
+	// mysql !dialect, in order, when :name placeholders use inputs more than
+	// once or out of order. Otherwise the inputs are bound in order.
+	Args  []string `json:"args,omitempty"`
+	Model string   `json:"model,omitempty"`
+	// CheckColumns is set for commands reading rows when the file has a
+	// !check_columns directive.
+	CheckColumns bool   `json:"check_columns,omitempty"`
+	Deprecated   string `json:"deprecated,omitempty"`
+	// MaxConcurrency limits the number of concurrent calls of the command,
+	// see !max_concurrency. With NoWait, calls over the limit fail instead
+	// of waiting.
+	MaxConcurrency int  `json:"max_concurrency,omitempty"`
+	NoWait         bool `json:"no_wait,omitempty"`
+	// LimitGroup names the group of queries whose rate is limited by a
+	// Limiter, see !limit_group.
+	LimitGroup string `json:"limit_group,omitempty"`
+	// Fallback names the command run when the command fails, see
+	// !fallback.
+	Fallback string `json:"fallback,omitempty"`
+	// Key names the struct grouping the inputs in KeyInputs into one
+	// parameter, see !key.
+	Key       string   `json:"key,omitempty"`
+	KeyInputs []string `json:"key_inputs,omitempty"`
+	// ContextInputs are the inputs read from the context instead of being
+	// parameters, see !input_ctx.
+	ContextInputs []contextInput `json:"context_inputs,omitempty"`
+	// Bind lists the parameters fixed by the generated Bind function, see
+	// !bind.
+	Bind []string `json:"bind,omitempty"`
+	// NoPrepare runs the query without preparing it first, see !no_prepare.
+	NoPrepare bool `json:"no_prepare,omitempty"`
+	// CountUsage counts the calls of the command, see !usage_counts.
+	CountUsage bool `json:"usage_counts,omitempty"`
+	// ReadOnly runs the query in a read only transaction, see !readonly.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// ModelGen generates the model struct from the outputs, see !model_gen.
+	ModelGen bool `json:"model_gen,omitempty"`
+	// Tags are the keys of the struct tags of the generated output structs,
+	// with the field names converted to TagCase, see !tags.
+	Tags    []string `json:"tags,omitempty"`
+	TagCase string   `json:"tag_case,omitempty"`
+	// Mapper names the function building the model from the row, see
+	// !mapper.
+	Mapper string `json:"mapper,omitempty"`
+	// AuditLog writes a row to the audit log along with the query, recording
+	// the inputs in AuditInputs, see !audit_log.
+	AuditLog    bool     `json:"audit_log,omitempty"`
+	AuditInputs []string `json:"audit_inputs,omitempty"`
+	// AsOf names the dialect of the clause reading the tables in AsOfTables,
+	// or all of them, at the time passed to the query, see !as_of.
+	AsOf       string   `json:"as_of,omitempty"`
+	AsOfTables []string `json:"as_of_tables,omitempty"`
+	// PartitionBy names the input of the insert selecting the partition of
+	// PartitionTable it goes to, created per PartitionInterval, see
+	// !partition_by.
+	PartitionBy       string `json:"partition_by,omitempty"`
+	PartitionInterval string `json:"partition_interval,omitempty"`
+	PartitionTable    string `json:"partition_table,omitempty"`
+	// Encrypted are the names of the inputs and outputs passed through the
+	// FieldCodec, see !encrypted.
+	Encrypted []string `json:"encrypted,omitempty"`
+	// Sensitive are the names of the inputs whose values are replaced by
+	// Redacted in the Call given to Middleware, see !sensitive.
+	Sensitive []string `json:"sensitive,omitempty"`
+	// ETag lists the outputs hashed by the ETag method of the result type,
+	// see !etag.
+	ETag []string `json:"etag,omitempty"`
+	// IfMatch names the command reading the row whose ETag is compared by
+	// the generated IfMatch function, see !if_match.
+	IfMatch string `json:"if_match,omitempty"`
+	// CacheKey generates the CacheKey function of the command, see
+	// !cache_keys.
+	CacheKey bool `json:"cache_key,omitempty"`
+	// CacheKeyFields are the entries of the cache key, replacing the inputs,
+	// see !cache_key.
+	CacheKeyFields []IRCacheKeyField `json:"cache_key_fields,omitempty"`
+	// WarmUp runs the query in the generated WarmUp method, see !warmup.
+	WarmUp bool `json:"warmup,omitempty"`
+	// Compare generates the Compare function running the query against two
+	// databases, see !compare.
+	Compare bool `json:"compare,omitempty"`
+}
+
+// IRField is an input or an output of a query, with its Go type.
+type IRField struct {
+	Name string `json:"name"`
+	Typ  string `json:"type"`
+	// Null is set for outputs declared null, which are scanned as nullable.
+	Null bool `json:"null,omitempty"`
+}
+
+// IRContextInput is an input read from the context, see !input_ctx. Key is
+// the name of its key, see the generated With functions.
+type IRContextInput struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// IRCacheKeyField is an entry of the cache key of a query, named Name, whose
+// value is the Go expression Expr over the inputs, see !cache_key.
+type IRCacheKeyField struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+// irFields returns args, empty rather than nil.
+func irFields(args []arg) []IRField {
+	return append([]IRField{}, args...)
+}
+
+// BuildIR returns the intermediate representation of nf.
+func BuildIR(nf *NormFile) IR {
+	ir := IR{
+		Version: IRVersion,
+		Package: nf.Package,
+		OutFile: nf.OutFile,
+		Dialect: nf.Dialect,
+		Imports: nf.DeclaredImports,
+		Queries: []IRQuery{},
+	}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		q := IRQuery{
+			Command:   cmd.Kind(),
+			IRCommand: c.IRCommand,
+			SQL:       c.BodyString(),
+			Functions: cmd.Funcs(),
+		}
+		q.Inputs = irFields(c.Inputs)
+		q.Outputs = irFields(c.Outputs)
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne:
+			q.ResultType = c.ResultType()
+			q.Reader = true
+		case *cmdExecReturning:
+			q.ResultType = c.ResultType()
+		}
+		ir.Queries = append(ir.Queries, q)
+	}
+	return ir
+}
+
+// WriteIR writes the intermediate representation of nf as JSON.
+func WriteIR(w io.Writer, nf *NormFile) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(BuildIR(nf))
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const irInput = `-- !norm
+-- !package store
+-- !file store.go
+-- !dialect postgres
+-- !import _ github.com/lib/pq
+-- !import "github.com/google/uuid"
+
+-- !read_one FindUser
+-- !input_ctx tenant string key:tenantDomain
+-- !input email string
+-- !output ID int
+-- !output Name string null
+-- !key UserKey email
+-- !max_concurrency 4 nowait
+-- !limit_group lookups
+-- !fallback FindUserCached
+-- !tags json,db snake
+-- !etag ID Name
+SELECT id, name FROM users WHERE tenant = $1 AND email = $2
+
+-- !read_one FindUserCached
+-- !input_ctx tenant string key:tenantDomain
+-- !input email string
+-- !output ID int
+-- !output Name string null
+-- !key UserKey email
+-- !model FindUserOutput
+SELECT id, name FROM users_cache WHERE tenant = $1 AND email = $2
+
+-- !read ListOrders
+-- !input user int
+-- !input status string
+-- !output ID int
+-- !model Order
+-- !mapper toOrder
+-- !bind status
+-- !sensitive user
+-- !as_of sqlserver orders
+SELECT id FROM orders WHERE user_id = $1 AND status = $2
+
+-- !exec DeleteUser
+-- !input id int
+DELETE FROM users WHERE id = $1
+`
+
+// irQuery returns the query named name in the JSON of ir.
+func irQuery(t *testing.T, ir map[string]interface{}, name string) map[string]interface{} {
+	for _, q := range ir["queries"].([]interface{}) {
+		if q := q.(map[string]interface{}); q["name"] == name {
+			return q
+		}
+	}
+	t.Fatalf("No query %s in the IR", name)
+	return nil
+}
+
+func TestIR(t *testing.T) {
+	LoadTemplates()
+	nf := ParseData([]byte(irInput), []Source{{"<input>", 0}}, ParseOptions{})
+	var bb bytes.Buffer
+	if err := WriteIR(&bb, nf); err != nil {
+		t.Fatal(err)
+	}
+	var ir map[string]interface{}
+	if err := json.Unmarshal(bb.Bytes(), &ir); err != nil {
+		t.Fatal(err)
+	}
+	var want map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"dialect": "postgres",
+		"imports": [{"name": "_", "path": "github.com/lib/pq"}, {"path": "github.com/google/uuid"}]
+	}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(ir[key], value) {
+			t.Errorf("Expected %s %v, got %v", key, value, ir[key])
+		}
+	}
+	tests := []struct {
+		query string
+		key   string
+		want  string
+	}{
+		{"FindUser", "context_inputs", `[{"name": "tenant", "key": "tenantDomain"}]`},
+		{"FindUser", "inputs", `[{"name": "tenant", "type": "string"}, {"name": "email", "type": "string"}]`},
+		{"FindUser", "outputs", `[{"name": "ID", "type": "int"}, {"name": "Name", "type": "string", "null": true}]`},
+		{"FindUser", "key", `"UserKey"`},
+		{"FindUser", "key_inputs", `["email"]`},
+		{"FindUser", "max_concurrency", `4`},
+		{"FindUser", "no_wait", `true`},
+		{"FindUser", "limit_group", `"lookups"`},
+		{"FindUser", "fallback", `"FindUserCached"`},
+		{"FindUser", "tags", `["json", "db"]`},
+		{"FindUser", "tag_case", `"snake"`},
+		{"FindUser", "etag", `["ID", "Name"]`},
+		{"FindUser", "reader", `true`},
+		{"ListOrders", "model", `"Order"`},
+		{"ListOrders", "mapper", `"toOrder"`},
+		{"ListOrders", "bind", `["status"]`},
+		{"ListOrders", "as_of", `"sqlserver"`},
+		{"ListOrders", "as_of_tables", `["orders"]`},
+		{"ListOrders", "result_type", `"Order"`},
+		{"ListOrders", "sensitive", `["user"]`},
+		{"DeleteUser", "command", `"exec"`},
+		{"DeleteUser", "inputs", `[{"name": "id", "type": "int"}]`},
+		{"DeleteUser", "outputs", `[]`},
+		{"DeleteUser", "reader", `null`},
+	}
+	for _, test := range tests {
+		var want interface{}
+		if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if got := irQuery(t, ir, test.query)[test.key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %s of %s to be %s, got %v", test.key, test.query, test.want, got)
+		}
+	}
+}
+
+// TestIRTemplates checks that the IR of every command is what the templates
+// render.
+func TestIRTemplates(t *testing.T) {
+	LoadTemplates()
+	nf := ParseData([]byte(irInput), []Source{{"<input>", 0}}, ParseOptions{})
+	ir := BuildIR(nf)
+	for ix, cmd := range nf.Cmds {
+		c := cmd.Base().IRCommand
+		q := ir.Queries[ix].IRCommand
+		q.Inputs, q.Outputs = c.Inputs, c.Outputs
+		if !reflect.DeepEqual(q, c) {
+			t.Errorf("The IR of %s differs from the command:\n%+v\n%+v", c.FuncName, q, c)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"fmt"
+	"text/template"
+)
+
+const keyTypes = `{{range .}}
+// {{.Name}} is the key taken by the queries declared with !key {{.Name}}.
+type {{.Name}} struct {
+{{getStructSig .Fields}}
+}
+{{end}}`
+
+var keyTypesTmpl *template.Template
+
+// keyType is a struct grouping inputs, declared with !key.
+type keyType struct {
+	Name   string
+	Fields []arg
+}
+
+// isKeyInput reports whether the input named name is a field of the key of
+// c.
+func (c *cmdBase) isKeyInput(name string) bool {
+	return c.Key != "" && containsString(c.KeyInputs, name)
+}
+
+// Params returns the parameters of the generated functions: the inputs, with
+// the inputs grouped by !key replaced by a key parameter where the first of
+// them is, and without those read from the context. Commands read at a point
+// in time take the time last, see !as_of.
+func (c *cmdBase) Params() []arg {
+	var ret []arg
+	seen := false
+	for _, inp := range c.Inputs {
+		if c.isContextInput(inp.Name) {
+			continue
+		}
+		if !c.isKeyInput(inp.Name) {
+			ret = append(ret, inp)
+			continue
+		}
+		if !seen {
+			ret = append(ret, arg{Name: "key", Typ: c.Key})
+			seen = true
+		}
+	}
+	if c.AsOf != "" {
+		ret = append(ret, arg{Name: "asOf", Typ: "time.Time"})
+	}
+	return ret
+}
+
+// InputExpr returns the expression of the value of the input named name in
+// the generated functions.
+func (c *cmdBase) InputExpr(name string) string {
+	if c.isKeyInput(name) {
+		return "key." + FieldName(name)
+	}
+	return name
+}
+
+// resolveKeys checks the !key directives of the commands of nf and returns
+// the key structs to generate. All inputs are grouped if none are listed.
+// Commands naming the same key must group inputs of the same names and types.
+func resolveKeys(nf *NormFile) []keyType {
+	var ret []keyType
+	byName := map[string]int{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if c.Key == "" {
+			continue
+		}
+		if TakesRows(cmd) {
+			panic(fmt.Sprintf("!key of %s on line %d: %s commands take rows instead", c.FuncName, c.Line, cmd.Kind()))
+		}
+		if len(c.KeyInputs) == 0 {
+			for _, inp := range c.Inputs {
+				if !c.isContextInput(inp.Name) {
+					c.KeyInputs = append(c.KeyInputs, inp.Name)
+				}
+			}
+		}
+		var fields []arg
+		for _, name := range c.KeyInputs {
+			ix := inputIndex(c.Inputs, name)
+			if ix < 0 || c.isContextInput(name) {
+				panic(fmt.Sprintf("!key of %s on line %d: unknown input %s", c.FuncName, c.Line, name))
+			}
+			fields = append(fields, arg{Name: FieldName(name), Typ: c.Inputs[ix].Typ})
+		}
+		if inputIndex(c.Inputs, "key") >= 0 {
+			panic(fmt.Sprintf("!key of %s on line %d: an input is already named key", c.FuncName, c.Line))
+		}
+		if ix, ok := byName[c.Key]; ok {
+			if getStructSig(ret[ix].Fields) != getStructSig(fields) {
+				panic(fmt.Sprintf("!key of %s on line %d: %s has other fields in another query", c.FuncName, c.Line, c.Key))
+			}
+			continue
+		}
+		byName[c.Key] = len(ret)
+		ret = append(ret, keyType{c.Key, fields})
+	}
+	return ret
+}
@@ -0,0 +1,138 @@
+package core
+
+import "text/template"
+
+const concurrencyLimit = `
+// ErrConcurrencyLimit is returned by queries with a !max_concurrency nowait
+// limit when the limit is reached.
+var ErrConcurrencyLimit = errors.New("norm: concurrency limit reached")
+
+// acquire takes a slot of sem. Unless nowait is set, it waits for a free slot
+// until ctx is done.
+func acquire(ctx context.Context, sem chan struct{}, nowait bool) error {
+	if nowait {
+		select {
+		case sem <- struct{}{}:
+			return nil
+		default:
+			return ErrConcurrencyLimit
+		}
+	}
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+`
+
+var concurrencyLimitTmpl *template.Template
+
+const rateLimit = `
+// Limiter limits the rate of the queries of a group, see Limiters. Wait
+// blocks until a query may run, or returns an error if ctx is done first.
+// NewTokenBucket returns one, and *rate.Limiter of golang.org/x/time/rate
+// implements it too.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Limiters are the limiters of the query groups declared with !limit_group,
+// by group name. They are set by the program before running queries. The
+// queries of a group without a limiter are not limited.
+var Limiters = map[string]Limiter{}
+
+// tokenBucket is the Limiter returned by NewTokenBucket.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a Limiter letting perSecond queries run per second
+// on average, and bursts of up to burst queries at once.
+func NewTokenBucket(perSecond float64, burst int) Limiter {
+	return &tokenBucket{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait takes a token, waiting for it to be refilled if there is none left.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// The token was taken ahead of time, and is given back.
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+`
+
+var rateLimitTmpl *template.Template
+
+// acquireSlot is the start of the generated functions of commands with a
+// !max_concurrency limit. The slot is released when the function returns.
+// "limit" waits for the Limiter of the group of commands with a !limit_group.
+const acquireSlot = `
+{{- define "acquire"}}
+{{- if .MaxConcurrency}}
+	if err := acquire(ctx, sem{{.FuncName}}, {{.NoWait}}); err != nil {
+		return {{.ErrReturn}}
+	}
+	defer func() { <-sem{{.FuncName}} }()
+{{- end}}
+{{- end}}
+{{- define "limit"}}
+{{- if .LimitGroup}}
+	if l := Limiters["{{.LimitGroup}}"]; l != nil {
+		if err := l.Wait(ctx); err != nil {
+			return {{.ErrReturn}}
+		}
+	}
+{{- end}}
+{{- end}}
+{{- define "semaphore"}}
+{{- if .MaxConcurrency}}
+var sem{{.FuncName}} = make(chan struct{}, {{.MaxConcurrency}})
+{{end}}
+{{- end}}`
+
+// hasLimits reports whether any command of nf has a !max_concurrency limit.
+func (nf *NormFile) hasLimits() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().MaxConcurrency > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLimitGroups reports whether any command of nf has a !limit_group.
+func (nf *NormFile) hasLimitGroups() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().LimitGroup != "" {
+			return true
+		}
+	}
+	return false
+}
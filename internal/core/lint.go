@@ -0,0 +1,100 @@
+package core
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// predeclared are the predeclared identifiers of Go, which inputs named like
+// them shadow in the generated functions.
+var predeclared = map[string]bool{
+	"bool": true, "byte": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "rune": true, "string": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"uintptr": true, "true": true, "false": true, "iota": true, "nil": true,
+	"append": true, "cap": true, "close": true, "complex": true, "copy": true,
+	"delete": true, "imag": true, "len": true, "make": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true, "recover": true,
+}
+
+// generatedNames are the receiver, parameters and variables of the generated
+// functions, which inputs named like them collide with.
+var generatedNames = map[string]bool{
+	"n": true, "ctx": true, "o": true, "other": true, "dst": true, "err": true,
+	"rows": true, "row": true, "result": true, "stmt": true, "cancel": true,
+	"start": true, "r": true, "opts": true, "call": true, "ret": true,
+}
+
+// lint checks the definitions of the commands of nf for the mistakes the
+// generation lets through, which only show up when the generated code is
+// compiled or run: empty queries, inputs named like Go keywords or
+// identifiers, outputs scanned from a column named like another output, and
+// commands without a !doc line. Inputs never used and outputs never scanned
+// by their count are reported by Analyze.
+func lint(nf *NormFile) []Warning {
+	var ret []Warning
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		warn := func(format string, args ...interface{}) {
+			ret = append(ret, Warning{c.Line, c.FuncName + ": " + fmt.Sprintf(format, args...)})
+		}
+		if strings.TrimSpace(c.BodyString()) == "" {
+			warn("query is empty")
+		}
+		if len(c.Doc) == 0 {
+			warn("no !doc line documents the command")
+		}
+		for _, inp := range c.Inputs {
+			switch {
+			case token.Lookup(inp.Name).IsKeyword():
+				warn("input %s is a Go keyword, which does not compile as a parameter", inp.Name)
+			case predeclared[inp.Name]:
+				warn("input %s shadows the predeclared Go identifier %s", inp.Name, inp.Name)
+			case generatedNames[inp.Name]:
+				warn("input %s collides with %s in the generated code", inp.Name, inp.Name)
+			}
+		}
+		switch cmd.(type) {
+		case *cmdExec, *cmdExecMany, *cmdCopy, *cmdScript:
+			continue
+		}
+		for _, msg := range checkScanned(c) {
+			ret = append(ret, Warning{c.Line, c.FuncName + ": " + msg})
+		}
+	}
+	return ret
+}
+
+// checkScanned compares the outputs of c with the names of the columns of
+// its SELECT or RETURNING list. Outputs are scanned from the columns in
+// order, so an output named like another column than the one it is scanned
+// from is most likely declared out of order.
+func checkScanned(c *cmdBase) []string {
+	cols := selectList(tokenize(c.BodyString()))
+	if len(cols) != len(c.Outputs) {
+		return nil
+	}
+	names := make([]string, len(cols))
+	for ix, expr := range cols {
+		name, _ := columnName(expr)
+		if name == "" {
+			return nil
+		}
+		names[ix] = name
+	}
+	var ret []string
+	for ix, out := range c.Outputs {
+		if strings.EqualFold(out.Name, FieldName(names[ix])) {
+			continue
+		}
+		for jx, name := range names {
+			if strings.EqualFold(out.Name, FieldName(name)) {
+				ret = append(ret, fmt.Sprintf("output %s is scanned from column %d, %s, but is named like column %d, %s", out.Name, ix+1, names[ix], jx+1, name))
+				break
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	const header = "-- !norm\n-- !package store\n\n"
+	tests := []struct {
+		name  string
+		input string
+		want  []Warning
+	}{
+		{
+			"clean",
+			"-- !read GetUsers\n-- !input domain string\n-- !output ID int\n-- !output Email string\n-- !doc Lists users.\nSELECT id, email AS Email FROM users WHERE domain = $1\n",
+			nil,
+		},
+		{
+			"empty body",
+			"-- !exec Nothing\n-- !doc Does nothing.\n\n-- !exec Other\n-- !doc Deletes x.\nDELETE FROM x\n",
+			[]Warning{{4, "Nothing: query is empty"}},
+		},
+		{
+			"missing doc",
+			"-- !exec DeleteUsers\nDELETE FROM users\n",
+			[]Warning{{4, "DeleteUsers: no !doc line documents the command"}},
+		},
+		{
+			"keyword",
+			"-- !exec DeleteUsers\n-- !input type string\n-- !doc Deletes users.\nDELETE FROM users WHERE type = $1\n",
+			[]Warning{{4, "DeleteUsers: input type is a Go keyword, which does not compile as a parameter"}},
+		},
+		{
+			"predeclared name",
+			"-- !exec DeleteUsers\n-- !input len int\n-- !input string string\n-- !doc Deletes users.\nDELETE FROM users WHERE len = $1 AND s = $2\n",
+			[]Warning{
+				{4, "DeleteUsers: input len shadows the predeclared Go identifier len"},
+				{4, "DeleteUsers: input string shadows the predeclared Go identifier string"},
+			},
+		},
+		{
+			"generated name",
+			"-- !read_one GetUser\n-- !input rows int\n-- !output ID int\n-- !doc Gets a user.\nSELECT id FROM users WHERE rows = $1\n",
+			[]Warning{{4, "GetUser: input rows collides with rows in the generated code"}},
+		},
+		{
+			"outputs never scanned",
+			"-- !read GetUsers\n-- !output Email string\n-- !output ID int\n-- !doc Lists users.\nSELECT id, email FROM users\n",
+			[]Warning{
+				{4, "GetUsers: output Email is scanned from column 1, id, but is named like column 2, email"},
+				{4, "GetUsers: output ID is scanned from column 2, email, but is named like column 1, id"},
+			},
+		},
+		{
+			"outputs scanned from aliases",
+			"-- !exec_returning AddUser\n-- !input email string\n-- !output Email string\n-- !output ID int\n-- !doc Adds a user.\nINSERT INTO users (email) VALUES ($1) RETURNING id AS email, email AS id\n",
+			nil,
+		},
+		{
+			"outputs of another count",
+			"-- !read GetUsers\n-- !output Email string\n-- !doc Lists users.\nSELECT id, email FROM users\n",
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nf := ParseData([]byte(header+test.input), []Source{{"<input>", 0}}, ParseOptions{})
+			if got := lint(nf); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mapperRow is the struct a row is scanned into before the function named by
+// !mapper builds the model from it.
+const mapperRow = `
+{{- define "mapperRow"}}
+{{- if .Mapper}}
+// {{.FuncName}}Row holds the columns of a row of {{.FuncName}}, from which
+// {{.Mapper}} builds the {{.ResultType}}.
+type {{.FuncName}}Row struct {
+{{getStructSig .Outputs}}
+}
+{{end}}
+{{- end}}`
+
+// IntoArgs returns the Scan destinations of the Into function generated for a
+// command reading one row: the fields of the row given to the mapper, or dst.
+func (c *cmdBase) IntoArgs() string {
+	if c.Mapper == "" {
+		return c.ScanPtrArgs("dst")
+	}
+	var dests []string
+	for _, o := range c.Outputs {
+		dests = append(dests, c.scanDest(o.Name, "&row."+o.Name))
+	}
+	return strings.Join(dests, ", ")
+}
+
+// checkMappers checks that the commands of nf with a !mapper read rows into a
+// model. The mapper is a func(<FuncName>Row) (<Model>, error), qualified by
+// the name of an imported package if it is not in the generated one.
+func checkMappers(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if c.Mapper == "" {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne, *cmdExecReturning:
+		default:
+			panic(fmt.Sprintf("!mapper of %s on line %d: %s commands cannot have a mapper", c.FuncName, c.Line, cmd.Kind()))
+		}
+		if c.Model == "" {
+			panic(fmt.Sprintf("!mapper of %s on line %d: needs a !model for the mapper to build", c.FuncName, c.Line))
+		}
+		if c.ModelGen {
+			panic(fmt.Sprintf("!mapper of %s on line %d: the model of !model_gen is read without a mapper", c.FuncName, c.Line))
+		}
+	}
+}
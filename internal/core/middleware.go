@@ -0,0 +1,92 @@
+package core
+
+import (
+	"strings"
+	"text/template"
+)
+
+const middleware = `
+// Call is a call of a query method of Norm, as seen by Middleware.
+type Call struct {
+	// Name is the name of the query, like the one of its method.
+	Name string
+	// Args are the inputs of the call, or the rows of !exec_many queries.
+	// The values of sensitive inputs are Redacted, see !sensitive. Changing
+	// them does not change the call.
+	Args []interface{}
+}
+
+// Redacted replaces the value of a sensitive input in Call.Args, so that
+// middleware logging or tracing calls cannot leak it.
+type Redacted struct{}
+
+func (Redacted) String() string {
+	return "[REDACTED]"
+}
+
+// Exec runs a call, see Middleware.
+type Exec func(ctx context.Context, call *Call) error
+
+// Middleware wraps the Exec running the calls of a Norm in another one, to
+// run code around every call, such as auth checks, rate limiting or fault
+// injection. The returned Exec fails the call by returning an error without
+// running next.
+type Middleware func(next Exec) Exec
+
+// Use returns a Norm running its calls through the middleware of n, then mw
+// in order, so that the first Middleware is the outermost. Transactions
+// started from it, see WithTx and Begin, keep the middleware.
+func (n *Norm) Use(mw ...Middleware) *Norm {
+	ret := *n
+	ret.middleware = append(append([]Middleware(nil), n.middleware...), mw...)
+	return &ret
+}
+
+// run runs call through the middleware of n, ending with fn.
+func (n *Norm) run(ctx context.Context, call *Call, fn func(ctx context.Context) error) error {
+	exec := Exec(func(ctx context.Context, call *Call) error {
+		return fn(ctx)
+	})
+	for ix := len(n.middleware) - 1; ix >= 0; ix-- {
+		exec = n.middleware[ix](exec)
+	}
+	return exec(ctx, call)
+}
+
+// bare returns n without its middleware, to run the query of a call once the
+// middleware let it through.
+func (n *Norm) bare() *Norm {
+	ret := *n
+	ret.middleware = nil
+	return &ret
+}
+`
+
+var middlewareTmpl *template.Template
+
+// CallArgs returns the Args of the Call of c given to its middleware, or ""
+// if c has no inputs. Parameters holding a sensitive input are Redacted.
+func (c *cmdBase) CallArgs() string {
+	params := c.Params()
+	if len(params) == 0 {
+		return ""
+	}
+	var args []string
+	for _, p := range params {
+		if c.hasSensitiveArg(p) {
+			args = append(args, "Redacted{}")
+		} else {
+			args = append(args, p.Name)
+		}
+	}
+	return "[]interface{}{" + strings.Join(args, ", ") + "}"
+}
+
+// RowsCallArg returns the Args of the Call of the rows of an !exec_many or
+// !copy command, which are Redacted if any of their fields is sensitive.
+func (c *cmdBase) RowsCallArg() string {
+	if len(c.Sensitive) > 0 {
+		return "Redacted{}"
+	}
+	return "rows"
+}
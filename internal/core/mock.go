@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"go/format"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"text/template"
+)
+
+const mock = `// Code generated by norm. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .Imports}}	{{.}}
+{{end}})
+
+// MockQuerier implements Querier with a function field for every method, for
+// tests that do not use a database. Calling a method whose function is not set
+// panics.
+type MockQuerier struct {
+{{- range .Methods}}
+	{{.Name}}Func func{{.Sig}}
+{{- end}}
+}
+
+var _ Querier = (*MockQuerier)(nil)
+{{range .Methods}}
+func (m *MockQuerier) {{.Name}}{{.Sig}} {
+	if m.{{.Name}}Func == nil {
+		panic("MockQuerier.{{.Name}}Func is not set")
+	}
+	{{if .Results}}return {{end}}m.{{.Name}}Func({{join .Args ", "}})
+}
+{{end}}`
+
+var mockTmpl *template.Template
+
+// MockFileName returns the name of the mock file, next to the output file.
+func MockFileName(outFile string) string {
+	return filepath.Join(filepath.Dir(outFile), "norm_mock.go")
+}
+
+// GenerateMock returns a file with a mock implementation of the Querier
+// interface in the generated source src.
+func GenerateMock(nf *NormFile, src []byte) []byte {
+	f, methods := querierMethods(src)
+	var body bytes.Buffer
+	for _, m := range methods {
+		body.WriteString(m.Sig + "\n")
+	}
+	// Only the imports used by the method signatures are needed.
+	var imports []string
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := packageName(path)
+		spec := imp.Path.Value
+		if imp.Name != nil {
+			name = imp.Name.Name
+			spec = name + " " + spec
+		}
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\.`).Match(body.Bytes()) {
+			imports = append(imports, spec)
+		}
+	}
+	var bb bytes.Buffer
+	if err := mockTmpl.Execute(&bb, map[string]interface{}{
+		"Package": nf.Package,
+		"Imports": imports,
+		"Methods": methods,
+	}); err != nil {
+		panic(err)
+	}
+	formatted, err := format.Source(bb.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return formatted
+}
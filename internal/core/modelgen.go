@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const modelTypes = `{{range .}}
+// {{.Name}} is the model of the queries declared with !model {{.Name}} and
+// !model_gen.
+type {{.Name}} struct {
+{{getTaggedStructSig .Fields .Tags .TagCase}}
+}
+{{end}}`
+
+var modelTypesTmpl *template.Template
+
+const modelFile = `// Code generated by norm. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .Imports}}	{{.}}
+{{end}})
+{{template "model_types" .Models}}`
+
+var modelFileTmpl *template.Template
+
+// modelType is a model struct generated from the outputs of the queries
+// reading into it, declared with !model_gen.
+type modelType struct {
+	Name    string
+	Fields  []arg
+	Tags    []string
+	TagCase string
+}
+
+// resolveModels checks the !model_gen directives of the commands of nf and
+// returns the models to generate. The fields are the outputs of the command,
+// or the inputs of commands taking rows. Commands generating the same model
+// must have fields of the same names, types and tags.
+func resolveModels(nf *NormFile) []modelType {
+	var ret []modelType
+	byName := map[string]int{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if !c.ModelGen {
+			continue
+		}
+		if _, ok := cmd.(*cmdView); ok {
+			panic(fmt.Sprintf("!model_gen of %s on line %d: views always generate their model", c.FuncName, c.Line))
+		}
+		if c.Model == "" {
+			panic(fmt.Sprintf("!model_gen of %s on line %d: needs a !model naming the struct", c.FuncName, c.Line))
+		}
+		if strings.Contains(c.Model, ".") {
+			panic(fmt.Sprintf("!model_gen of %s on line %d: %s is in another package, use !model_pkg instead", c.FuncName, c.Line, c.Model))
+		}
+		if nf.ModelPkg != "" && nf.ModelFile == "" {
+			panic(fmt.Sprintf("!model_gen of %s on line %d: models of !model_pkg need a !model_file to be written to", c.FuncName, c.Line))
+		}
+		var fields []arg
+		if TakesRows(cmd) {
+			for _, inp := range c.Inputs {
+				fields = append(fields, arg{Name: FieldName(inp.Name), Typ: inp.Typ})
+			}
+		} else {
+			fields = append(fields, c.Outputs...)
+		}
+		m := modelType{c.Model, fields, c.Tags, c.TagCase}
+		if ix, ok := byName[c.Model]; ok {
+			if ret[ix].sig() != m.sig() {
+				panic(fmt.Sprintf("!model_gen of %s on line %d: %s has other fields in another query", c.FuncName, c.Line, c.Model))
+			}
+			continue
+		}
+		byName[c.Model] = len(ret)
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// sig returns the fields of m as written in the generated struct.
+func (m modelType) sig() string {
+	return getTaggedStructSig(m.Fields, m.Tags, m.TagCase)
+}
+
+// GenerateModels returns the file of the models of nf declared in the
+// package of !model_pkg, written to !model_file. It imports database/sql,
+// time and the imports of nf used by the types of the fields. Types generated
+// by norm cannot be used, as the generated package imports the models.
+func GenerateModels(nf *NormFile) []byte {
+	var sigs bytes.Buffer
+	for _, m := range nf.Models {
+		sigs.WriteString(getStructSig(m.Fields) + "\n")
+	}
+	var imports []string
+	for _, spec := range append([]string{`"database/sql"`, `"time"`}, nf.Imports...) {
+		alias, quoted := "", spec
+		if ix := strings.Index(spec, " "); ix >= 0 {
+			alias, quoted = spec[:ix], spec[ix+1:]
+		}
+		importPath, err := strconv.Unquote(quoted)
+		if err != nil || importPath == nf.ModelPkg {
+			continue
+		}
+		name := alias
+		if name == "" {
+			name = path.Base(importPath)
+		}
+		if regexp.MustCompile(`\b`+regexp.QuoteMeta(name)+`\.`).Match(sigs.Bytes()) && !containsString(imports, spec) {
+			imports = append(imports, spec)
+		}
+	}
+	var bb bytes.Buffer
+	if err := modelFileTmpl.Execute(&bb, map[string]interface{}{
+		"Package": packageName(nf.ModelPkg),
+		"Imports": imports,
+		"Models":  nf.Models,
+	}); err != nil {
+		panic(err)
+	}
+	formatted, err := format.Source(bb.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return formatted
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bindNamedInputs replaces the :name placeholders in the bodies of the
+// commands of nf with the placeholder of the input with that name: $n, or ?
+// for the mysql !dialect. ? placeholders are bound in order, so when inputs
+// are used more than once or out of order, the Args of the command list the
+// input of every placeholder, which the arguments of the query follow. sqlite
+// numbers $n placeholders in the order they first appear in rather than by
+// n, so the sqlite !dialect also takes ? placeholders when the inputs first
+// appear out of order. The bodies of !script commands, which take no inputs,
+// are left as they are.
+func bindNamedInputs(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		if _, ok := cmd.(*cmdScript); ok {
+			continue
+		}
+		c := cmd.Base()
+		body := c.BodyString()
+		var parts []string
+		var args []string
+		last := 0
+		for _, t := range tokenize(body) {
+			if t.Kind != tokPlaceholder || t.Text[0] != ':' {
+				continue
+			}
+			ix := inputIndex(c.Inputs, t.Text[1:])
+			if ix < 0 {
+				panic(fmt.Sprintf("Unknown input %s in the query on line %d", t.Text, c.Line))
+			}
+			parts = append(parts, body[last:t.Pos])
+			args = append(args, c.Inputs[ix].Name)
+			last = t.Pos + len(t.Text)
+		}
+		if last == 0 {
+			continue
+		}
+		parts = append(parts, body[last:])
+		question := nf.Dialect == "mysql" || nf.Dialect == "sqlite" && !firstInOrder(c, args)
+		var bb strings.Builder
+		for ix, name := range args {
+			bb.WriteString(parts[ix])
+			if question {
+				bb.WriteString("?")
+			} else {
+				fmt.Fprintf(&bb, "$%d", inputIndex(c.Inputs, name)+1)
+			}
+		}
+		bb.WriteString(parts[len(args)])
+		c.Body = strings.Split(bb.String(), "\n")
+		if !question {
+			continue
+		}
+		for _, inp := range c.Inputs {
+			if !containsString(args, inp.Name) {
+				panic(fmt.Sprintf("Input %s is not used in the query on line %d", inp.Name, c.Line))
+			}
+		}
+		inOrder := len(args) == len(c.Inputs)
+		for ix := 0; inOrder && ix < len(args); ix++ {
+			inOrder = args[ix] == c.Inputs[ix].Name
+		}
+		if inOrder {
+			continue
+		}
+		if c.HasSliceInputs() {
+			panic(fmt.Sprintf("Slice inputs of the query on line %d: %s binds ? placeholders in order, so with slice inputs every input must be used once, in the order of the inputs", c.Line, nf.Dialect))
+		}
+		c.Args = args
+	}
+}
+
+// firstInOrder reports whether the inputs of c first appear in args, the
+// inputs of the placeholders of its query, in the order of the inputs.
+func firstInOrder(c *cmdBase, args []string) bool {
+	next := 0
+	for _, name := range args {
+		ix := inputIndex(c.Inputs, name)
+		if ix > next {
+			return false
+		}
+		if ix == next {
+			next++
+		}
+	}
+	return true
+}
+
+// ArgInputs returns the names of the inputs bound to the placeholders of the
+// query, in order: the Args of the command, or its inputs.
+func (c *cmdBase) ArgInputs() []string {
+	if c.Args != nil {
+		return c.Args
+	}
+	var ret []string
+	for _, inp := range c.Inputs {
+		ret = append(ret, inp.Name)
+	}
+	return ret
+}
+
+func inputIndex(inputs []arg, name string) int {
+	for ix, inp := range inputs {
+		if inp.Name == name {
+			return ix
+		}
+	}
+	return -1
+}
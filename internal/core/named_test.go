@@ -0,0 +1,177 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBindNamedInputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "postgres",
+			input: `-- !read_one GetUser
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE email = :email OR name = :name OR alias = :email
+`,
+			want: "SELECT id FROM users WHERE email = $1 OR name = $2 OR alias = $1",
+		},
+		{
+			name: "mysql",
+			input: `-- !dialect mysql
+-- !read_one GetUser
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE email = :email OR name = :name
+`,
+			want: "SELECT id FROM users WHERE email = ? OR name = ?",
+		},
+		{
+			name: "mysql out of order",
+			input: `-- !dialect mysql
+-- !read_one GetUser
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE name = :name OR email = :email
+`,
+			want: "SELECT id FROM users WHERE name = ? OR email = ?",
+		},
+		{
+			name: "mysql slice out of order",
+			input: `-- !dialect mysql
+-- !read GetUsers
+-- !input ids []int64
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE name = :name AND id IN (:ids)
+`,
+			wantErr: "<input>:3: Slice inputs of the query: mysql binds",
+		},
+		{
+			name: "sqlite",
+			input: `-- !dialect sqlite
+-- !read_one GetUser
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE email = :email OR name = :name OR alias = :email
+`,
+			want: "SELECT id FROM users WHERE email = $1 OR name = $2 OR alias = $1",
+		},
+		{
+			name: "sqlite out of order",
+			input: `-- !dialect sqlite
+-- !read_one GetUser
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE name = :name OR email = :email OR alias = :name
+`,
+			want: "SELECT id FROM users WHERE name = ? OR email = ? OR alias = ?",
+		},
+		{
+			name: "mysql unused",
+			input: `-- !dialect mysql
+-- !read_one GetUser
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE email = :email
+`,
+			wantErr: "<input>:3: Input name is not used in the query",
+		},
+		{
+			name: "script",
+			input: `-- !script Setup
+CREATE TABLE t (x TEXT DEFAULT ':none');
+SELECT :none;
+-- !end
+`,
+			want: "CREATE TABLE t (x TEXT DEFAULT ':none');\nSELECT :none;",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseBody(test.input)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestBindNamedInputsMySQLArgs(t *testing.T) {
+	input := `-- !norm
+-- !dialect mysql
+
+-- !read_one GetUser
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE name = :name OR email = :email OR alias = :name
+
+-- !exec_many AddUsers
+-- !input email string
+-- !input name string
+INSERT INTO users (name, email, alias) VALUES (:name, :email, :name)
+
+-- !read_one GetUserInOrder
+-- !input email string
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE email = :email OR name = :name
+`
+	nf := ParseData([]byte(input), []Source{{"<input>", 0}}, ParseOptions{})
+	if got := ParseData([]byte(strings.Replace(input, "mysql", "sqlite", 1)), []Source{{"<input>", 0}}, ParseOptions{}).Cmds[0].Base().QueryArgs(); got != ", name, email, name" {
+		t.Errorf("Expected the sqlite arguments of GetUser in placeholder order, got %q", got)
+	}
+	get, many, inOrder := nf.Cmds[0].Base(), nf.Cmds[1].(*cmdExecMany), nf.Cmds[2].Base()
+	if got, want := get.BodyString(), "SELECT id FROM users WHERE name = ? OR email = ? OR alias = ?"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got, want := get.QueryArgs(), ", name, email, name"; got != want {
+		t.Errorf("Expected the arguments %q of GetUser, got %q", want, got)
+	}
+	if got, want := many.RowArgs("row"), ", row.Name, row.Email, row.Name"; got != want {
+		t.Errorf("Expected the arguments %q of AddUsers, got %q", want, got)
+	}
+	if inOrder.Args != nil {
+		t.Errorf("Expected no Args for inputs used in order, got %v", inOrder.Args)
+	}
+	if got, want := inOrder.QueryArgs(), ", email, name"; got != want {
+		t.Errorf("Expected the arguments %q of GetUserInOrder, got %q", want, got)
+	}
+}
+
+// parseBody parses input, after a -- !norm line, and returns the body of its first command, or the
+// error the parsing panicked with.
+func parseBody(input string) (body string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+	nf := ParseData([]byte("-- !norm\n"+input), []Source{{"<input>", 0}}, ParseOptions{})
+	return nf.Cmds[0].Base().BodyString(), nil
+}
@@ -0,0 +1,2510 @@
+// Package core parses norm files and generates Go code from them. It is
+// shared by the norm command and by the parser and codegen packages, and does
+// not run other programs, so that the library does not need them.
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const header = `// Code generated by norm. DO NOT EDIT.
+{{if .date}}// Generated on: {{.date}}
+{{end -}}
+package {{.package}}
+
+import (
+	"context"
+	"database/sql"
+	{{.imports}}
+)
+`
+
+var headerTmpl *template.Template
+
+const normType = `
+// DBTX is implemented by both *sql.DB and *sql.Tx, so that the queries of a
+// Norm can run inside a transaction.
+type DBTX interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Norm runs the queries of the norm file against a database, or inside a
+// transaction, see WithTx.
+type Norm struct {
+	db    DBTX
+	stmts *stmtCache
+	// replica runs the reads, see WithReplica.
+	replica    *Norm
+	middleware []Middleware
+}
+
+// NewNorm opens the database with {{if .}}Open{{else}}sql.Open{{end}} and returns a Norm using it.
+func NewNorm(driverName, dataSourceName string) (*Norm, error) {
+	db, err := {{if .}}Open{{else}}sql.Open{{end}}(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &Norm{db: db, stmts: newStmtCache(db)}, nil
+}
+
+// NewNormFromDB returns a Norm using a database handle opened elsewhere, for
+// example with custom pool settings. Closing the Norm closes db.
+func NewNormFromDB(db *sql.DB) *Norm {
+	return &Norm{db: db, stmts: newStmtCache(db)}
+}
+
+// WithTx returns a Norm running its queries inside tx. Committing or rolling
+// back tx is up to the caller.
+func (n *Norm) WithTx(tx *sql.Tx) *Norm {
+	return n.withDB(tx)
+}
+
+// withDB returns a Norm running the queries of n on db, with the same
+// statements and middleware.
+func (n *Norm) withDB(db DBTX) *Norm {
+	return &Norm{db: db, stmts: n.stmts, middleware: n.middleware}
+}
+
+// NormTx is a transaction started with Begin. It has the query methods of
+// Norm, which run inside the transaction.
+type NormTx struct {
+	*Norm
+	tx *sql.Tx
+}
+
+// Begin starts a transaction. It returns an error for a Norm that already runs
+// inside a transaction.
+func (n *Norm) Begin(ctx context.Context) (*NormTx, error) {
+	db, ok := n.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("norm: Begin called inside a transaction")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &NormTx{Norm: n.withDB(tx), tx: tx}, nil
+}
+
+// Commit commits the transaction.
+func (t *NormTx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction.
+func (t *NormTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// normContextKey is the key of the Norm carried by a context, see NewContext.
+type normContextKey struct{}
+
+// NewContext returns a copy of ctx carrying n, for middleware handing a
+// request scoped Norm, like one running inside the transaction of the
+// request, to the handlers, which get it back with FromContext.
+func NewContext(ctx context.Context, n *Norm) context.Context {
+	return context.WithValue(ctx, normContextKey{}, n)
+}
+
+// FromContext returns the Norm carried by ctx, and whether it carries one.
+func FromContext(ctx context.Context) (*Norm, bool) {
+	n, ok := ctx.Value(normContextKey{}).(*Norm)
+	return n, ok
+}
+
+// Close closes the prepared statements and the database of n. It does nothing
+// for a Norm returned by WithTx.
+func (n *Norm) Close() error {
+	if db, ok := n.db.(*sql.DB); ok {
+		if n.stmts != nil {
+			n.stmts.close()
+		}
+		return db.Close()
+	}
+	return nil
+}
+`
+
+var normTypeTmpl *template.Template
+
+const largeResult = `
+// LargeResultThreshold is the number of rows above which the slice returning
+// functions report the result through OnLargeResult. Set to 0 to disable.
+var LargeResultThreshold = {{.}}
+
+// OnLargeResult is called when a slice returning function reads more than
+// LargeResultThreshold rows. By default it logs a suggestion to use the
+// streaming Scan variant instead.
+var OnLargeResult = func(funcName string, rows int) {
+	log.Printf("norm: %s returned %d rows (threshold %d), consider using %sScan", funcName, rows, LargeResultThreshold, funcName)
+}
+`
+
+var largeResultTmpl *template.Template
+
+const columnCheck = `
+// CheckColumns enables comparing the columns returned by a query with its
+// declared outputs before scanning, so that a reordered SELECT list results
+// in an error instead of values in the wrong fields.
+var CheckColumns = true
+
+// checkColumns returns an error if the columns of rows do not match outputs.
+// Names are compared ignoring case and underscores.
+func checkColumns(funcName string, rows *sql.Rows, outputs ...string) error {
+	if !CheckColumns {
+		return nil
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	mismatch := len(cols) != len(outputs)
+	for ix := 0; !mismatch && ix < len(cols); ix++ {
+		mismatch = normalizeColumn(cols[ix]) != normalizeColumn(outputs[ix])
+	}
+	if mismatch {
+		return fmt.Errorf("%s: query returned columns %v, but outputs are %v", funcName, cols, outputs)
+	}
+	return nil
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+`
+
+var columnCheckTmpl *template.Template
+
+const deprecation = `
+// OnDeprecatedUse is called the first time a deprecated function is used, with
+// the deprecation message from the norm file.
+var OnDeprecatedUse func(funcName, msg string)
+
+func reportDeprecatedUse(once *sync.Once, funcName, msg string) {
+	if OnDeprecatedUse == nil {
+		return
+	}
+	once.Do(func() {
+		OnDeprecatedUse(funcName, msg)
+	})
+}
+`
+
+var deprecationTmpl *template.Template
+
+const readOne = `
+{{if and (not .Model) (gt (len .Outputs) 1)}}
+type {{.FuncName}}Output struct {
+{{getTaggedStructSig .Outputs .Tags .TagCase}}
+}
+{{end}}
+{{- template "mapperRow" .}}
+
+{{if .Deprecated}}
+var deprecated{{.FuncName}}Once sync.Once
+{{end}}
+{{- template "semaphore" .}}
+// {{.FuncName}}Into is like {{.FuncName}} but reads the row into dst instead of
+// allocating a new value. dst may be partially written if an error is returned.
+{{- if .Fallback}}
+// If the query fails, {{.Fallback}}Into is run instead when ShouldFallback
+// allows it, which Degraded reports.
+{{- end}}
+{{.DeprecatedDoc true -}}
+{{- if .Fallback -}}
+func (n *Norm) {{.FuncName}}Into(ctx context.Context, dst *{{.ResultType}}{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) error {
+	err := n.primary{{.FuncName}}Into(ctx, dst{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+	if err == nil || ctx.Err() != nil || !ShouldFallback(ctx, "{{.FuncName}}", err) {
+		return err
+	}
+	setDegraded(ctx)
+	return n.{{.Fallback}}Into(ctx, dst{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+}
+
+func (n *Norm) primary{{.FuncName}}Into(ctx context.Context, dst *{{.ResultType}}{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) error {
+{{- else -}}
+func (n *Norm) {{.FuncName}}Into(ctx context.Context, dst *{{.ResultType}}{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) error {
+{{- end}}
+	if n.middleware != nil {
+		return n.run(ctx, &Call{Name: "{{.FuncName}}"{{with .CallArgs}}, Args: {{.}}{{end}}}, func(ctx context.Context) error {
+			return n.bare().{{if .Fallback}}primary{{end}}{{.FuncName}}Into(ctx, dst{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+		})
+	}
+	call := applyOptions(opts)
+{{- if .Reads}}
+	n = n.reader(call)
+{{- end}}
+{{- if .ReadOnly}}
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		// The transaction is only there to reject writes, there is nothing
+		// to commit.
+		defer tx.Rollback()
+		return (&Norm{db: tx, stmts: n.stmts}).{{if .Fallback}}primary{{end}}{{.FuncName}}Into(ctx, dst{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+	}
+{{- end}}
+{{- template "timeout"}}
+{{- if .Deprecated}}
+	reportDeprecatedUse(&deprecated{{.FuncName}}Once, "{{.FuncName}}", {{printf "%q" .Deprecated}})
+{{- end}}
+{{- template "count" .}}
+{{- template "contextInputs" .}}
+{{- template "limit" .}}
+{{- template "acquire" .}}
+{{- template "expand" .}}
+{{- if not .NoPrepare}}
+	stmt, release, err := n.prepare(ctx, {{.Query}}, {{.Reuse}})
+	if err != nil {
+		return err
+	}
+	defer release()
+{{- end}}
+{{- if .Mapper}}
+	var row {{.FuncName}}Row
+{{- end}}
+{{- if .CheckColumns}}
+	rows, err := {{.Runner}}.QueryContext(ctx{{.RunArgs}})
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err = checkColumns("{{.FuncName}}", rows, {{.OutputNames}}); err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err = rows.Scan({{.IntoArgs}}); err != nil {
+		return err
+	}
+{{- if .Mapper}}
+	if *dst, err = {{.Mapper}}(row); err != nil {
+		return err
+	}
+{{- end}}
+	return rows.Close()
+{{- else if .Mapper}}
+	if err := {{.Runner}}.QueryRowContext(ctx{{.RunArgs}}).Scan({{.IntoArgs}}); err != nil {
+		return err
+	}
+	v, err := {{.Mapper}}(row)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+{{- else}}
+	return {{.Runner}}.QueryRowContext(ctx{{.RunArgs}}).Scan({{.ScanPtrArgs "dst"}})
+{{- end}}
+}
+
+{{range .Doc}}// {{print .}}
+{{end -}}
+{{.DeprecatedDoc (gt (len .Doc) 0) -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) (*{{.ResultType}}, error) {
+	var o {{.ResultType}}
+	if err := n.{{.FuncName}}Into(ctx, &o{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+`
+
+var readOneTmpl *template.Template
+
+const read = `
+type {{.FuncName}}Result struct {
+	release  func()
+	rows     *sql.Rows
+	deadline *resultDeadline
+}
+
+func (res {{.FuncName}}Result) Next() bool {
+	res.deadline.next()
+	return res.rows.Next()
+}
+
+func (res {{.FuncName}}Result) Scan({{getFuncSigWithTypePrefix .Outputs "*"}}) error {
+	return res.rows.Scan({{.ScanDests}})
+}
+
+// SetRowTimeout makes the result close its rows if Next is not called again
+// within d, after which Err returns ErrRowTimeout. A deadline for the whole
+// result is set on the context of {{.FuncName}}Scan instead.
+func (res {{.FuncName}}Result) SetRowTimeout(d time.Duration) {
+	res.deadline.timeout = d
+}
+
+// Err returns the error, if any, that stopped Next.
+func (res {{.FuncName}}Result) Err() error {
+	return res.deadline.err(res.rows.Err())
+}
+
+func (res {{.FuncName}}Result) Close() {
+	res.deadline.stop()
+	if (res.rows != nil) {
+		res.rows.Close()
+	}
+	if (res.release != nil) {
+		res.release()
+	}
+}
+
+{{if .Deprecated}}
+var deprecated{{.FuncName}}Once sync.Once
+{{end}}
+{{- template "semaphore" .}}
+{{range .Doc}}// {{print .}}
+{{end -}}
+{{.DeprecatedDoc (gt (len .Doc) 0) -}}
+func (n *Norm) {{.FuncName}}Scan(ctx context.Context{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) (*{{.FuncName}}Result, error) {
+	if n.middleware != nil {
+		var ret *{{.FuncName}}Result
+		err := n.run(ctx, &Call{Name: "{{.FuncName}}"{{with .CallArgs}}, Args: {{.}}{{end}}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().{{.FuncName}}Scan(ctx{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+			return err
+		})
+		return ret, err
+	}
+	call := applyOptions(opts)
+	n = n.reader(call)
+{{- if .ReadOnly}}
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		res, err := (&Norm{db: tx, stmts: n.stmts}).{{.FuncName}}Scan(ctx{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		// The transaction ends with the result, and has nothing to commit.
+		release := res.release
+		res.release = func() {
+			if release != nil {
+				release()
+			}
+			tx.Rollback()
+		}
+		return res, nil
+	}
+{{- end}}
+{{- if .Deprecated}}
+	reportDeprecatedUse(&deprecated{{.FuncName}}Once, "{{.FuncName}}", {{printf "%q" .Deprecated}})
+{{- end}}
+{{- template "count" .}}
+{{- template "contextInputs" .}}
+{{- template "limit" .}}
+{{- if .MaxConcurrency}}
+	if err := acquire(ctx, sem{{.FuncName}}, {{.NoWait}}); err != nil {
+		return nil, err
+	}
+{{- end}}
+	ctx, cancel := call.context(ctx)
+	result := {{.FuncName}}Result{deadline: &resultDeadline{cancel: cancel{{if .MaxConcurrency}}, sem: sem{{.FuncName}}{{end}}}}
+{{- template "expand" .}}
+	var err error
+{{- if not .NoPrepare}}
+	var stmt *sql.Stmt
+	stmt, result.release, err = n.prepare(ctx, {{.Query}}, {{.Reuse}})
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+{{- end}}
+	result.rows, err = {{.Runner}}.QueryContext(ctx{{.RunArgs}})
+	if err != nil {
+		result.Close()
+		return nil, err
+	}{{if .CheckColumns}}
+	if err = checkColumns("{{.FuncName}}", result.rows, {{.OutputNames}}); err != nil {
+		result.Close()
+		return nil, err
+	}{{end}}
+	return &result, nil
+}
+
+{{if and (not .Model) (gt (len .Outputs) 1)}}
+type {{.FuncName}}Output struct {
+{{getTaggedStructSig .Outputs .Tags .TagCase}}
+}
+{{end}}
+{{- template "mapperRow" .}}
+
+// Append{{.FuncName}} is like {{.FuncName}} but appends the rows to dst.
+// This allows reusing the same slice across calls.
+{{- if .Fallback}}
+// If the query fails, Append{{.Fallback}} is run instead when ShouldFallback
+// allows it, which Degraded reports. {{.FuncName}}Scan has no fallback.
+{{- end}}
+{{.DeprecatedDoc true -}}
+{{- if .Fallback -}}
+func (n *Norm) Append{{.FuncName}}(ctx context.Context, dst []{{.ResultType}}{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) ([]{{.ResultType}}, error) {
+	ret, err := n.primaryAppend{{.FuncName}}(ctx, dst{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+	if err == nil || ctx.Err() != nil || !ShouldFallback(ctx, "{{.FuncName}}", err) {
+		return ret, err
+	}
+	setDegraded(ctx)
+	return n.Append{{.Fallback}}(ctx, dst{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+}
+
+func (n *Norm) primaryAppend{{.FuncName}}(ctx context.Context, dst []{{.ResultType}}{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) ([]{{.ResultType}}, error) {
+{{- else -}}
+func (n *Norm) Append{{.FuncName}}(ctx context.Context, dst []{{.ResultType}}{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) ([]{{.ResultType}}, error) {
+{{- end}}
+	res, err := n.{{.FuncName}}Scan(ctx{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+	if (err != nil) {
+		return dst, err
+	}
+	defer res.Close(){{if .LargeResult}}
+	start := len(dst){{end}}
+	for res.Next() {
+{{- if .Mapper}}
+		var row {{.FuncName}}Row
+		if err := res.Scan({{getCallSigWithPrefix .Outputs "&row."}}); err != nil {
+			return dst, err
+		}
+		o, err := {{.Mapper}}(row)
+		if err != nil {
+			return dst, err
+		}
+{{- else}}
+		var o {{.ResultType}}
+		if err := res.Scan({{.ScanArgs "o"}}); err != nil {
+			return dst, err
+		}
+{{- end}}
+		dst = append(dst, o)
+	}
+	if err := res.Err(); err != nil {
+		return dst, err
+	}{{if .LargeResult}}
+	if LargeResultThreshold > 0 && len(dst)-start > LargeResultThreshold && OnLargeResult != nil {
+		OnLargeResult("{{.FuncName}}", len(dst)-start)
+	}{{end}}
+	return dst, nil
+}
+
+{{.DeprecatedDoc false -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) ([]{{.ResultType}}, error) {
+	return n.Append{{.FuncName}}(ctx, nil{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+}
+`
+
+var readTmpl *template.Template
+
+const exec = `
+{{if .Deprecated}}
+var deprecated{{.FuncName}}Once sync.Once
+{{end}}
+{{- template "semaphore" .}}
+{{range .Doc}}// {{print .}}
+{{end -}}
+{{.DeprecatedDoc (gt (len .Doc) 0) -}}
+{{- if eq .Returns "result" -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) (sql.Result, error) {
+{{- else if eq .Returns "rows_affected" -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) (int64, error) {
+{{- else -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context{{if .Params}}, {{else}}{{end}}{{getFuncSig .Params}}, opts ...Option) error {
+{{- end}}
+	if n.middleware != nil {
+{{- if .Returns}}
+		var ret {{if eq .Returns "result"}}sql.Result{{else}}int64{{end}}
+		err := n.run(ctx, &Call{Name: "{{.FuncName}}"{{with .CallArgs}}, Args: {{.}}{{end}}}, func(ctx context.Context) (err error) {
+			ret, err = n.bare().{{.FuncName}}(ctx{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+			return err
+		})
+		return ret, err
+{{- else}}
+		return n.run(ctx, &Call{Name: "{{.FuncName}}"{{with .CallArgs}}, Args: {{.}}{{end}}}, func(ctx context.Context) error {
+			return n.bare().{{.FuncName}}(ctx{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+		})
+{{- end}}
+	}
+	call := applyOptions(opts)
+{{- if .AuditLog}}
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		// The audit log row is written in the transaction of the query.
+		tx, err := sqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return {{.ErrReturn}}
+		}
+		defer tx.Rollback()
+{{- if .Returns}}
+		ret, err := (&Norm{db: tx, stmts: n.stmts}).{{.FuncName}}(ctx{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...)
+		if err != nil {
+			return {{.ErrReturn}}
+		}
+		return ret, tx.Commit()
+{{- else}}
+		if err := (&Norm{db: tx, stmts: n.stmts}).{{.FuncName}}(ctx{{if .Params}}, {{else}}{{end}}{{getCallSig .Params}}, opts...); err != nil {
+			return err
+		}
+		return tx.Commit()
+{{- end}}
+	}
+	if err := writeAuditLog(ctx, n.db, "{{.FuncName}}", {{.AuditKeys}}); err != nil {
+		return {{.ErrReturn}}
+	}
+{{- end}}
+{{- template "timeout"}}
+{{- if .Deprecated}}
+	reportDeprecatedUse(&deprecated{{.FuncName}}Once, "{{.FuncName}}", {{printf "%q" .Deprecated}})
+{{- end}}
+{{- template "count" .}}
+{{- template "contextInputs" .}}
+{{- template "limit" .}}
+{{- template "acquire" .}}
+{{- if .PartitionBy}}
+	if err := n.{{.PartitionFunc}}(ctx, {{.InputExpr .PartitionBy}}); err != nil {
+		return {{.ErrReturn}}
+	}
+{{- end}}
+{{- template "expand" .}}
+{{- if not .NoPrepare}}
+	stmt, release, err := n.prepare(ctx, {{.Query}}, {{.Reuse}})
+	if err != nil {
+		return {{.ErrReturn}}
+	}
+	defer release()
+{{- end}}
+{{- if eq .Returns "result"}}
+	return {{.Runner}}.ExecContext(ctx{{.RunArgs}})
+{{- else if eq .Returns "rows_affected"}}
+	res, err := {{.Runner}}.ExecContext(ctx{{.RunArgs}})
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+{{- else}}
+	_, err {{if .NoPrepare}}:{{end}}= {{.Runner}}.ExecContext(ctx{{.RunArgs}})
+	if err != nil {
+		return err
+	}
+	return nil
+{{- end}}
+}
+`
+
+var execTmpl *template.Template
+
+var funcMap template.FuncMap = template.FuncMap{
+	"getFuncSig":               getFuncSig,
+	"getTypeSig":               getTypeSig,
+	"getFuncSigWithTypePrefix": getFuncSigWithTypePrefix,
+	"getCallSig":               getCallSig,
+	"getCallSigWithPrefix":     getCallSigWithPrefix,
+	"getStructSig":             getStructSig,
+	"getTaggedStructSig":       getTaggedStructSig,
+	"isSlice":                  isSlice,
+}
+
+type genAble interface {
+	gen(io.Writer) error
+	Base() *cmdBase
+	// kind is the name of the command in the norm file.
+	Kind() string
+	// funcs lists the names of the generated functions and types.
+	Funcs() []string
+}
+
+// arg is an input or an output of a command.
+type arg = IRField
+
+// cmdBase is the part shared by all the commands. The templates read the
+// directives of the command from its IRCommand, see IR, and the Go code they
+// generate from the methods of cmdBase derived from it.
+type cmdBase struct {
+	IRCommand
+	Body []string
+	// ETagMethod is set on the one command generating the ETag method of its
+	// result type, see !etag.
+	ETagMethod bool
+	// NotSensitive are the inputs of a !not_sensitive directive, which
+	// checkSensitive leaves out of Sensitive.
+	NotSensitive []string
+}
+
+func (c *cmdBase) Base() *cmdBase {
+	return c
+}
+
+func (c *cmdBase) BodyString() string {
+	return strings.Join(c.Body, "\n")
+}
+
+// ResultType is the Go type a single row of the command is read into: the
+// model if one is given, the type of the only output, or the generated
+// Output struct otherwise.
+func (c *cmdBase) ResultType() string {
+	if c.Model != "" {
+		return c.Model
+	}
+	if len(c.Outputs) == 1 {
+		return c.Outputs[0].Typ
+	}
+	return c.FuncName + "Output"
+}
+
+// ScanArgs returns the Scan destinations for reading a row into the variable
+// v of type ResultType.
+func (c *cmdBase) ScanArgs(v string) string {
+	if c.Model == "" && len(c.Outputs) == 1 {
+		return "&" + v
+	}
+	return getCallSigWithPrefix(c.Outputs, "&"+v+".")
+}
+
+// ErrReturn returns the values returned along with err by the function
+// generated for the command, when it fails before running the query.
+func (c *cmdBase) ErrReturn() string {
+	return "err"
+}
+
+// DeprecatedDoc returns the deprecation paragraph for the doc comments of the
+// generated functions, or "" if the command is not deprecated.
+// afterComment separates it from a preceding comment paragraph.
+func (c *cmdBase) DeprecatedDoc(afterComment bool) string {
+	if c.Deprecated == "" {
+		return ""
+	}
+	if afterComment {
+		return "//\n// Deprecated: " + c.Deprecated + "\n"
+	}
+	return "// Deprecated: " + c.Deprecated + "\n"
+}
+
+// OutputNames returns the quoted names of the outputs, for use as arguments
+// of checkColumns.
+func (c *cmdBase) OutputNames() string {
+	var names []string
+	for _, o := range c.Outputs {
+		names = append(names, strconv.Quote(o.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// ScanPtrArgs is like ScanArgs, but p is a pointer to ResultType, and the
+// outputs declared null or encrypted are wrapped, see scanDest.
+func (c *cmdBase) ScanPtrArgs(p string) string {
+	if c.Model == "" && len(c.Outputs) == 1 {
+		return c.scanDest(c.Outputs[0].Name, p)
+	}
+	var dests []string
+	for _, o := range c.Outputs {
+		dests = append(dests, c.scanDest(o.Name, "&"+p+"."+o.Name))
+	}
+	return strings.Join(dests, ", ")
+}
+
+type cmdReadOne struct {
+	cmdBase
+}
+
+func (c *cmdReadOne) gen(w io.Writer) error {
+	return readOneTmpl.Execute(w, c)
+}
+
+func (c *cmdReadOne) Kind() string {
+	return "read_one"
+}
+
+// Reads reports whether the command only reads, and runs on the replica set
+// with WithReplica.
+func (c *cmdReadOne) Reads() bool {
+	return true
+}
+
+func (c *cmdReadOne) Funcs() []string {
+	ret := []string{c.FuncName, c.FuncName + "Into"}
+	if c.Model == "" && len(c.Outputs) > 1 {
+		ret = append(ret, c.FuncName+"Output")
+	}
+	if c.Mapper != "" {
+		ret = append(ret, c.FuncName+"Row")
+	}
+	if c.CacheKey {
+		ret = append(ret, c.FuncName+"CacheKey")
+	}
+	if c.Compare {
+		ret = append(ret, "Compare"+c.FuncName)
+	}
+	return ret
+}
+
+type cmdRead struct {
+	cmdBase
+	LargeResult bool
+}
+
+func (c *cmdRead) gen(w io.Writer) error {
+	return readTmpl.Execute(w, c)
+}
+
+func (c *cmdRead) Kind() string {
+	return "read"
+}
+
+// ErrReturn returns the values returned along with err by the Scan function
+// of the command.
+func (c *cmdRead) ErrReturn() string {
+	return "nil, err"
+}
+
+func (c *cmdRead) Funcs() []string {
+	ret := []string{c.FuncName, "Append" + c.FuncName, c.FuncName + "Scan", c.FuncName + "Result"}
+	if c.Model == "" && len(c.Outputs) > 1 {
+		ret = append(ret, c.FuncName+"Output")
+	}
+	if c.Mapper != "" {
+		ret = append(ret, c.FuncName+"Row")
+	}
+	if c.CacheKey {
+		ret = append(ret, c.FuncName+"CacheKey")
+	}
+	if c.Compare {
+		ret = append(ret, "Compare"+c.FuncName)
+	}
+	return ret
+}
+
+type cmdExec struct {
+	cmdBase
+	// Returns is "result" for !result, which returns the sql.Result, and
+	// "rows_affected" for !rows_affected, which returns the number of rows
+	// affected. Otherwise only an error is returned.
+	Returns string
+	// ifMatchRead is the command named by IfMatch.
+	ifMatchRead *cmdBase
+}
+
+func (c *cmdExec) ErrReturn() string {
+	switch c.Returns {
+	case "result":
+		return "nil, err"
+	case "rows_affected":
+		return "0, err"
+	}
+	return "err"
+}
+
+func (c *cmdExec) gen(w io.Writer) error {
+	return execTmpl.Execute(w, c)
+}
+
+func (c *cmdExec) Kind() string {
+	return "exec"
+}
+
+func (c *cmdExec) Funcs() []string {
+	if c.IfMatch != "" {
+		return []string{c.FuncName, c.FuncName + "IfMatch"}
+	}
+	return []string{c.FuncName}
+}
+
+func checkStart(firstLine string) bool {
+	return firstLine == "-- !norm"
+}
+
+func getFuncSig(args []arg) string {
+	var ret strings.Builder
+	for ix, a := range args {
+		if ix < len(args)-1 {
+			fmt.Fprintf(&ret, "%s %s, ", a.Name, a.Typ)
+		} else {
+			fmt.Fprintf(&ret, "%s %s", a.Name, a.Typ)
+		}
+	}
+	return ret.String()
+}
+
+func getTypeSig(args []arg) string {
+	var ret strings.Builder
+	for ix, a := range args {
+		if ix < len(args)-1 {
+			fmt.Fprintf(&ret, "%s, ", a.Typ)
+		} else {
+			fmt.Fprintf(&ret, "%s", a.Typ)
+		}
+	}
+	return ret.String()
+}
+
+func getFuncSigWithTypePrefix(args []arg, typPrefix string) string {
+	var ret strings.Builder
+	for ix, a := range args {
+		if ix < len(args)-1 {
+			fmt.Fprintf(&ret, "%s %s%s, ", a.Name, typPrefix, a.Typ)
+		} else {
+			fmt.Fprintf(&ret, "%s %s%s", a.Name, typPrefix, a.Typ)
+		}
+	}
+	return ret.String()
+}
+
+func getCallSig(args []arg) string {
+	var ret strings.Builder
+	for ix, a := range args {
+		if ix < len(args)-1 {
+			fmt.Fprintf(&ret, "%s, ", a.Name)
+		} else {
+			fmt.Fprintf(&ret, "%s", a.Name)
+		}
+	}
+	return ret.String()
+}
+
+func getCallSigWithPrefix(args []arg, prefix string) string {
+	var ret strings.Builder
+	for ix, a := range args {
+		if ix < len(args)-1 {
+			fmt.Fprintf(&ret, "%s%s, ", prefix, a.Name)
+		} else {
+			fmt.Fprintf(&ret, "%s%s", prefix, a.Name)
+		}
+	}
+	return ret.String()
+}
+
+func getStructSig(args []arg) string {
+	var ret strings.Builder
+	for ix, a := range args {
+		if ix < len(args)-1 {
+			fmt.Fprintf(&ret, "\t%s %s\n", a.Name, a.Typ)
+		} else {
+			fmt.Fprintf(&ret, "\t%s %s", a.Name, a.Typ)
+		}
+	}
+	return ret.String()
+
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NormFile is the parsed representation of a norm input file.
+type NormFile struct {
+	OutFile string
+	// Package is empty when the file has no !package directive, see
+	// ResolvePackage.
+	Package string
+	Imports []string
+	// DeclaredImports are the imports of the !import and !model_pkg
+	// directives, see IR.
+	DeclaredImports      []IRImport
+	LargeResultThreshold int
+	CheckColumns         bool
+	// Deprecations is set if any command has a !deprecated directive.
+	Deprecations bool
+	// ConnSetup holds the statements run on every new connection.
+	ConnSetup []string
+	// Attached holds the schema names of the !attach directives.
+	Attached []string
+	// OnConnect is set by !on_connect directives, which generate Open even
+	// without statements.
+	OnConnect bool
+	// Mocks is set by the !mocks directive, see GenerateMock.
+	Mocks bool
+	// ApplicationName is the default name the generated Open identifies
+	// connections with, see !application_name.
+	ApplicationName string
+	// Dialect is set by the !dialect directive and selects the placeholders
+	// :name is replaced with, see bindNamedInputs.
+	Dialect string
+	// IDTypes are the types declared with !id_type.
+	IDTypes []idType
+	// Keys are the structs declared with !key.
+	Keys []keyType
+	// ContextKeys are the keys of the values read by !input_ctx.
+	ContextKeys []contextKey
+	// NoPrepare is set by a !no_prepare directive before the commands, which
+	// applies to all of them.
+	NoPrepare bool
+	// UsageCounts is set by the !usage_counts directive, which generates
+	// QueryCounts.
+	UsageCounts bool
+	// CacheKeys is set by the !cache_keys directive, which generates the
+	// CacheKey functions of the queries reading rows.
+	CacheKeys bool
+	// ModelPkg is the import path of the package that models are declared
+	// in, if they are not in the generated package.
+	ModelPkg string
+	// Tags and TagCase are set by a !tags directive before the commands,
+	// which applies to the commands without their own.
+	Tags    []string
+	TagCase string
+	// ModelFile is the file the generated models are written to when they
+	// are declared in ModelPkg, see !model_gen.
+	ModelFile string
+	// Sources are the norm files of the input, when it has several.
+	Sources []Source
+	// Input are the lines of the input, see ParseOptions.
+	Input []string
+	// Stamp selects the second line of the generated files, see !stamp.
+	Stamp string
+	// Models are the structs generated for the !model_gen directives.
+	Models []modelType
+	// Encrypted are the names of the inputs and outputs encrypted in every
+	// command, set by a !encrypted directive before the commands.
+	Encrypted []string
+	// Sensitive are the names of the inputs redacted in every command, set
+	// by a !sensitive directive before the commands. With
+	// SensitiveByDefault, all inputs are, see !sensitive_by_default.
+	Sensitive          []string
+	SensitiveByDefault bool
+	// WarmUpQueries are the queries run by WarmUp, see !warmup. WarmUp is
+	// only generated if there are any.
+	WarmUpQueries []string
+	// Partitions are the tables inserted into by the commands with a
+	// !partition_by directive.
+	Partitions []partitionedTable
+	Cmds       []genAble
+}
+
+// addImport adds the import of path, optionally under alias, unless the file
+// already has the same import. context and database/sql are always imported
+// by the header.
+func (nf *NormFile) addImport(alias, path string) {
+	spec := strconv.Quote(path)
+	if alias != "" {
+		spec = alias + " " + spec
+	}
+	if spec == `"context"` || spec == `"database/sql"` || containsString(nf.Imports, spec) {
+		return
+	}
+	nf.Imports = append(nf.Imports, spec)
+}
+
+// declareImport records the import imp of a directive in DeclaredImports,
+// unless it is already there.
+func (nf *NormFile) declareImport(imp IRImport) {
+	for _, other := range nf.DeclaredImports {
+		if other == imp {
+			return
+		}
+	}
+	nf.DeclaredImports = append(nf.DeclaredImports, imp)
+}
+
+var (
+	rxFile        = regexp.MustCompile(`^-- !file ([^\s]+)$`)
+	rxPkg         = regexp.MustCompile(`^-- !package ([^\s]+)$`)
+	rxImports     = regexp.MustCompile(`^-- !import (?:([^\s"]+) )?("[^"]+"|[^\s"]+)$`)
+	rxLargeResult = regexp.MustCompile(`^-- !large_result_threshold ([0-9]+)$`)
+	rxModelPkg    = regexp.MustCompile(`^-- !model_pkg ([^\s]+)$`)
+	rxModelFile   = regexp.MustCompile(`^-- !model_file ([^\s]+)$`)
+	rxReadOne     = regexp.MustCompile(`^-- !read_one ([^\s]+)$`)
+	rxRead        = regexp.MustCompile(`^-- !read ([^\s]+)$`)
+	rxReturning   = regexp.MustCompile(`^-- !exec_returning ([^\s]+)$`)
+	rxExec        = regexp.MustCompile(`^-- !exec ([^\s]+)$`)
+	rxExecMany    = regexp.MustCompile(`^-- !exec_many ([^\s]+)$`)
+	rxCopy        = regexp.MustCompile(`^-- !copy ([^\s]+)$`)
+	rxScript      = regexp.MustCompile(`^-- !script ([^\s]+)$`)
+	rxInput       = regexp.MustCompile(`^-- !input ([^\s]+) ([^\s]+)$`)
+	rxInputCtx    = regexp.MustCompile(`^-- !input_ctx ([^\s]+) ([^\s]+) key:([\pL_][\pL\pN_]*)$`)
+	rxOutput      = regexp.MustCompile(`^-- !output ([^\s]+) ([^\s]+)( null)?$`)
+	rxModel       = regexp.MustCompile(`^-- !model ([^\s]+)$`)
+	rxView        = regexp.MustCompile(`^-- !view ([^\s]+) ([^\s]+)$`)
+	rxCommand     = regexp.MustCompile(`^-- !(read_one|read|exec_returning|exec_many|exec|view|script|copy)(\s|$)`)
+	rxEnv         = regexp.MustCompile(`^-- !env ([^\s]+) (.+)$`)
+	rxDeprecated  = regexp.MustCompile(`^-- !deprecated (.+)$`)
+	rxIfdef       = regexp.MustCompile(`^-- !ifdef ([^\s]+)$`)
+	rxAttach      = regexp.MustCompile(`^-- !attach ([^\s]+) (.+)$`)
+	rxPragma      = regexp.MustCompile(`^-- !pragma (.+)$`)
+	rxOnConnect   = regexp.MustCompile(`^-- !on_connect(?: (.+))?$`)
+	rxAppName     = regexp.MustCompile(`^-- !application_name ([^\s]+)$`)
+	rxDialect     = regexp.MustCompile(`^-- !dialect (postgres|sqlite|mysql)$`)
+	rxMaxConc     = regexp.MustCompile(`^-- !max_concurrency ([0-9]+)( nowait)?$`)
+	rxLimitGroup  = regexp.MustCompile(`^-- !limit_group ([A-Za-z0-9_.-]+)$`)
+	rxFallback    = regexp.MustCompile(`^-- !fallback ([^\s]+)$`)
+	rxBind        = regexp.MustCompile(`^-- !bind((?: [^\s]+)+)$`)
+	rxKey         = regexp.MustCompile(`^-- !key ([^\s]+)((?: [^\s]+)*)$`)
+	rxStamp       = regexp.MustCompile(`^-- !stamp ([a-z]+)$`)
+	rxTags        = regexp.MustCompile(`^-- !tags ([a-z]+(?:,[a-z]+)*)(?: (snake|camel))?$`)
+	rxMapper      = regexp.MustCompile(`^-- !mapper ([^\s]+)$`)
+	rxAsOf        = regexp.MustCompile(`^-- !as_of ([^\s]+)((?: [^\s]+)*)$`)
+	rxPartitionBy = regexp.MustCompile(`^-- !partition_by ([^\s]+) ([^\s]+)$`)
+	rxEncrypted   = regexp.MustCompile(`^-- !encrypted((?: [^\s]+)+)$`)
+	rxSensitive   = regexp.MustCompile(`^-- !(?:not_)?sensitive((?: [^\s]+)+)$`)
+	rxETag        = regexp.MustCompile(`^-- !etag((?: [^\s]+)*)$`)
+	rxIfMatch     = regexp.MustCompile(`^-- !if_match ([^\s]+)$`)
+	rxCacheKey    = regexp.MustCompile(`^-- !cache_key ([\pL_][\pL\pN_]*)(?: (.+))?$`)
+	rxAuditLog    = regexp.MustCompile(`^-- !audit_log((?: [^\s]+)*)$`)
+	rxIDType      = regexp.MustCompile(`^-- !id_type ([^\s]+) ([^\s]+)((?: [^\s]+)*)$`)
+	rxDoc         = regexp.MustCompile(`^-- !doc(?: (.*))?$`)
+)
+
+// ParseOptions are the settings given on the command line that affect how a
+// norm file is read.
+type ParseOptions struct {
+	// Env selects the !env directives that apply.
+	Env string
+	// Defines are the names for which !ifdef sections are kept.
+	Defines []string
+	// sources are the norm files read, when the input has several.
+	sources []Source
+	// input are the lines of the input, shown along with its errors.
+	input []string
+	// skip are the lines of the input with errors already found, which are
+	// left out, see ParseFiles.
+	skip map[int]bool
+}
+
+// lineScanner reads the lines of a norm file. Directives scoped to the
+// selected environment with -- !env <name> are returned as plain directives.
+// Sections between -- !ifdef <name> and -- !endif are returned as blank lines
+// unless name is defined, as are the !ifdef and !endif lines themselves, so
+// that the line numbers of the remaining lines do not change.
+type lineScanner struct {
+	*bufio.Scanner
+	env     string
+	defines map[string]bool
+	line    int
+	text    string
+	// ifdefs holds the open !ifdef sections, innermost last.
+	ifdefs []ifdef
+	skip   map[int]bool
+	// input are the lines of the input, which blocks look ahead in for their
+	// !end, see parseBlock.
+	input []string
+	// skipped is set when the line is one of skip, which the parser leaves
+	// out, along with the block of a command.
+	skipped bool
+}
+
+type ifdef struct {
+	line   int
+	active bool
+}
+
+func newLineScanner(r io.Reader, opts ParseOptions) *lineScanner {
+	s := &lineScanner{Scanner: bufio.NewScanner(r), env: opts.Env, defines: map[string]bool{}, skip: opts.skip, input: opts.input}
+	for _, name := range opts.Defines {
+		s.defines[name] = true
+	}
+	return s
+}
+
+func (s *lineScanner) Scan() bool {
+	if !s.Scanner.Scan() {
+		return false
+	}
+	s.line++
+	s.text = s.Scanner.Text()
+	if matches := rxEnv.FindStringSubmatch(s.text); matches != nil && matches[1] == s.env {
+		s.text = "-- !" + matches[2]
+	}
+	active := len(s.ifdefs) == 0 || s.ifdefs[len(s.ifdefs)-1].active
+	s.skipped = s.skip[s.line]
+	if s.skipped {
+		if strings.HasPrefix(s.text, `-- !ifdef`) {
+			// Keeps the section open for its !endif.
+			s.ifdefs = append(s.ifdefs, ifdef{s.line, active})
+		}
+		if !active {
+			s.skipped = false
+			s.text = ""
+		}
+	} else if strings.HasPrefix(s.text, `-- !ifdef`) {
+		matches := rxIfdef.FindStringSubmatch(s.text)
+		if len(matches) != 2 {
+			panic(fmt.Sprintf("Format error on line %d: %q", s.line, s.text))
+		}
+		s.ifdefs = append(s.ifdefs, ifdef{s.line, active && s.defines[matches[1]]})
+		s.text = ""
+	} else if s.text == `-- !endif` {
+		if len(s.ifdefs) == 0 {
+			panic(fmt.Sprintf("No !ifdef for !endif on line %d", s.line))
+		}
+		s.ifdefs = s.ifdefs[:len(s.ifdefs)-1]
+		s.text = ""
+	} else if !active {
+		s.text = ""
+	}
+	return true
+}
+
+func (s *lineScanner) Text() string {
+	return s.text
+}
+
+// ParseFile parses the norm file at inputFile, or the norm files it names as
+// a directory or a glob, see inputFiles. Several files are parsed as one, each
+// writing its commands to its own !file, and errors name the file.
+func ParseFile(inputFile string, opts ParseOptions) *NormFile {
+	return ParseFiles([]string{inputFile}, opts)
+}
+
+// ParseFiles reads the norm files named by args, see inputFiles, as one
+// input, in the order of args. A file named by several args is read once.
+func ParseFiles(args []string, opts ParseOptions) *NormFile {
+	var paths []string
+	for _, arg := range args {
+		matches, err := inputFiles(arg)
+		if err != nil {
+			panic(&parseError{Msg: err.Error()})
+		}
+		for _, path := range matches {
+			if !containsString(paths, filepath.Clean(path)) {
+				paths = append(paths, filepath.Clean(path))
+			}
+		}
+	}
+	r, sources, err := readSources(paths)
+	if err != nil {
+		panic(&parseError{Msg: err.Error()})
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		panic(&parseError{Msg: err.Error()})
+	}
+	return ParseData(data, sources, opts)
+}
+
+// ParseData parses data, the input made of the norm files of sources.
+func ParseData(data []byte, sources []Source, opts ParseOptions) *NormFile {
+	opts.sources = sources
+	opts.input = strings.Split(string(data), "\n")
+	located := &NormFile{Sources: sources, Input: opts.input}
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(string)
+			if !ok {
+				panic(r)
+			}
+			panic(NewParseError(located, msg))
+		}
+	}()
+	// The input is parsed again without every line found to have an error,
+	// until no other line has one, so that all of them are reported at once.
+	opts.skip = map[int]bool{}
+	var errs ParseErrors
+	for {
+		nf, msg := parseLines(data, opts)
+		if msg == "" && len(errs) == 0 {
+			return nf
+		}
+		if msg == "" {
+			panic(errs)
+		}
+		errs = append(errs, NewParseError(located, msg))
+		line := errorLine(msg)
+		if line == 0 || opts.skip[line] {
+			panic(errs)
+		}
+		opts.skip[line] = true
+	}
+}
+
+// parseLines parses data, returning the message of the first error found in
+// its lines instead of panicking with it.
+func parseLines(data []byte, opts ParseOptions) (nf *NormFile, lineErr string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(lineError)
+			if !ok {
+				panic(r)
+			}
+			lineErr = string(msg)
+		}
+	}()
+	return parse(bytes.NewReader(data), opts), ""
+}
+
+func parse(r io.Reader, opts ParseOptions) *NormFile {
+	nf := &NormFile{
+		OutFile: "db.go",
+		Sources: opts.sources,
+		Input:   opts.input,
+	}
+	scanner := newLineScanner(r, opts)
+	i := 1
+	var groups []fileGroup
+	// Errors found while reading the lines are reported with the line, so
+	// that ParseFiles can carry on without it.
+	scanning := true
+	defer func() {
+		if r := recover(); r != nil {
+			if msg, ok := r.(string); ok && scanning {
+				panic(lineError(msg))
+			}
+			panic(r)
+		}
+	}()
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		// The commands of every other norm file of the input are written to
+		// the main output file, unless it has a !file of its own.
+		for len(opts.sources) > 1 && i > opts.sources[1].Start {
+			groups = append(groups, fileGroup{len(nf.Cmds), ""})
+			opts.sources = opts.sources[1:]
+		}
+		if i == 1 && !checkStart(line) {
+			panic("Not a valid norm file")
+		} else if i == 1 {
+			i++
+			continue
+		}
+		if scanner.skipped {
+			if rxCommand.MatchString(line) {
+				// The directives of the block are still checked, whatever
+				// the command.
+				i = parseBlock(scanner, i+1, &cmdBase{}, true, func(string, int) bool { return true })
+				continue
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !env `) {
+			// Scoped to an environment other than the selected one.
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !file`) {
+			matches := rxFile.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			if len(nf.Cmds) == 0 {
+				nf.OutFile = matches[1]
+			} else {
+				groups = append(groups, fileGroup{len(nf.Cmds), matches[1]})
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !package`) {
+			matches := rxPkg.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			if nf.Package != "" && nf.Package != matches[1] {
+				panic(fmt.Sprintf("!package on line %d: the input is already in package %s", i, nf.Package))
+			}
+			nf.Package = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !import`) {
+			matches := rxImports.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			path := strings.Trim(matches[2], `"`)
+			nf.addImport(matches[1], path)
+			nf.declareImport(IRImport{Name: matches[1], Path: path})
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !large_result_threshold`) {
+			matches := rxLargeResult.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			threshold, err := strconv.Atoi(matches[1])
+			if err != nil {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.LargeResultThreshold = threshold
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !id_type`) {
+			matches := rxIDType.FindStringSubmatch(line)
+			if len(matches) != 4 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			if _, ok := idTypeNulls[matches[2]]; !ok {
+				panic(fmt.Sprintf("Unsupported !id_type base type on line %d: %q", i, line))
+			}
+			t := idType{Name: matches[1], Base: matches[2]}
+			for _, col := range strings.Fields(matches[3]) {
+				if !strings.Contains(col, ".") {
+					panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+				}
+				t.Columns = append(t.Columns, strings.ToLower(col))
+			}
+			nf.IDTypes = append(nf.IDTypes, t)
+			i++
+			continue
+		}
+		if line == `-- !mocks` {
+			nf.Mocks = true
+			i++
+			continue
+		}
+		if line == `-- !check_columns` {
+			nf.CheckColumns = true
+			i++
+			continue
+		}
+		if line == `-- !no_prepare` {
+			nf.NoPrepare = true
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !stamp`) {
+			matches := rxStamp.FindStringSubmatch(line)
+			if len(matches) != 2 || !Stamps[matches[1]] {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.Stamp = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !tags`) {
+			nf.Tags, nf.TagCase = parseTags(line, i)
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !encrypted`) {
+			matches := rxEncrypted.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.Encrypted = append(nf.Encrypted, strings.Fields(matches[1])...)
+			i++
+			continue
+		}
+		if line == `-- !sensitive_by_default` {
+			nf.SensitiveByDefault = true
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !sensitive`) {
+			matches := rxSensitive.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.Sensitive = append(nf.Sensitive, strings.Fields(matches[1])...)
+			i++
+			continue
+		}
+		if line == `-- !usage_counts` {
+			nf.UsageCounts = true
+			i++
+			continue
+		}
+		if line == `-- !cache_keys` {
+			nf.CacheKeys = true
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !attach`) {
+			matches := rxAttach.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.Attached = append(nf.Attached, matches[1])
+			nf.ConnSetup = append(nf.ConnSetup, attachStatement(matches[1], matches[2]))
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !pragma`) {
+			matches := rxPragma.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			for _, setting := range strings.Fields(matches[1]) {
+				if !strings.Contains(setting, "=") {
+					panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+				}
+				nf.ConnSetup = append(nf.ConnSetup, "PRAGMA "+setting)
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !on_connect`) {
+			matches := rxOnConnect.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.OnConnect = true
+			if matches[1] != "" {
+				nf.ConnSetup = append(nf.ConnSetup, matches[1])
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !application_name`) {
+			matches := rxAppName.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.ApplicationName = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !dialect`) {
+			matches := rxDialect.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			if nf.Dialect != "" && nf.Dialect != matches[1] {
+				panic(fmt.Sprintf("!dialect on line %d: the input is already in dialect %s", i, nf.Dialect))
+			}
+			nf.Dialect = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !model_pkg`) {
+			matches := rxModelPkg.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.ModelPkg = strings.Trim(matches[1], `"`)
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !model_file`) {
+			matches := rxModelFile.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			nf.ModelFile = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !read_one`) {
+			matches := rxReadOne.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdReadOne{}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, true, nil)
+			continue
+		}
+		if strings.HasPrefix(line, `-- !exec_returning`) {
+			matches := rxReturning.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdExecReturning{}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, true, nil)
+			continue
+		}
+		if strings.HasPrefix(line, `-- !read `) {
+			matches := rxRead.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdRead{}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, true, nil)
+			continue
+		}
+		if strings.HasPrefix(line, `-- !view`) {
+			matches := rxView.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdView{ViewName: matches[2]}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Model = cmd.FuncName
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, true, func(line string, i int) bool {
+				if line == `-- !materialized` {
+					cmd.Materialized = true
+					return true
+				}
+				return false
+			})
+			continue
+		}
+		if strings.HasPrefix(line, `-- !exec_many`) {
+			matches := rxExecMany.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdExecMany{}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, false, modelDirective(&cmd.cmdBase))
+			if cmd.HasSliceInputs() {
+				panic(fmt.Sprintf("Slice inputs are not supported by !exec_many on line %d", cmd.Line))
+			}
+			continue
+		}
+		if strings.HasPrefix(line, `-- !script`) {
+			matches := rxScript.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdScript{}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, false, nil)
+			if len(cmd.Inputs) > 0 || len(cmd.Steps()) == 0 {
+				panic(fmt.Sprintf("!script on line %d takes statements separated by semicolons, and no inputs", cmd.Line))
+			}
+			continue
+		}
+		if strings.HasPrefix(line, `-- !copy`) {
+			matches := rxCopy.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdCopy{}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, false, modelDirective(&cmd.cmdBase))
+			toks := tokenize(cmd.BodyString())
+			if name, end := qualifiedName(toks, 0); name == "" || end != len(toks) || len(cmd.Inputs) == 0 || cmd.HasSliceInputs() {
+				panic(fmt.Sprintf("!copy on line %d takes a table name as body, and inputs naming its columns", cmd.Line))
+			}
+			continue
+		}
+		if strings.HasPrefix(line, `-- !exec`) {
+			matches := rxExec.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd := &cmdExec{}
+			nf.Cmds = append(nf.Cmds, cmd)
+			cmd.FuncName = matches[1]
+			cmd.Line = i
+			i++
+			i = parseBlock(scanner, i, &cmd.cmdBase, false, func(line string, i int) bool {
+				switch line {
+				case `-- !result`:
+					cmd.Returns = "result"
+				case `-- !rows_affected`:
+					cmd.Returns = "rows_affected"
+				default:
+					return false
+				}
+				return true
+			})
+			continue
+		}
+		if line == `-- !end` {
+			panic(fmt.Sprintf("!end on line %d does not end a command", i))
+		}
+		if strings.HasPrefix(line, `-- !`) {
+			panic(fmt.Sprintf("Unknown command on line %d: %q", i, line))
+		}
+		i++
+	}
+	if n := len(scanner.ifdefs); n > 0 {
+		panic(fmt.Sprintf("No !endif for !ifdef on line %d", scanner.ifdefs[n-1].line))
+	}
+	scanning = false
+	if len(opts.skip) > 0 {
+		// The commands are only checked as a whole once the lines are free
+		// of errors.
+		return nf
+	}
+	assignFiles(nf, groups)
+	checkDuplicates(nf)
+
+	deriveInputs(nf)
+	deriveOutputs(nf)
+	bindNamedInputs(nf)
+	nf.Keys = resolveKeys(nf)
+	nf.ContextKeys = resolveContextKeys(nf)
+	checkBinds(nf)
+	if nf.Tags != nil {
+		for _, cmd := range nf.Cmds {
+			if c := cmd.Base(); c.Tags == nil {
+				c.Tags, c.TagCase = nf.Tags, nf.TagCase
+			}
+		}
+	}
+	nf.Models = resolveModels(nf)
+	if nf.ModelPkg != "" {
+		pkg := packageName(nf.ModelPkg)
+		local := map[string]bool{}
+		for _, cmd := range nf.Cmds {
+			if v, ok := cmd.(*cmdView); ok {
+				local[v.FuncName] = true
+			}
+		}
+		for _, cmd := range nf.Cmds {
+			c := cmd.Base()
+			if c.Model != "" && !strings.Contains(c.Model, ".") && !local[c.Model] {
+				c.Model = pkg + "." + c.Model
+			}
+		}
+		// The qualifier is guessed from the path, so the package is
+		// imported under it when its last element is not a valid name,
+		// like my-models or foo.v2.
+		alias := ""
+		if pkg != path.Base(nf.ModelPkg) {
+			alias = pkg
+		}
+		nf.addImport(alias, nf.ModelPkg)
+		nf.declareImport(IRImport{Name: alias, Path: nf.ModelPkg})
+	}
+	resolveFallbacks(nf)
+	if nf.NoPrepare {
+		for _, cmd := range nf.Cmds {
+			cmd.Base().NoPrepare = true
+		}
+	}
+	if nf.UsageCounts {
+		for _, cmd := range nf.Cmds {
+			cmd.Base().CountUsage = true
+		}
+		nf.addImport("", "sync/atomic")
+	}
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().Deprecated != "" {
+			nf.Deprecations = true
+			nf.addImport("", "sync")
+		}
+	}
+	if len(nf.IDTypes) > 0 {
+		nf.addImport("", "database/sql/driver")
+	}
+	checkNullOutputs(nf)
+	checkReadOnly(nf)
+	checkEncrypted(nf)
+	checkSensitive(nf)
+	setCacheKeys(nf)
+	checkAuditLogs(nf)
+	checkMappers(nf)
+	checkETags(nf)
+	nf.WarmUpQueries = warmUpQueries(nf)
+	checkCompares(nf)
+	checkAsOf(nf)
+	nf.Partitions = resolvePartitions(nf)
+	if nf.hasAuditLog() {
+		nf.addImport("", "encoding/json")
+		nf.addImport("", "time")
+	}
+	if nf.hasScripts() {
+		nf.addImport("", "time")
+	}
+	if nf.hasNullOutputs() {
+		nf.addImport("", "time")
+	}
+	if len(nf.Partitions) > 0 {
+		nf.addImport("", "time")
+	}
+	if nf.hasEncrypted() {
+		nf.addImport("", "database/sql/driver")
+	}
+	if nf.hasETags() {
+		nf.addImport("", "crypto/sha256")
+		nf.addImport("", "encoding/json")
+	}
+	if nf.CacheKeys {
+		nf.addImport("", "encoding/json")
+	}
+	if nf.hasCompare() {
+		nf.addImport("", "encoding/json")
+		nf.addImport("", "fmt")
+	}
+	if nf.hasAsOf() {
+		nf.addImport("", "fmt")
+		nf.addImport("", "strings")
+		nf.addImport("", "time")
+	}
+	if nf.hasSliceInputs() {
+		nf.addImport("", "strconv")
+		nf.addImport("", "strings")
+	}
+	if nf.needsConnect() {
+		nf.addImport("", "database/sql/driver")
+		if nf.ApplicationName != "" {
+			nf.addImport("", "strings")
+		}
+	}
+	// Used by Norm.Begin, the statement cache, PrepareAll and the options of
+	// the calls.
+	nf.addImport("", "container/list")
+	nf.addImport("", "errors")
+	nf.addImport("", "fmt")
+	nf.addImport("", "sync")
+	nf.addImport("", "time")
+	if nf.hasLimitGroups() {
+		nf.addImport("", "time")
+	}
+	if nf.hasFallbacks() {
+		nf.addImport("", "sync/atomic")
+	}
+	if nf.hasResults() {
+		nf.addImport("", "sync/atomic")
+		nf.addImport("", "time")
+	}
+	if nf.CheckColumns {
+		for _, cmd := range nf.Cmds {
+			switch cmd.(type) {
+			case *cmdExec, *cmdExecMany, *cmdCopy, *cmdScript:
+			default:
+				cmd.Base().CheckColumns = true
+			}
+		}
+		nf.addImport("", "fmt")
+		nf.addImport("", "strings")
+	}
+	if nf.LargeResultThreshold > 0 {
+		for _, cmd := range nf.Cmds {
+			switch c := cmd.(type) {
+			case *cmdRead:
+				c.LargeResult = true
+			case *cmdView:
+				c.LargeResult = true
+			}
+		}
+		nf.addImport("", "log")
+	}
+	return nf
+}
+
+// parseBlock reads the directives and body of a command up to the next blank
+// line, or up to its -- !end line if it has one, in which case the body may
+// hold blank lines. i is the number of the next line to be read, and the
+// number of the line following the block is returned. Commands that do not
+// read rows do not take outputs or a model. Directives specific to a command
+// are passed to directive, which reports whether it handled the line.
+func parseBlock(scanner *lineScanner, i int, cmd *cmdBase, withOutputs bool, directive func(line string, i int) bool) int {
+	hasEnd := blockHasEnd(scanner.input, i)
+	defer func() {
+		cmd.Body = trimBlankLines(cmd.Body)
+	}()
+	for scanner.Scan() {
+		line := scanner.Text()
+		if scanner.skipped {
+			i++
+			continue
+		}
+		if line == `-- !end` {
+			i++
+			break
+		}
+		if len(strings.TrimSpace(line)) == 0 && !hasEnd {
+			i++
+			break
+		}
+		if strings.HasPrefix(line, `-- !env `) {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !input_ctx`) {
+			matches := rxInputCtx.FindStringSubmatch(line)
+			if len(matches) != 4 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Inputs = append(cmd.Inputs, arg{Name: matches[1], Typ: matches[2]})
+			cmd.ContextInputs = append(cmd.ContextInputs, contextInput{Name: matches[1], Key: matches[3]})
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !input`) {
+			matches := rxInput.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			inp := arg{Name: matches[1], Typ: matches[2]}
+			cmd.Inputs = append(cmd.Inputs, inp)
+			i++
+			continue
+		}
+		if withOutputs && strings.HasPrefix(line, `-- !output`) {
+			matches := rxOutput.FindStringSubmatch(line)
+			if len(matches) != 4 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Outputs = append(cmd.Outputs, arg{Name: matches[1], Typ: matches[2], Null: matches[3] != ""})
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !doc`) {
+			matches := rxDoc.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Doc = append(cmd.Doc, matches[1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !encrypted`) {
+			matches := rxEncrypted.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Encrypted = append(cmd.Encrypted, strings.Fields(matches[1])...)
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !sensitive`) || strings.HasPrefix(line, `-- !not_sensitive`) {
+			matches := rxSensitive.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			if strings.HasPrefix(line, `-- !not_sensitive`) {
+				cmd.NotSensitive = append(cmd.NotSensitive, strings.Fields(matches[1])...)
+			} else {
+				cmd.Sensitive = append(cmd.Sensitive, strings.Fields(matches[1])...)
+			}
+			i++
+			continue
+		}
+		if withOutputs && strings.HasPrefix(line, `-- !etag`) {
+			matches := rxETag.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.ETag = append([]string{}, strings.Fields(matches[1])...)
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !if_match`) {
+			matches := rxIfMatch.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.IfMatch = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !cache_key`) {
+			matches := rxCacheKey.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.CacheKeyFields = append(cmd.CacheKeyFields, IRCacheKeyField{Name: matches[1], Expr: strings.TrimSpace(matches[2])})
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !partition_by`) {
+			matches := rxPartitionBy.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.PartitionBy, cmd.PartitionInterval = matches[1], matches[2]
+			i++
+			continue
+		}
+		if line == `-- !warmup` {
+			cmd.WarmUp = true
+			i++
+			continue
+		}
+		if line == `-- !compare` {
+			cmd.Compare = true
+			i++
+			continue
+		}
+		if line == `-- !model_gen` {
+			cmd.ModelGen = true
+			i++
+			continue
+		}
+		if withOutputs && strings.HasPrefix(line, `-- !tags`) {
+			cmd.Tags, cmd.TagCase = parseTags(line, i)
+			i++
+			continue
+		}
+		if withOutputs && strings.HasPrefix(line, `-- !mapper`) {
+			matches := rxMapper.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Mapper = matches[1]
+			i++
+			continue
+		}
+		if withOutputs && strings.HasPrefix(line, `-- !as_of`) {
+			matches := rxAsOf.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.AsOf = matches[1]
+			cmd.AsOfTables = strings.Fields(matches[2])
+			i++
+			continue
+		}
+		if withOutputs && strings.HasPrefix(line, `-- !model`) {
+			matches := rxModel.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Model = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !deprecated`) {
+			matches := rxDeprecated.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Deprecated = matches[1]
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !max_concurrency`) {
+			matches := rxMaxConc.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			limit, err := strconv.Atoi(matches[1])
+			if err != nil || limit == 0 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.MaxConcurrency = limit
+			cmd.NoWait = matches[2] != ""
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !limit_group`) {
+			matches := rxLimitGroup.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.LimitGroup = matches[1]
+			i++
+			continue
+		}
+		if line == `-- !no_prepare` {
+			cmd.NoPrepare = true
+			i++
+			continue
+		}
+		if line == `-- !readonly` {
+			cmd.ReadOnly = true
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !audit_log`) {
+			matches := rxAuditLog.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.AuditLog = true
+			cmd.AuditInputs = strings.Fields(matches[1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !bind`) {
+			matches := rxBind.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Bind = strings.Fields(matches[1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !key`) {
+			matches := rxKey.FindStringSubmatch(line)
+			if len(matches) != 3 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Key = matches[1]
+			cmd.KeyInputs = strings.Fields(matches[2])
+			i++
+			continue
+		}
+		if withOutputs && strings.HasPrefix(line, `-- !fallback`) {
+			matches := rxFallback.FindStringSubmatch(line)
+			if len(matches) != 2 {
+				panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+			}
+			cmd.Fallback = matches[1]
+			i++
+			continue
+		}
+		if directive != nil && directive(line, i) {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line, `-- !`) {
+			panic(fmt.Sprintf("Unknown command on line %d: %q", i, line))
+		}
+		cmd.Body = append(cmd.Body, line)
+		i++
+	}
+	return i
+}
+
+// blockHasEnd reports whether the block of a command starting on line i of
+// input ends with a -- !end line, before the next command.
+func blockHasEnd(input []string, i int) bool {
+	for ix := i - 1; ix >= 0 && ix < len(input); ix++ {
+		line := strings.TrimRight(input[ix], "\r")
+		if line == `-- !end` {
+			return true
+		}
+		if rxCommand.MatchString(line) {
+			return false
+		}
+	}
+	return false
+}
+
+// trimBlankLines returns lines without its leading and trailing blank lines.
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func LoadTemplates() {
+	var err error
+	headerTmpl, err = template.New("header").Parse(header)
+	if err != nil {
+		panic(err)
+	}
+	largeResultTmpl, err = template.New("large_result").Parse(largeResult)
+	if err != nil {
+		panic(err)
+	}
+	columnCheckTmpl, err = template.New("column_check").Parse(columnCheck)
+	if err != nil {
+		panic(err)
+	}
+	normTypeTmpl, err = template.New("norm_type").Parse(normType)
+	if err != nil {
+		panic(err)
+	}
+	stmtCacheTmpl, err = template.New("stmt_cache").Parse(stmtCache)
+	if err != nil {
+		panic(err)
+	}
+	callOptionsTmpl, err = template.New("call_options").Parse(callOptions)
+	if err != nil {
+		panic(err)
+	}
+	middlewareTmpl, err = template.New("middleware").Parse(middleware)
+	if err != nil {
+		panic(err)
+	}
+	prepareAllTmpl, err = template.New("prepare_all").Parse(prepareAll)
+	if err != nil {
+		panic(err)
+	}
+	mockTmpl, err = template.New("mock").Funcs(template.FuncMap{"join": strings.Join}).Parse(mock)
+	if err != nil {
+		panic(err)
+	}
+	querierTmpl, err = template.New("querier").Funcs(template.FuncMap{"join": strings.Join}).Parse(querier)
+	if err != nil {
+		panic(err)
+	}
+	fuzzTmpl, err = template.New("fuzz").Funcs(funcMap).Parse(fuzz)
+	if err != nil {
+		panic(err)
+	}
+	concurrencyLimitTmpl, err = template.New("concurrency_limit").Parse(concurrencyLimit)
+	if err != nil {
+		panic(err)
+	}
+	rateLimitTmpl, err = template.New("rate_limit").Parse(rateLimit)
+	if err != nil {
+		panic(err)
+	}
+	fallbackTmpl, err = template.New("fallback").Parse(fallback)
+	if err != nil {
+		panic(err)
+	}
+	idTypesTmpl, err = template.New("id_types").Parse(idTypes)
+	if err != nil {
+		panic(err)
+	}
+	keyTypesTmpl, err = template.New("key_types").Funcs(funcMap).Parse(keyTypes)
+	if err != nil {
+		panic(err)
+	}
+	contextKeysTmpl, err = template.New("context_keys").Parse(contextKeys)
+	if err != nil {
+		panic(err)
+	}
+	modelTypesTmpl, err = template.New("model_types").Funcs(funcMap).Parse(modelTypes)
+	if err != nil {
+		panic(err)
+	}
+	modelFileTmpl, err = template.New("model_file").Funcs(funcMap).Parse(modelFile + `{{define "model_types"}}` + modelTypes + `{{end}}`)
+	if err != nil {
+		panic(err)
+	}
+	scriptStepTmpl, err = template.New("script_step").Parse(scriptStep)
+	if err != nil {
+		panic(err)
+	}
+	scriptTmpl, err = template.New("script").Funcs(funcMap).Parse(script + countCall)
+	if err != nil {
+		panic(err)
+	}
+	auditLogTmpl, err = template.New("audit_log").Parse(auditLog)
+	if err != nil {
+		panic(err)
+	}
+	bindTmpl, err = template.New("bind").Funcs(funcMap).Parse(bind)
+	if err != nil {
+		panic(err)
+	}
+	nullableTmpl, err = template.New("nullable").Parse(nullable)
+	if err != nil {
+		panic(err)
+	}
+	usageCountsTmpl, err = template.New("usage_counts").Parse(usageCounts)
+	if err != nil {
+		panic(err)
+	}
+	expandSlicesTmpl, err = template.New("expand_slices").Parse(expandSlices)
+	if err != nil {
+		panic(err)
+	}
+	asOfQueryTmpl, err = template.New("as_of_query").Parse(asOfQuery)
+	if err != nil {
+		panic(err)
+	}
+	partitionsTmpl, err = template.New("partitions").Parse(partitions)
+	if err != nil {
+		panic(err)
+	}
+	encryptedFieldTmpl, err = template.New("encrypted_field").Parse(encryptedField)
+	if err != nil {
+		panic(err)
+	}
+	etagMethodTmpl, err = template.New("etag_method").Funcs(template.FuncMap{"join": strings.Join}).Parse(etagMethod)
+	if err != nil {
+		panic(err)
+	}
+	etagMismatchTmpl, err = template.New("etag_mismatch").Parse(etagMismatch)
+	if err != nil {
+		panic(err)
+	}
+	ifMatchTmpl, err = template.New("if_match").Funcs(funcMap).Parse(ifMatch)
+	if err != nil {
+		panic(err)
+	}
+	warmUpTmpl, err = template.New("warm_up").Parse(warmUp)
+	if err != nil {
+		panic(err)
+	}
+	compareRowsTmpl, err = template.New("compare_rows").Parse(compareRows)
+	if err != nil {
+		panic(err)
+	}
+	compareTmpl, err = template.New("compare").Funcs(funcMap).Parse(compare)
+	if err != nil {
+		panic(err)
+	}
+	cacheKeyFuncTmpl, err = template.New("cache_key_func").Parse(cacheKeyFunc)
+	if err != nil {
+		panic(err)
+	}
+	cacheKeyTmpl, err = template.New("cache_key").Funcs(funcMap).Parse(cacheKey)
+	if err != nil {
+		panic(err)
+	}
+	resultDeadlineTmpl, err = template.New("result_deadline").Parse(resultDeadline)
+	if err != nil {
+		panic(err)
+	}
+	connectTmpl, err = template.New("connect").Parse(connect)
+	if err != nil {
+		panic(err)
+	}
+	deprecationTmpl, err = template.New("deprecation").Parse(deprecation)
+	if err != nil {
+		panic(err)
+	}
+	readOneTmpl, err = template.New("read_one").Funcs(funcMap).Parse(readOne + callTimeout + acquireSlot + countCall + contextInputs + expandQuery + mapperRow)
+	if err != nil {
+		panic(err)
+	}
+	readTmpl, err = template.New("read").Funcs(funcMap).Parse(read + acquireSlot + countCall + contextInputs + expandQuery + mapperRow)
+	if err != nil {
+		panic(err)
+	}
+	execTmpl, err = template.New("exec").Funcs(funcMap).Parse(exec + callTimeout + acquireSlot + countCall + contextInputs + expandQuery)
+	if err != nil {
+		panic(err)
+	}
+	execManyTmpl, err = template.New("exec_many").Funcs(funcMap).Parse(execMany + callTimeout + acquireSlot + countCall + beginTx)
+	if err != nil {
+		panic(err)
+	}
+	copyTmpl, err = template.New("copy").Funcs(funcMap).Parse(copyFrom + callTimeout + acquireSlot + countCall + beginTx)
+	if err != nil {
+		panic(err)
+	}
+	viewTmpl, err = template.New("view").Funcs(funcMap).Parse(view)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GenerateSource returns the formatted Go source for nf.
+func GenerateSource(nf *NormFile) []byte {
+	var bb bytes.Buffer
+
+	if err := headerTmpl.Execute(&bb, headerData(nf)); err != nil {
+		panic(err)
+	}
+
+	if err := normTypeTmpl.Execute(&bb, nf.needsConnect()); err != nil {
+		panic(err)
+	}
+
+	if err := stmtCacheTmpl.Execute(&bb, nil); err != nil {
+		panic(err)
+	}
+
+	if err := callOptionsTmpl.Execute(&bb, nil); err != nil {
+		panic(err)
+	}
+
+	if err := middlewareTmpl.Execute(&bb, nil); err != nil {
+		panic(err)
+	}
+
+	if err := prepareAllTmpl.Execute(&bb, nf.preparedQueries()); err != nil {
+		panic(err)
+	}
+
+	if len(nf.WarmUpQueries) > 0 {
+		if err := warmUpTmpl.Execute(&bb, nf.WarmUpQueries); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.LargeResultThreshold > 0 {
+		if err := largeResultTmpl.Execute(&bb, nf.LargeResultThreshold); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.needsConnect() {
+		if err := connectTmpl.Execute(&bb, nf); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasLimits() {
+		if err := concurrencyLimitTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasLimitGroups() {
+		if err := rateLimitTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if len(nf.IDTypes) > 0 {
+		if err := idTypesTmpl.Execute(&bb, nf.IDTypes); err != nil {
+			panic(err)
+		}
+	}
+
+	if len(nf.Keys) > 0 {
+		if err := keyTypesTmpl.Execute(&bb, nf.Keys); err != nil {
+			panic(err)
+		}
+	}
+
+	if len(nf.ContextKeys) > 0 {
+		if err := contextKeysTmpl.Execute(&bb, nf.ContextKeys); err != nil {
+			panic(err)
+		}
+	}
+
+	if len(nf.Models) > 0 && nf.ModelPkg == "" {
+		if err := modelTypesTmpl.Execute(&bb, nf.Models); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasAuditLog() {
+		if err := auditLogTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasScripts() {
+		if err := scriptStepTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if len(nf.Partitions) > 0 {
+		if err := partitionsTmpl.Execute(&bb, nf.Partitions); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasEncrypted() {
+		if err := encryptedFieldTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasIfMatch() {
+		if err := etagMismatchTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.CacheKeys {
+		if err := cacheKeyFuncTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasCompare() {
+		if err := compareRowsTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasNullOutputs() {
+		if err := nullableTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.UsageCounts {
+		if err := usageCountsTmpl.Execute(&bb, nf.countedQueries()); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasSliceInputs() {
+		if err := expandSlicesTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasAsOf() {
+		if err := asOfQueryTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasFallbacks() {
+		if err := fallbackTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.hasResults() {
+		if err := resultDeadlineTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.CheckColumns {
+		if err := columnCheckTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if nf.Deprecations {
+		if err := deprecationTmpl.Execute(&bb, nil); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, cmd := range nf.Cmds {
+		genCmd(&bb, cmd)
+	}
+
+	formatted, err := format.Source(bb.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return appendQuerier(formatted, nf.readerFuncs())
+}
+
+// genCmd writes the code generated for cmd to w.
+func genCmd(w io.Writer, cmd genAble) {
+	if err := cmd.gen(w); err != nil {
+		panic(err)
+	}
+	if cmd.Base().ETagMethod {
+		if err := etagMethodTmpl.Execute(w, cmd.Base()); err != nil {
+			panic(err)
+		}
+	}
+	if c, ok := cmd.(*cmdExec); ok && c.IfMatch != "" {
+		if err := ifMatchTmpl.Execute(w, c); err != nil {
+			panic(err)
+		}
+	}
+	if cmd.Base().CacheKey {
+		if err := cacheKeyTmpl.Execute(w, cmd.Base()); err != nil {
+			panic(err)
+		}
+	}
+	if cmd.Base().Compare {
+		_, one := cmd.(*cmdReadOne)
+		if err := compareTmpl.Execute(w, compareCmd{cmd.Base(), one}); err != nil {
+			panic(err)
+		}
+	}
+	if c := cmd.Base(); len(c.Bind) > 0 {
+		if err := bindTmpl.Execute(w, bindCmd{c, bindResults(cmd)}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// packageName guesses the name of the package imported as path from its last
+// element, skipping a major version suffix.
+func packageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && regexp.MustCompile(`^v[0-9]+$`).MatchString(name) {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
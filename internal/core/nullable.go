@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const nullable = `
+// nullable scans a column that can be NULL into dst, a pointer to a type
+// that cannot hold NULL, storing the zero value for NULL. It is used for the
+// outputs declared with !output <name> <type> null.
+type nullable struct {
+	dst interface{}
+}
+
+func (n nullable) Scan(src interface{}) error {
+	switch dst := n.dst.(type) {
+	case *string:
+		var v sql.NullString
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.String
+	case *int:
+		var v sql.NullInt64
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = int(v.Int64)
+	case *int32:
+		var v sql.NullInt32
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Int32
+	case *int64:
+		var v sql.NullInt64
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Int64
+	case *float64:
+		var v sql.NullFloat64
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Float64
+	case *bool:
+		var v sql.NullBool
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Bool
+	case *time.Time:
+		var v sql.NullTime
+		if err := v.Scan(src); err != nil {
+			return err
+		}
+		*dst = v.Time
+	default:
+		return errors.New("norm: unsupported type for a nullable output")
+	}
+	return nil
+}
+`
+
+var nullableTmpl *template.Template
+
+// nullableTypes are the types of the outputs that can be declared null.
+var nullableTypes = map[string]bool{
+	"string":    true,
+	"int":       true,
+	"int32":     true,
+	"int64":     true,
+	"float64":   true,
+	"bool":      true,
+	"time.Time": true,
+}
+
+// isNullOutput reports whether the output named name is declared null.
+func (c *cmdBase) isNullOutput(name string) bool {
+	for _, out := range c.Outputs {
+		if out.Name == name {
+			return out.Null
+		}
+	}
+	return false
+}
+
+// nullableDest wraps the scan destination expr of the output named name in
+// nullable if it is declared null.
+func (c *cmdBase) nullableDest(name, expr string) string {
+	if c.isNullOutput(name) {
+		return "nullable{" + expr + "}"
+	}
+	return expr
+}
+
+// ScanDests returns the arguments of rows.Scan in the Scan method of the
+// result, whose parameters are named after the outputs.
+func (c *cmdBase) ScanDests() string {
+	var dests []string
+	for _, o := range c.Outputs {
+		dests = append(dests, c.scanDest(o.Name, o.Name))
+	}
+	return strings.Join(dests, ", ")
+}
+
+// checkNullOutputs checks that the outputs of the commands of nf declared null
+// have a type nullable supports.
+func checkNullOutputs(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		for _, o := range c.Outputs {
+			if o.Null && !nullableTypes[o.Typ] {
+				var types []string
+				for t := range nullableTypes {
+					types = append(types, t)
+				}
+				sort.Strings(types)
+				panic(fmt.Sprintf("Output %s of %s on line %d cannot be null: the type must be one of %s, or a pointer", o.Name, c.FuncName, c.Line, strings.Join(types, ", ")))
+			}
+		}
+	}
+}
+
+// hasNullOutputs reports whether any command of nf has outputs declared null.
+func (nf *NormFile) hasNullOutputs() bool {
+	for _, cmd := range nf.Cmds {
+		for _, o := range cmd.Base().Outputs {
+			if o.Null {
+				return true
+			}
+		}
+	}
+	return false
+}
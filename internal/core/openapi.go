@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// openAPISchema is the subset of an OpenAPI 3.0 schema object used for the
+// generated structs.
+type openAPISchema struct {
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Nullable    bool                      `json:"nullable,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Properties  map[string]*openAPISchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+	Items       *openAPISchema            `json:"items,omitempty"`
+}
+
+// openAPINullTypes maps the sql.Null types to the type they hold.
+var openAPINullTypes = map[string]string{
+	"sql.NullBool":    "bool",
+	"sql.NullByte":    "byte",
+	"sql.NullFloat64": "float64",
+	"sql.NullInt16":   "int16",
+	"sql.NullInt32":   "int32",
+	"sql.NullInt64":   "int64",
+	"sql.NullString":  "string",
+	"sql.NullTime":    "time.Time",
+}
+
+// openAPIType returns the schema of a field of Go type typ. Types that are
+// not known are left without a type, which allows any value.
+func openAPIType(typ string) *openAPISchema {
+	if strings.HasPrefix(typ, "*") {
+		s := openAPIType(typ[1:])
+		s.Nullable = true
+		return s
+	}
+	if inner, ok := openAPINullTypes[typ]; ok {
+		s := openAPIType(inner)
+		s.Nullable = true
+		return s
+	}
+	if typ == "[]byte" {
+		return &openAPISchema{Type: "string", Format: "byte"}
+	}
+	if strings.HasPrefix(typ, "[]") {
+		return &openAPISchema{Type: "array", Items: openAPIType(typ[2:])}
+	}
+	switch typ {
+	case "string":
+		return &openAPISchema{Type: "string"}
+	case "bool":
+		return &openAPISchema{Type: "boolean"}
+	case "int8", "int16", "int32", "rune", "uint8", "uint16", "byte":
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case "int", "int64", "uint", "uint32", "uint64":
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case "float32":
+		return &openAPISchema{Type: "number", Format: "float"}
+	case "float64":
+		return &openAPISchema{Type: "number", Format: "double"}
+	case "time.Time":
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+	return &openAPISchema{}
+}
+
+// openAPISchemas returns the schemas of the structs read by the commands of
+// nf, keyed by struct name: the generated Output structs, the models of views
+// and the models named with !model. A model read by several commands gets the
+// outputs of all of them. Only generated structs get a description.
+func openAPISchemas(nf *NormFile) map[string]*openAPISchema {
+	ret := map[string]*openAPISchema{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		switch cmd.(type) {
+		case *cmdExec, *cmdExecMany, *cmdCopy, *cmdScript:
+			continue
+		}
+		if c.Model == "" && len(c.Outputs) < 2 {
+			continue
+		}
+		name := c.ResultType()
+		if nf.ModelPkg != "" {
+			name = strings.TrimPrefix(name, packageName(nf.ModelPkg)+".")
+		}
+		s, ok := ret[name]
+		if !ok {
+			s = &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+			ret[name] = s
+		}
+		// Models named with !model are declared elsewhere, the doc of the
+		// command describes the query rather than the model.
+		if _, view := cmd.(*cmdView); view || c.Model == "" {
+			s.Description = strings.Join(c.Doc, " ")
+		}
+		for _, out := range c.Outputs {
+			if _, ok := s.Properties[out.Name]; ok {
+				continue
+			}
+			p := openAPIType(out.Typ)
+			s.Properties[out.Name] = p
+			if !p.Nullable {
+				s.Required = append(s.Required, out.Name)
+			}
+		}
+	}
+	return ret
+}
+
+// WriteOpenAPI writes an OpenAPI document with the schemas of the structs of
+// nf as components, for HTTP layers to reference.
+func WriteOpenAPI(w io.Writer, nf *NormFile) error {
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":   nf.Package,
+			"version": "1.0.0",
+		},
+		"paths": map[string]interface{}{},
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas(nf),
+		},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
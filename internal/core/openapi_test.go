@@ -0,0 +1,15 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteOpenAPI(t *testing.T) {
+	nf := parseTestFile(t, "testdata/openapi/store.norm.sql")
+	var bb bytes.Buffer
+	if err := WriteOpenAPI(&bb, nf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/openapi/store.json", bb.Bytes())
+}
@@ -0,0 +1,82 @@
+package core
+
+import "text/template"
+
+const callOptions = `
+// Option changes how a single call of a query method runs, see WithTimeout
+// and OnPrimary. The query methods of !read, !read_one, !exec, !exec_many and
+// !copy commands take options after their inputs.
+type Option func(*callOptions)
+
+// callOptions are the options of a call, set by its Options.
+type callOptions struct {
+	timeout   time.Duration
+	onPrimary bool
+}
+
+// WithTimeout cancels the call if it does not complete within d. For Scan
+// functions, d bounds the whole result, until it is closed.
+func WithTimeout(d time.Duration) Option {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// OnPrimary runs a read on the Norm it is called on instead of its replica,
+// see WithReplica, for example to read back a row written just before.
+func OnPrimary() Option {
+	return func(o *callOptions) {
+		o.onPrimary = true
+	}
+}
+
+func applyOptions(opts []Option) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// context returns ctx with the timeout of the call, if any, and the function
+// canceling it.
+func (o callOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// WithReplica returns a Norm running the reads of n on replica, unless they
+// are called with OnPrimary, and the other queries on n. The reads count in
+// the Stats of replica. Transactions, see WithTx and Begin, run all their
+// queries on n.
+func (n *Norm) WithReplica(replica *Norm) *Norm {
+	ret := *n
+	ret.replica = replica
+	return &ret
+}
+
+// reader returns the Norm to run a read called with o on.
+func (n *Norm) reader(o callOptions) *Norm {
+	if n.replica == nil || o.onPrimary {
+		return n
+	}
+	return n.replica
+}
+`
+
+var callOptionsTmpl *template.Template
+
+// callTimeout applies the timeout of the call in the generated query methods
+// that run the query, after the transactions of !readonly and !audit_log
+// queries, which run the method again with the same options. Reads go to the
+// replica of the Norm before that, with n.reader.
+const callTimeout = `
+{{- define "timeout"}}
+	if call.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.timeout)
+		defer cancel()
+	}
+{{- end}}`
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nullTypes maps the Go types of columns to the type scanning them when they
+// can be NULL.
+var nullTypes = map[string]string{
+	"bool":      "sql.NullBool",
+	"int64":     "sql.NullInt64",
+	"float64":   "sql.NullFloat64",
+	"string":    "sql.NullString",
+	"time.Time": "sql.NullTime",
+}
+
+// deriveOutputs synthesizes the outputs of the commands of nf reading rows
+// that declare none, from the SELECT or RETURNING list of their body. Every
+// column must be a column reference or have an alias, which gives the name of
+// the output. Columns of tables created in the file get a Go type matching
+// their SQL type, with a sql.Null type when they can be NULL. count()
+// expressions are int64, and other expressions interface{}.
+func deriveOutputs(nf *NormFile) {
+	var schema []*table
+	for _, cmd := range nf.Cmds {
+		switch cmd.(type) {
+		case *cmdExec, *cmdExecMany, *cmdCopy, *cmdScript:
+			continue
+		}
+		c := cmd.Base()
+		if len(c.Outputs) > 0 {
+			continue
+		}
+		cols := selectList(tokenize(c.BodyString()))
+		if len(cols) == 0 {
+			continue
+		}
+		if schema == nil {
+			schema = ParseSchema(nf)
+		}
+		reads, _ := tableRefs(c.BodyString())
+		seen := map[string]bool{}
+		for ix, expr := range cols {
+			name, ref := columnName(expr)
+			if name == "" {
+				panic(fmt.Sprintf("Cannot derive output %d of the query on line %d, give the column an alias or declare the outputs", ix+1, c.Line))
+			}
+			out := arg{Name: FieldName(name), Typ: "interface{}"}
+			if out.Name == "" {
+				panic(fmt.Sprintf("Cannot derive output %d of the query on line %d, give the column an alias or declare the outputs", ix+1, c.Line))
+			}
+			if seen[out.Name] {
+				panic(fmt.Sprintf("Duplicate output %s of the query on line %d", out.Name, c.Line))
+			}
+			seen[out.Name] = true
+			if ref != "" {
+				if t, col := readColumn(schema, reads, ref); col != nil {
+					out.Typ = nf.outputType(t, col)
+				}
+			} else if len(expr) > 0 && expr[0].is("count") {
+				out.Typ = "int64"
+			}
+			if out.Typ == "time.Time" {
+				nf.addImport("", "time")
+			}
+			c.Outputs = append(c.Outputs, out)
+		}
+	}
+}
+
+// columnName returns the name of the column returned for the expression
+// expr of a SELECT list, and the column it refers to if it is a column
+// reference.
+func columnName(expr []sqlToken) (name, ref string) {
+	n := len(expr)
+	if n == 0 || !isNameToken(expr[n-1]) || expr[n-1].is("END") {
+		return "", ""
+	}
+	name = expr[n-1].name()
+	if isColumnRef(expr) {
+		return name, name
+	}
+	base := expr[:n-1]
+	switch last := base[len(base)-1]; {
+	case last.is("AS"):
+		base = base[:len(base)-1]
+	case last.Text == ")" || isNameToken(last):
+		// An alias without AS.
+	default:
+		return "", ""
+	}
+	if isColumnRef(base) {
+		return name, base[len(base)-1].name()
+	}
+	return name, ""
+}
+
+func isNameToken(t sqlToken) bool {
+	return t.Kind == tokIdent || t.Kind == tokQuotedIdent
+}
+
+// isColumnRef reports whether toks is a column name, possibly qualified with
+// a table name.
+func isColumnRef(toks []sqlToken) bool {
+	switch len(toks) {
+	case 1:
+		return isNameToken(toks[0])
+	case 3:
+		return isNameToken(toks[0]) && toks[1].Text == "." && isNameToken(toks[2])
+	}
+	return false
+}
+
+// readColumn returns the column named name of the tables in reads and its
+// table, or nil.
+func readColumn(schema []*table, reads []string, name string) (*table, *column) {
+	for _, tableName := range reads {
+		if t, col := findColumn(schema, tableName, name); col != nil {
+			return t, col
+		}
+	}
+	return nil, nil
+}
+
+// FieldName returns the exported Go field name of the column named col.
+func FieldName(col string) string {
+	var name strings.Builder
+	for _, p := range strings.FieldsFunc(col, func(r rune) bool {
+		return r == '_' || !isIdentPart(r)
+	}) {
+		if strings.EqualFold(p, "id") {
+			name.WriteString("ID")
+			continue
+		}
+		name.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+	return name.String()
+}
+
+// outputType returns the Go type scanning the column col of t. Columns that
+// can be NULL are scanned into a sql.Null type, or a pointer to their ID type.
+func (nf *NormFile) outputType(t *table, col *column) string {
+	typ := nf.columnType(t.Name, col, goInputType(col.Type))
+	if col.NotNull {
+		return typ
+	}
+	if null, ok := nullTypes[typ]; ok {
+		return null
+	}
+	if typ != goInputType(col.Type) {
+		return "*" + typ
+	}
+	return typ
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"text/template"
+)
+
+const partitions = `
+// partitionKey is a partition of the database db.
+type partitionKey struct {
+	db   *sql.DB
+	name string
+}
+
+// createdPartitions holds the partitionKey of the partitions created by
+// createPartition outside of a transaction, which are not created again in the
+// same database.
+var createdPartitions sync.Map
+
+// createPartition creates the partition of table holding the rows of t, for
+// a table partitioned by range with one partition per day, month or year as
+// given by interval. Partitions are named after the table and the start of
+// their range, like events_2024_01 for the monthly partition of January 2024.
+func createPartition(ctx context.Context, db DBTX, table, interval string, t time.Time) error {
+	t = t.UTC()
+	start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	var end time.Time
+	var layout string
+	switch interval {
+	case "daily":
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		end, layout = start.AddDate(0, 0, 1), "2006_01_02"
+	case "monthly":
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end, layout = start.AddDate(0, 1, 0), "2006_01"
+	default:
+		end, layout = start.AddDate(1, 0, 0), "2006"
+	}
+	name := table + "_" + start.Format(layout)
+	// A partition created in a transaction is gone if it rolls back, so only
+	// those created outside of one are remembered.
+	sqlDB, ok := db.(*sql.DB)
+	if ok {
+		if _, done := createdPartitions.Load(partitionKey{sqlDB, name}); done {
+			return nil
+		}
+	}
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')", name, table, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("creating partition %s of %s: %v", name, table, err)
+	}
+	if ok {
+		createdPartitions.Store(partitionKey{sqlDB, name}, true)
+	}
+	return nil
+}
+{{range .}}
+// {{.FuncName}} creates the {{.Interval}} partition of {{.Table}} holding the
+// rows of t if it does not exist. The inserts declared with !partition_by call
+// it before writing their row.
+func (n *Norm) {{.FuncName}}(ctx context.Context, t time.Time) error {
+	return createPartition(ctx, n.db, "{{.Table}}", "{{.Interval}}", t)
+}
+{{end}}`
+
+var partitionsTmpl *template.Template
+
+// partitionIntervals are the ranges of the partitions of !partition_by.
+var partitionIntervals = map[string]bool{
+	"daily":   true,
+	"monthly": true,
+	"yearly":  true,
+}
+
+// partitionedTable is a table partitioned by range that inserts create the
+// partitions of, see !partition_by.
+type partitionedTable struct {
+	Table    string
+	Interval string
+	FuncName string
+}
+
+// partitionFunc returns the name of the generated function creating the
+// partitions of table.
+func partitionFunc(table string) string {
+	return "Create" + FieldName(table) + "Partition"
+}
+
+// PartitionFunc returns the name of the generated function creating the
+// partition the row of the command is inserted in.
+func (c *cmdBase) PartitionFunc() string {
+	return partitionFunc(c.PartitionTable)
+}
+
+// resolvePartitions checks the !partition_by directives of the commands of nf
+// and returns the partitioned tables. The directive names the time.Time
+// input the row is partitioned by, and applies to !exec commands inserting
+// into one table. Commands inserting into the same table must use the same
+// interval.
+func resolvePartitions(nf *NormFile) []partitionedTable {
+	var ret []partitionedTable
+	byTable := map[string]int{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if c.PartitionBy == "" {
+			continue
+		}
+		if _, ok := cmd.(*cmdExec); !ok {
+			panic(fmt.Sprintf("!partition_by of %s on line %d: %s commands cannot insert into partitions", c.FuncName, c.Line, cmd.Kind()))
+		}
+		if !partitionIntervals[c.PartitionInterval] {
+			panic(fmt.Sprintf("!partition_by of %s on line %d: unknown interval %s, expected daily, monthly or yearly", c.FuncName, c.Line, c.PartitionInterval))
+		}
+		ix := inputIndex(c.Inputs, c.PartitionBy)
+		if ix < 0 {
+			panic(fmt.Sprintf("!partition_by of %s on line %d: unknown input %s", c.FuncName, c.Line, c.PartitionBy))
+		}
+		if c.Inputs[ix].Typ != "time.Time" {
+			panic(fmt.Sprintf("!partition_by of %s on line %d: input %s is a %s, not a time.Time", c.FuncName, c.Line, c.PartitionBy, c.Inputs[ix].Typ))
+		}
+		_, writes := tableRefs(c.BodyString())
+		if len(writes) != 1 {
+			panic(fmt.Sprintf("!partition_by of %s on line %d: the query must insert into one table", c.FuncName, c.Line))
+		}
+		c.PartitionTable = writes[0]
+		if ix, ok := byTable[c.PartitionTable]; ok {
+			if ret[ix].Interval != c.PartitionInterval {
+				panic(fmt.Sprintf("!partition_by of %s on line %d: %s is partitioned %s in another query", c.FuncName, c.Line, c.PartitionTable, ret[ix].Interval))
+			}
+			continue
+		}
+		byTable[c.PartitionTable] = len(ret)
+		ret = append(ret, partitionedTable{c.PartitionTable, c.PartitionInterval, partitionFunc(c.PartitionTable)})
+	}
+	return ret
+}
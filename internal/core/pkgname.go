@@ -0,0 +1,211 @@
+package core
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ResolvePackage sets the package of the generated file when the norm file
+// has no !package directive: the package of the other Go files in its
+// directory is used, or else a name derived from the directory. The
+// directory is not read when the directive is given.
+func ResolvePackage(nf *NormFile) error {
+	if nf.Package != "" {
+		return nil
+	}
+	dir := filepath.Dir(nf.OutFile)
+	sibling, _, err := siblingPackage(dir, nf.OutFile, false)
+	if err != nil {
+		return err
+	}
+	if sibling != "" {
+		nf.Package = sibling
+		return nil
+	}
+	nf.Package = "db"
+	if abs, err := filepath.Abs(dir); err == nil {
+		if name := identifier(filepath.Base(abs)); name != "" {
+			nf.Package = name
+		}
+	}
+	return nil
+}
+
+// CheckPackage checks the !package of nf against the package of the other Go
+// files in the directory of the generated file, which would not compile along
+// with it. Files generated by norm are left out, since they are rewritten
+// with the new package. A directory that does not exist yet has no files.
+func CheckPackage(nf *NormFile) error {
+	if nf.Package == "" {
+		return nil
+	}
+	sibling, file, err := siblingPackage(filepath.Dir(nf.OutFile), nf.OutFile, true)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if sibling != "" && sibling != nf.Package {
+		return fmt.Errorf("package %s conflicts with package %s declared in %s", nf.Package, sibling, file)
+	}
+	return nil
+}
+
+// siblingPackage returns the package declared by the non-test Go files in
+// dir other than outFile, and the file declaring it. Files excluded from the
+// build by their name or their build constraints, such as //go:build ignore
+// tools of package main, are skipped, and so are the files generated by norm
+// if skipGenerated is set. go/build is not used for this, since it runs the
+// go command.
+func siblingPackage(dir, outFile string, skipGenerated bool) (string, string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	out, err := filepath.Abs(outFile)
+	if err != nil {
+		return "", "", err
+	}
+	fset := token.NewFileSet()
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if abs, err := filepath.Abs(path); err == nil && abs == out {
+			continue
+		}
+		if !matchFileName(name) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return "", "", err
+		}
+		if skipGenerated && len(f.Comments) > 0 && f.Comments[0].List[0].Text == "// Code generated by norm. DO NOT EDIT." {
+			continue
+		}
+		if ok, err := matchConstraints(f.Comments); err != nil {
+			return "", "", fmt.Errorf("%s: %v", path, err)
+		} else if !ok {
+			continue
+		}
+		return f.Name.Name, path, nil
+	}
+	return "", "", nil
+}
+
+// knownOS and knownArch are the values of GOOS and GOARCH, which restrict the
+// files whose names end with them to the builds for them.
+var (
+	knownOS   = toSet("aix android darwin dragonfly freebsd hurd illumos ios js linux nacl netbsd openbsd plan9 solaris wasip1 windows zos")
+	knownArch = toSet("386 amd64 amd64p32 arm armbe arm64 arm64be loong64 mips mipsle mips64 mips64le mips64p32 mips64p32le ppc ppc64 ppc64le riscv riscv64 s390 s390x sparc sparc64 wasm")
+	unixOS    = toSet("aix android darwin dragonfly freebsd hurd illumos ios linux netbsd openbsd solaris")
+)
+
+func toSet(words string) map[string]bool {
+	ret := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		ret[word] = true
+	}
+	return ret
+}
+
+// matchFileName reports whether the Go file name is built for the current
+// GOOS and GOARCH, as far as its _GOOS, _GOARCH or _GOOS_GOARCH suffix goes.
+func matchFileName(name string) bool {
+	parts := strings.Split(strings.TrimSuffix(name, ".go"), "_")
+	if len(parts) < 2 {
+		return true
+	}
+	last := parts[len(parts)-1]
+	if len(parts) >= 3 && knownOS[parts[len(parts)-2]] && knownArch[last] {
+		return parts[len(parts)-2] == runtime.GOOS && last == runtime.GOARCH
+	}
+	switch {
+	case knownOS[last]:
+		return last == runtime.GOOS
+	case knownArch[last]:
+		return last == runtime.GOARCH
+	}
+	return true
+}
+
+// matchConstraints reports whether the //go:build or // +build lines among the
+// comments before the package clause of a file are satisfied by the current
+// GOOS and GOARCH, the gc compiler and the Go release.
+func matchConstraints(comments []*ast.CommentGroup) (bool, error) {
+	for _, group := range comments {
+		for _, c := range group.List {
+			if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				return false, err
+			}
+			if !expr.Eval(matchTag) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func matchTag(tag string) bool {
+	switch {
+	case tag == runtime.GOOS, tag == runtime.GOARCH, tag == "gc":
+		return true
+	case tag == "unix":
+		return unixOS[runtime.GOOS]
+	case strings.HasPrefix(tag, "go1."):
+		minor, err := strconv.Atoi(strings.TrimPrefix(tag, "go1."))
+		return err == nil && minor <= goMinor()
+	}
+	return false
+}
+
+// goMinor returns the minor version of the Go release norm is built with.
+func goMinor() int {
+	version := strings.TrimPrefix(runtime.Version(), "go1.")
+	if ix := strings.IndexAny(version, ".-rb "); ix >= 0 {
+		version = version[:ix]
+	}
+	minor, err := strconv.Atoi(version)
+	if err != nil {
+		// A development version is newer than all the releases.
+		return 1 << 30
+	}
+	return minor
+}
+
+// identifier turns a directory name into a package name, or returns "" if
+// that is not possible.
+func identifier(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		case r == '-' || r == '.':
+			sb.WriteRune('_')
+		}
+	}
+	ret := sb.String()
+	if ret == "" || unicode.IsDigit(rune(ret[0])) {
+		return ""
+	}
+	return ret
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a_plan9.go": "package plan9\n",
+		"b.go":       "//go:build !go1.1\n\npackage old\n",
+		"gen.go":     "//go:build ignore\n\npackage main\n",
+		"old.go":     "// +build ignore\n\npackage main\n",
+		"store.go":   "package store\n",
+		"a_test.go":  "package store_test\n",
+		"db.norm.go": "package stale\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		pkg  string
+		want string
+	}{
+		{"", "store"},
+		{"other", "other"},
+	}
+	for _, test := range tests {
+		nf := &NormFile{Package: test.pkg, OutFile: filepath.Join(dir, "db.norm.go")}
+		if err := ResolvePackage(nf); err != nil {
+			t.Fatal(err)
+		}
+		if nf.Package != test.want {
+			t.Errorf("Expected package %s for !package %q, got %s", test.want, test.pkg, nf.Package)
+		}
+	}
+}
+
+func TestResolvePackageSkipsDirectory(t *testing.T) {
+	nf := &NormFile{Package: "db", OutFile: filepath.Join("does", "not", "exist.go")}
+	if err := ResolvePackage(nf); err != nil {
+		t.Errorf("Expected the directory not to be read with !package, got %v", err)
+	}
+}
+
+func TestCheckPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"db.go":    "package old\n",
+		"users.go": "// Code generated by norm. DO NOT EDIT.\n\npackage old\n",
+		"gen.go":   "//go:build ignore\n\npackage main\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only generated and ignored files are next to db.go.
+	if err := CheckPackage(&NormFile{Package: "store", OutFile: filepath.Join(dir, "db.go")}); err != nil {
+		t.Errorf("Expected no conflict with the generated files, got %v", err)
+	}
+	err = CheckPackage(&NormFile{Package: "store", OutFile: filepath.Join(dir, "store.go")})
+	if want := "package store conflicts with package old declared in " + filepath.Join(dir, "db.go"); err == nil || err.Error() != want {
+		t.Errorf("Expected the error %q, got %v", want, err)
+	}
+	if err := CheckPackage(&NormFile{Package: "old", OutFile: filepath.Join(dir, "store.go")}); err != nil {
+		t.Errorf("Expected no conflict with the same package, got %v", err)
+	}
+	if err := CheckPackage(&NormFile{Package: "store", OutFile: filepath.Join(dir, "missing", "store.go")}); err != nil {
+		t.Errorf("Expected no conflict in a missing directory, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import "text/template"
+
+const prepareAll = `
+// PrepareAll prepares the queries of the norm file, so that errors in them,
+// like references to missing tables or columns, are reported at startup
+// instead of on first use. The statements are kept for the later calls.
+// Bulk loads with COPY are not checked, as they are only prepared inside a
+// transaction, and neither are the queries with !no_prepare.
+func (n *Norm) PrepareAll(ctx context.Context) error {
+	for _, q := range []struct {
+		funcName, query string
+		reuse           bool
+	}{
+{{- range .}}
+		{"{{.FuncName}}", ` + "`{{.Query}}`" + `, {{.Reuse}}},
+{{- end}}
+	} {
+		_, release, err := n.prepare(ctx, q.query, q.reuse)
+		if err != nil {
+			return fmt.Errorf("%s: %v", q.funcName, err)
+		}
+		release()
+	}
+	return nil
+}
+`
+
+var prepareAllTmpl *template.Template
+
+// preparedQuery is a query checked by PrepareAll.
+type preparedQuery struct {
+	FuncName string
+	Query    string
+	// Reuse is set for the queries kept in the statement cache by their
+	// functions.
+	Reuse bool
+}
+
+// preparedQueries returns the queries of nf checked by PrepareAll. Queries
+// with slice inputs are checked with one element per slice, for which
+// expandSlices leaves the placeholders as they are.
+func (nf *NormFile) preparedQueries() []preparedQuery {
+	var ret []preparedQuery
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().NoPrepare {
+			continue
+		}
+		switch c := cmd.(type) {
+		case *cmdCopy, *cmdScript:
+		case *cmdExecMany:
+			ret = append(ret, preparedQuery{c.FuncName, c.BodyString(), false})
+		case *cmdView:
+			list := c.list()
+			ret = append(ret, preparedQuery{list.FuncName, list.BodyString(), true})
+		default:
+			b := cmd.Base()
+			ret = append(ret, preparedQuery{b.FuncName, b.BodyString(), !b.HasSliceInputs()})
+		}
+	}
+	return ret
+}
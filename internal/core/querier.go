@@ -0,0 +1,192 @@
+package core
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+	"text/template"
+)
+
+// normMethods are the methods of Norm that are not queries.
+var normMethods = map[string]bool{
+	"Begin":       true,
+	"Close":       true,
+	"PrepareAll":  true,
+	"ReadOnly":    true,
+	"Stats":       true,
+	"Use":         true,
+	"WithReplica": true,
+	"WithTx":      true,
+}
+
+// querierMethod is a query method of the generated Norm type.
+type querierMethod struct {
+	Name string
+	// Sig is the signature without the func keyword, with parameter names.
+	Sig string
+	// Args are the parameter names, for passing them on in a call.
+	Args    []string
+	Results int
+}
+
+// querierMethods returns the query methods of Norm in the generated source
+// src, along with the parsed file.
+func querierMethods(src []byte) (*ast.File, []querierMethod) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		panic(err)
+	}
+	var ret []querierMethod
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.FuncDecl)
+		if !ok || d.Recv == nil || len(d.Recv.List) != 1 || !d.Name.IsExported() || normMethods[d.Name.Name] {
+			continue
+		}
+		if NodeString(fset, d.Recv.List[0].Type) != "*Norm" {
+			continue
+		}
+		m := querierMethod{
+			Name:    d.Name.Name,
+			Sig:     strings.TrimPrefix(NodeString(fset, d.Type), "func"),
+			Results: len(fieldTypes(fset, d.Type.Results)),
+		}
+		for _, p := range d.Type.Params.List {
+			for _, n := range p.Names {
+				arg := n.Name
+				if _, ok := p.Type.(*ast.Ellipsis); ok {
+					arg += "..."
+				}
+				m.Args = append(m.Args, arg)
+			}
+		}
+		ret = append(ret, m)
+	}
+	// Bind functions are helpers over the query methods, see !bind.
+	names := map[string]bool{}
+	for _, m := range ret {
+		names[m.Name] = true
+	}
+	queries := ret[:0]
+	for _, m := range ret {
+		if !strings.HasPrefix(m.Name, "Bind") || !names[strings.TrimPrefix(m.Name, "Bind")] {
+			queries = append(queries, m)
+		}
+	}
+	return f, queries
+}
+
+// readerFuncs returns the names of the functions of the commands of nf that
+// read rows: those of !read and !read_one commands, and the List queries of
+// views. The other query methods of Norm are in Writer.
+func (nf *NormFile) readerFuncs() map[string]bool {
+	ret := map[string]bool{}
+	for _, cmd := range nf.Cmds {
+		var funcs []string
+		switch c := cmd.(type) {
+		case *cmdRead, *cmdReadOne:
+			funcs = c.Funcs()
+		case *cmdView:
+			funcs = c.list().Funcs()
+		}
+		for _, name := range funcs {
+			ret[name] = true
+		}
+	}
+	return ret
+}
+
+const querier = `
+// Reader has the methods of Norm reading rows. Code depending on it, like
+// reports, cannot change data, see ReadOnlyNorm.
+type Reader interface {
+{{- range .Readers}}
+	{{.Name}}{{.Sig}}
+{{- end}}
+}
+
+// Writer has the methods of Norm running statements, and the other methods
+// not in Reader.
+type Writer interface {
+{{- range .Writers}}
+	{{.Name}}{{.Sig}}
+{{- end}}
+}
+
+// Querier has the query methods of Norm. Code depending on it instead of *Norm
+// can be tested with fakes.
+type Querier interface {
+	Reader
+	Writer
+}
+
+var _ Querier = (*Norm)(nil)
+
+// ReadOnlyNorm has the methods of Norm in Reader and none writing to the
+// database, so that the compiler rejects writes from the code using it. Open
+// it with the DSN of a read only user or replica, so that the database
+// rejects them too.
+type ReadOnlyNorm struct {
+	n *Norm
+}
+
+// NewReadOnlyNorm opens the database like NewNorm and returns a ReadOnlyNorm
+// using it.
+func NewReadOnlyNorm(driverName, dataSourceName string) (*ReadOnlyNorm, error) {
+	n, err := NewNorm(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadOnlyNorm{n}, nil
+}
+
+// ReadOnly returns a ReadOnlyNorm running the queries of n.
+func (n *Norm) ReadOnly() *ReadOnlyNorm {
+	return &ReadOnlyNorm{n}
+}
+
+// Close closes the Norm of r.
+func (r *ReadOnlyNorm) Close() error {
+	return r.n.Close()
+}
+
+var _ Reader = (*ReadOnlyNorm)(nil)
+{{range .Readers}}
+func (r *ReadOnlyNorm) {{.Name}}{{.Sig}} {
+	{{if .Results}}return {{end}}r.n.{{.Name}}({{join .Args ", "}})
+}
+{{end}}`
+
+var querierTmpl *template.Template
+
+// appendQuerier adds the Reader, Writer and Querier interfaces and the
+// ReadOnlyNorm type to the generated source src. The method sets are taken
+// from the generated code, so that they always match the methods of Norm, and
+// split by the names in readers, see readerFuncs.
+func appendQuerier(src []byte, readers map[string]bool) []byte {
+	_, methods := querierMethods(src)
+	var readMethods, writeMethods []querierMethod
+	for _, m := range methods {
+		if readers[m.Name] {
+			readMethods = append(readMethods, m)
+		} else {
+			writeMethods = append(writeMethods, m)
+		}
+	}
+	var bb bytes.Buffer
+	bb.Write(src)
+	if err := querierTmpl.Execute(&bb, map[string]interface{}{
+		"Readers": readMethods,
+		"Writers": writeMethods,
+	}); err != nil {
+		panic(err)
+	}
+	formatted, err := format.Source(bb.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return formatted
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// TestReadmeExample checks that the code shown in the Example section of the
+// README is the code generated for the query shown there.
+func TestReadmeExample(t *testing.T) {
+	data, err := ioutil.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme := string(data)
+	example := readme[strings.Index(readme, "## Example"):strings.Index(readme, "## Query catalog")]
+	query := codeBlock(t, example, "```sql\n")
+	want := codeBlock(t, example, "```go\n")
+	LoadTemplates()
+	nf := ParseData([]byte("-- !norm\n-- !package example\n"+query), []Source{{"README.md", 0}}, ParseOptions{})
+	src := string(GenerateSource(nf))
+	start := strings.Index(src, "type GetUserListNoModelResult struct")
+	end := strings.Index(src, "func (n *Norm) GetUserListNoModel(")
+	if start < 0 || end < 0 {
+		t.Fatal("The generated code has no GetUserListNoModel query")
+	}
+	end += strings.Index(src[end:], "\n}\n") + len("\n}\n")
+	if got := src[start:end]; got != want {
+		t.Errorf("The README example is out of date, it should be:\n%s", got)
+	}
+}
+
+// codeBlock returns the content of the first code block of text opened by
+// fence.
+func codeBlock(t *testing.T, text, fence string) string {
+	start := strings.Index(text, fence)
+	if start < 0 {
+		t.Fatalf("No %q code block", strings.TrimSpace(fence))
+	}
+	text = text[start+len(fence):]
+	return text[:strings.Index(text, "```")]
+}
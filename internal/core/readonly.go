@@ -0,0 +1,20 @@
+package core
+
+import "fmt"
+
+// checkReadOnly checks that the commands of nf with a !readonly directive
+// only read rows. The transaction the generated functions begin applies to a
+// Norm on a database; a Norm already running inside a transaction keeps it.
+func checkReadOnly(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if !c.ReadOnly {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne:
+		default:
+			panic(fmt.Sprintf("!readonly of %s on line %d: %s commands cannot be read only", c.FuncName, c.Line, cmd.Kind()))
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"io"
+)
+
+// cmdExecReturning runs a statement with a RETURNING clause, and scans the
+// returned row like a read_one command.
+type cmdExecReturning struct {
+	cmdReadOne
+}
+
+func (c *cmdExecReturning) gen(w io.Writer) error {
+	return readOneTmpl.Execute(w, c)
+}
+
+func (c *cmdExecReturning) Kind() string {
+	return "exec_returning"
+}
+
+// Reads is false, so that the statement runs on the primary even with a
+// replica.
+func (c *cmdExecReturning) Reads() bool {
+	return false
+}
+
+// checkReturning warns about exec_returning commands without a RETURNING
+// clause, which return no row to scan.
+func checkReturning(nf *NormFile) []Warning {
+	var ret []Warning
+	for _, cmd := range nf.Cmds {
+		c, ok := cmd.(*cmdExecReturning)
+		if !ok {
+			continue
+		}
+		found := false
+		depth := 0
+		for _, t := range tokenize(c.BodyString()) {
+			switch {
+			case t.Kind == tokPunct && t.Text == "(":
+				depth++
+			case t.Kind == tokPunct && t.Text == ")":
+				depth--
+			case depth == 0 && t.is("RETURNING"):
+				found = true
+			}
+		}
+		if !found {
+			ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: exec_returning statement has no RETURNING clause", c.FuncName)})
+		}
+	}
+	return ret
+}
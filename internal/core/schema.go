@@ -0,0 +1,280 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type column struct {
+	Name       string
+	Type       string
+	PrimaryKey bool
+	NotNull    bool
+}
+
+type foreignKey struct {
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+type table struct {
+	Name        string
+	Columns     []column
+	ForeignKeys []foreignKey
+	// Unique holds the columns of the primary key and of the unique
+	// constraints, which the databases index.
+	Unique [][]string
+}
+
+// ParseSchema collects the tables created by the exec commands of nf.
+func ParseSchema(nf *NormFile) []*table {
+	var ret []*table
+	for _, cmd := range nf.Cmds {
+		if _, ok := cmd.(*cmdExec); !ok {
+			continue
+		}
+		ret = append(ret, parseCreateTables(cmd.Base().BodyString())...)
+	}
+	return ret
+}
+
+// parseCreateTables returns the tables defined by the CREATE TABLE statements
+// in sql.
+func parseCreateTables(sql string) []*table {
+	var ret []*table
+	toks := tokenize(sql)
+	for ix := 0; ix < len(toks); ix++ {
+		if !toks[ix].is("CREATE") {
+			continue
+		}
+		j := ix + 1
+		for j < len(toks) && (toks[j].is("TEMP") || toks[j].is("TEMPORARY") || toks[j].is("UNLOGGED")) {
+			j++
+		}
+		if j >= len(toks) || !toks[j].is("TABLE") {
+			continue
+		}
+		j++
+		if j+2 < len(toks) && toks[j].is("IF") && toks[j+1].is("NOT") && toks[j+2].is("EXISTS") {
+			j += 3
+		}
+		name, j := qualifiedName(toks, j)
+		if name == "" || j >= len(toks) || toks[j].Text != "(" {
+			continue
+		}
+		end := matchParen(toks, j)
+		if end < 0 {
+			end = len(toks)
+		}
+		t := &table{Name: name}
+		for _, def := range splitTopLevel(toks[j+1 : end]) {
+			parseTableElement(t, def)
+		}
+		ret = append(ret, t)
+		ix = end
+	}
+	return ret
+}
+
+// qualifiedName reads a possibly schema qualified name starting at toks[ix].
+// It returns the name and the index of the sqlToken following it.
+func qualifiedName(toks []sqlToken, ix int) (string, int) {
+	var parts []string
+	for ix < len(toks) && (toks[ix].Kind == tokIdent || toks[ix].Kind == tokQuotedIdent) {
+		parts = append(parts, toks[ix].name())
+		ix++
+		if ix < len(toks) && toks[ix].Text == "." {
+			ix++
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "."), ix
+}
+
+func parseTableElement(t *table, def []sqlToken) {
+	if len(def) == 0 {
+		return
+	}
+	if def[0].is("CONSTRAINT") && len(def) > 2 {
+		def = def[2:]
+	}
+	switch {
+	case def[0].is("PRIMARY"):
+		t.Unique = append(t.Unique, parenNames(def, 0))
+		for _, c := range parenNames(def, 0) {
+			for ix := range t.Columns {
+				if t.Columns[ix].Name == c {
+					t.Columns[ix].PrimaryKey = true
+				}
+			}
+		}
+		return
+	case def[0].is("FOREIGN"):
+		fk := foreignKey{Columns: parenNames(def, 0)}
+		fk.RefTable, fk.RefColumns = references(def)
+		if fk.RefTable != "" {
+			t.ForeignKeys = append(t.ForeignKeys, fk)
+		}
+		return
+	case def[0].is("UNIQUE"):
+		t.Unique = append(t.Unique, parenNames(def, 0))
+		return
+	case def[0].is("CHECK") || def[0].is("EXCLUDE") || def[0].is("INDEX") || def[0].is("KEY"):
+		return
+	}
+	col := column{Name: def[0].name()}
+	ix := 1
+	var typ []string
+	for ix < len(def) && !isColumnConstraint(def[ix]) {
+		if def[ix].Text == "(" {
+			end := matchParen(def, ix)
+			if end < 0 {
+				end = len(def) - 1
+			}
+			var args []string
+			for _, a := range def[ix+1 : end] {
+				args = append(args, a.Text)
+			}
+			if len(typ) > 0 {
+				typ[len(typ)-1] += "(" + strings.Join(args, "") + ")"
+			}
+			ix = end + 1
+			continue
+		}
+		typ = append(typ, def[ix].Text)
+		ix++
+	}
+	col.Type = strings.Join(typ, " ")
+	for ; ix < len(def); ix++ {
+		switch {
+		case def[ix].is("PRIMARY"):
+			col.PrimaryKey = true
+			col.NotNull = true
+			t.Unique = append(t.Unique, []string{col.Name})
+		case def[ix].is("UNIQUE"):
+			t.Unique = append(t.Unique, []string{col.Name})
+		case def[ix].is("NOT") && ix+1 < len(def) && def[ix+1].is("NULL"):
+			col.NotNull = true
+		}
+	}
+	t.Columns = append(t.Columns, col)
+	if refTable, refColumns := references(def); refTable != "" {
+		t.ForeignKeys = append(t.ForeignKeys, foreignKey{[]string{col.Name}, refTable, refColumns})
+	}
+}
+
+func isColumnConstraint(t sqlToken) bool {
+	for _, kw := range []string{"CONSTRAINT", "PRIMARY", "NOT", "NULL", "UNIQUE", "CHECK", "DEFAULT", "REFERENCES", "COLLATE", "GENERATED", "AUTOINCREMENT", "AUTO_INCREMENT"} {
+		if t.is(kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// parenNames returns the names in the first parenthesized list at or after
+// def[ix].
+func parenNames(def []sqlToken, ix int) []string {
+	for ; ix < len(def); ix++ {
+		if def[ix].Text == "(" {
+			break
+		}
+	}
+	end := matchParen(def, ix)
+	if end < 0 {
+		return nil
+	}
+	var ret []string
+	for _, t := range def[ix+1 : end] {
+		if t.Kind == tokIdent || t.Kind == tokQuotedIdent {
+			ret = append(ret, t.name())
+		}
+	}
+	return ret
+}
+
+// references returns the table and columns of a REFERENCES clause in def.
+func references(def []sqlToken) (string, []string) {
+	for ix, t := range def {
+		if !t.is("REFERENCES") {
+			continue
+		}
+		name, next := qualifiedName(def, ix+1)
+		if next < len(def) && def[next].Text == "(" {
+			return name, parenNames(def, next)
+		}
+		return name, nil
+	}
+	return "", nil
+}
+
+// WriteMermaid writes tables as a Mermaid entity relationship diagram.
+func WriteMermaid(w io.Writer, tables []*table) error {
+	var sb strings.Builder
+	sb.WriteString("erDiagram\n")
+	for _, t := range tables {
+		fmt.Fprintf(&sb, "    %s {\n", mermaidWord(t.Name))
+		for _, c := range t.Columns {
+			fmt.Fprintf(&sb, "        %s %s", mermaidWord(c.Type), mermaidWord(c.Name))
+			if c.PrimaryKey {
+				sb.WriteString(" PK")
+			} else if isForeignKeyColumn(t, c.Name) {
+				sb.WriteString(" FK")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("    }\n")
+	}
+	for _, t := range tables {
+		for _, fk := range t.ForeignKeys {
+			fmt.Fprintf(&sb, "    %s }o--|| %s : %q\n", mermaidWord(t.Name), mermaidWord(fk.RefTable), strings.Join(fk.Columns, ", "))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteDot writes tables as a Graphviz digraph with an edge per foreign key.
+func WriteDot(w io.Writer, tables []*table) error {
+	var sb strings.Builder
+	sb.WriteString("digraph schema {\n")
+	sb.WriteString("    node [shape=record];\n")
+	for _, t := range tables {
+		var fields []string
+		for _, c := range t.Columns {
+			fields = append(fields, dotEscape(c.Name+" "+c.Type)+`\l`)
+		}
+		fmt.Fprintf(&sb, "    %q [label=\"{%s|%s}\"];\n", t.Name, dotEscape(t.Name), strings.Join(fields, ""))
+	}
+	for _, t := range tables {
+		for _, fk := range t.ForeignKeys {
+			fmt.Fprintf(&sb, "    %q -> %q [label=%q];\n", t.Name, fk.RefTable, strings.Join(fk.Columns, ", "))
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func isForeignKeyColumn(t *table, name string) bool {
+	for _, fk := range t.ForeignKeys {
+		for _, c := range fk.Columns {
+			if c == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mermaidWord makes s usable as a single word in a Mermaid diagram.
+func mermaidWord(s string) string {
+	return strings.NewReplacer(" ", "_", ",", "_", ".", "_").Replace(s)
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`).Replace(s)
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriteERD(t *testing.T) {
+	tables := ParseSchema(parseTestFile(t, "testdata/erd/schema.norm.sql"))
+	for golden, write := range map[string]func(io.Writer, []*table) error{
+		"testdata/erd/schema.mmd": WriteMermaid,
+		"testdata/erd/schema.dot": WriteDot,
+	} {
+		var bb bytes.Buffer
+		if err := write(&bb, tables); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, golden, bb.Bytes())
+	}
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+const scriptStep = `
+// ScriptStep describes a statement run by a function generated for a !script
+// block, passed to OnScriptStep.
+type ScriptStep struct {
+	// Script is the name of the function running the script.
+	Script string
+	// Step is the index of the statement in the script, starting at 1.
+	Step      int
+	Statement string
+	// DryRun is set when the statement was not run.
+	DryRun  bool
+	Elapsed time.Duration
+	Err     error
+}
+
+// OnScriptStep, if set, is called after every statement of a script, or for
+// every statement of a dry run.
+var OnScriptStep func(step ScriptStep)
+`
+
+var scriptStepTmpl *template.Template
+
+const script = `
+{{if .Deprecated}}
+var deprecated{{.FuncName}}Once sync.Once
+{{end}}
+// {{.FuncName}}Steps are the statements run by {{.FuncName}}, in order.
+var {{.FuncName}}Steps = []string{
+{{- range .Steps}}
+	{{printf "%q" .}},
+{{- end}}
+}
+
+// {{.FuncName}} runs {{.FuncName}}Steps one after the other, outside of a
+// transaction unless the Norm runs inside one, and stops at the first
+// error. With dryRun, the statements are only reported to OnScriptStep.
+{{- if .Doc}}
+//
+{{- end}}
+{{range .Doc}}// {{print .}}
+{{end -}}
+{{.DeprecatedDoc true -}}
+func (n *Norm) {{.FuncName}}(ctx context.Context, dryRun bool) error {
+{{- if .Deprecated}}
+	reportDeprecatedUse(&deprecated{{.FuncName}}Once, "{{.FuncName}}", {{printf "%q" .Deprecated}})
+{{- end}}
+{{- template "count" .}}
+	for ix, stmt := range {{.FuncName}}Steps {
+		step := ScriptStep{Script: "{{.FuncName}}", Step: ix + 1, Statement: stmt, DryRun: dryRun}
+		if !dryRun {
+			start := time.Now()
+			_, step.Err = n.db.ExecContext(ctx, stmt)
+			step.Elapsed = time.Since(start)
+		}
+		if OnScriptStep != nil {
+			OnScriptStep(step)
+		}
+		if step.Err != nil {
+			return fmt.Errorf("{{.FuncName}} step %d: %v", step.Step, step.Err)
+		}
+	}
+	return nil
+}
+`
+
+var scriptTmpl *template.Template
+
+// cmdScript runs a list of maintenance statements, like VACUUM or REINDEX,
+// separated by semicolons in the body. The statements are not prepared, as
+// databases do not prepare most maintenance statements.
+type cmdScript struct {
+	cmdBase
+}
+
+func (c *cmdScript) gen(w io.Writer) error {
+	return scriptTmpl.Execute(w, c)
+}
+
+func (c *cmdScript) Kind() string {
+	return "script"
+}
+
+func (c *cmdScript) Funcs() []string {
+	return []string{c.FuncName, c.FuncName + "Steps"}
+}
+
+// Steps returns the statements of the body, split at the semicolons outside
+// of strings and comments.
+func (c *cmdScript) Steps() []string {
+	body := c.BodyString()
+	var ret []string
+	start := 0
+	for _, tok := range tokenize(body) {
+		if tok.Text != ";" {
+			continue
+		}
+		if stmt := strings.TrimSpace(body[start:tok.Pos]); stmt != "" {
+			ret = append(ret, stmt)
+		}
+		start = tok.Pos + 1
+	}
+	if stmt := strings.TrimSpace(body[start:]); stmt != "" {
+		ret = append(ret, stmt)
+	}
+	return ret
+}
+
+// hasScripts reports whether nf has any !script commands.
+func (nf *NormFile) hasScripts() bool {
+	for _, cmd := range nf.Cmds {
+		if _, ok := cmd.(*cmdScript); ok {
+			return true
+		}
+	}
+	return false
+}
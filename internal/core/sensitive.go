@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isSensitive reports whether the input named name is redacted, see
+// checkSensitive.
+func (c *cmdBase) isSensitive(name string) bool {
+	return containsString(c.Sensitive, name)
+}
+
+// checkSensitive resolves the sensitive inputs of the commands of nf, whose
+// values are replaced by Redacted in the Call given to the middleware. An
+// input is sensitive if a !sensitive directive of its command names it, if a
+// !sensitive directive before the commands names it, ignoring case, or for
+// every input with !sensitive_by_default, unless !not_sensitive names it.
+// Encrypted inputs are always sensitive.
+func checkSensitive(nf *NormFile) {
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		for _, name := range c.Sensitive {
+			if inputIndex(c.Inputs, name) < 0 {
+				panic(fmt.Sprintf("!sensitive of %s on line %d: unknown input %s", c.FuncName, c.Line, name))
+			}
+		}
+		for _, name := range c.NotSensitive {
+			switch {
+			case inputIndex(c.Inputs, name) < 0:
+				panic(fmt.Sprintf("!not_sensitive of %s on line %d: unknown input %s", c.FuncName, c.Line, name))
+			case c.isSensitive(name):
+				panic(fmt.Sprintf("!not_sensitive of %s on line %d: input %s is also declared !sensitive", c.FuncName, c.Line, name))
+			case c.isEncrypted(name):
+				panic(fmt.Sprintf("!not_sensitive of %s on line %d: input %s is encrypted", c.FuncName, c.Line, name))
+			}
+		}
+		var sensitive []string
+		for _, inp := range c.Inputs {
+			declared := c.isSensitive(inp.Name) || c.isEncrypted(inp.Name)
+			byFile := nf.SensitiveByDefault
+			for _, name := range nf.Sensitive {
+				byFile = byFile || strings.EqualFold(inp.Name, name)
+			}
+			if declared || byFile && !containsString(c.NotSensitive, inp.Name) {
+				sensitive = append(sensitive, inp.Name)
+			}
+		}
+		c.Sensitive = sensitive
+	}
+}
+
+// hasSensitiveArg reports whether the Args of the Call of c hold a sensitive
+// value in the parameter p: a sensitive input, or the key holding one.
+func (c *cmdBase) hasSensitiveArg(p arg) bool {
+	if c.Key != "" && p.Name == "key" {
+		for _, name := range c.KeyInputs {
+			if c.isSensitive(name) {
+				return true
+			}
+		}
+		return false
+	}
+	return c.isSensitive(p.Name)
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSensitive(t *testing.T) {
+	input := `-- !norm
+-- !package store
+-- !sensitive Password
+-- !cache_keys
+
+-- !read_one Login
+-- !input email string
+-- !input password string
+-- !output ID int
+SELECT id FROM users WHERE email = $1 AND password = $2
+
+-- !read_one FindUser
+-- !input email string
+-- !input tenant string
+-- !output ID int
+-- !key UserKey email tenant
+-- !sensitive email
+SELECT id FROM users WHERE email = $1 AND tenant = $2
+
+-- !exec SetSSN
+-- !input id int
+-- !input ssn string
+-- !encrypted ssn
+UPDATE users SET ssn = $2 WHERE id = $1
+
+-- !exec_many AddUsers
+-- !input email string
+-- !input password string
+INSERT INTO users (email, password) VALUES ($1, $2)
+
+-- !read_one CountUsers
+-- !input tenant string
+-- !output Count int
+SELECT count(*) FROM users WHERE tenant = $1
+`
+	nf := ParseData([]byte(input), []Source{{"<input>", 0}}, ParseOptions{})
+	tests := []struct {
+		sensitive []string
+		callArgs  string
+		cacheKey  bool
+	}{
+		{[]string{"password"}, "[]interface{}{email, Redacted{}}", false},
+		{[]string{"email"}, "[]interface{}{Redacted{}}", false},
+		{[]string{"ssn"}, "[]interface{}{id, Redacted{}}", false},
+		{[]string{"password"}, "", false},
+		{nil, "[]interface{}{tenant}", true},
+	}
+	for ix, test := range tests {
+		c := nf.Cmds[ix].Base()
+		if !reflect.DeepEqual(c.Sensitive, test.sensitive) {
+			t.Errorf("Expected the sensitive inputs %v for %s, got %v", test.sensitive, c.FuncName, c.Sensitive)
+		}
+		if _, ok := nf.Cmds[ix].(*cmdExecMany); ok {
+			if got := c.RowsCallArg(); got != "Redacted{}" {
+				t.Errorf("Expected the rows of %s to be redacted, got %s", c.FuncName, got)
+			}
+		} else if got := c.CallArgs(); got != test.callArgs {
+			t.Errorf("Expected the Args %s for %s, got %s", test.callArgs, c.FuncName, got)
+		}
+		if c.CacheKey != test.cacheKey {
+			t.Errorf("Expected CacheKey %v for %s, got %v", test.cacheKey, c.FuncName, c.CacheKey)
+		}
+	}
+}
+
+func TestSensitiveByDefault(t *testing.T) {
+	input := `-- !norm
+-- !package store
+-- !sensitive_by_default
+
+-- !read_one FindUser
+-- !input email string
+-- !input tenant string
+-- !output ID int
+-- !not_sensitive tenant
+SELECT id FROM users WHERE email = $1 AND tenant = $2
+
+-- !exec AddUser
+-- !input email string
+-- !audit_log
+INSERT INTO users (email) VALUES ($1)
+`
+	nf := ParseData([]byte(input), []Source{{"<input>", 0}}, ParseOptions{})
+	if got := nf.Cmds[0].Base().CallArgs(); got != "[]interface{}{Redacted{}, tenant}" {
+		t.Errorf("Expected only the email of FindUser to be redacted, got %s", got)
+	}
+	if got := nf.Cmds[1].Base().AuditInputs; len(got) != 0 {
+		t.Errorf("Expected no input in the audit log of AddUser, got %v", got)
+	}
+}
+
+func TestSensitiveErrors(t *testing.T) {
+	header := "-- !norm\n-- !package store\n\n-- !exec SetSSN\n-- !input id int\n-- !input ssn string\n-- !encrypted ssn\n"
+	body := "UPDATE users SET ssn = $2 WHERE id = $1\n"
+	tests := []struct {
+		directives string
+		err        string
+	}{
+		{"-- !sensitive name\n", "<input>:4: !sensitive of SetSSN: unknown input name"},
+		{"-- !not_sensitive name\n", "<input>:4: !not_sensitive of SetSSN: unknown input name"},
+		{"-- !sensitive id\n-- !not_sensitive id\n", "<input>:4: !not_sensitive of SetSSN: input id is also declared !sensitive"},
+		{"-- !not_sensitive ssn\n", "<input>:4: !not_sensitive of SetSSN: input ssn is encrypted"},
+		{"-- !sensitive id\n-- !audit_log id\n", "<input>:4: !audit_log of SetSSN: input id is sensitive"},
+		{"-- !sensitive\n", "<input>:8:14: Format error: \"-- !sensitive\""},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected the error %q for %q, got %v", test.err, test.directives, err)
+				}
+			}()
+			ParseData([]byte(header+test.directives+body), []Source{{"<input>", 0}}, ParseOptions{})
+		}()
+	}
+}
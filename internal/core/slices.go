@@ -0,0 +1,289 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const expandSlices = `
+// emptyIn is an IN or NOT IN predicate of a query holding only the
+// placeholder of a slice input, from start to end in the query. question is
+// set if the placeholder is ?.
+type emptyIn struct {
+	start, end, input int
+	not, question     bool
+}
+
+// expandSlices rewrites the placeholders of query for the slice inputs of a
+// query. counts holds the length of every slice input, and -1 for the other
+// inputs. The placeholder of a slice becomes one placeholder per element, and
+// the following placeholders are renumbered. The predicates in preds become
+// 1=0 for IN and 1=1 for NOT IN when their slice is empty, other placeholders
+// of an empty slice become NULL. The returned slice has room for the
+// arguments.
+func expandSlices(query string, counts []int, preds []emptyIn) (string, []interface{}) {
+	offsets := make([]int, len(counts))
+	total := 0
+	for ix, count := range counts {
+		offsets[ix] = total
+		if count < 0 {
+			total++
+		} else {
+			total += count
+		}
+	}
+	var b strings.Builder
+	positional := 0
+	for i := 0; i < len(query); i++ {
+		for len(preds) > 0 && preds[0].start < i {
+			preds = preds[1:]
+		}
+		if len(preds) > 0 && preds[0].start == i && counts[preds[0].input] == 0 {
+			if preds[0].not {
+				b.WriteString("1=1")
+			} else {
+				b.WriteString("1=0")
+			}
+			if preds[0].question {
+				positional++
+			}
+			i = preds[0].end - 1
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '\x60':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				end = len(query) - i - 2
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		case c == '?' && positional < len(counts):
+			writePlaceholders(&b, "?", counts[positional], 0)
+			positional++
+			continue
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			end := i + 1
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			n, _ := strconv.Atoi(query[i+1 : end])
+			if n < 1 || n > len(counts) {
+				break
+			}
+			writePlaceholders(&b, "$", counts[n-1], offsets[n-1]+1)
+			i = end - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), make([]interface{}, 0, total)
+}
+
+// writePlaceholders writes the placeholders of an input with count elements,
+// or -1 if it is not a slice, numbered from first if it is not 0.
+func writePlaceholders(b *strings.Builder, prefix string, count, first int) {
+	if count == 0 {
+		b.WriteString("NULL")
+		return
+	}
+	if count < 0 {
+		count = 1
+	}
+	for ix := 0; ix < count; ix++ {
+		if ix > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(prefix)
+		if first > 0 {
+			b.WriteString(strconv.Itoa(first + ix))
+		}
+	}
+}
+`
+
+var expandSlicesTmpl *template.Template
+
+// expandQuery is the start of the generated functions of commands with slice
+// inputs, building the query and its arguments, or of commands read at a
+// point in time, building the query.
+const expandQuery = `
+{{- define "expand"}}
+{{- if .HasSliceInputs}}
+	query, args := expandSlices(` + "`{{.BodyString}}`" + `, []int{ {{- .SliceCounts -}} }, {{.EmptyIns}})
+{{- range .Inputs}}
+{{- if isSlice .Typ}}
+	for _, v := range {{$.InputExpr .Name}} {
+		args = append(args, v)
+	}
+{{- else}}
+	args = append(args, {{$.ArgExpr .Name}})
+{{- end}}
+{{- end}}
+{{- else if .AsOf}}
+	query := asOfQuery(asOf, {{.AsOfArgs}})
+{{- end}}
+{{- end}}`
+
+// isSlice reports whether an input of type typ is expanded into one
+// placeholder per element. []byte values are passed as one argument.
+func isSlice(typ string) bool {
+	return strings.HasPrefix(typ, "[]") && typ != "[]byte"
+}
+
+// HasSliceInputs reports whether the command has inputs expanded by
+// expandSlices.
+func (c *cmdBase) HasSliceInputs() bool {
+	for _, inp := range c.Inputs {
+		if isSlice(inp.Typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// SliceCounts returns the counts argument of expandSlices.
+func (c *cmdBase) SliceCounts() string {
+	var counts []string
+	for _, inp := range c.Inputs {
+		if isSlice(inp.Typ) {
+			counts = append(counts, "len("+c.InputExpr(inp.Name)+")")
+		} else {
+			counts = append(counts, "-1")
+		}
+	}
+	return strings.Join(counts, ", ")
+}
+
+// emptyIns returns the IN and NOT IN predicates of the query, in order, whose
+// list is only the placeholder of a slice input, and whose left operand is a
+// column, a literal or a function call without placeholders. expandSlices
+// replaces them when the slice is empty, since an empty list is not valid SQL
+// and NULL would make NOT IN match no rows.
+func (c *cmdBase) emptyIns() []string {
+	tokens := tokenize(c.BodyString())
+	var ret []string
+	positional := 0
+	for ix, t := range tokens {
+		if t.Kind != tokPlaceholder {
+			continue
+		}
+		input := -1
+		if t.Text == "?" {
+			input = positional
+			positional++
+		} else if n, err := strconv.Atoi(t.Text[1:]); err == nil {
+			input = n - 1
+		}
+		if input < 0 || input >= len(c.Inputs) || !isSlice(c.Inputs[input].Typ) {
+			continue
+		}
+		if ix < 3 || ix+1 >= len(tokens) || tokens[ix-1].Text != "(" || !tokens[ix-2].is("IN") || tokens[ix+1].Text != ")" {
+			continue
+		}
+		op := ix - 3
+		not := tokens[op].is("NOT")
+		if not {
+			op--
+		}
+		start := operandStart(tokens, op)
+		if start < 0 {
+			continue
+		}
+		ret = append(ret, fmt.Sprintf("{%d, %d, %d, %t, %t}", tokens[start].Pos, tokens[ix+1].Pos+1, input, not, t.Text == "?"))
+	}
+	return ret
+}
+
+// operandStart returns the index of the first token of the operand ending
+// with tokens[end], or -1 if it is not a column, a literal or a function call
+// without placeholders.
+func operandStart(tokens []sqlToken, end int) int {
+	if end < 0 {
+		return -1
+	}
+	switch t := tokens[end]; {
+	case t.Text == ")":
+		depth := 0
+		for ix := end; ix >= 0; ix-- {
+			switch {
+			case tokens[ix].Kind == tokPlaceholder:
+				return -1
+			case tokens[ix].Text == ")":
+				depth++
+			case tokens[ix].Text == "(":
+				depth--
+			}
+			if depth > 0 {
+				continue
+			}
+			// Only a name written right before the parenthesis is the
+			// function called, as in lower(email).
+			if ix > 0 && tokens[ix-1].Kind == tokIdent && tokens[ix-1].Pos+len(tokens[ix-1].Text) == tokens[ix].Pos {
+				return ix - 1
+			}
+			return ix
+		}
+		return -1
+	case t.Kind == tokString || t.Kind == tokNumber:
+		return end
+	case t.Kind == tokIdent || t.Kind == tokQuotedIdent:
+		for end >= 2 && tokens[end-1].Text == "." && (tokens[end-2].Kind == tokIdent || tokens[end-2].Kind == tokQuotedIdent) {
+			end -= 2
+		}
+		return end
+	}
+	return -1
+}
+
+// EmptyIns returns the preds argument of expandSlices.
+func (c *cmdBase) EmptyIns() string {
+	preds := c.emptyIns()
+	if len(preds) == 0 {
+		return "nil"
+	}
+	return "[]emptyIn{" + strings.Join(preds, ", ") + "}"
+}
+
+// Query returns the expression of the query run by the generated functions.
+func (c *cmdBase) Query() string {
+	if c.HasSliceInputs() || c.AsOf != "" {
+		return "query"
+	}
+	return "`" + c.BodyString() + "`"
+}
+
+// QueryArgs returns the arguments of the query following the context, with a
+// leading comma.
+func (c *cmdBase) QueryArgs() string {
+	if c.HasSliceInputs() {
+		return ", args..."
+	}
+	var ret strings.Builder
+	for _, name := range c.ArgInputs() {
+		ret.WriteString(", " + c.ArgExpr(name))
+	}
+	return ret.String()
+}
+
+// hasSliceInputs reports whether any command of nf has slice inputs.
+func (nf *NormFile) hasSliceInputs() bool {
+	for _, cmd := range nf.Cmds {
+		if cmd.Base().HasSliceInputs() {
+			return true
+		}
+	}
+	return false
+}
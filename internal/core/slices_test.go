@@ -0,0 +1,31 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEmptyIns(t *testing.T) {
+	ids := arg{Name: "ids", Typ: "[]int64"}
+	name := arg{Name: "name", Typ: "string"}
+	tests := []struct {
+		body   string
+		inputs []arg
+		want   []string
+	}{
+		{"SELECT 1 FROM t WHERE id IN ($1)", []arg{ids}, []string{"{22, 32, 0, false, false}"}},
+		{"SELECT 1 FROM t WHERE t.id NOT IN ($1)", []arg{ids}, []string{"{22, 38, 0, true, false}"}},
+		{"SELECT 1 FROM t WHERE lower(name) NOT IN (?)", []arg{ids}, []string{"{22, 44, 0, true, true}"}},
+		{"SELECT 1 FROM t WHERE name = ? AND id IN (?)", []arg{name, ids}, []string{"{35, 44, 1, false, true}"}},
+		{"SELECT 1 FROM t WHERE (a, b) IN ($1)", []arg{ids}, []string{"{22, 36, 0, false, false}"}},
+		{"SELECT 1 FROM t WHERE coalesce(a, $1) IN ($2)", []arg{name, ids}, nil},
+		{"SELECT 1 FROM t WHERE id IN ($1, 3)", []arg{ids}, nil},
+		{"SELECT 1 FROM t WHERE name IN ($1)", []arg{name}, nil},
+	}
+	for _, test := range tests {
+		c := &cmdBase{IRCommand: IRCommand{Inputs: test.inputs}, Body: []string{test.body}}
+		if got := c.emptyIns(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("emptyIns of %q = %v, want %v", test.body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source is a norm file read as part of the input. Its lines follow line
+// Start of the combined input.
+type Source struct {
+	Name  string
+	Start int
+}
+
+// inputFiles returns the norm files named by arg: the files matching arg if
+// it is a glob, the *.sql files starting with -- !norm under arg if it is a
+// directory, or else arg itself.
+func inputFiles(arg string) ([]string, error) {
+	if strings.ContainsAny(arg, "*?[") {
+		paths, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+		return paths, nil
+	}
+	info, err := os.Stat(arg)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{arg}, nil
+	}
+	var ret []string
+	err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".sql") && isNormFile(path) {
+			ret = append(ret, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no norm files in %s", arg)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// isNormFile reports whether the file at path starts with -- !norm.
+func isNormFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	return scanner.Scan() && checkStart(scanner.Text())
+}
+
+// readSources reads the norm files at paths as one input. The -- !norm line
+// of every file but the first is left blank, which also ends a block left
+// open at the end of the previous file.
+func readSources(paths []string) (io.Reader, []Source, error) {
+	var readers []io.Reader
+	var sources []Source
+	lines := 0
+	for ix, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ix > 0 {
+			nl := bytes.IndexByte(data, '\n')
+			if nl < 0 {
+				nl = len(data)
+			}
+			if !checkStart(strings.TrimRight(string(data[:nl]), "\r")) {
+				return nil, nil, fmt.Errorf("%s is not a valid norm file", path)
+			}
+			data = data[nl:]
+		}
+		if len(data) == 0 || data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		sources = append(sources, Source{path, lines})
+		lines += bytes.Count(data, []byte("\n"))
+		readers = append(readers, bytes.NewReader(data))
+	}
+	return io.MultiReader(readers...), sources, nil
+}
+
+// position returns the norm file and the line in it of line of the input.
+func (nf *NormFile) position(line int) (string, int) {
+	for ix := len(nf.Sources) - 1; ix >= 0; ix-- {
+		if s := nf.Sources[ix]; line > s.Start {
+			return s.Name, line - s.Start
+		}
+	}
+	return "", line
+}
+
+// Pos formats the position of line of the input as file:line.
+func (nf *NormFile) Pos(line int) string {
+	name, line := nf.position(line)
+	return fmt.Sprintf("%s:%d", name, line)
+}
+
+var rxLineRef = regexp.MustCompile(`line ([0-9]+)`)
+
+// locate rewrites the references to lines of the input in msg to name the
+// norm file, when the input has several of them.
+func (nf *NormFile) locate(msg string) string {
+	if len(nf.Sources) < 2 {
+		return msg
+	}
+	return rxLineRef.ReplaceAllStringFunc(msg, func(ref string) string {
+		line, _ := strconv.Atoi(strings.TrimPrefix(ref, "line "))
+		name, line := nf.position(line)
+		return fmt.Sprintf("line %d of %s", line, name)
+	})
+}
+
+// checkDuplicates checks that the functions and types generated for the
+// commands of nf have distinct names, which commands in different files can
+// easily break.
+func checkDuplicates(nf *NormFile) {
+	declared := map[string]*cmdBase{}
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		for _, name := range cmd.Funcs() {
+			if prev, ok := declared[name]; ok && prev != c {
+				panic(fmt.Sprintf("%s is generated for both %s on line %d and %s on line %d", name, prev.FuncName, prev.Line, c.FuncName, c.Line))
+			}
+			declared[name] = c
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInputFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_inputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"users.norm.sql":                         "-- !norm\n",
+		"orders.sql":                             "-- !norm\n",
+		"schema.sql":                             "CREATE TABLE users (id INT);\n",
+		"notes.txt":                              "-- !norm\n",
+		filepath.Join("billing", "invoices.sql"): "-- !norm\n",
+		filepath.Join("empty", "schema.sql"):     "CREATE TABLE t (x INT);\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		arg  string
+		want []string
+		err  string
+	}{
+		// A directory has the norm files under it, in order.
+		{dir, []string{filepath.Join("billing", "invoices.sql"), "orders.sql", "users.norm.sql"}, ""},
+		// A glob has the files it matches, norm files or not.
+		{filepath.Join(dir, "*.sql"), []string{"orders.sql", "schema.sql", "users.norm.sql"}, ""},
+		{filepath.Join(dir, "*", "*.sql"), []string{filepath.Join("billing", "invoices.sql"), filepath.Join("empty", "schema.sql")}, ""},
+		{filepath.Join(dir, "users.norm.sql"), []string{"users.norm.sql"}, ""},
+		{filepath.Join(dir, "*.go"), nil, "no files match"},
+		{filepath.Join(dir, "empty"), nil, "no norm files in"},
+		{filepath.Join(dir, "missing.sql"), nil, "no such file or directory"},
+	}
+	for _, test := range tests {
+		paths, err := inputFiles(test.arg)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected an error %q for %s, got %v", test.err, test.arg, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.arg, err)
+			continue
+		}
+		var got []string
+		for _, path := range paths {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, rel)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Expected %v for %s, got %v", test.want, test.arg, got)
+		}
+	}
+}
+
+func TestParseFilesDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.sql": "-- !norm\n-- !package store\n-- !file store.go\n\n-- !read GetUsers\n-- !output ID int\nSELECT id FROM users\n",
+		"b.sql": "-- !norm\n\n-- !read GetOrders\n-- !output ID int\nSELECT id FROM orders\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nf := ParseFiles([]string{dir}, ParseOptions{})
+	if nf.Package != "store" || len(nf.Cmds) != 2 {
+		t.Fatalf("Expected the two files in package store, got package %q with %d commands", nf.Package, len(nf.Cmds))
+	}
+	if got, want := nf.Pos(nf.Cmds[1].Base().Line), filepath.Join(dir, "b.sql")+":3"; got != want {
+		t.Errorf("Expected GetOrders at %s, got %s", want, got)
+	}
+
+	// The same name in two files is reported with both positions.
+	dup := "-- !norm\n\n-- !read GetUsers\n-- !output ID int\nSELECT id FROM admins\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.sql"), []byte(dup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err, _ := recover().(error)
+		want := filepath.Join(dir, "a.sql") + ":5: GetUsers is generated for both GetUsers and GetUsers on line 3 of " + filepath.Join(dir, "b.sql")
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("Expected the error %q, got %v", want, err)
+		}
+	}()
+	ParseFiles([]string{dir}, ParseOptions{})
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokPlaceholder
+	tokPunct
+)
+
+// sqlToken is a lexical element of an SQL statement. Comments and whitespace
+// are not returned by tokenize.
+type sqlToken struct {
+	Kind tokenKind
+	Text string
+	// Pos is the byte offset of the token in the scanned string.
+	Pos int
+}
+
+// is reports whether t is the identifier or keyword s, ignoring case.
+func (t sqlToken) is(s string) bool {
+	return t.Kind == tokIdent && strings.EqualFold(t.Text, s)
+}
+
+// name returns the identifier named by t, without any quoting.
+func (t sqlToken) name() string {
+	if t.Kind == tokQuotedIdent {
+		return t.Text[1 : len(t.Text)-1]
+	}
+	return t.Text
+}
+
+// tokenize splits an SQL statement into tokens. It is not a full SQL lexer,
+// but it understands enough of the common dialects (quoting, comments,
+// placeholders) to be used for analysis of query bodies.
+func tokenize(sql string) []sqlToken {
+	var ret []sqlToken
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case c == '\'':
+			end := scanQuoted(sql, i, '\'')
+			ret = append(ret, sqlToken{tokString, sql[i:end], i})
+			i = end
+		case c == '"' || c == '`':
+			end := scanQuoted(sql, i, c)
+			ret = append(ret, sqlToken{tokQuotedIdent, sql[i:end], i})
+			i = end
+		case c == '[':
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				end = len(sql) - i - 1
+			}
+			ret = append(ret, sqlToken{tokQuotedIdent, sql[i : i+end+1], i})
+			i += end + 1
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			end := i + 1
+			for end < len(sql) && isDigit(sql[end]) {
+				end++
+			}
+			ret = append(ret, sqlToken{tokPlaceholder, sql[i:end], i})
+			i = end
+		case c == '?':
+			ret = append(ret, sqlToken{tokPlaceholder, "?", i})
+			i++
+		case c == ':' && i+1 < len(sql) && sql[i+1] == ':':
+			ret = append(ret, sqlToken{tokPunct, "::", i})
+			i += 2
+		case c == ':' && i+1 < len(sql) && isIdentStart(rune(sql[i+1])):
+			end := i + 1
+			for end < len(sql) && isIdentPart(rune(sql[end])) {
+				end++
+			}
+			ret = append(ret, sqlToken{tokPlaceholder, sql[i:end], i})
+			i = end
+		case isDigit(c):
+			end := i
+			for end < len(sql) && (isDigit(sql[end]) || sql[end] == '.') {
+				end++
+			}
+			ret = append(ret, sqlToken{tokNumber, sql[i:end], i})
+			i = end
+		case isIdentStart(rune(c)):
+			end := i
+			for end < len(sql) && isIdentPart(rune(sql[end])) {
+				end++
+			}
+			ret = append(ret, sqlToken{tokIdent, sql[i:end], i})
+			i = end
+		default:
+			ret = append(ret, sqlToken{tokPunct, string(c), i})
+			i++
+		}
+	}
+	return ret
+}
+
+// scanQuoted returns the offset just past the quoted section starting at
+// start. A doubled quote character is an escaped quote.
+func scanQuoted(sql string, start int, quote byte) int {
+	i := start + 1
+	for i < len(sql) {
+		if sql[i] == quote {
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return len(sql)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// splitTopLevel splits toks on commas that are not nested in parentheses.
+func splitTopLevel(toks []sqlToken) [][]sqlToken {
+	var ret [][]sqlToken
+	depth := 0
+	start := 0
+	for ix, t := range toks {
+		if t.Kind != tokPunct {
+			continue
+		}
+		switch t.Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				ret = append(ret, toks[start:ix])
+				start = ix + 1
+			}
+		}
+	}
+	if start < len(toks) {
+		ret = append(ret, toks[start:])
+	}
+	return ret
+}
+
+// matchParen returns the index of the parenthesis closing the one at
+// toks[open], or -1 if it is not closed.
+func matchParen(toks []sqlToken, open int) int {
+	depth := 0
+	for ix := open; ix < len(toks); ix++ {
+		if toks[ix].Kind != tokPunct {
+			continue
+		}
+		switch toks[ix].Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return ix
+			}
+		}
+	}
+	return -1
+}
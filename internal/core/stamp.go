@@ -0,0 +1,43 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stamps are the values of !stamp and -stamp, selecting the line following
+// the first line of the generated files: the date of the generation, a hash
+// of the content, or no line at all. The date is the default.
+var Stamps = map[string]bool{
+	"date": true,
+	"hash": true,
+	"none": true,
+}
+
+// headerData returns the data of the header template for nf.
+func headerData(nf *NormFile) map[string]string {
+	date := ""
+	if nf.Stamp == "" || nf.Stamp == "date" {
+		date = fmt.Sprintf("%s", time.Now())
+	}
+	return map[string]string{
+		"package": nf.Package,
+		"date":    date,
+		"imports": strings.Join(nf.Imports, "\n"),
+	}
+}
+
+// StampHash returns src with a line holding the SHA-256 of src after its
+// first line, for files generated with !stamp hash. The hash only changes
+// with the content, so regenerating an unchanged file leaves it as it is.
+func StampHash(src []byte) []byte {
+	nl := bytes.IndexByte(src, '\n') + 1
+	var bb bytes.Buffer
+	bb.Write(src[:nl])
+	fmt.Fprintf(&bb, "// Content hash: sha256:%x\n", sha256.Sum256(src))
+	bb.Write(src[nl:])
+	return bb.Bytes()
+}
@@ -0,0 +1,237 @@
+package core
+
+import "text/template"
+
+const stmtCache = `
+// StmtCacheSize is the number of prepared statements a Norm keeps, read when
+// the Norm is created. Preparing a query beyond it closes the least recently
+// used statement, so that long running services stay below the limit of
+// prepared statements of the server. 0 keeps every statement.
+var StmtCacheSize = 256
+
+// StmtStats are the statistics of the statement cache of a Norm, see Stats.
+type StmtStats struct {
+	// Cached is the number of statements in the cache.
+	Cached int
+	// Hits counts the statements found in the cache, and Misses those
+	// prepared for it.
+	Hits, Misses int64
+	// Evictions counts the statements closed to keep the cache within
+	// StmtCacheSize.
+	Evictions int64
+	// Executions counts the calls of every query, by the name of its
+	// function, including those not using the cache.
+	Executions map[string]int64
+}
+
+// stmtCache holds the statements prepared by a Norm and the Norms derived from
+// it, so that every query is prepared once per database instead of once per
+// call. The statements are closed by Close.
+type stmtCache struct {
+	db   *sql.DB
+	size int
+	mu   sync.Mutex
+	// stmts are the cached statements by query, and lru the same statements,
+	// the most recently used first.
+	stmts map[string]*cachedStmt
+	lru   *list.List
+	// preparing holds the queries being prepared, closing the channel once
+	// they are. Concurrent calls of the same query wait for it rather than
+	// preparing the query again.
+	preparing map[string]chan struct{}
+	hits      int64
+	misses    int64
+	evictions int64
+	calls     map[string]int64
+}
+
+// cachedStmt is a statement of a stmtCache. It is closed once it is evicted
+// and no call uses it anymore.
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	elem    *list.Element
+	uses    int
+	evicted bool
+}
+
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{
+		db:        db,
+		size:      StmtCacheSize,
+		stmts:     map[string]*cachedStmt{},
+		lru:       list.New(),
+		preparing: map[string]chan struct{}{},
+		calls:     map[string]int64{},
+	}
+}
+
+// get returns the statement of query, preparing it on first use, and the
+// function to call once done with it.
+func (c *stmtCache) get(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	c.mu.Lock()
+	for {
+		if c.stmts == nil {
+			c.mu.Unlock()
+			return nil, nil, errors.New("norm: query on a closed Norm")
+		}
+		if cs, ok := c.stmts[query]; ok {
+			c.lru.MoveToFront(cs.elem)
+			c.hits++
+			cs.uses++
+			c.mu.Unlock()
+			return cs.stmt, func() { c.release(cs) }, nil
+		}
+		done, ok := c.preparing[query]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	c.misses++
+	done := make(chan struct{})
+	c.preparing[query] = done
+	c.mu.Unlock()
+	stmt, err := c.db.PrepareContext(ctx, query)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.preparing, query)
+	close(done)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.stmts == nil {
+		stmt.Close()
+		return nil, nil, errors.New("norm: query on a closed Norm")
+	}
+	cs := &cachedStmt{query: query, stmt: stmt, uses: 1}
+	cs.elem = c.lru.PushFront(cs)
+	c.stmts[query] = cs
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.evict(c.lru.Back())
+	}
+	return stmt, func() { c.release(cs) }, nil
+}
+
+// evict removes the statement of elem from the cache, closing it unless a
+// call still uses it. c.mu must be held.
+func (c *stmtCache) evict(elem *list.Element) {
+	cs := c.lru.Remove(elem).(*cachedStmt)
+	delete(c.stmts, cs.query)
+	cs.evicted = true
+	c.evictions++
+	if cs.uses == 0 {
+		cs.stmt.Close()
+	}
+}
+
+// release ends a use of cs returned by get.
+func (c *stmtCache) release(cs *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cs.uses--
+	if cs.evicted && cs.uses == 0 {
+		cs.stmt.Close()
+	}
+}
+
+// close closes the cached statements, or those still in use once they are
+// released. Later calls to get fail.
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cs := range c.stmts {
+		cs.evicted = true
+		if cs.uses == 0 {
+			cs.stmt.Close()
+		}
+	}
+	c.stmts = nil
+	c.lru.Init()
+}
+
+// Stats returns the statistics of the statement cache of n, which is shared
+// with the Norms derived from it.
+func (n *Norm) Stats() StmtStats {
+	if n.stmts == nil {
+		return StmtStats{}
+	}
+	c := n.stmts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := StmtStats{
+		Cached:     len(c.stmts),
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+		Executions: make(map[string]int64, len(c.calls)),
+	}
+	for name, count := range c.calls {
+		stats.Executions[name] = count
+	}
+	return stats
+}
+
+// countCall counts a call of the query name in the Stats of n.
+func (n *Norm) countCall(name string) {
+	if n.stmts == nil {
+		return
+	}
+	n.stmts.mu.Lock()
+	n.stmts.calls[name]++
+	n.stmts.mu.Unlock()
+}
+
+// prepare returns the statement of query, and the function to call once done
+// with it. Only queries with reuse set are cached, as queries with expanded
+// slice inputs or read at a point in time differ from call to call. Inside a
+// transaction, the cached statement is rebound to the transaction.
+func (n *Norm) prepare(ctx context.Context, query string, reuse bool) (*sql.Stmt, func(), error) {
+	if !reuse || n.stmts == nil {
+		stmt, err := n.db.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stmt, func() { stmt.Close() }, nil
+	}
+	stmt, release, err := n.stmts.get(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, ok := n.db.(*sql.Tx)
+	if !ok {
+		return stmt, release, nil
+	}
+	txStmt := tx.StmtContext(ctx, stmt)
+	return txStmt, func() {
+		txStmt.Close()
+		release()
+	}, nil
+}
+`
+
+var stmtCacheTmpl *template.Template
+
+// Runner returns the receiver of the calls running the query: the prepared
+// statement, or the database with !no_prepare.
+func (c *cmdBase) Runner() string {
+	if c.NoPrepare {
+		return "n.db"
+	}
+	return "stmt"
+}
+
+// RunArgs returns the arguments of the calls running the query following the
+// context, with a leading comma.
+func (c *cmdBase) RunArgs() string {
+	if c.NoPrepare {
+		return ", " + c.Query() + c.QueryArgs()
+	}
+	return c.QueryArgs()
+}
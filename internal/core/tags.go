@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// getTaggedStructSig is like getStructSig, but every field gets a tag of each
+// key in tags, such as json or db, with the name of the field converted to
+// tagCase.
+func getTaggedStructSig(args []arg, tags []string, tagCase string) string {
+	if len(tags) == 0 {
+		return getStructSig(args)
+	}
+	var lines []string
+	for _, a := range args {
+		var tag []string
+		for _, key := range tags {
+			tag = append(tag, fmt.Sprintf("%s:%q", key, tagName(a.Name, tagCase)))
+		}
+		lines = append(lines, fmt.Sprintf("\t%s %s `%s`", a.Name, a.Typ, strings.Join(tag, " ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tagName converts the field name name to tagCase: "snake" turns UserID into
+// user_id, and "camel" into userId.
+func tagName(name, tagCase string) string {
+	words := splitWords(name)
+	for ix, w := range words {
+		w = strings.ToLower(w)
+		if tagCase == "camel" && ix > 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		words[ix] = w
+	}
+	if tagCase == "camel" {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words, "_")
+}
+
+// splitWords splits a Go identifier into words at underscores and changes of
+// case, keeping initialisms like ID or HTTP together.
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for ix := 0; ix <= len(runes); ix++ {
+		if ix == len(runes) || runes[ix] == '_' {
+			if ix > start {
+				words = append(words, string(runes[start:ix]))
+			}
+			start = ix + 1
+			continue
+		}
+		if ix == start || !unicode.IsUpper(runes[ix]) {
+			continue
+		}
+		prev := runes[ix-1]
+		nextLower := ix+1 < len(runes) && unicode.IsLower(runes[ix+1])
+		if !unicode.IsUpper(prev) || nextLower {
+			words = append(words, string(runes[start:ix]))
+			start = ix
+		}
+	}
+	return words
+}
+
+// parseTags parses the !tags directive on line i, returning the tag keys and
+// the case of their values, snake by default.
+func parseTags(line string, i int) ([]string, string) {
+	matches := rxTags.FindStringSubmatch(line)
+	if len(matches) != 3 {
+		panic(fmt.Sprintf("Format error on line %d: %q", i, line))
+	}
+	tagCase := matches[2]
+	if tagCase == "" {
+		tagCase = "snake"
+	}
+	return strings.Split(matches[1], ","), tagCase
+}
@@ -0,0 +1,22 @@
+-- !norm
+-- !package store
+-- !file store.go
+
+-- !read ListOrders
+-- !input user int64
+-- !output ID int64
+-- !output Email string
+SELECT o.id, u.email FROM orders o JOIN users AS u ON u.id = o.user_id WHERE o.user_id = $1
+
+-- !exec ArchiveOrders
+-- !input before int64
+WITH old AS (SELECT id FROM orders WHERE created < $1)
+INSERT INTO archived_orders SELECT * FROM old
+
+-- !copy CopyEvents
+-- !input kind string
+events
+
+-- !exec_many InsertUsers
+-- !input email string
+INSERT INTO users (email) VALUES ($1)
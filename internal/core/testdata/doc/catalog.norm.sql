@@ -0,0 +1,23 @@
+-- !norm
+-- !package store
+-- !file store.go
+
+-- !read ListUsers
+-- !input domain string
+-- !output ID int64
+-- !output Email string
+-- !model User
+-- !doc Lists the users of a domain,
+-- !doc by email.
+SELECT id, email FROM users WHERE domain = $1 ORDER BY email
+
+-- !read_one FindUser
+-- !input email string
+-- !output ID int64
+-- !output Name string
+SELECT id, name FROM users WHERE email = $1
+
+-- !exec DeleteUser
+-- !input id int64
+-- !doc Deletes a user.
+DELETE FROM users WHERE id = $1
@@ -0,0 +1,30 @@
+-- !norm
+-- !package store
+-- !file store.go
+
+-- !exec CreateSchema
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    score DOUBLE PRECISION
+);
+CREATE TABLE billing.orders (
+    id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    "total" NUMERIC(10, 2),
+    PRIMARY KEY (id)
+);
+CREATE TABLE order_items (
+    order_id BIGINT NOT NULL,
+    line INT NOT NULL,
+    note TEXT,
+    PRIMARY KEY (order_id, line),
+    CONSTRAINT fk_order FOREIGN KEY (order_id) REFERENCES billing.orders (id)
+)
+
+-- !read ListUsers
+-- !output ID int64
+SELECT id FROM users
+
+-- !exec CreateLogs
+CREATE TABLE logs (id INT, msg TEXT)
@@ -0,0 +1,16 @@
+-- !norm
+-- !file store.go
+
+-- !read_one FindUser
+-- !input email string
+-- !output ID int
+-- !doc Finds a user by email. The doc lines of a paragraph are joined and wrapped at
+-- !doc eighty
+-- !doc characters.
+-- !doc
+-- !doc It returns:
+-- !doc - the id of the user, on a line of its own however long the list item gets to be
+-- !doc - nothing else
+-- !doc   indented  code  keeps  its  spaces
+-- !doc A last paragraph.
+SELECT id FROM user WHERE email = $1
@@ -0,0 +1,12 @@
+-- !norm
+-- !file store.go
+
+-- !read_one FindUser
+-- !doc Finds a user by email.
+-- !readonly
+-- !output ID int
+-- !input email string
+-- !output Email string
+-- !input_ctx tenant string key:tenantDomain
+-- !limit_group lookups
+SELECT id, email FROM user WHERE email = $1 AND domain = $2
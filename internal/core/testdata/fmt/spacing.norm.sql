@@ -0,0 +1,23 @@
+-- !norm  
+-- !file   store.go
+
+  	
+
+-- !read   GetUsers   
+-- !input  domain    string
+-- !output  ID   int
+-- !doc Lists the users.   
+SELECT id
+FROM user
+WHERE domain = $1
+  
+
+
+-- !script Setup
+CREATE TABLE t (x TEXT);
+
+
+INSERT INTO t VALUES (1);
+-- !end
+
+
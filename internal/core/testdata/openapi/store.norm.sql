@@ -0,0 +1,41 @@
+-- !norm
+-- !package store
+-- !file store.go
+-- !import "time"
+-- !import "database/sql"
+
+-- !read ListUsers
+-- !input domain string
+-- !output ID int64
+-- !output Email string
+-- !output Name string null
+-- !output Score sql.NullFloat64
+-- !output Avatar []byte
+-- !output Tags []string
+-- !output Created time.Time
+-- !output Role Role
+-- !doc Lists the users
+-- !doc of a domain.
+SELECT id, email, name, score, avatar, tags, created, role FROM users WHERE domain = $1
+
+-- !read_one FindUser
+-- !input email string
+-- !output ID int64
+-- !output Email string
+-- !model User
+-- !doc Finds a user.
+SELECT id, email FROM users WHERE email = $1
+
+-- !read ListAdmins
+-- !output ID int64
+-- !output Active bool
+-- !model User
+SELECT id, active FROM admins
+
+-- !read_one CountUsers
+-- !output Count int
+SELECT count(*) FROM users
+
+-- !exec DeleteUser
+-- !input id int64
+DELETE FROM users WHERE id = $1
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/template"
+)
+
+const usageCounts = `
+// queryCounts holds the number of calls of every query, by name.
+var queryCounts = map[string]*int64{
+{{- range .}}
+	"{{.}}": new(int64),
+{{- end}}
+}
+
+// QueryCounts returns the number of calls of every query of the norm file
+// since the program started, by the name of the query. Written as JSON and
+// summed across instances, it is the usage report read by norm prune to find
+// the queries that are never called.
+func QueryCounts() map[string]int64 {
+	ret := make(map[string]int64, len(queryCounts))
+	for name, count := range queryCounts {
+		ret[name] = atomic.LoadInt64(count)
+	}
+	return ret
+}
+`
+
+var usageCountsTmpl *template.Template
+
+// countCall is the start of the generated functions running a query,
+// counting the call in the Stats of the Norm, and in QueryCounts when the file
+// has a !usage_counts directive.
+const countCall = `
+{{- define "count"}}
+	n.countCall("{{.FuncName}}")
+{{- if .CountUsage}}
+	atomic.AddInt64(queryCounts["{{.FuncName}}"], 1)
+{{- end}}
+{{- end}}`
+
+// countedQueries returns the names of the queries of nf counted by
+// QueryCounts, including the List queries of views.
+func (nf *NormFile) countedQueries() []string {
+	var ret []string
+	for _, cmd := range nf.Cmds {
+		if v, ok := cmd.(*cmdView); ok {
+			ret = append(ret, v.list().FuncName)
+			continue
+		}
+		ret = append(ret, cmd.Base().FuncName)
+	}
+	return ret
+}
+
+// UnusedQueries returns the queries of nf with no calls in the usage report
+// read from r, a JSON object of call counts by query name as returned by
+// QueryCounts.
+func UnusedQueries(nf *NormFile, r io.Reader) ([]Warning, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var counts map[string]int64
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return nil, fmt.Errorf("reading usage report: %v", err)
+	}
+	var ret []Warning
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		name := c.FuncName
+		if v, ok := cmd.(*cmdView); ok {
+			name = v.list().FuncName
+		}
+		if counts[name] == 0 {
+			ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: no calls in the usage report", name)})
+		}
+	}
+	return ret, nil
+}
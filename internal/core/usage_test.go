@@ -0,0 +1,135 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const usageQueries = `-- !norm
+-- !package store
+-- !usage_counts
+
+-- !read GetUsers
+-- !output ID int
+SELECT id FROM users
+
+-- !read_one GetUser
+-- !input id int
+-- !output ID int
+SELECT id FROM users WHERE id = $1
+
+-- !exec_many AddUsers
+-- !input email string
+INSERT INTO users (email) VALUES ($1)
+
+-- !copy CopyUsers
+-- !input email string
+users
+
+-- !exec DeleteUser
+-- !input id int
+DELETE FROM users WHERE id = $1
+`
+
+// usageMain calls the queries of usageQueries through a driver that fails to
+// connect, as the calls are counted before they run, and prints QueryCounts.
+const usageMain = `package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"example.com/app/store"
+)
+
+type noDatabase struct{}
+
+func (noDatabase) Open(string) (driver.Conn, error) {
+	return nil, errors.New("no database")
+}
+
+func main() {
+	sql.Register("none", noDatabase{})
+	n, err := store.NewNorm("none", "")
+	if err != nil {
+		panic(err)
+	}
+	ctx := context.Background()
+	n.GetUsers(ctx)
+	n.GetUsers(ctx)
+	n.AddUsers(ctx, []store.AddUsersRow{{Email: "a@a.com"}})
+	n.AddUsers(ctx, nil)
+	n.CopyUsers(ctx, []store.CopyUsersRow{{Email: "b@b.com"}})
+	json.NewEncoder(os.Stdout).Encode(store.QueryCounts())
+}
+`
+
+func TestUnusedQueries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a Go program")
+	}
+	LoadTemplates()
+	nf := ParseData([]byte(usageQueries), []Source{{"<input>", 0}}, ParseOptions{})
+	dir, err := ioutil.TempDir("", "norm_usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{
+		"go.mod":         []byte("module example.com/app\n\ngo 1.15\n"),
+		"main.go":        []byte(usageMain),
+		"store/store.go": GenerateSource(nf),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := osexec.Command("go", "run", ".")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	report, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Running the calls: %v\n%s", err, stderr.String())
+	}
+	if want := `{"AddUsers":2,"CopyUsers":1,"DeleteUser":0,"GetUser":0,"GetUsers":2}` + "\n"; string(report) != want {
+		t.Errorf("Expected the counts %s, got %s", want, report)
+	}
+
+	unused, err := UnusedQueries(nf, bytes.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Warning{{9, "GetUser: no calls in the usage report"}, {22, "DeleteUser: no calls in the usage report"}}
+	if !reflect.DeepEqual(unused, want) {
+		t.Errorf("Expected %v, got %v", want, unused)
+	}
+
+	// Queries missing from the report, as when it predates them, have no
+	// calls.
+	unused, err = UnusedQueries(nf, strings.NewReader(`{"GetUsers": 3, "AddUsers": 1, "CopyUsers": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unused, want) {
+		t.Errorf("Expected %v for a partial report, got %v", want, unused)
+	}
+	if _, err := UnusedQueries(nf, strings.NewReader(`[1]`)); err == nil || !strings.HasPrefix(err.Error(), "reading usage report: ") {
+		t.Errorf("Expected an error reading a report that is not an object, got %v", err)
+	}
+}
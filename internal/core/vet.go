@@ -0,0 +1,166 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// whereEnd are the keywords ending a WHERE clause.
+var whereEnd = map[string]bool{
+	"GROUP": true, "ORDER": true, "LIMIT": true, "OFFSET": true, "HAVING": true,
+	"RETURNING": true, "UNION": true, "EXCEPT": true, "INTERSECT": true,
+	"WINDOW": true, "FOR": true, "FETCH": true,
+}
+
+// Vet returns the warnings of Analyze and lint along with the suggestions of
+// suggestIndexes, for the vet subcommand, in the order of their lines.
+func Vet(nf *NormFile) []Warning {
+	ret := append(Analyze(nf), lint(nf)...)
+	ret = append(ret, suggestIndexes(nf)...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Line < ret[j].Line
+	})
+	return ret
+}
+
+// suggestIndexes suggests an index for every query on a single table created
+// in nf that filters or sorts on columns no index of the table starts with.
+// The primary key, unique constraints and CREATE INDEX statements of the file
+// are taken to be the indexes. The suggested index has the columns compared
+// for equality, then the first column compared with a range or, without one,
+// the columns of the ORDER BY clause.
+func suggestIndexes(nf *NormFile) []Warning {
+	schema := ParseSchema(nf)
+	indexes := parseIndexes(nf)
+	var ret []Warning
+	for _, cmd := range nf.Cmds {
+		switch cmd.(type) {
+		case *cmdCopy, *cmdView, *cmdScript:
+			continue
+		}
+		c := cmd.Base()
+		reads, writes := tableRefs(c.BodyString())
+		tables := map[string]bool{}
+		for _, name := range append(reads, writes...) {
+			tables[strings.ToLower(lastPart(name))] = true
+		}
+		if len(tables) != 1 {
+			continue
+		}
+		var t *table
+		for _, s := range schema {
+			if tables[strings.ToLower(lastPart(s.Name))] {
+				t = s
+			}
+		}
+		if t == nil {
+			continue
+		}
+		eq, ranged, order := filterColumns(t, tokenize(c.BodyString()))
+		cols := eq
+		if ranged != "" {
+			cols = append(cols, ranged)
+		} else {
+			cols = append(cols, order...)
+		}
+		if len(cols) == 0 || hasIndex(t, indexes, eq, cols[0]) {
+			continue
+		}
+		ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: no index of %s starts with a column used by the query, consider CREATE INDEX ON %s (%s)", c.FuncName, t.Name, t.Name, strings.Join(cols, ", "))})
+	}
+	return ret
+}
+
+// filterColumns returns the columns of t compared for equality in the WHERE
+// clause of toks, the first column compared with a range, and the columns of
+// the ORDER BY clause. Only the outermost statement is read.
+func filterColumns(t *table, toks []sqlToken) (eq []string, ranged string, order []string) {
+	seen := map[string]bool{}
+	depth := 0
+	clause := ""
+	for ix := 0; ix < len(toks); ix++ {
+		tok := toks[ix]
+		switch {
+		case tok.Text == "(":
+			depth++
+			continue
+		case tok.Text == ")":
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case tok.is("WHERE"):
+			clause = "where"
+			continue
+		case tok.is("ORDER") && ix+1 < len(toks) && toks[ix+1].is("BY"):
+			clause = "order"
+			ix++
+			continue
+		case tok.Kind == tokIdent && whereEnd[strings.ToUpper(tok.Text)]:
+			clause = ""
+			continue
+		}
+		if clause == "" || (tok.Kind != tokIdent && tok.Kind != tokQuotedIdent) {
+			continue
+		}
+		// Column references may be qualified by the table or its alias.
+		if ix+1 < len(toks) && toks[ix+1].Text == "." {
+			continue
+		}
+		_, col := findColumn([]*table{t}, "", tok.name())
+		if col == nil || seen[col.Name] {
+			continue
+		}
+		name := col.Name
+		switch clause {
+		case "where":
+			if ix+1 >= len(toks) {
+				continue
+			}
+			next := toks[ix+1]
+			switch {
+			case next.Text == "=" || next.is("IN"):
+				eq = append(eq, name)
+				seen[name] = true
+			case ranged == "" && (comparisonOps[next.Text] && next.Text != "<>" && next.Text != "!=" || next.is("BETWEEN")):
+				ranged = name
+				seen[name] = true
+			}
+		case "order":
+			if ix == 0 || toks[ix-1].Text == "," || toks[ix-1].is("BY") || toks[ix-1].Text == "." {
+				order = append(order, name)
+				seen[name] = true
+			}
+		}
+	}
+	return eq, ranged, order
+}
+
+// hasIndex reports whether an index of t can serve a query comparing the
+// columns eq for equality, or else filtering or sorting on first: whether it
+// starts with one of them.
+func hasIndex(t *table, indexes []index, eq []string, first string) bool {
+	var leading []string
+	for _, cols := range t.Unique {
+		if len(cols) > 0 {
+			leading = append(leading, cols[0])
+		}
+	}
+	for _, ix := range indexes {
+		if strings.EqualFold(lastPart(ix.Table), lastPart(t.Name)) && len(ix.Columns) > 0 {
+			leading = append(leading, ix.Columns[0])
+		}
+	}
+	for _, col := range leading {
+		if len(eq) == 0 && strings.EqualFold(col, first) {
+			return true
+		}
+		for _, e := range eq {
+			if strings.EqualFold(col, e) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestIndexes(t *testing.T) {
+	input := `-- !norm
+-- !package store
+-- !file store.go
+
+-- !exec CreateSchema
+CREATE TABLE users (
+    id BIGINT PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    domain TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    name TEXT
+);
+CREATE INDEX users_name ON users (name, domain)
+
+-- !read_one FindUser
+-- !input id int64
+-- !output Email string
+SELECT email FROM users WHERE id = $1
+
+-- !read ListDomain
+-- !input domain string
+-- !output ID int64
+SELECT id FROM users WHERE domain = $1 ORDER BY created
+
+-- !read ListRecent
+-- !input domain string
+-- !input since string
+-- !output ID int64
+SELECT u.id FROM users u WHERE u.domain = $1 AND u.created > $2 AND name <> 'x' ORDER BY u.email
+
+-- !read ListSorted
+-- !output ID int64
+SELECT id FROM users ORDER BY created DESC, id
+
+-- !read ListNamed
+-- !input name string
+-- !output ID int64
+SELECT id FROM users WHERE name = $1 AND domain IN (SELECT domain FROM admins WHERE created > now())
+
+-- !read ListOrders
+-- !input domain string
+-- !output ID int64
+SELECT o.id FROM orders o JOIN users u ON u.id = o.user_id WHERE u.domain = $1
+
+-- !read ListAll
+-- !output ID int64
+SELECT id FROM users
+`
+	nf := ParseData([]byte(input), []Source{{"<input>", 0}}, ParseOptions{})
+	// FindUser uses the primary key and ListNamed the index users_name,
+	// ListOrders reads two tables and ListAll neither filters nor sorts.
+	want := []Warning{
+		{20, "ListDomain: no index of users starts with a column used by the query, consider CREATE INDEX ON users (domain, created)"},
+		{25, "ListRecent: no index of users starts with a column used by the query, consider CREATE INDEX ON users (domain, created)"},
+		{31, "ListSorted: no index of users starts with a column used by the query, consider CREATE INDEX ON users (created, id)"},
+	}
+	if got := suggestIndexes(nf); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the suggestions:\n%v\ngot:\n%v", want, got)
+	}
+}
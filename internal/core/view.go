@@ -0,0 +1,133 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+const view = `
+// {{.FuncName}} is a row of the {{.ViewName}} view.
+{{range .Doc}}// {{print .}}
+{{end -}}
+type {{.FuncName}} struct {
+{{getTaggedStructSig .Outputs .Tags .TagCase}}
+}
+
+// Create{{.FuncName}}View creates the {{.ViewName}} view.
+func (n *Norm) Create{{.FuncName}}View(ctx context.Context) error {
+	_, err := n.db.ExecContext(ctx, ` + "`{{.CreateString}}`" + `)
+	return err
+}
+{{if .Materialized}}
+// Refresh{{.FuncName}}View recomputes the rows of the {{.ViewName}} view.
+func (n *Norm) Refresh{{.FuncName}}View(ctx context.Context) error {
+	_, err := n.db.ExecContext(ctx, ` + "`REFRESH MATERIALIZED VIEW {{.ViewName}}`" + `)
+	return err
+}
+{{end}}
+`
+
+var viewTmpl *template.Template
+
+// cmdView declares a database view. The view gets a generated model struct,
+// and is read with a generated List function. Other commands can read into
+// the model with !model.
+type cmdView struct {
+	cmdBase
+	ViewName     string
+	Materialized bool
+	LargeResult  bool
+}
+
+func (c *cmdView) CreateString() string {
+	kind := "VIEW"
+	if c.Materialized {
+		kind = "MATERIALIZED VIEW"
+	}
+	return fmt.Sprintf("CREATE %s %s AS\n%s", kind, c.ViewName, c.BodyString())
+}
+
+// list is the read command for all rows of the view.
+func (c *cmdView) list() *cmdRead {
+	return &cmdRead{
+		cmdBase: cmdBase{
+			IRCommand: IRCommand{
+				Line:         c.Line,
+				FuncName:     "List" + c.FuncName,
+				Outputs:      c.Outputs,
+				CountUsage:   c.CountUsage,
+				Doc:          []string{fmt.Sprintf("List%s reads all rows of the %s view.", c.FuncName, c.ViewName)},
+				Model:        c.Model,
+				CheckColumns: c.CheckColumns,
+			},
+			Body: []string{"SELECT * FROM " + c.ViewName},
+		},
+		LargeResult: c.LargeResult,
+	}
+}
+
+func (c *cmdView) gen(w io.Writer) error {
+	if err := viewTmpl.Execute(w, c); err != nil {
+		return err
+	}
+	return c.list().gen(w)
+}
+
+func (c *cmdView) Kind() string {
+	return "view"
+}
+
+func (c *cmdView) Funcs() []string {
+	ret := []string{c.FuncName, "Create" + c.FuncName + "View"}
+	if c.Materialized {
+		ret = append(ret, "Refresh"+c.FuncName+"View")
+	}
+	return append(ret, c.list().Funcs()...)
+}
+
+// checkViewModels warns about commands reading into the model of a view with
+// outputs the view does not have.
+func checkViewModels(nf *NormFile) []Warning {
+	views := map[string]*cmdView{}
+	for _, cmd := range nf.Cmds {
+		if v, ok := cmd.(*cmdView); ok {
+			views[v.FuncName] = v
+		}
+	}
+	var ret []Warning
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if _, ok := cmd.(*cmdView); ok || c.Model == "" {
+			continue
+		}
+		v, ok := views[c.Model]
+		if !ok {
+			continue
+		}
+		for _, out := range c.Outputs {
+			found := false
+			for _, col := range v.Outputs {
+				if col.Name == out.Name {
+					found = true
+					if col.Typ != out.Typ {
+						ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: output %s is %s but view %s has %s", c.FuncName, out.Name, out.Typ, v.ViewName, col.Typ)})
+					}
+				}
+			}
+			if !found {
+				ret = append(ret, Warning{c.Line, fmt.Sprintf("%s: output %s is not a column of view %s (%s)", c.FuncName, out.Name, v.ViewName, strings.Join(argNames(v.Outputs), ", "))})
+			}
+		}
+	}
+	return ret
+}
+
+func argNames(args []arg) []string {
+	var ret []string
+	for _, a := range args {
+		ret = append(ret, a.Name)
+	}
+	return ret
+}
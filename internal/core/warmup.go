@@ -0,0 +1,71 @@
+package core
+
+import (
+	"fmt"
+	"text/template"
+)
+
+const warmUp = `
+// WarmUpConns is the number of connections WarmUp opens at once when the Norm
+// uses a *sql.DB, leaving them in the pool for the first calls. Connections
+// over the idle limit of the pool, see sql.DB.SetMaxIdleConns, are closed
+// again.
+var WarmUpConns = 2
+
+// WarmUp readies the Norm before the program reports it is ready: it opens
+// WarmUpConns connections, prepares the queries with PrepareAll, and runs the
+// queries declared with !warmup, discarding their results, to fill the caches
+// of the database. The !warmup queries are counted as calls.
+func (n *Norm) WarmUp(ctx context.Context) error {
+	if sqlDB, ok := n.db.(*sql.DB); ok {
+		conns := make([]*sql.Conn, 0, WarmUpConns)
+		for len(conns) < WarmUpConns {
+			conn, err := sqlDB.Conn(ctx)
+			if err != nil {
+				for _, c := range conns {
+					c.Close()
+				}
+				return err
+			}
+			conns = append(conns, conn)
+		}
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+	if err := n.PrepareAll(ctx); err != nil {
+		return err
+	}
+{{- range .}}
+	if _, err := n.{{.}}(ctx); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("{{.}}: %v", err)
+	}
+{{- end}}
+	return nil
+}
+`
+
+var warmUpTmpl *template.Template
+
+// warmUpQueries checks the !warmup directives of the commands of nf and
+// returns the names of the queries run by WarmUp. They must be !read or
+// !read_one commands without parameters.
+func warmUpQueries(nf *NormFile) []string {
+	var ret []string
+	for _, cmd := range nf.Cmds {
+		c := cmd.Base()
+		if !c.WarmUp {
+			continue
+		}
+		switch cmd.(type) {
+		case *cmdRead, *cmdReadOne:
+		default:
+			panic(fmt.Sprintf("!warmup of %s on line %d: %s commands cannot be run by WarmUp", c.FuncName, c.Line, cmd.Kind()))
+		}
+		if len(c.Params()) > 0 {
+			panic(fmt.Sprintf("!warmup of %s on line %d: queries run by WarmUp cannot take parameters", c.FuncName, c.Line))
+		}
+		ret = append(ret, c.FuncName)
+	}
+	return ret
+}
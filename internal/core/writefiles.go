@@ -0,0 +1,101 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// WriteFiles writes the generated files, by name. Every file is first written
+// to a temporary file in the same directory, and the temporary files are only
+// renamed over the files once all of them are written. Files keep their mode,
+// and new files are created with mode 0644. If renaming one of the files
+// fails, the files already replaced are restored and the new ones removed, so
+// a run failing to generate or write the code leaves the previous files as
+// they were, rather than truncated or half updated.
+func WriteFiles(files map[string][]byte) error {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	temps := map[string]string{}
+	defer func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}()
+	// previous holds the content of the files being replaced, for restoring
+	// them.
+	previous := map[string][]byte{}
+	for _, name := range names {
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(name); err == nil {
+			mode = info.Mode().Perm()
+			if previous[name], err = ioutil.ReadFile(name); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		tmp, err := writeTemp(name, files[name], mode)
+		if tmp != "" {
+			temps[name] = tmp
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var replaced []string
+	for _, name := range names {
+		if err := os.Rename(temps[name], name); err != nil {
+			restoreFiles(replaced, previous)
+			return err
+		}
+		delete(temps, name)
+		replaced = append(replaced, name)
+	}
+	return nil
+}
+
+// writeTemp writes data to a new temporary file next to name with mode, and
+// returns the name of the temporary file.
+func writeTemp(name string, data []byte, mode os.FileMode) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	return f.Name(), err
+}
+
+// restoreFiles puts back the previous content of the files in names, or
+// removes those which did not exist before, after a failed WriteFiles. Errors
+// are ignored, as there is nothing left to do about them.
+func restoreFiles(names []string, previous map[string][]byte) {
+	for _, name := range names {
+		data, ok := previous[name]
+		if !ok {
+			os.Remove(name)
+			continue
+		}
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(name); err == nil {
+			mode = info.Mode().Perm()
+		}
+		tmp, err := writeTemp(name, data, mode)
+		if err == nil {
+			err = os.Rename(tmp, name)
+		}
+		if err != nil && tmp != "" {
+			os.Remove(tmp)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFilesKeepsMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "db.go")
+	if err := ioutil.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "db_mock.go")
+	if err := WriteFiles(map[string][]byte{existing: []byte("new"), created: []byte("mock")}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]os.FileMode{existing: 0600, created: 0644} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, want %v", name, info.Mode().Perm(), want)
+		}
+	}
+}
+
+func TestWriteFilesRestoresOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(first, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "b.go")
+	// A directory that is not empty cannot be replaced by a file, so the
+	// last rename fails.
+	last := filepath.Join(dir, "c.go")
+	if err := os.MkdirAll(filepath.Join(last, "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = WriteFiles(map[string][]byte{first: []byte("new"), created: []byte("new"), last: []byte("new")})
+	if err == nil {
+		t.Fatal("Expected an error replacing a directory")
+	}
+	if data, err := ioutil.ReadFile(first); err != nil || string(data) != "old" {
+		t.Errorf("Expected %s to be restored, got %q, %v", first, data, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", created, err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected only a.go and c.go to be left, got %d files", len(entries))
+	}
+}
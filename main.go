@@ -0,0 +1,77 @@
+/**
+`norm` can be used with `go generate` to create a simple API for programs. It
+does not force a object structure, which can be decided outside of this layer.
+This allows consumers to not have leaky DB related fluff in their models.
+
+norm is run as norm <command> [flags] <input files>, see norm help for the
+commands. `norm generate` writes the code of the input files, read as one, and
+is also run by norm without a command, as in norm [flags] <input files>. With
+-strict, warnings about the queries fail the generation instead, including the
+type errors of the package of the !model structs, whose check is turned off by
+-skip-model-check. With -rewrite "<command>", every query body is piped through
+the command before it is used, see rewriteQueries. With -env <name>, directives
+written as -- !env <name> <directive> apply as if they were plain directives.
+With -define <name>[,<name>...], the sections between -- !ifdef <name> and
+-- !endif are kept; they are left out otherwise. Files with connection setup,
+such as -- !on_connect <statement>, or -- !attach <schema> <path> and
+-- !pragma <name>=<value>... for sqlite, get a generated Open function running
+it on every new connection. With -fuzz, Go fuzz targets for the inputs of the
+queries are written next to the output file, and with a -- !mocks line in the
+file, a mock of the generated Querier interface is written to norm_mock.go.
+With -o <file>, the code is written to file instead of the !file of the input,
+and with -stdout, it is written to the standard output as one file, and no
+other file is written. With -stamp hash, the date in the header of the
+generated files is replaced by a hash of their content, and with -stamp none,
+it is left out, see !stamp. With -check, or running `norm check`, the files are
+compared with the ones on disk instead of being written, and norm exits with
+status 1 after printing a diff if they are out of date. The files are only
+replaced once they are all generated, and keep their mode. If replacing one of
+them fails, those already replaced are restored, so a failed run leaves the
+previous files as they were. With -plugin "<command>", which may be repeated,
+the command reads the queries as JSON, see IR, and returns more files to write,
+see runPlugin.
+
+Errors in the input are reported as file:line: message, those of all the
+lines at once. norm exits with status 1 when a check fails, 2 for a wrong use
+of the command line, 3 for an error in the input, 4 when the files cannot be
+written, and 5 when anything else fails, such as a plugin, a -rewrite command
+or the connection to a database.
+
+The commands reading norm files all take -env and -define.
+`norm fmt [-l] [-w] <input files>` prints the files in their canonical layout,
+with directives spaced and ordered consistently and doc lines wrapped, see
+formatNorm, rewrites them with -w, and lists those not formatted with -l.
+`norm doc <input file>` prints a Markdown catalog of the queries, and
+`norm erd [-format mermaid|dot] <input file>` prints a diagram of the tables
+created in the file. `norm deps <input file>` prints the tables each query
+reads and writes as JSON. `norm ir <input file>` prints the queries of the file
+as JSON, in the stable format of IR, for code generators outside of norm.
+`norm ast <input file>` prints the directives and commands of the file as
+written, with their positions, as JSON, for editor tooling and linters.
+`norm diff-api [-fail-on-breaking] <input file>` reports how the exported API
+of the output file would change if it were regenerated.
+`norm openapi <input file>` prints OpenAPI schemas of the structs read by the
+queries. `norm analyze [packages]` reports SQL run directly through
+database/sql in packages importing a package generated by norm.
+`norm vet <input file>` reports the warnings of the queries, the mistakes in
+their definitions, see lint, and suggested indexes.
+`norm prune -usage <report> <input file>` lists the queries with no calls in a
+usage report.
+`norm drift [-driver <name>] -dsn <dsn> <input file>` compares the tables and
+indexes created in the file with a live database.
+*/
+package main
+
+import (
+	"github.com/agrewal/norm/internal/cli"
+
+	// The drivers of sqlite, postgres and mysql are linked for norm drift,
+	// see -driver.
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	cli.Main()
+}
@@ -0,0 +1,43 @@
+package parser_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agrewal/norm/parser"
+)
+
+func ExampleParse() {
+	src := `-- !norm
+-- !file store.go
+
+-- !read GetUsers
+-- !input domain string
+-- !output ID int
+SELECT id FROM user WHERE domain = $1
+`
+	f, err := parser.Parse(strings.NewReader(src), parser.Package("store"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(f.OutFile(), f.Package())
+	// Output: store.go store
+}
+
+func ExampleParse_errors() {
+	src := `-- !norm
+-- !file store.go
+
+-- !read GetUsers
+-- !input domain
+SELECT id FROM user WHERE domain = $1
+`
+	_, err := parser.Parse(strings.NewReader(src), parser.Name("store.norm.sql"))
+	fmt.Println(err)
+	// Output:
+	// store.norm.sql:5:11: Format error: "-- !input domain"
+	// 	-- !input domain
+	// 	          ^
+	// 	expected -- !input <name> <type>
+}
@@ -0,0 +1,73 @@
+// Package parser parses norm files, for tools embedding norm instead of
+// running the norm command. The parsed files are turned into Go code by the
+// codegen package.
+//
+// Parsing only reads the input given to Parse: the package of the generated
+// code comes from the !package directive of the file, or from the Package
+// option, unless the ResolvePackage option is given to look for it next to the
+// output file as the norm command does.
+package parser
+
+import (
+	"io"
+
+	"github.com/agrewal/norm/internal/core"
+)
+
+// File is a parsed norm file. OutFile returns the output file named by its
+// !file directive, and Package the package of the generated code.
+type File = core.File
+
+// Option is a setting of Parse.
+type Option func(*core.ParseInput)
+
+// Name sets the name of the input in the errors, <input> by default.
+func Name(name string) Option {
+	return func(in *core.ParseInput) {
+		in.Name = name
+	}
+}
+
+// Env selects the -- !env <name> directives that apply, as the -env flag of
+// the norm command does.
+func Env(name string) Option {
+	return func(in *core.ParseInput) {
+		in.Env = name
+	}
+}
+
+// Define keeps the sections between -- !ifdef <name> and -- !endif for the
+// names given, as the -define flag of the norm command does.
+func Define(names ...string) Option {
+	return func(in *core.ParseInput) {
+		in.Defines = append(in.Defines, names...)
+	}
+}
+
+// Package sets the package of the generated code when the file has no
+// !package directive.
+func Package(name string) Option {
+	return func(in *core.ParseInput) {
+		in.Package = name
+	}
+}
+
+// ResolvePackage sets the package of the generated code, when the file has
+// no !package directive, from the Go files in the directory of the output
+// file, or else from the name of the directory. It is the only option reading
+// the file system.
+func ResolvePackage() Option {
+	return func(in *core.ParseInput) {
+		in.ResolvePackage = true
+	}
+}
+
+// Parse parses the norm file read from r. The errors of all the lines are
+// returned at once, each with its position.
+func Parse(r io.Reader, opts ...Option) (*File, error) {
+	var in core.ParseInput
+	for _, opt := range opts {
+		opt(&in)
+	}
+	return core.ParseReader(r, in)
+}
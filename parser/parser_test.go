@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const input = `-- !norm
+-- !file store.go
+-- !env prod file store_prod.go
+
+-- !read GetUsers
+-- !input domain string
+-- !output ID int
+SELECT id FROM user WHERE domain = $1
+`
+
+// withHeader returns input with the directives header after its first line.
+func withHeader(header string) string {
+	return strings.Replace(input, "-- !norm\n", "-- !norm\n"+header, 1)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []Option
+		outFile string
+		pkg     string
+	}{
+		{"plain", input, nil, "store.go", ""},
+		{"package directive", withHeader("-- !package db\n"), []Option{Package("other")}, "store.go", "db"},
+		{"package option", input, []Option{Package("other")}, "store.go", "other"},
+		{"env", input, []Option{Env("prod")}, "store_prod.go", ""},
+		{"define", withHeader("-- !ifdef prod\n-- !package prod\n-- !endif\n"), []Option{Define("prod")}, "store.go", "prod"},
+		{"undefined", withHeader("-- !ifdef prod\n-- !package prod\n-- !endif\n"), nil, "store.go", ""},
+	}
+	for _, test := range tests {
+		f, err := Parse(strings.NewReader(test.input), test.opts...)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if f.OutFile() != test.outFile {
+			t.Errorf("%s: expected output file %s, got %s", test.name, test.outFile, f.OutFile())
+		}
+		if f.Package() != test.pkg {
+			t.Errorf("%s: expected package %q, got %q", test.name, test.pkg, f.Package())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	src := strings.Replace(input, "-- !input domain string", "-- !input domain", 1) + "-- !nope\n"
+	_, err := Parse(strings.NewReader(src), Name("store.norm.sql"))
+	if err == nil {
+		t.Fatal("Expected errors")
+	}
+	for _, want := range []string{"store.norm.sql:6:", "store.norm.sql:9:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error at %s, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestParseResolvePackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "norm_parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "store.go"), []byte("package store\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := strings.Replace(input, "-- !file store.go", "-- !file "+filepath.Join(dir, "db.go"), 1)
+	f, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Package() != "" {
+		t.Errorf("Expected the directory not to be read without ResolvePackage, got package %s", f.Package())
+	}
+	f, err = Parse(strings.NewReader(src), ResolvePackage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Package() != "store" {
+		t.Errorf("Expected package store, got %q", f.Package())
+	}
+}